@@ -12,13 +12,12 @@ import (
 
 	"github.com/grafana/agent/converter"
 	convert_diag "github.com/grafana/agent/converter/diag"
-	"github.com/grafana/river/diag"
+	"github.com/grafana/agent/pkg/flow"
 )
 
 func convertCommand() *cobra.Command {
 	f := &flowConvert{
 		output:       "",
-		sourceFormat: "",
 		bypassErrors: false,
 	}
 
@@ -34,14 +33,50 @@ convert will read from stdin.
 The -o flag can be used to write the formatted file back to disk. When -o
 is not provided, convert will write the result to stdout.
 
-The -r flag can be used to generate a diagnostic report. When -r is not
-provided, no report is generated.
+The -r flag can be used to generate a diagnostic report. The report ends
+with a checklist of manual steps needed to complete the migration. When -r
+is not provided, no report is generated.
 
 The -f flag can be used to specify the format we are converting from.
 
+Several files can be converted and merged into a single River file by
+repeating the -f flag with format:file pairs, such as
+-f prometheus:prometheus.yaml -f promtail:promtail.yaml, instead of
+supplying the file argument. Progress, --check, and the other flags apply
+to the merged output, and -e is passed to the converter of every file.
+Components which would be defined by more than one file are reported as
+critical errors.
+
 The -b flag can be used to bypass errors. Errors are defined as 
 non-critical issues identified during the conversion where an
-output can still be generated.`,
+output can still be generated.
+
+The -e flag can be used to pass extra arguments to the converter
+which were used by the original format. Multiple arguments can be
+passed by separating them with a space. Arguments containing spaces
+can be quoted, such as -e '--label="us east"'.
+
+The --annotate-warnings flag can be used to add warnings which apply to
+specific blocks as comments above those blocks in the output.
+
+The --target-version flag can be used to report an error for every
+component in the output which isn't available in the given version of
+Grafana Agent, such as v0.35.
+
+The --allowed-components and --denied-components flags can be used to
+report an error for every component in the output which isn't allowed by
+the given lists of components. Entries are component names or a prefix
+followed by a wildcard, such as prometheus.*. The
+--omit-denied-components flag omits those components from the output
+instead.
+
+The --progress flag can be used to print the number of blocks converted
+so far to stderr as the conversion proceeds.
+
+The --check flag can be used to only check whether the file converts. The
+diagnostics and their summary are printed to stderr, but no River is
+written. The exit code reflects the diagnostics in the same way as without
+--check.`,
 		Args:         cobra.RangeArgs(0, 1),
 		SilenceUsage: true,
 
@@ -55,12 +90,12 @@ output can still be generated.`,
 				err = f.Run(args[0])
 			}
 
-			var diags diag.Diagnostics
+			var diags convert_diag.Diagnostics
 			if errors.As(err, &diags) {
 				for _, diag := range diags {
 					fmt.Fprintln(os.Stderr, diag)
 				}
-				return fmt.Errorf("encountered errors during formatting")
+				return fmt.Errorf("encountered errors during formatting: %s", diagnosticsSummary(diags))
 			}
 
 			return err
@@ -69,61 +104,161 @@ output can still be generated.`,
 
 	cmd.Flags().StringVarP(&f.output, "output", "o", f.output, "The filepath and filename where the output is written.")
 	cmd.Flags().StringVarP(&f.report, "report", "r", f.report, "The filepath and filename where the report is written.")
-	cmd.Flags().StringVarP(&f.sourceFormat, "source-format", "f", f.sourceFormat, fmt.Sprintf("The format of the source file. Supported formats: %s.", supportedFormatsList()))
+	cmd.Flags().StringArrayVarP(&f.sourceFormats, "source-format", "f", f.sourceFormats, fmt.Sprintf("The format of the source file, or a format:file pair which may be repeated to merge several files. Supported formats: %s.", supportedFormatsList()))
 	cmd.Flags().BoolVarP(&f.bypassErrors, "bypass-errors", "b", f.bypassErrors, "Enable bypassing errors when converting")
+	cmd.Flags().StringVarP(&f.extraArgs, "extra-args", "e", f.extraArgs, "Extra arguments from the original format used by the converter.")
+	cmd.Flags().BoolVar(&f.annotateWarnings, "annotate-warnings", f.annotateWarnings, "Add warnings as comments above the blocks they apply to")
+	cmd.Flags().StringVar(&f.targetVersion, "target-version", f.targetVersion, "Report components which aren't available in this version of the agent, such as v0.35")
+	cmd.Flags().StringSliceVar(&f.allowedComponents, "allowed-components", f.allowedComponents, "Comma-separated list of components which may be used in the output, such as prometheus.*")
+	cmd.Flags().StringSliceVar(&f.deniedComponents, "denied-components", f.deniedComponents, "Comma-separated list of components which may not be used in the output")
+	cmd.Flags().BoolVar(&f.omitDeniedComponents, "omit-denied-components", f.omitDeniedComponents, "Omit components which aren't allowed from the output instead of reporting an error")
+	cmd.Flags().BoolVar(&f.progress, "progress", f.progress, "Print the number of blocks converted so far to stderr")
+	cmd.Flags().BoolVar(&f.check, "check", f.check, "Only check whether the file converts, printing diagnostics without writing any output")
 	return cmd
 }
 
 type flowConvert struct {
-	output       string
-	report       string
-	sourceFormat string
-	bypassErrors bool
+	output        string
+	report        string
+	sourceFormats []string
+	bypassErrors  bool
+	extraArgs     string
+
+	annotateWarnings bool
+	targetVersion    string
+
+	allowedComponents    []string
+	deniedComponents     []string
+	omitDeniedComponents bool
+
+	progress bool
+	check    bool
 }
 
 func (fc *flowConvert) Run(configFile string) error {
-	if fc.sourceFormat == "" {
-		return fmt.Errorf("source-format is a required flag")
+	sources, err := fc.sources(configFile)
+	if err != nil {
+		return err
+	}
+	return convert(sources, fc)
+}
+
+// sources reads the files to convert. configFile is the file argument, or
+// "-" if it isn't supplied, which is only used when a single format is
+// given.
+func (fc *flowConvert) sources(configFile string) ([]converter.Source, error) {
+	var pairs []converter.Source
+	for _, format := range fc.sourceFormats {
+		kind, file, ok := strings.Cut(format, ":")
+		if !ok {
+			continue
+		}
+		if file == "" {
+			return nil, fmt.Errorf("source-format %q is missing a file", format)
+		}
+		pairs = append(pairs, converter.Source{Name: file, Kind: converter.Input(kind)})
 	}
 
+	switch {
+	case len(fc.sourceFormats) == 0 || fc.sourceFormats[0] == "":
+		return nil, fmt.Errorf("source-format is a required flag")
+	case len(pairs) == 0 && len(fc.sourceFormats) > 1:
+		return nil, fmt.Errorf("source-format must be given once, or as format:file pairs to merge several files")
+	case len(pairs) == 0:
+		config, err := readConvertFile(configFile)
+		if err != nil {
+			return nil, err
+		}
+		return []converter.Source{{Name: configFile, Kind: converter.Input(fc.sourceFormats[0]), Config: config}}, nil
+	case len(pairs) != len(fc.sourceFormats):
+		return nil, fmt.Errorf("source-format can't mix formats with format:file pairs")
+	case configFile != "-":
+		return nil, fmt.Errorf("the file argument can't be used with format:file pairs")
+	}
+
+	for i := range pairs {
+		config, err := readConvertFile(pairs[i].Name)
+		if err != nil {
+			return nil, err
+		}
+		pairs[i].Config = config
+	}
+	return pairs, nil
+}
+
+// readConvertFile reads the file to convert, or stdin if configFile is "-".
+func readConvertFile(configFile string) ([]byte, error) {
 	if configFile == "-" {
-		return convert(os.Stdin, fc)
+		return io.ReadAll(os.Stdin)
 	}
 
 	fi, err := os.Stat(configFile)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if fi.IsDir() {
-		return fmt.Errorf("cannot convert a directory")
+		return nil, fmt.Errorf("cannot convert a directory")
 	}
+	return os.ReadFile(configFile)
+}
 
-	f, err := os.Open(configFile)
+func convert(sources []converter.Source, fc *flowConvert) error {
+	extraArgs, err := parseExtraArgs(fc.extraArgs)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-	return convert(f, fc)
-}
 
-func convert(r io.Reader, fc *flowConvert) error {
-	inputBytes, err := io.ReadAll(r)
-	if err != nil {
-		return err
+	var progress func(converted int)
+	if fc.progress {
+		progress = progressPrinter(os.Stderr)
 	}
 
-	riverBytes, diags := converter.Convert(inputBytes, converter.Input(fc.sourceFormat), []string{})
+	var (
+		riverBytes []byte
+		diags      convert_diag.Diagnostics
+	)
+	if len(sources) == 1 {
+		riverBytes, diags = converter.ConvertWithProgress(sources[0].Config, sources[0].Kind, extraArgs, progress)
+	} else {
+		riverBytes, diags = converter.ConvertMerged(sources, extraArgs, progress)
+	}
+	if fc.targetVersion != "" && len(riverBytes) > 0 {
+		diags.AddAll(converter.ValidateTargetVersion(riverBytes, fc.targetVersion))
+	}
+	if (len(fc.allowedComponents) > 0 || len(fc.deniedComponents) > 0) && len(riverBytes) > 0 {
+		var policyDiags convert_diag.Diagnostics
+		riverBytes, policyDiags = converter.ValidateComponentPolicy(riverBytes, func(name string) bool {
+			return flow.ComponentAllowed(fc.allowedComponents, fc.deniedComponents, name)
+		}, fc.omitDeniedComponents)
+		diags.AddAll(policyDiags)
+	}
 	err = generateConvertReport(diags, fc)
 	if err != nil {
 		return err
 	}
 
-	hasError := hasErrorLevel(diags, convert_diag.SeverityLevelError)
-	hasCritical := hasErrorLevel(diags, convert_diag.SeverityLevelCritical)
-	if hasCritical || (!fc.bypassErrors && hasError) {
+	counts := diags.CountBySeverity()
+	if counts[convert_diag.SeverityLevelCritical] > 0 || (!fc.bypassErrors && counts[convert_diag.SeverityLevelError] > 0) {
 		return diags
 	}
 
+	if fc.check {
+		// Failed checks are reported by the caller like any other failed
+		// conversion.
+		for _, diag := range diags {
+			fmt.Fprintln(os.Stderr, diag)
+		}
+		fmt.Fprintln(os.Stderr, checkSummary(diags))
+		return nil
+	}
+
+	if fc.annotateWarnings {
+		riverBytes, err = converter.AnnotateWarnings(riverBytes, diags)
+		if err != nil {
+			return err
+		}
+	}
+
 	var buf bytes.Buffer
 	buf.WriteString(string(riverBytes))
 
@@ -156,15 +291,52 @@ func generateConvertReport(diags convert_diag.Diagnostics, fc *flowConvert) erro
 	return nil
 }
 
-// HasErrorLevel returns true if any diagnostic exists at the provided severity.
-func hasErrorLevel(ds convert_diag.Diagnostics, sev convert_diag.Severity) bool {
-	for _, diag := range ds {
-		if diag.Severity == sev {
-			return true
+// progressPrinter returns a progress callback for the converter which writes
+// the number of blocks converted so far to w whenever it changes.
+func progressPrinter(w io.Writer) func(converted int) {
+	last := 0
+	return func(converted int) {
+		if converted == last {
+			return
 		}
+		last = converted
+		fmt.Fprintf(w, "converted %d blocks\n", converted)
+	}
+}
+
+// checkSummary returns the message printed by --check when the file
+// converts.
+func checkSummary(diags convert_diag.Diagnostics) string {
+	if summary := diagnosticsSummary(diags); summary != "" {
+		return "conversion check passed: " + summary
 	}
+	return "conversion check passed"
+}
 
-	return false
+// diagnosticsSummary summarizes the number of diagnostics of each severity in
+// ds, from the most to the least severe, such as "3 errors, 12 warnings".
+func diagnosticsSummary(ds convert_diag.Diagnostics) string {
+	counts := ds.CountBySeverity()
+
+	var parts []string
+	for _, sev := range []struct {
+		severity         convert_diag.Severity
+		singular, plural string
+	}{
+		{convert_diag.SeverityLevelCritical, "critical error", "critical errors"},
+		{convert_diag.SeverityLevelError, "error", "errors"},
+		{convert_diag.SeverityLevelWarn, "warning", "warnings"},
+		{convert_diag.SeverityLevelInfo, "info", "info"},
+	} {
+		switch n := counts[sev.severity]; n {
+		case 0:
+		case 1:
+			parts = append(parts, "1 "+sev.singular)
+		default:
+			parts = append(parts, fmt.Sprintf("%d %s", n, sev.plural))
+		}
+	}
+	return strings.Join(parts, ", ")
 }
 
 func supportedFormatsList() string {
@@ -0,0 +1,161 @@
+package flowmode
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/grafana/agent/pkg/flow"
+	"github.com/grafana/river/diag"
+)
+
+func graphCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "graph",
+		Short: "Inspect the component graph of a config",
+		Long:  `The graph command contains a collection of utilities for inspecting the component graph of a Grafana Agent Flow configuration.`,
+	}
+
+	cmd.AddCommand(
+		graphExplainCommand(),
+		graphExplainEdgeCommand(),
+	)
+	return cmd
+}
+
+func graphExplainCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "explain [flags] from to path",
+		Short: "Explain why two components are connected",
+		Long: `The explain subcommand prints every dependency path between two
+blocks of the configuration at path, along with the expressions which create
+each edge of the path.
+
+The from and to arguments are block IDs, such as prometheus.scrape.default.
+If from doesn't depend on to, explain checks whether to depends on from
+instead.
+
+The configuration isn't evaluated, so components aren't built or validated.`,
+		Args:         cobra.ExactArgs(3),
+		SilenceUsage: true,
+
+		RunE: func(_ *cobra.Command, args []string) error {
+			err := graphExplain(os.Stdout, args[0], args[1], args[2])
+
+			var diags diag.Diagnostics
+			if errors.As(err, &diags) {
+				for _, diag := range diags {
+					fmt.Fprintln(os.Stderr, diag)
+				}
+				return fmt.Errorf("encountered errors while reading the config")
+			}
+			return err
+		},
+	}
+
+	return cmd
+}
+
+func graphExplain(w io.Writer, from, to, path string) error {
+	source, err := loadFlowSource(path, "flow", false, nil)
+	if err != nil {
+		return err
+	}
+
+	paths, err := flow.ExplainDependency(source, from, to)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		// Check the other direction before giving up, since users aren't
+		// expected to know which of the blocks is the dependant one.
+		paths, err = flow.ExplainDependency(source, to, from)
+		if err != nil {
+			return err
+		}
+		from, to = to, from
+	}
+	if len(paths) == 0 {
+		fmt.Fprintf(w, "%s and %s do not depend on each other.\n", from, to)
+		return nil
+	}
+
+	fmt.Fprintf(w, "%s depends on %s through %d path(s):\n", from, to, len(paths))
+	for i, path := range paths {
+		nodes := make([]string, 0, len(path)+1)
+		for _, edge := range path {
+			nodes = append(nodes, edge.From)
+		}
+		nodes = append(nodes, to)
+
+		fmt.Fprintf(w, "\nPath %d: %s\n", i+1, strings.Join(nodes, " -> "))
+		for _, edge := range path {
+			fmt.Fprintf(w, "  %s -> %s\n", edge.From, edge.To)
+			for _, ref := range edge.References {
+				fmt.Fprintf(w, "    %s\n", ref)
+			}
+		}
+	}
+	return nil
+}
+
+func graphExplainEdgeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "explain-edge [flags] from to path",
+		Short: "Explain why one component references another",
+		Long: `The explain-edge subcommand prints every expression in the block from
+of the configuration at path which references the block to, along with the
+attribute holding the expression and its line of the configuration.
+
+The from and to arguments are block IDs, such as prometheus.scrape.default.
+Unlike explain, only direct references from from to to are printed.
+
+The configuration isn't evaluated, so components aren't built or validated.`,
+		Args:         cobra.ExactArgs(3),
+		SilenceUsage: true,
+
+		RunE: func(_ *cobra.Command, args []string) error {
+			err := graphExplainEdge(os.Stdout, args[0], args[1], args[2])
+
+			var diags diag.Diagnostics
+			if errors.As(err, &diags) {
+				for _, diag := range diags {
+					fmt.Fprintln(os.Stderr, diag)
+				}
+				return fmt.Errorf("encountered errors while reading the config")
+			}
+			return err
+		},
+	}
+
+	return cmd
+}
+
+func graphExplainEdge(w io.Writer, from, to, path string) error {
+	source, err := loadFlowSource(path, "flow", false, nil)
+	if err != nil {
+		return err
+	}
+
+	refs, err := flow.ExplainEdge(source, from, to)
+	if err != nil {
+		return err
+	}
+	if len(refs) == 0 {
+		fmt.Fprintf(w, "%s does not reference %s.\n", from, to)
+		return nil
+	}
+
+	fmt.Fprintf(w, "%s references %s in %d expression(s):\n", from, to, len(refs))
+	for _, ref := range refs {
+		fmt.Fprintf(w, "\n%s: %s references %s\n", ref.Pos, ref.Attribute, ref.Expression)
+		if ref.Line != "" {
+			fmt.Fprintf(w, "    %s\n", ref.Line)
+		}
+	}
+	return nil
+}
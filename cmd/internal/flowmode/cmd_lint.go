@@ -0,0 +1,134 @@
+package flowmode
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/grafana/agent/pkg/flow"
+	"github.com/grafana/river/diag"
+)
+
+func lintCommand() *cobra.Command {
+	l := &flowLint{}
+
+	cmd := &cobra.Command{
+		Use:   "lint [flags] path",
+		Short: "Check a config against lint rules",
+		Long: `The lint subcommand checks the components of the configuration at path
+against lint rules, such as requiring every component to have a tag, and
+prints a diagnostic for every violation. path may be a file or a directory
+of .river files.
+
+Every registered rule which isn't opt-in is checked unless the --rule flag
+is given. The --rule flag may be repeated to check several rules, including
+opt-in rules such as require-tags. The --list flag prints the registered
+rules instead.
+
+The configuration isn't evaluated, so components aren't built, and
+attributes which reference other components aren't checked. The exit code
+is non-zero if any rule reports an error.`,
+		Args:         cobra.RangeArgs(0, 1),
+		SilenceUsage: true,
+
+		RunE: func(_ *cobra.Command, args []string) error {
+			if l.list {
+				printLintRules(os.Stdout)
+				return nil
+			}
+			if len(args) == 0 {
+				return fmt.Errorf("path is required")
+			}
+
+			err := l.Run(args[0])
+
+			var diags diag.Diagnostics
+			if errors.As(err, &diags) {
+				var errs int
+				for _, d := range diags {
+					fmt.Fprintln(os.Stderr, d)
+					if d.Severity == diag.SeverityLevelError {
+						errs++
+					}
+				}
+				return fmt.Errorf("encountered %d lint errors", errs)
+			}
+			return err
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&l.rules, "rule", l.rules, "Name of a rule to check; may be repeated. Every registered rule which isn't opt-in is checked by default")
+	cmd.Flags().BoolVar(&l.list, "list", l.list, "Print the registered rules instead of checking a config")
+	return cmd
+}
+
+type flowLint struct {
+	rules []string
+	list  bool
+}
+
+// Run checks the config at path against the selected rules. Violations are
+// returned as diag.Diagnostics.
+func (l *flowLint) Run(path string) error {
+	rules, err := l.selectedRules()
+	if err != nil {
+		return err
+	}
+
+	source, err := loadFlowSource(path, "flow", false, nil)
+	if err != nil {
+		return err
+	}
+
+	if diags := flow.LintSource(source, rules); diags.HasErrors() {
+		return diags
+	} else if len(diags) > 0 {
+		// Warnings don't fail the check, but are still reported.
+		for _, diag := range diags {
+			fmt.Fprintln(os.Stderr, diag)
+		}
+	}
+	return nil
+}
+
+// selectedRules returns the rules named by the --rule flag, or the default
+// rules if the flag isn't given.
+func (l *flowLint) selectedRules() ([]flow.LintRule, error) {
+	if len(l.rules) == 0 {
+		return flow.DefaultLintRules(), nil
+	}
+
+	rules := make([]flow.LintRule, 0, len(l.rules))
+	for _, name := range l.rules {
+		r, ok := flow.GetLintRule(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown lint rule %q, run lint --list to print the registered rules", name)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// printLintRules writes the name and description of every registered rule
+// to w. Opt-in rules are marked as such.
+func printLintRules(w io.Writer) {
+	rules := flow.LintRules()
+
+	width := 0
+	for _, r := range rules {
+		if len(r.Name) > width {
+			width = len(r.Name)
+		}
+	}
+	for _, r := range rules {
+		description := r.Description
+		if r.OptIn {
+			description += " (opt-in)"
+		}
+		fmt.Fprintf(w, "%s%s  %s\n", r.Name, strings.Repeat(" ", width-len(r.Name)), description)
+	}
+}
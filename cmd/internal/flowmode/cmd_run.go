@@ -14,6 +14,8 @@ import (
 	"time"
 
 	"github.com/fatih/color"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/go-kit/log"
 	"github.com/grafana/agent/component"
 	"github.com/grafana/agent/converter"
@@ -70,6 +72,17 @@ immediately.
 If path is a directory, all *.river files in that directory will be combined
 into a single unit. Subdirectories are not recursively searched for further merging.
 
+If path starts with git::, the config file is read from a Git repository, using
+the form git::<repository>//<path>?ref=<ref>. For example:
+
+  git::https://github.com/org/repo.git//agent/config.river?ref=main
+
+The repository is checked for changes to ref on every reload, and is only
+cloned again when ref points to a new commit. If ref is not provided, the
+default branch of the repository is used. Credentials for the repository can
+be provided with the --config.git.username and --config.git.password-file
+flags.
+
 run starts an HTTP server which can be used to debug Grafana Agent Flow or
 force it to reload (by sending a GET or POST request to /-/reload). The listen
 address can be changed through the --server.http.listen-addr flag.
@@ -123,6 +136,12 @@ depending on the nature of the reload error.
 		BoolVar(&r.disableReporting, "disable-reporting", r.disableReporting, "Disable reporting of enabled components to Grafana.")
 	cmd.Flags().StringVar(&r.configFormat, "config.format", r.configFormat, fmt.Sprintf("The format of the source file. Supported formats: %s.", supportedFormatsList()))
 	cmd.Flags().BoolVar(&r.configBypassConversionErrors, "config.bypass-conversion-errors", r.configBypassConversionErrors, "Enable bypassing errors when converting")
+	cmd.Flags().StringVar(&r.configExtraArgs, "config.extra-args", r.configExtraArgs, "Extra arguments from the original format used by the converter. Multiple arguments can be passed by separating them with a space")
+	cmd.Flags().StringVar(&r.configGitUsername, "config.git.username", r.configGitUsername, "Username to use when reading the config from a Git repository")
+	cmd.Flags().StringVar(&r.configGitPasswordFile, "config.git.password-file", r.configGitPasswordFile, "File containing the password or token to use when reading the config from a Git repository")
+	cmd.Flags().StringVar(&r.configEnvFile, "config.env-file", r.configEnvFile, "A .env file of environment variables to set before loading the config. Variables which are already set aren't overridden")
+	cmd.Flags().DurationVar(&r.configMinReloadInterval, "config.min-reload-interval", r.configMinReloadInterval, "Minimum time between applied reloads. Reloads requested sooner are coalesced and the latest is applied once the interval elapses")
+	cmd.Flags().IntVar(&r.configParallelism, "config.parallelism", r.configParallelism, "Maximum number of components which don't depend on each other evaluated concurrently. When 0, components are loaded one at a time and updated by one worker per CPU")
 	return cmd
 }
 
@@ -144,6 +163,12 @@ type flowRun struct {
 	clusterName                  string
 	configFormat                 string
 	configBypassConversionErrors bool
+	configExtraArgs              string
+	configGitUsername            string
+	configGitPasswordFile        string
+	configEnvFile                string
+	configMinReloadInterval      time.Duration
+	configParallelism            int
 }
 
 func (fr *flowRun) Run(configPath string) error {
@@ -157,6 +182,19 @@ func (fr *flowRun) Run(configPath string) error {
 		return fmt.Errorf("path argument not provided")
 	}
 
+	configDir, err := configDirectory(configPath)
+	if err != nil {
+		return fmt.Errorf("resolving config directory: %w", err)
+	}
+
+	// Environment variables are loaded before anything else, so they're set
+	// by the time the config is first loaded.
+	if fr.configEnvFile != "" {
+		if err := loadEnvFile(fr.configEnvFile); err != nil {
+			return fmt.Errorf("loading environment file: %w", err)
+		}
+	}
+
 	l, err := logging.New(os.Stderr, logging.DefaultOptions)
 	if err != nil {
 		return fmt.Errorf("building logger: %w", err)
@@ -198,7 +236,7 @@ func (fr *flowRun) Run(configPath string) error {
 	// To work around this, we lazily create variables for the functions the HTTP
 	// service needs and set them after the Flow controller exists.
 	var (
-		reload func() (*flow.Source, error)
+		reload func(caller reloadCaller) (*flow.Source, bool, error)
 		ready  func() bool
 	)
 
@@ -227,8 +265,14 @@ func (fr *flowRun) Run(configPath string) error {
 		Tracer:   t,
 		Gatherer: prometheus.DefaultGatherer,
 
-		ReadyFunc:  func() bool { return ready() },
-		ReloadFunc: func() (*flow.Source, error) { return reload() },
+		ReadyFunc: func() bool { return ready() },
+		ReloadFunc: func(req httpservice.ReloadRequest) (*flow.Source, bool, error) {
+			return reload(reloadCaller{
+				Trigger:    flow.ReloadTriggerHTTP,
+				RemoteAddr: req.RemoteAddr,
+				Reason:     req.Reason,
+			})
+		},
 
 		HTTPListenAddr:   fr.httpListenAddr,
 		MemoryListenAddr: fr.inMemoryAddr,
@@ -247,11 +291,24 @@ func (fr *flowRun) Run(configPath string) error {
 
 	labelService := labelstore.New(l)
 
+	var gitAuth transport.AuthMethod
+	if flow.IsGitSource(configPath) {
+		if gitAuth, err = fr.gitAuth(); err != nil {
+			return err
+		}
+	}
+
 	f := flow.New(flow.Options{
 		Logger:   l,
 		Tracer:   t,
 		DataPath: fr.storagePath,
 		Reg:      reg,
+		GitAuth:  gitAuth,
+
+		MinReloadInterval: fr.configMinReloadInterval,
+		ConfigDir:         configDir,
+		Parallelism:       fr.configParallelism,
+
 		Services: []service.Service{
 			httpService,
 			uiService,
@@ -261,20 +318,32 @@ func (fr *flowRun) Run(configPath string) error {
 		},
 	})
 
+	converterExtraArgs, err := parseExtraArgs(fr.configExtraArgs)
+	if err != nil {
+		return err
+	}
+
 	ready = f.Ready
-	reload = func() (*flow.Source, error) {
-		flowSource, err := loadFlowSource(configPath, fr.configFormat, fr.configBypassConversionErrors)
+	reload = func(caller reloadCaller) (flowSource *flow.Source, changed bool, err error) {
+		defer func() { logReloadAudit(l, caller, f, changed, err) }()
+
+		if flow.IsGitSource(configPath) {
+			flowSource, err = loadGitFlowSource(ctx, f, configPath, fr.configFormat, fr.configBypassConversionErrors, converterExtraArgs)
+		} else {
+			flowSource, err = loadFlowSource(configPath, fr.configFormat, fr.configBypassConversionErrors, converterExtraArgs)
+		}
 		defer instrumentation.InstrumentSHA256(flowSource.SHA256())
 		defer instrumentation.InstrumentLoad(err == nil)
 
 		if err != nil {
-			return nil, fmt.Errorf("reading config path %q: %w", configPath, err)
+			return nil, false, fmt.Errorf("reading config path %q: %w", configPath, err)
 		}
-		if err := f.LoadSource(flowSource, nil); err != nil {
-			return flowSource, fmt.Errorf("error during the initial grafana/agent load: %w", err)
+		changed, err = f.ReloadWithTrigger(ctx, flowSource, caller.Trigger)
+		if err != nil {
+			return flowSource, changed, fmt.Errorf("error during the initial grafana/agent load: %w", err)
 		}
 
-		return flowSource, nil
+		return flowSource, changed, nil
 	}
 
 	// Flow controller
@@ -303,7 +372,7 @@ func (fr *flowRun) Run(configPath string) error {
 	// Perform the initial reload. This is done after starting the HTTP server so
 	// that /metric and pprof endpoints are available while the Flow controller
 	// is loading.
-	if source, err := reload(); err != nil {
+	if source, _, err := reload(startupCaller); err != nil {
 		var diags diag.Diagnostics
 		if errors.As(err, &diags) {
 			p := diag.NewPrinter(diag.PrinterConfig{
@@ -341,11 +410,8 @@ func (fr *flowRun) Run(configPath string) error {
 		case <-ctx.Done():
 			return nil
 		case <-reloadSignal:
-			if _, err := reload(); err != nil {
-				level.Error(l).Log("msg", "failed to reload config", "err", err)
-			} else {
-				level.Info(l).Log("msg", "config reloaded")
-			}
+			// The result is logged by reload.
+			_, _, _ = reload(signalCaller)
 		}
 	}
 }
@@ -362,7 +428,25 @@ func getEnabledComponentsFunc(f *flow.Flow) func() map[string]interface{} {
 	}
 }
 
-func loadFlowSource(path string, converterSourceFormat string, converterBypassErrors bool) (*flow.Source, error) {
+// configDirectory returns the absolute directory of the config at path, which
+// is exposed to the config as config_dir. It's path itself when path is a
+// directory of config files, and empty for configs read from Git.
+func configDirectory(path string) (string, error) {
+	if flow.IsGitSource(path) {
+		return "", nil
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if fi, err := os.Stat(abs); err == nil && fi.IsDir() {
+		return abs, nil
+	}
+	return filepath.Dir(abs), nil
+}
+
+func loadFlowSource(path string, converterSourceFormat string, converterBypassErrors bool, converterExtraArgs []string) (*flow.Source, error) {
 	fi, err := os.Stat(path)
 	if err != nil {
 		return nil, err
@@ -394,26 +478,64 @@ func loadFlowSource(path string, converterSourceFormat string, converterBypassEr
 			return nil, err
 		}
 
-		return flow.ParseSources(sources)
+		return flow.ParseSourcesWithIncludes(sources, os.ReadFile)
 	}
 
 	bb, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
+	return parseFlowSource(path, bb, os.ReadFile, converterSourceFormat, converterBypassErrors, converterExtraArgs)
+}
+
+// loadGitFlowSource reads the config file described by path from a Git
+// repository through f.
+func loadGitFlowSource(ctx context.Context, f *flow.Flow, path string, converterSourceFormat string, converterBypassErrors bool, converterExtraArgs []string) (*flow.Source, error) {
+	bb, err := f.ReadGitSource(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return parseFlowSource(path, bb, nil, converterSourceFormat, converterBypassErrors, converterExtraArgs)
+}
+
+// parseFlowSource parses a single config file, converting it to River first
+// if it is not already in the flow format. Files included by the config are
+// read with readFile, which may be nil if includes aren't supported.
+func parseFlowSource(path string, bb []byte, readFile func(name string) ([]byte, error), converterSourceFormat string, converterBypassErrors bool, converterExtraArgs []string) (*flow.Source, error) {
 	if converterSourceFormat != "flow" {
 		var diags convert_diag.Diagnostics
-		bb, diags = converter.Convert(bb, converter.Input(converterSourceFormat), []string{})
-		hasError := hasErrorLevel(diags, convert_diag.SeverityLevelError)
-		hasCritical := hasErrorLevel(diags, convert_diag.SeverityLevelCritical)
-		if hasCritical || (!converterBypassErrors && hasError) {
+		bb, diags = converter.Convert(bb, converter.Input(converterSourceFormat), converterExtraArgs)
+		counts := diags.CountBySeverity()
+		if counts[convert_diag.SeverityLevelCritical] > 0 || (!converterBypassErrors && counts[convert_diag.SeverityLevelError] > 0) {
 			return nil, diags
 		}
 	}
 
 	instrumentation.InstrumentConfig(bb)
 
-	return flow.ParseSource(path, bb)
+	return flow.ParseSourceWithIncludes(path, bb, readFile)
+}
+
+// gitAuth returns the authentication method to use when reading the config
+// from a Git repository. gitAuth returns nil if no credentials were provided.
+func (fr *flowRun) gitAuth() (transport.AuthMethod, error) {
+	if fr.configGitUsername == "" && fr.configGitPasswordFile == "" {
+		return nil, nil
+	}
+
+	var password string
+	if fr.configGitPasswordFile != "" {
+		bb, err := os.ReadFile(fr.configGitPasswordFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading git password file: %w", err)
+		}
+		password = strings.TrimSpace(string(bb))
+	}
+
+	return &githttp.BasicAuth{
+		Username: fr.configGitUsername,
+		Password: password,
+	}, nil
 }
 
 func interruptContext() (context.Context, context.CancelFunc) {
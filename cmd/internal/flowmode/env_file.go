@@ -0,0 +1,167 @@
+package flowmode
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// loadEnvFile sets the environment variables defined in the .env-style file
+// at path. Variables which are already set in the environment are left
+// unchanged, so the environment always takes precedence over the file.
+func loadEnvFile(path string) error {
+	bb, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	vars, err := parseEnvFile(bb)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	for _, v := range vars {
+		if _, set := os.LookupEnv(v.name); set {
+			continue
+		}
+		if err := os.Setenv(v.name, v.value); err != nil {
+			return fmt.Errorf("setting %s: %w", v.name, err)
+		}
+	}
+	return nil
+}
+
+// envVar is a variable defined in a .env file.
+type envVar struct {
+	name, value string
+}
+
+// parseEnvFile parses the variables of a .env file, in the order they're
+// defined. Each line of the file is one of:
+//
+//   - Empty, or a comment starting with #.
+//   - NAME=value, optionally prefixed with export.
+//
+// Unquoted values end at the first # preceded by whitespace, and surrounding
+// whitespace is removed. Values wrapped in single quotes are kept as-is.
+// Values wrapped in double quotes may use the escapes \n, \t, \", and \\.
+func parseEnvFile(bb []byte) ([]envVar, error) {
+	var (
+		vars    []envVar
+		scanner = bufio.NewScanner(bytes.NewReader(bb))
+		lineNum int
+	)
+	for scanner.Scan() {
+		lineNum++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+
+		name, rawValue, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || !validEnvName(name) {
+			return nil, fmt.Errorf("line %d: expected NAME=value", lineNum)
+		}
+
+		value, err := parseEnvValue(strings.TrimSpace(rawValue))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		vars = append(vars, envVar{name: name, value: value})
+	}
+	return vars, scanner.Err()
+}
+
+// validEnvName returns whether name is a valid environment variable name:
+// letters, digits, and underscores, not starting with a digit.
+func validEnvName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// parseEnvValue parses the value of a variable in a .env file, with
+// surrounding whitespace already removed.
+func parseEnvValue(raw string) (string, error) {
+	if raw == "" {
+		return "", nil
+	}
+
+	switch quote := raw[0]; quote {
+	case '\'', '"':
+		end := closingQuote(raw, quote)
+		if end < 0 {
+			return "", fmt.Errorf("unterminated quoted value")
+		}
+		if rest := strings.TrimSpace(raw[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+			return "", fmt.Errorf("unexpected text %q after quoted value", rest)
+		}
+		if quote == '\'' {
+			return raw[1:end], nil
+		}
+		return unescapeEnvValue(raw[1:end]), nil
+	}
+
+	// Unquoted values end at an inline comment.
+	for i := 1; i < len(raw); i++ {
+		if raw[i] == '#' && (raw[i-1] == ' ' || raw[i-1] == '\t') {
+			raw = raw[:i]
+			break
+		}
+	}
+	return strings.TrimSpace(raw), nil
+}
+
+// closingQuote returns the index of the quote which closes the quoted value
+// starting at raw[0], or -1 if there isn't one. Within double quotes, quotes
+// escaped with a backslash don't close the value.
+func closingQuote(raw string, quote byte) int {
+	for i := 1; i < len(raw); i++ {
+		switch {
+		case quote == '"' && raw[i] == '\\':
+			i++ // Skip over the escaped character.
+		case raw[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// unescapeEnvValue replaces the escapes of a double quoted value. Unknown
+// escapes are kept as-is.
+func unescapeEnvValue(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			sb.WriteByte(s[i])
+			continue
+		}
+
+		i++
+		switch s[i] {
+		case 'n':
+			sb.WriteByte('\n')
+		case 't':
+			sb.WriteByte('\t')
+		case '"', '\\':
+			sb.WriteByte(s[i])
+		default:
+			sb.WriteByte('\\')
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,121 @@
+package flowmode
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEnvFile(t *testing.T) {
+	tt := []struct {
+		name      string
+		input     string
+		expect    []envVar
+		expectErr string
+	}{
+		{
+			name:   "empty",
+			input:  "",
+			expect: nil,
+		},
+		{
+			name: "comments and blank lines",
+			input: `
+				# A comment.
+
+				NAME=value
+			`,
+			expect: []envVar{{"NAME", "value"}},
+		},
+		{
+			name:   "export prefix",
+			input:  `export NAME=value`,
+			expect: []envVar{{"NAME", "value"}},
+		},
+		{
+			name:   "empty value",
+			input:  `NAME=`,
+			expect: []envVar{{"NAME", ""}},
+		},
+		{
+			name:   "surrounding whitespace",
+			input:  `  NAME = some value  `,
+			expect: []envVar{{"NAME", "some value"}},
+		},
+		{
+			name:   "inline comment",
+			input:  `NAME=value # comment`,
+			expect: []envVar{{"NAME", "value"}},
+		},
+		{
+			name:   "hash without whitespace",
+			input:  `URL=http://localhost/#anchor`,
+			expect: []envVar{{"URL", "http://localhost/#anchor"}},
+		},
+		{
+			name:   "single quotes",
+			input:  `NAME='value # not a comment \n'`,
+			expect: []envVar{{"NAME", `value # not a comment \n`}},
+		},
+		{
+			name:   "double quotes",
+			input:  `NAME="line 1\nline \"2\"" # comment`,
+			expect: []envVar{{"NAME", "line 1\nline \"2\""}},
+		},
+		{
+			name: "order is kept",
+			input: `
+				B=2
+				A=1
+			`,
+			expect: []envVar{{"B", "2"}, {"A", "1"}},
+		},
+		{
+			name:      "missing equals",
+			input:     "NAME=value\nINVALID",
+			expectErr: "line 2: expected NAME=value",
+		},
+		{
+			name:      "invalid name",
+			input:     `1NAME=value`,
+			expectErr: "line 1: expected NAME=value",
+		},
+		{
+			name:      "unterminated quote",
+			input:     `NAME="value`,
+			expectErr: "line 1: unterminated quoted value",
+		},
+		{
+			name:      "text after quotes",
+			input:     `NAME="value" extra`,
+			expectErr: `line 1: unexpected text "extra" after quoted value`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := parseEnvFile([]byte(tc.input))
+			if tc.expectErr != "" {
+				require.EqualError(t, err, tc.expectErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expect, actual)
+		})
+	}
+}
+
+func TestLoadEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	require.NoError(t, os.WriteFile(path, []byte("ENV_FILE_TEST_SET=from file\nENV_FILE_TEST_UNSET=from file\n"), 0644))
+
+	t.Setenv("ENV_FILE_TEST_SET", "from environment")
+	t.Setenv("ENV_FILE_TEST_UNSET", "")
+	require.NoError(t, os.Unsetenv("ENV_FILE_TEST_UNSET"))
+
+	require.NoError(t, loadEnvFile(path))
+	require.Equal(t, "from environment", os.Getenv("ENV_FILE_TEST_SET"))
+	require.Equal(t, "from file", os.Getenv("ENV_FILE_TEST_UNSET"))
+}
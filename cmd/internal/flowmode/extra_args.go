@@ -0,0 +1,78 @@
+package flowmode
+
+import (
+	"fmt"
+	"strings"
+)
+
+// parseExtraArgs splits extraArgs into a list of arguments to pass to a
+// converter.
+//
+// Arguments are separated by whitespace, following shell quoting rules:
+//
+//   - Text inside of single quotes is kept as-is.
+//   - Text inside of double quotes is kept as-is, except for backslash escapes
+//     of a double quote or backslash.
+//   - Outside of quotes, a backslash escapes the following character.
+//
+// Quotes may appear anywhere within an argument, so --label="us east" is
+// parsed as the single argument --label=us east.
+func parseExtraArgs(extraArgs string) ([]string, error) {
+	var (
+		args []string
+
+		current strings.Builder
+		inArg   bool // Whether an argument is being built, even if empty.
+		quote   rune // The open quote character, or 0 when outside quotes.
+		escaped bool // Whether the previous character was an escaping backslash.
+	)
+
+	for _, r := range extraArgs {
+		switch {
+		case escaped:
+			if quote == '"' && r != '"' && r != '\\' {
+				// Within double quotes, only quotes and backslashes can be escaped.
+				current.WriteRune('\\')
+			}
+			current.WriteRune(r)
+			escaped = false
+
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if escaped {
+		return nil, fmt.Errorf("extra arguments end with an unfinished escape sequence")
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("extra arguments have an unterminated %c quote", quote)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,89 @@
+package flowmode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExtraArgs(t *testing.T) {
+	tt := []struct {
+		name      string
+		input     string
+		expect    []string
+		expectErr string
+	}{
+		{
+			name:   "empty",
+			input:  "",
+			expect: nil,
+		},
+		{
+			name:   "whitespace only",
+			input:  "  \t ",
+			expect: nil,
+		},
+		{
+			name:   "simple flags",
+			input:  "-enable-features=integrations-next -config.expand-env",
+			expect: []string{"-enable-features=integrations-next", "-config.expand-env"},
+		},
+		{
+			name:   "double quoted value",
+			input:  `--label="us east" --other=x`,
+			expect: []string{"--label=us east", "--other=x"},
+		},
+		{
+			name:   "single quoted value",
+			input:  `--label='us "east"'`,
+			expect: []string{`--label=us "east"`},
+		},
+		{
+			name:   "escaped quotes",
+			input:  `--label="say \"hi\"" --path=C:\\dir`,
+			expect: []string{`--label=say "hi"`, `--path=C:\dir`},
+		},
+		{
+			name:   "backslash kept inside double quotes",
+			input:  `--regex="a\d+"`,
+			expect: []string{`--regex=a\d+`},
+		},
+		{
+			name:   "escaped space",
+			input:  `--label=us\ east`,
+			expect: []string{"--label=us east"},
+		},
+		{
+			name:   "mixed forms",
+			input:  `--k=v -k v -name "quoted value" --flag`,
+			expect: []string{"--k=v", "-k", "v", "-name", "quoted value", "--flag"},
+		},
+		{
+			name:   "empty quoted argument",
+			input:  `-k "" -j`,
+			expect: []string{"-k", "", "-j"},
+		},
+		{
+			name:      "unterminated quote",
+			input:     `--label="us east`,
+			expectErr: `extra arguments have an unterminated " quote`,
+		},
+		{
+			name:      "trailing escape",
+			input:     `--label=x\`,
+			expectErr: "extra arguments end with an unfinished escape sequence",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := parseExtraArgs(tc.input)
+			if tc.expectErr != "" {
+				require.EqualError(t, err, tc.expectErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expect, actual)
+		})
+	}
+}
@@ -26,6 +26,8 @@ func Run() {
 	cmd.AddCommand(
 		convertCommand(),
 		fmtCommand(),
+		graphCommand(),
+		lintCommand(),
 		runCommand(),
 		toolsCommand(),
 	)
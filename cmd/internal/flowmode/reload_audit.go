@@ -0,0 +1,60 @@
+package flowmode
+
+import (
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/agent/pkg/flow"
+)
+
+// reloadCaller describes what triggered a reload of the config.
+type reloadCaller struct {
+	Trigger    flow.ReloadTrigger // What triggered the reload, such as an HTTP request or a signal.
+	RemoteAddr string             // Address of the client which requested the reload, if any.
+	Reason     string             // Reason given by the client for the reload, if any.
+}
+
+var (
+	startupCaller = reloadCaller{Trigger: flow.ReloadTriggerStartup}
+	signalCaller  = reloadCaller{Trigger: flow.ReloadTriggerSignal}
+)
+
+// logReloadAudit logs an audit line recording the caller of a reload, its
+// result, and the summary of the load from f.
+func logReloadAudit(l log.Logger, caller reloadCaller, f *flow.Flow, changed bool, err error) {
+	keyvals := []interface{}{
+		"msg", "config reload triggered by " + string(caller.Trigger),
+		"trigger", caller.Trigger,
+	}
+	if caller.RemoteAddr != "" {
+		keyvals = append(keyvals, "remote_addr", caller.RemoteAddr)
+	}
+	if caller.Reason != "" {
+		keyvals = append(keyvals, "reason", caller.Reason)
+	}
+
+	switch {
+	case err != nil:
+		keyvals = append(keyvals, "result", "failed", "err", err)
+	case !changed:
+		keyvals = append(keyvals, "result", "unchanged")
+	default:
+		keyvals = append(keyvals, "result", "reloaded")
+	}
+
+	// The summary describes the last successful load, which is the config
+	// still running when this reload failed.
+	if summary, ok := f.LastLoadSummary(); ok {
+		keyvals = append(keyvals,
+			"sha256", summary.SHA256,
+			"components", summary.Components,
+			"warnings", summary.Warnings,
+			"duration", summary.Duration,
+		)
+	}
+
+	logger := level.Info(l)
+	if err != nil {
+		logger = level.Error(l)
+	}
+	logger.Log(keyvals...)
+}
@@ -9,8 +9,9 @@ import (
 // HealthComponent is an optional extension interface for Components which
 // report health information.
 //
-// Health information is exposed to the end user for informational purposes and
-// cannot be referened in a River expression.
+// Health information is exposed to the end user for informational purposes,
+// and can be referenced in River expressions through the health attribute of
+// the component.
 type HealthComponent interface {
 	Component
 
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/grafana/river/encoding/riverjson"
+	"golang.org/x/exp/slices"
 )
 
 var (
@@ -59,10 +60,12 @@ func ParseID(input string) ID {
 // InfoOptions is used by to determine how much information to return with
 // [Info].
 type InfoOptions struct {
-	GetHealth    bool // When true, sets the Health field of returned components.
+	GetHealth    bool // When true, sets the Health and Ready fields of returned components.
 	GetArguments bool // When true, sets the Arguments field of returned components.
 	GetExports   bool // When true, sets the Exports field of returned components.
 	GetDebugInfo bool // When true, sets the DebugInfo field of returned components.
+	GetResources bool // When true, sets the Resources field of returned components.
+	GetErrors    bool // When true, sets the Errors field of returned components.
 }
 
 // String returns the "<ModuleID>/<LocalID>" string representation of the id.
@@ -86,8 +89,9 @@ type Info struct {
 	// The sort order of the list is not guaranteed.
 	ModuleIDs []string
 
-	ID    ID     // ID of the component.
-	Label string // Component label. Not set for singleton components.
+	ID    ID       // ID of the component.
+	Label string   // Component label. Not set for singleton components.
+	Tags  []string // Tags set by the tags meta-argument of the component.
 
 	// References and ReferencedBy are the list of IDs in the same module that
 	// this component depends on, or is depended on by, respectively.
@@ -95,36 +99,82 @@ type Info struct {
 
 	Registration Registration // Component registration.
 	Health       Health       // Current component health.
+	Ready        bool         // Whether the component is running and ready. Set along with Health.
 
 	Arguments Arguments   // Current arguments value of the component.
 	Exports   Exports     // Current exports value of the component.
 	DebugInfo interface{} // Current debug info of the component.
+
+	Resources *ResourceUsage // Approximate resource usage of the component.
+
+	// Errors holds the most recent errors from evaluating and running the
+	// component, oldest first.
+	Errors []TimestampedError
+}
+
+// HasTags returns whether the component has every tag in tags.
+func (info *Info) HasTags(tags []string) bool {
+	for _, tag := range tags {
+		if !slices.Contains(info.Tags, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// TimestampedError is an error reported by a component along with the time
+// it was reported.
+type TimestampedError struct {
+	Time  time.Time // Time the error was reported.
+	Error string    // Error message.
+}
+
+// ResourceUsage is an approximation of the resources used by a running
+// component.
+type ResourceUsage struct {
+	// Goroutines is the number of goroutines attributed to the component.
+	// Goroutines are attributed to a component when they are started by the
+	// component while it is running.
+	Goroutines int
 }
 
 // MarshalJSON returns a JSON representation of cd. The format of the
 // representation is not stable and is subject to change.
 func (info *Info) MarshalJSON() ([]byte, error) {
 	type (
+		componentResourcesJSON struct {
+			Goroutines int `json:"goroutines"`
+		}
+
 		componentHealthJSON struct {
 			State       string    `json:"state"`
 			Message     string    `json:"message"`
 			UpdatedTime time.Time `json:"updatedTime"`
 		}
 
+		componentErrorJSON struct {
+			Time  time.Time `json:"time"`
+			Error string    `json:"error"`
+		}
+
 		componentDetailJSON struct {
-			Name             string               `json:"name"`
-			Type             string               `json:"type,omitempty"`
-			LocalID          string               `json:"localID"`
-			ModuleID         string               `json:"moduleID"`
-			Label            string               `json:"label,omitempty"`
-			References       []string             `json:"referencesTo"`
-			ReferencedBy     []string             `json:"referencedBy"`
-			Health           *componentHealthJSON `json:"health"`
-			Original         string               `json:"original"`
-			Arguments        json.RawMessage      `json:"arguments,omitempty"`
-			Exports          json.RawMessage      `json:"exports,omitempty"`
-			DebugInfo        json.RawMessage      `json:"debugInfo,omitempty"`
-			CreatedModuleIDs []string             `json:"createdModuleIDs,omitempty"`
+			Name             string                  `json:"name"`
+			Type             string                  `json:"type,omitempty"`
+			LocalID          string                  `json:"localID"`
+			ModuleID         string                  `json:"moduleID"`
+			Label            string                  `json:"label,omitempty"`
+			Tags             []string                `json:"tags,omitempty"`
+			References       []string                `json:"referencesTo"`
+			ReferencedBy     []string                `json:"referencedBy"`
+			Health           *componentHealthJSON    `json:"health"`
+			Ready            bool                    `json:"ready"`
+			Original         string                  `json:"original"`
+			Arguments        json.RawMessage         `json:"arguments,omitempty"`
+			Exports          json.RawMessage         `json:"exports,omitempty"`
+			DebugInfo        json.RawMessage         `json:"debugInfo,omitempty"`
+			CreatedModuleIDs []string                `json:"createdModuleIDs,omitempty"`
+			Resources        *componentResourcesJSON `json:"resources,omitempty"`
+			Errors           []componentErrorJSON    `json:"errors,omitempty"`
 		}
 	)
 
@@ -133,6 +183,8 @@ func (info *Info) MarshalJSON() ([]byte, error) {
 		referencedBy = info.ReferencedBy
 
 		arguments, exports, debugInfo json.RawMessage
+		resources                     *componentResourcesJSON
+		recentErrors                  []componentErrorJSON
 		err                           error
 	)
 
@@ -155,6 +207,12 @@ func (info *Info) MarshalJSON() ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	if info.Resources != nil {
+		resources = &componentResourcesJSON{Goroutines: info.Resources.Goroutines}
+	}
+	for _, e := range info.Errors {
+		recentErrors = append(recentErrors, componentErrorJSON{Time: e.Time, Error: e.Error})
+	}
 
 	return json.Marshal(&componentDetailJSON{
 		Name:         info.Registration.Name,
@@ -162,6 +220,7 @@ func (info *Info) MarshalJSON() ([]byte, error) {
 		ModuleID:     info.ID.ModuleID,
 		LocalID:      info.ID.LocalID,
 		Label:        info.Label,
+		Tags:         info.Tags,
 		References:   references,
 		ReferencedBy: referencedBy,
 		Health: &componentHealthJSON{
@@ -169,10 +228,13 @@ func (info *Info) MarshalJSON() ([]byte, error) {
 			Message:     info.Health.Message,
 			UpdatedTime: info.Health.UpdateTime,
 		},
+		Ready:            info.Ready,
 		Arguments:        arguments,
 		Exports:          exports,
 		DebugInfo:        debugInfo,
 		CreatedModuleIDs: info.ModuleIDs,
+		Resources:        resources,
+		Errors:           recentErrors,
 	})
 }
 
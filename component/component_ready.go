@@ -0,0 +1,17 @@
+package component
+
+// ReadyComponent is an optional extension interface for Components which need
+// time after Run is called before they're fully operational, such as
+// components which must first establish a connection.
+//
+// Readiness is separate from health: a component may be healthy while it's
+// still becoming ready. Components which don't implement ReadyComponent are
+// considered ready as soon as they're running.
+type ReadyComponent interface {
+	Component
+
+	// Ready returns whether the component is fully operational. Ready is only
+	// called while the component is running, and must be safe for calling
+	// concurrently.
+	Ready() bool
+}
@@ -19,6 +19,7 @@ func init() {
 		Name:    "loki.write",
 		Args:    Arguments{},
 		Exports: Exports{},
+		Sink:    true,
 
 		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
 			return New(opts, args.(Arguments))
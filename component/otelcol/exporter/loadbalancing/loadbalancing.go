@@ -26,6 +26,7 @@ func init() {
 		Name:    "otelcol.exporter.loadbalancing",
 		Args:    Arguments{},
 		Exports: otelcol.ConsumerExports{},
+		Sink:    true,
 
 		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
 			fact := loadbalancingexporter.NewFactory()
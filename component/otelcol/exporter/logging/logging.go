@@ -16,6 +16,7 @@ func init() {
 		Name:    "otelcol.exporter.logging",
 		Args:    Arguments{},
 		Exports: otelcol.ConsumerExports{},
+		Sink:    true,
 
 		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
 			fact := loggingexporter.NewFactory()
@@ -17,6 +17,7 @@ func init() {
 		Name:    "otelcol.exporter.loki",
 		Args:    Arguments{},
 		Exports: otelcol.ConsumerExports{},
+		Sink:    true,
 
 		Build: func(o component.Options, a component.Arguments) (component.Component, error) {
 			return New(o, a.(Arguments))
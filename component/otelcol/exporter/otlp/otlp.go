@@ -18,6 +18,7 @@ func init() {
 		Name:    "otelcol.exporter.otlp",
 		Args:    Arguments{},
 		Exports: otelcol.ConsumerExports{},
+		Sink:    true,
 
 		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
 			fact := otlpexporter.NewFactory()
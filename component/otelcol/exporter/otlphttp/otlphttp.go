@@ -18,6 +18,7 @@ func init() {
 		Name:    "otelcol.exporter.otlphttp",
 		Args:    Arguments{},
 		Exports: otelcol.ConsumerExports{},
+		Sink:    true,
 
 		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
 			fact := otlphttpexporter.NewFactory()
@@ -41,6 +41,7 @@ func init() {
 		Name:    "prometheus.remote_write",
 		Args:    Arguments{},
 		Exports: Exports{},
+		Sink:    true,
 
 		Build: func(o component.Options, c component.Arguments) (component.Component, error) {
 			return New(o, c.(Arguments))
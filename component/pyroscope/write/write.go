@@ -37,6 +37,7 @@ func init() {
 		Name:    "pyroscope.write",
 		Args:    Arguments{},
 		Exports: Exports{},
+		Sink:    true,
 		Build: func(o component.Options, c component.Arguments) (component.Component, error) {
 			return New(o, c.(Arguments))
 		},
@@ -3,6 +3,7 @@ package component
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"reflect"
 	"strings"
 
@@ -104,6 +105,13 @@ type Options struct {
 	// The result of GetServiceData may be cached as the value will not change at
 	// runtime.
 	GetServiceData func(name string) (interface{}, error)
+
+	// HTTPClient is the HTTP client configured for the controller running the
+	// component, such as to use a proxy or a custom CA bundle. Components
+	// making HTTP requests should use it unless their arguments configure a
+	// client of their own. HTTPClient is nil if the controller doesn't
+	// configure a client.
+	HTTPClient *http.Client
 }
 
 // Registration describes a single component.
@@ -128,6 +136,42 @@ type Registration struct {
 	// A component which does not expose exports must leave this set to nil.
 	Exports Exports
 
+	// Sink marks the component as a pure sink: a component which consumes data
+	// but is not expected to be referenced by other components. Sinks are
+	// never reported as unreferenced components.
+	//
+	// Components which do not register an Exports value can never be
+	// referenced and are always treated as sinks.
+	Sink bool
+
+	// Deprecation marks the component as deprecated. When set, a warning is
+	// reported for every block of the component in a loaded config.
+	Deprecation *Deprecation
+
+	// DeprecatedArguments marks arguments of the component as deprecated,
+	// keyed by the name of the attribute or block. Arguments inside blocks are
+	// keyed by the dot-separated path to them, such as "client.bearer_token".
+	// A warning is reported for every deprecated argument set in a loaded
+	// config, suggesting the Replacement if there is one.
+	DeprecatedArguments map[string]Deprecation
+
+	// RestartOnUpdate marks the component as unable to apply new arguments
+	// while it's running. When the arguments of such a component change, the
+	// running component is stopped and a new one is built and run with the new
+	// arguments instead of calling Update.
+	//
+	// Exports, metrics, and the data path are shared between the stopped
+	// component and its replacement.
+	RestartOnUpdate bool
+
+	// Singleton marks the component as one which must only exist once per
+	// process, such as components which listen on a fixed port or modify
+	// global state. When Flow controllers share a singleton registry, the
+	// first controller to evaluate the component builds it, and the other
+	// controllers reuse that component instead of building their own. Every
+	// block of a singleton component must use the same label.
+	Singleton bool
+
 	// Build should construct a new component from an initial Arguments and set
 	// of options.
 	Build func(opts Options, args Arguments) (Component, error)
@@ -138,6 +182,37 @@ func (r Registration) CloneArguments() Arguments {
 	return reflect.New(reflect.TypeOf(r.Args)).Interface()
 }
 
+// Deprecation describes a deprecated component or argument.
+type Deprecation struct {
+	// Replacement optionally names the component or argument to use instead.
+	Replacement string
+
+	// Message optionally explains the deprecation, such as the release in which
+	// the deprecated component or argument will be removed.
+	Message string
+}
+
+// String returns a description of the deprecation to append to a warning.
+func (d Deprecation) String() string {
+	var sb strings.Builder
+	if d.Replacement != "" {
+		fmt.Fprintf(&sb, "use %s instead", d.Replacement)
+	}
+	if d.Message != "" {
+		if sb.Len() > 0 {
+			sb.WriteString("; ")
+		}
+		sb.WriteString(d.Message)
+	}
+	return sb.String()
+}
+
+// IsSink reports whether the registered component is a sink. See
+// [Registration.Sink] for more information.
+func (r Registration) IsSink() bool {
+	return r.Sink || r.Exports == nil
+}
+
 // Register registers a component. Register will panic if the name is in use by
 // another component, if the name is invalid, or if the component name has a
 // suffix length mismatch with an existing component.
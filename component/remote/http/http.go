@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"reflect"
 	"strings"
 	"sync"
 	"time"
@@ -43,6 +44,8 @@ type Arguments struct {
 	Headers map[string]string `river:"headers,attr,optional"`
 	Body    string            `river:"body,attr,optional"`
 
+	// Client configures the HTTP client. The client of the controller is used
+	// instead when Client isn't set and the controller configures one.
 	Client common_config.HTTPClientConfig `river:"client,block,optional"`
 }
 
@@ -204,6 +207,9 @@ func (c *Component) pollError() error {
 		level.Error(c.log).Log("msg", "failed to build request", "err", err)
 		return fmt.Errorf("building request: %w", err)
 	}
+	// The client of the controller doesn't set a user agent, so it's set
+	// here; a User-Agent header in Headers still overrides it.
+	req.Header.Set("User-Agent", userAgent)
 	for name, value := range c.args.Headers {
 		req.Header.Set(name, value)
 	}
@@ -273,15 +279,19 @@ func (c *Component) Update(args component.Arguments) (err error) {
 		customUserAgent = userAgent
 	}
 
-	cli, err := prom_config.NewClientFromConfig(
-		*newArgs.Client.Convert(),
-		c.opts.ID,
-		prom_config.WithUserAgent(customUserAgent),
-	)
-	if err != nil {
-		return err
+	if c.opts.HTTPClient != nil && reflect.DeepEqual(newArgs.Client, common_config.DefaultHTTPClientConfig) {
+		c.cli = c.opts.HTTPClient
+	} else {
+		cli, err := prom_config.NewClientFromConfig(
+			*newArgs.Client.Convert(),
+			c.opts.ID,
+			prom_config.WithUserAgent(customUserAgent),
+		)
+		if err != nil {
+			return err
+		}
+		c.cli = cli
 	}
-	c.cli = cli
 
 	// Send an updated event if one wasn't already read.
 	select {
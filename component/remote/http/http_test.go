@@ -10,6 +10,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/grafana/agent/component"
 	http_component "github.com/grafana/agent/component/remote/http"
 	"github.com/grafana/agent/pkg/flow/componenttest"
 	"github.com/grafana/agent/pkg/flow/logging/level"
@@ -177,3 +178,44 @@ func (lh *lazyHandler) SetHandler(h http.HandlerFunc) {
 
 	lh.inner = h
 }
+
+func TestControllerHTTPClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Header.Get("X-Shared-Client"))
+	}))
+	defer srv.Close()
+
+	// The client of the controller marks every request it sends.
+	shared := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		r.Header.Set("X-Shared-Client", "true")
+		return http.DefaultTransport.RoundTrip(r)
+	})}
+
+	var exports http_component.Exports
+	opts := component.Options{
+		ID:            "remote.http.test",
+		Logger:        util.TestFlowLogger(t),
+		OnStateChange: func(e component.Exports) { exports = e.(http_component.Exports) },
+		HTTPClient:    shared,
+	}
+
+	var args http_component.Arguments
+	require.NoError(t, river.Unmarshal([]byte(fmt.Sprintf(`url = "%s"`, srv.URL)), &args))
+	c, err := http_component.New(opts, args)
+	require.NoError(t, err)
+	require.Equal(t, "true", exports.Content.Value)
+
+	// Configuring a client replaces the client of the controller.
+	require.NoError(t, river.Unmarshal([]byte(fmt.Sprintf(`
+		url = "%s"
+		client {
+			follow_redirects = false
+		}
+	`, srv.URL)), &args))
+	require.NoError(t, c.Update(args))
+	require.Equal(t, "", exports.Content.Value)
+}
+
+type roundTripFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
@@ -6,6 +6,7 @@ import (
 	"fmt"
 
 	"github.com/grafana/agent/converter/diag"
+	"github.com/grafana/agent/converter/internal/common"
 	"github.com/grafana/agent/converter/internal/prometheusconvert"
 	"github.com/grafana/agent/converter/internal/promtailconvert"
 	"github.com/grafana/agent/converter/internal/staticconvert"
@@ -33,8 +34,9 @@ var SupportedFormats = []string{
 // file.
 //
 // extraArgs are supported to be passed along to a converter such as enabling
-// integrations-next for the static converter. Converters that do not support
-// extraArgs will return a critical severity diagnostic if any are passed.
+// integrations-next for the static converter. Every converter declares the
+// extra arguments it accepts: a warning is returned for every unknown extra
+// argument, which is ignored, and an error for every malformed one.
 //
 // Conversions are made as literally as possible, so the resulting config files
 // may be unoptimized (i.e., lacking component reuse). A converted config file
@@ -46,16 +48,109 @@ var SupportedFormats = []string{
 // config. If the conversion completed successfully but generated warnings, an
 // error is returned alongside the resulting config.
 func Convert(in []byte, kind Input, extraArgs []string) ([]byte, diag.Diagnostics) {
+	return ConvertWithProgress(in, kind, extraArgs, nil)
+}
+
+// ConvertWithProgress is like Convert, but calls progress as the conversion
+// proceeds with the number of top-level blocks converted so far. progress is
+// called at least once for every scrape config or integration in the input,
+// which gives feedback during large conversions. progress may be nil.
+func ConvertWithProgress(in []byte, kind Input, extraArgs []string, progress func(converted int)) ([]byte, diag.Diagnostics) {
 	switch kind {
 	case InputPrometheus:
-		return prometheusconvert.Convert(in, extraArgs)
+		return prometheusconvert.ConvertWithProgress(in, extraArgs, progress)
 	case InputPromtail:
-		return promtailconvert.Convert(in, extraArgs)
+		return promtailconvert.ConvertWithProgress(in, extraArgs, progress)
 	case InputStatic:
-		return staticconvert.Convert(in, extraArgs)
+		return staticconvert.ConvertWithProgress(in, extraArgs, progress)
 	}
 
 	var diags diag.Diagnostics
 	diags.Add(diag.SeverityLevelCritical, fmt.Sprintf("unrecognized kind %q given to the config converter", kind))
 	return nil, diags
 }
+
+// Source is a config file converted by ConvertMerged.
+type Source struct {
+	// Name identifies the file in diagnostics, such as its path.
+	Name   string
+	Kind   Input
+	Config []byte
+}
+
+// ConvertMerged converts every source with ConvertWithProgress, and merges
+// the converted configs into a single Grafana Agent Flow config, in order.
+// This consolidates configs of several programs, such as a Prometheus config
+// for metrics and a promtail config for logs, into one config.
+//
+// extraArgs are passed to the converter of every source. The summaries of
+// diagnostics are prefixed with the name of the source they were reported
+// for. A critical diagnostic is returned for every component whose ID is
+// used by more than one source, since references to the component would be
+// ambiguous.
+//
+// progress is called with the number of top-level blocks converted so far
+// across all sources, and may be nil.
+func ConvertMerged(sources []Source, extraArgs []string, progress func(converted int)) ([]byte, diag.Diagnostics) {
+	var (
+		diags  diag.Diagnostics
+		inputs = make([]common.MergeInput, 0, len(sources))
+		done   int // Blocks converted by the previous sources.
+	)
+
+	for _, src := range sources {
+		var (
+			sourceProgress func(converted int)
+			converted      int
+		)
+		if progress != nil {
+			sourceProgress = func(n int) {
+				converted = n
+				progress(done + n)
+			}
+		}
+
+		config, sourceDiags := ConvertWithProgress(src.Config, src.Kind, extraArgs, sourceProgress)
+		for _, d := range sourceDiags {
+			d.Summary = fmt.Sprintf("%s: %s", src.Name, d.Summary)
+			diags = append(diags, d)
+		}
+		inputs = append(inputs, common.MergeInput{Name: src.Name, Config: config})
+		done += converted
+	}
+
+	if counts := diags.CountBySeverity(); counts[diag.SeverityLevelCritical] > 0 {
+		return nil, diags
+	}
+
+	merged, mergeDiags := common.MergeConfigs(inputs)
+	diags.AddAll(mergeDiags)
+	return merged, diags
+}
+
+// AnnotateWarnings adds warning-level diagnostics returned by Convert as
+// comments in the converted config, above the blocks they apply to. This
+// keeps caveats of the conversion next to the converted blocks. Diagnostics
+// which don't apply to a specific block in the converted config are left out.
+func AnnotateWarnings(config []byte, diags diag.Diagnostics) ([]byte, error) {
+	return common.AnnotateWarnings(config, diags)
+}
+
+// ValidateTargetVersion reports an error for every component in the config
+// returned by Convert which isn't available in the given version of Grafana
+// Agent, such as v0.35. This prevents generating configs which older agents
+// can't run.
+func ValidateTargetVersion(config []byte, version string) diag.Diagnostics {
+	return common.ValidateTargetVersion(config, version)
+}
+
+// ValidateComponentPolicy reports an error for every component in the config
+// returned by Convert for which allowed returns false, such as components
+// rejected by the AllowedComponents and DeniedComponents options of Flow
+// controllers. allowed is called with the name of each component, such as
+// "prometheus.scrape". This lets configs be checked against the policy of the
+// environment they'll run in. If omit is true, those components are removed
+// from the returned config and reported as warnings instead.
+func ValidateComponentPolicy(config []byte, allowed func(name string) bool, omit bool) ([]byte, diag.Diagnostics) {
+	return common.ValidateComponentPolicy(config, allowed, omit)
+}
@@ -14,6 +14,15 @@ type Diagnostic struct {
 
 	Summary string
 	Detail  string
+
+	// Target optionally holds the ID of the block in the converted config
+	// which the Diagnostic applies to, such as "discovery.consul.default".
+	Target string
+
+	// FollowUp optionally holds a manual step which must be taken to complete
+	// the migration, such as configuring a feature the converter couldn't
+	// convert. Follow-ups are listed in the migration checklist of reports.
+	FollowUp string
 }
 
 var _ fmt.Stringer = (*Diagnostic)(nil)
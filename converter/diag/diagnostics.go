@@ -28,6 +28,26 @@ func (ds *Diagnostics) AddWithDetail(severity Severity, message string, detail s
 	})
 }
 
+// AddWithTarget adds an individual Diagnostic which applies to the block with
+// the given ID in the converted config.
+func (ds *Diagnostics) AddWithTarget(severity Severity, message string, target string) {
+	*ds = append(*ds, Diagnostic{
+		Severity: severity,
+		Summary:  message,
+		Target:   target,
+	})
+}
+
+// AddWithFollowUp adds an individual Diagnostic which requires the manual
+// step followUp to complete the migration.
+func (ds *Diagnostics) AddWithFollowUp(severity Severity, message string, followUp string) {
+	*ds = append(*ds, Diagnostic{
+		Severity: severity,
+		Summary:  message,
+		FollowUp: followUp,
+	})
+}
+
 // AddAll adds all given diagnostics to the diagnostics list.
 func (ds *Diagnostics) AddAll(diags Diagnostics) {
 	*ds = append(*ds, diags...)
@@ -46,6 +66,36 @@ func (ds Diagnostics) Error() string {
 	return sb.String()
 }
 
+// CountBySeverity returns the number of diagnostics of each severity.
+// Severities without any diagnostics aren't included.
+func (ds Diagnostics) CountBySeverity() map[Severity]int {
+	counts := make(map[Severity]int)
+	for _, diag := range ds {
+		counts[diag.Severity]++
+	}
+	return counts
+}
+
+// Checklist returns the follow-ups of the diagnostics in the order they were
+// added, leaving out duplicates.
+func (ds Diagnostics) Checklist() []string {
+	var (
+		items []string
+		seen  = make(map[string]struct{})
+	)
+	for _, diag := range ds {
+		if diag.FollowUp == "" {
+			continue
+		}
+		if _, ok := seen[diag.FollowUp]; ok {
+			continue
+		}
+		seen[diag.FollowUp] = struct{}{}
+		items = append(items, diag.FollowUp)
+	}
+	return items
+}
+
 func (ds Diagnostics) GenerateReport(writer io.Writer, reportType string) error {
 	switch reportType {
 	case Text:
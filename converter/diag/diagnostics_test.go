@@ -0,0 +1,23 @@
+package diag
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiagnostics_CountBySeverity(t *testing.T) {
+	var diags Diagnostics
+	require.Empty(t, diags.CountBySeverity())
+
+	diags.Add(SeverityLevelError, "a")
+	diags.Add(SeverityLevelWarn, "b")
+	diags.Add(SeverityLevelError, "c")
+	diags.Add(SeverityLevelInfo, "d")
+
+	require.Equal(t, map[Severity]int{
+		SeverityLevelError: 2,
+		SeverityLevelWarn:  1,
+		SeverityLevelInfo:  1,
+	}, diags.CountBySeverity())
+}
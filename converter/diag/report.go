@@ -1,16 +1,30 @@
 package diag
 
 import (
+	"fmt"
 	"io"
+	"strings"
 )
 
 const Text = ".txt"
 
-// generateTextReport generates a text report for the diagnostics.
+// generateTextReport generates a text report for the diagnostics, followed by
+// a checklist of the manual steps needed to complete the migration, if any.
 func generateTextReport(writer io.Writer, ds Diagnostics) error {
-	content := ds.Error()
+	var sb strings.Builder
+	sb.WriteString(ds.Error())
 
-	_, err := writer.Write([]byte(content))
+	if items := ds.Checklist(); len(items) > 0 {
+		if len(ds) > 0 {
+			sb.WriteString("\n\n")
+		}
+		sb.WriteString("Migration checklist:\n")
+		for _, item := range items {
+			fmt.Fprintf(&sb, "- [ ] %s\n", item)
+		}
+	}
+
+	_, err := writer.Write([]byte(sb.String()))
 	if err != nil {
 		return err
 	}
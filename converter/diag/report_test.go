@@ -0,0 +1,39 @@
+package diag
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateTextReport_Checklist(t *testing.T) {
+	var diags Diagnostics
+	diags.Add(SeverityLevelInfo, "converted config")
+	diags.AddWithFollowUp(SeverityLevelError, "unsupported a", "Migrate a manually.")
+	diags.AddWithFollowUp(SeverityLevelWarn, "unsupported b", "Migrate b manually.")
+	diags.AddWithFollowUp(SeverityLevelError, "unsupported a again", "Migrate a manually.")
+
+	var buf bytes.Buffer
+	require.NoError(t, diags.GenerateReport(&buf, Text))
+
+	expect := `(Info) converted config
+(Error) unsupported a
+(Warning) unsupported b
+(Error) unsupported a again
+
+Migration checklist:
+- [ ] Migrate a manually.
+- [ ] Migrate b manually.
+`
+	require.Equal(t, expect, buf.String())
+}
+
+func TestGenerateTextReport_NoChecklist(t *testing.T) {
+	var diags Diagnostics
+	diags.Add(SeverityLevelWarn, "warning")
+
+	var buf bytes.Buffer
+	require.NoError(t, diags.GenerateReport(&buf, Text))
+	require.Equal(t, "(Warning) warning", buf.String())
+}
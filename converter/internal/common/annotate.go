@@ -0,0 +1,60 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/grafana/agent/converter/diag"
+	"github.com/grafana/river/ast"
+	"github.com/grafana/river/parser"
+)
+
+// AnnotateWarnings adds the warning-level diagnostics which hold a Target as
+// comments above the top-level block they apply to in the River config in.
+// Warnings whose target doesn't exist in the config are left out.
+func AnnotateWarnings(in []byte, diags diag.Diagnostics) ([]byte, error) {
+	warnings := make(map[string][]string)
+	for _, d := range diags {
+		if d.Severity == diag.SeverityLevelWarn && d.Target != "" {
+			warnings[d.Target] = append(warnings[d.Target], d.Summary)
+		}
+	}
+	if len(warnings) == 0 || len(in) == 0 {
+		return in, nil
+	}
+
+	f, err := parser.ParseFile("", in)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find the line where each targeted block starts.
+	lineWarnings := make(map[int][]string)
+	for _, stmt := range f.Body {
+		block, ok := stmt.(*ast.BlockStmt)
+		if !ok {
+			continue
+		}
+
+		id := strings.Join(block.Name, ".")
+		if block.Label != "" {
+			id += "." + block.Label
+		}
+		if w, ok := warnings[id]; ok {
+			line := ast.StartPos(block).Position().Line
+			lineWarnings[line] = append(lineWarnings[line], w...)
+		}
+	}
+
+	var sb strings.Builder
+	for i, line := range strings.SplitAfter(string(in), "\n") {
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		for _, w := range lineWarnings[i+1] {
+			// Keep multi-line summaries within a single comment line.
+			w = strings.Join(strings.Fields(w), " ")
+			fmt.Fprintf(&sb, "%s// %s: %s\n", indent, diag.SeverityLevelWarn, w)
+		}
+		sb.WriteString(line)
+	}
+	return []byte(sb.String()), nil
+}
@@ -0,0 +1,44 @@
+package common_test
+
+import (
+	"testing"
+
+	"github.com/grafana/agent/converter/diag"
+	"github.com/grafana/agent/converter/internal/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnnotateWarnings(t *testing.T) {
+	in := `discovery.consulagent "default" {
+	server = "localhost:8500"
+}
+
+loki.write "default" {
+	endpoint {
+		url = "http://localhost/loki/api/v1/push"
+	}
+}
+`
+
+	var diags diag.Diagnostics
+	diags.AddWithTarget(diag.SeverityLevelWarn, "node_meta is not used by discovery.consulagent and will be ignored", "discovery.consulagent.default")
+	diags.AddWithTarget(diag.SeverityLevelError, "errors aren't annotated", "loki.write.default")
+	diags.AddWithTarget(diag.SeverityLevelWarn, "missing targets are ignored", "loki.write.missing")
+	diags.Add(diag.SeverityLevelWarn, "warnings without a target aren't annotated")
+
+	expect := `// Warning: node_meta is not used by discovery.consulagent and will be ignored
+discovery.consulagent "default" {
+	server = "localhost:8500"
+}
+
+loki.write "default" {
+	endpoint {
+		url = "http://localhost/loki/api/v1/push"
+	}
+}
+`
+
+	out, err := common.AnnotateWarnings([]byte(in), diags)
+	require.NoError(t, err)
+	require.Equal(t, expect, string(out))
+}
@@ -0,0 +1,84 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/grafana/agent/converter/diag"
+	"github.com/grafana/river/ast"
+	"github.com/grafana/river/parser"
+)
+
+// ValidateComponentPolicy returns a diagnostic for every top-level component
+// in the River config in for which allowed returns false. allowed is called
+// with the name of each component, such as "prometheus.scrape".
+//
+// Denied components are reported as errors. If omit is true, denied
+// components are removed from the returned config instead, and are reported
+// as warnings since blocks referencing them must be updated.
+func ValidateComponentPolicy(in []byte, allowed func(name string) bool, omit bool) ([]byte, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	f, err := parser.ParseFile("", in)
+	if err != nil {
+		diags.Add(diag.SeverityLevelCritical, fmt.Sprintf("failed to parse the converted config: %s", err))
+		return in, diags
+	}
+
+	// Lines of denied components to remove, indexed from 1.
+	omitted := make(map[int]bool)
+
+	for _, stmt := range f.Body {
+		block, ok := stmt.(*ast.BlockStmt)
+		// Config blocks such as logging have a single identifier in their
+		// name, and aren't subject to the policy.
+		if !ok || len(block.Name) < 2 {
+			continue
+		}
+
+		name := strings.Join(block.Name, ".")
+		if allowed(name) {
+			continue
+		}
+
+		id := name
+		if block.Label != "" {
+			id += "." + block.Label
+		}
+		if !omit {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.SeverityLevelError,
+				Summary:  fmt.Sprintf("%s isn't allowed by the component policy", id),
+				Target:   id,
+				FollowUp: fmt.Sprintf("Replace %s, which isn't allowed by the component policy.", id),
+			})
+			continue
+		}
+
+		diags.AddWithFollowUp(
+			diag.SeverityLevelWarn,
+			fmt.Sprintf("%s isn't allowed by the component policy and was omitted from the output", id),
+			fmt.Sprintf("Update blocks which referenced %s, which was omitted from the output.", id),
+		)
+		start, end := ast.StartPos(block).Position().Line, ast.EndPos(block).Position().Line
+		for line := start; line <= end; line++ {
+			omitted[line] = true
+		}
+	}
+
+	if len(omitted) == 0 {
+		return in, diags
+	}
+
+	var sb strings.Builder
+	for i, line := range strings.SplitAfter(string(in), "\n") {
+		if !omitted[i+1] {
+			sb.WriteString(line)
+		}
+	}
+
+	// Reformat the config to remove the blank lines left by omitted blocks.
+	out, newDiags := PrettyPrint([]byte(sb.String()))
+	diags.AddAll(newDiags)
+	return out, diags
+}
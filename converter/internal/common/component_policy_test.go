@@ -0,0 +1,87 @@
+package common_test
+
+import (
+	"testing"
+
+	"github.com/grafana/agent/converter/diag"
+	"github.com/grafana/agent/converter/internal/common"
+	"github.com/grafana/agent/pkg/flow"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateComponentPolicy(t *testing.T) {
+	in := []byte(`logging {
+	level = "debug"
+}
+
+discovery.consul "default" {
+	server = "localhost:8500"
+}
+
+prometheus.scrape "default" {
+	targets    = discovery.consul.default.targets
+	forward_to = [prometheus.remote_write.default.receiver]
+}
+
+prometheus.remote_write "default" {
+	endpoint {
+		url = "http://localhost:9009/api/prom/push"
+	}
+}
+`)
+
+	policy := func(allowed, denied []string) func(string) bool {
+		return func(name string) bool { return flow.ComponentAllowed(allowed, denied, name) }
+	}
+
+	t.Run("allowed", func(t *testing.T) {
+		out, diags := common.ValidateComponentPolicy(in, policy([]string{"prometheus.*", "discovery.*"}, nil), false)
+		require.Empty(t, diags)
+		require.Equal(t, string(in), string(out))
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		out, diags := common.ValidateComponentPolicy(in, policy([]string{"prometheus.*"}, []string{"prometheus.remote_write"}), false)
+		require.Equal(t, diag.Diagnostics{
+			{
+				Severity: diag.SeverityLevelError,
+				Summary:  "discovery.consul.default isn't allowed by the component policy",
+				Target:   "discovery.consul.default",
+				FollowUp: "Replace discovery.consul.default, which isn't allowed by the component policy.",
+			},
+			{
+				Severity: diag.SeverityLevelError,
+				Summary:  "prometheus.remote_write.default isn't allowed by the component policy",
+				Target:   "prometheus.remote_write.default",
+				FollowUp: "Replace prometheus.remote_write.default, which isn't allowed by the component policy.",
+			},
+		}, diags)
+		require.Equal(t, string(in), string(out))
+	})
+
+	t.Run("omitted", func(t *testing.T) {
+		out, diags := common.ValidateComponentPolicy(in, policy(nil, []string{"discovery.*"}), true)
+		var expect diag.Diagnostics
+		expect.AddWithFollowUp(
+			diag.SeverityLevelWarn,
+			"discovery.consul.default isn't allowed by the component policy and was omitted from the output",
+			"Update blocks which referenced discovery.consul.default, which was omitted from the output.",
+		)
+		require.Equal(t, expect, diags)
+		require.Equal(t, `logging {
+	level = "debug"
+}
+
+prometheus.scrape "default" {
+	targets    = discovery.consul.default.targets
+	forward_to = [prometheus.remote_write.default.receiver]
+}
+
+prometheus.remote_write "default" {
+	endpoint {
+		url = "http://localhost:9009/api/prom/push"
+	}
+}
+`, string(out))
+	})
+}
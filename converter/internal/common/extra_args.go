@@ -0,0 +1,77 @@
+package common
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/grafana/agent/converter/diag"
+)
+
+// ValidateExtraArgs validates the extra arguments passed to the converter of
+// format against accepted, the flags the converter accepts. Arguments follow
+// the syntax of the flag package: -name, --name, -name=value, or -name value
+// for flags which aren't booleans.
+//
+// A warning is returned for every flag which isn't in accepted, and an error
+// for every malformed argument, such as an argument which isn't a flag, a flag
+// missing its value, or a value the flag doesn't accept. Only the valid,
+// accepted arguments are returned, so they can be passed on to the converter.
+//
+// accepted is modified by setting the flags in args, so it must not be shared
+// between calls.
+func ValidateExtraArgs(format string, args []string, accepted *flag.FlagSet) ([]string, diag.Diagnostics) {
+	var (
+		diags diag.Diagnostics
+		valid []string
+	)
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name == "" {
+			diags.Add(diag.SeverityLevelError, fmt.Sprintf("extra argument %q for the %s converter is not a flag", arg, format))
+			continue
+		}
+
+		f := accepted.Lookup(name)
+		if f == nil {
+			diags.Add(diag.SeverityLevelWarn, fmt.Sprintf("unknown extra argument -%s for the %s converter is ignored", name, format))
+			if !hasValue && i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+				// Skip the value of the unknown flag.
+				i++
+			}
+			continue
+		}
+
+		consumed := []string{arg}
+		if !hasValue {
+			if isBoolFlag(f) {
+				value = "true"
+			} else if i+1 < len(args) {
+				i++
+				value = args[i]
+				consumed = append(consumed, value)
+			} else {
+				diags.Add(diag.SeverityLevelError, fmt.Sprintf("extra argument -%s for the %s converter needs a value", name, format))
+				continue
+			}
+		}
+
+		if err := f.Value.Set(value); err != nil {
+			diags.Add(diag.SeverityLevelError, fmt.Sprintf("invalid value %q for extra argument -%s of the %s converter: %s", value, name, format, err))
+			continue
+		}
+		valid = append(valid, consumed...)
+	}
+
+	return valid, diags
+}
+
+// isBoolFlag returns whether f can be set without a value, like the boolean
+// flags of the flag package.
+func isBoolFlag(f *flag.Flag) bool {
+	bf, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && bf.IsBoolFlag()
+}
@@ -0,0 +1,60 @@
+package common_test
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/grafana/agent/converter/diag"
+	"github.com/grafana/agent/converter/internal/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateExtraArgs(t *testing.T) {
+	accepted := func() *flag.FlagSet {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.String("enable-features", "", "")
+		fs.Bool("config.expand-env", false, "")
+		fs.Duration("interval", 0, "")
+		return fs
+	}
+
+	tt := []struct {
+		name   string
+		args   []string
+		expect []string
+		diags  diag.Diagnostics
+	}{
+		{
+			name:   "accepted",
+			args:   []string{"-enable-features", "integrations-next", "--config.expand-env", "-interval=1m"},
+			expect: []string{"-enable-features", "integrations-next", "--config.expand-env", "-interval=1m"},
+		},
+		{
+			name:   "unknown flags",
+			args:   []string{"-version", "-label", "team", "-enable-features=integrations-next"},
+			expect: []string{"-enable-features=integrations-next"},
+			diags: diag.Diagnostics{
+				{Severity: diag.SeverityLevelWarn, Summary: "unknown extra argument -version for the test converter is ignored"},
+				{Severity: diag.SeverityLevelWarn, Summary: "unknown extra argument -label for the test converter is ignored"},
+			},
+		},
+		{
+			name: "malformed",
+			args: []string{"integrations-next", "-interval=soon", "-config.expand-env=maybe", "-enable-features"},
+			diags: diag.Diagnostics{
+				{Severity: diag.SeverityLevelError, Summary: `extra argument "integrations-next" for the test converter is not a flag`},
+				{Severity: diag.SeverityLevelError, Summary: `invalid value "soon" for extra argument -interval of the test converter: parse error`},
+				{Severity: diag.SeverityLevelError, Summary: `invalid value "maybe" for extra argument -config.expand-env of the test converter: parse error`},
+				{Severity: diag.SeverityLevelError, Summary: "extra argument -enable-features for the test converter needs a value"},
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			args, diags := common.ValidateExtraArgs("test", tc.args, accepted())
+			require.Equal(t, tc.expect, args)
+			require.Equal(t, tc.diags, diags)
+		})
+	}
+}
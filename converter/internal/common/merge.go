@@ -0,0 +1,80 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/grafana/agent/converter/diag"
+	"github.com/grafana/river/ast"
+	"github.com/grafana/river/parser"
+)
+
+// MergeInput is a converted River config merged by MergeConfigs.
+type MergeInput struct {
+	// Name identifies the config in diagnostics, such as the path of the file
+	// it was converted from.
+	Name   string
+	Config []byte
+}
+
+// MergeConfigs merges the top-level blocks of the River configs in inputs
+// into a single config, in order. A diagnostic is returned for every block
+// whose ID, such as "prometheus.remote_write.default", is already used by an
+// earlier input, since references to the block would be ambiguous and the
+// merged config wouldn't load.
+func MergeConfigs(inputs []MergeInput) ([]byte, diag.Diagnostics) {
+	var (
+		diags   diag.Diagnostics
+		buf     bytes.Buffer
+		definer = make(map[string]string) // Name of the input defining each block ID.
+	)
+
+	for _, in := range inputs {
+		if len(bytes.TrimSpace(in.Config)) == 0 {
+			continue
+		}
+
+		f, err := parser.ParseFile(in.Name, in.Config)
+		if err != nil {
+			diags.Add(diag.SeverityLevelCritical, fmt.Sprintf("failed to parse the config converted from %s: %s", in.Name, err))
+			continue
+		}
+
+		for _, stmt := range f.Body {
+			block, ok := stmt.(*ast.BlockStmt)
+			if !ok {
+				continue
+			}
+
+			id := strings.Join(block.Name, ".")
+			if block.Label != "" {
+				id += "." + block.Label
+			}
+			if first, exists := definer[id]; exists {
+				diags = append(diags, diag.Diagnostic{
+					Severity: diag.SeverityLevelCritical,
+					Summary:  fmt.Sprintf("%s is defined by both %s and %s", id, first, in.Name),
+					Target:   id,
+					FollowUp: fmt.Sprintf("Rename %s in one of %s or %s, and update the references to it.", id, first, in.Name),
+				})
+				continue
+			}
+			definer[id] = in.Name
+		}
+
+		if buf.Len() > 0 {
+			buf.WriteString("\n")
+		}
+		buf.Write(bytes.TrimSpace(in.Config))
+		buf.WriteString("\n")
+	}
+
+	if buf.Len() == 0 {
+		return nil, diags
+	}
+
+	out, printDiags := PrettyPrint(buf.Bytes())
+	diags.AddAll(printDiags)
+	return out, diags
+}
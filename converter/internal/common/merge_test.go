@@ -0,0 +1,58 @@
+package common_test
+
+import (
+	"testing"
+
+	"github.com/grafana/agent/converter/diag"
+	"github.com/grafana/agent/converter/internal/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeConfigs(t *testing.T) {
+	metrics := []byte(`prometheus.scrape "default" {
+	targets    = []
+	forward_to = [prometheus.remote_write.default.receiver]
+}
+
+prometheus.remote_write "default" {
+	endpoint {
+		url = "http://localhost:9009/api/prom/push"
+	}
+}
+`)
+	logs := []byte(`loki.write "default" {
+	endpoint {
+		url = "http://localhost:3100/loki/api/v1/push"
+	}
+}
+`)
+
+	t.Run("merged", func(t *testing.T) {
+		out, diags := common.MergeConfigs([]common.MergeInput{
+			{Name: "prometheus.yaml", Config: metrics},
+			{Name: "empty.yaml"},
+			{Name: "promtail.yaml", Config: logs},
+		})
+		require.Empty(t, diags)
+		require.Equal(t, string(metrics)+"\n"+string(logs), string(out))
+	})
+
+	t.Run("collisions", func(t *testing.T) {
+		_, diags := common.MergeConfigs([]common.MergeInput{
+			{Name: "prometheus.yaml", Config: metrics},
+			{Name: "static.yaml", Config: []byte(`prometheus.remote_write "default" {}`)},
+		})
+		require.Equal(t, diag.Diagnostics{{
+			Severity: diag.SeverityLevelCritical,
+			Summary:  "prometheus.remote_write.default is defined by both prometheus.yaml and static.yaml",
+			Target:   "prometheus.remote_write.default",
+			FollowUp: "Rename prometheus.remote_write.default in one of prometheus.yaml or static.yaml, and update the references to it.",
+		}}, diags)
+	})
+
+	t.Run("nothing to merge", func(t *testing.T) {
+		out, diags := common.MergeConfigs([]common.MergeInput{{Name: "empty.yaml"}})
+		require.Empty(t, diags)
+		require.Nil(t, out)
+	})
+}
@@ -0,0 +1,17 @@
+package common
+
+import "github.com/grafana/river/token/builder"
+
+// ProgressFunc is called while a config is converted with the number of
+// top-level blocks converted so far.
+type ProgressFunc func(converted int)
+
+// Report calls p with the number of top-level blocks in f plus pending, the
+// number of converted blocks which haven't been appended to f yet. It does
+// nothing if p is nil.
+func (p ProgressFunc) Report(f *builder.File, pending int) {
+	if p == nil {
+		return
+	}
+	p(len(f.Body().Nodes()) + pending)
+}
@@ -0,0 +1,172 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/agent/converter/diag"
+	"github.com/grafana/river/ast"
+	"github.com/grafana/river/parser"
+)
+
+// componentVersions holds the release which introduced each component the
+// converters may generate. Components which aren't listed have been available
+// since the first release of Grafana Agent Flow.
+//
+// discovery.file is listed from v0.35, since older releases used that name
+// for the component now called local.file_match.
+var componentVersions = map[string]agentVersion{
+	"discovery.azure":              {0, 33},
+	"discovery.consul":             {0, 33},
+	"discovery.consulagent":        {0, 37},
+	"discovery.digitalocean":       {0, 33},
+	"discovery.dns":                {0, 33},
+	"discovery.docker":             {0, 30},
+	"discovery.dockerswarm":        {0, 37},
+	"discovery.ec2":                {0, 33},
+	"discovery.eureka":             {0, 36},
+	"discovery.file":               {0, 35},
+	"discovery.gce":                {0, 33},
+	"discovery.hetzner":            {0, 36},
+	"discovery.http":               {0, 34},
+	"discovery.ionos":              {0, 37},
+	"discovery.kubelet":            {0, 35},
+	"discovery.kuma":               {0, 37},
+	"discovery.lightsail":          {0, 33},
+	"discovery.linode":             {0, 37},
+	"discovery.marathon":           {0, 37},
+	"discovery.nerve":              {0, 37},
+	"discovery.openstack":          {0, 36},
+	"discovery.puppetdb":           {0, 36},
+	"discovery.scaleway":           {0, 37},
+	"discovery.serverset":          {0, 37},
+	"discovery.triton":             {0, 37},
+	"discovery.uyuni":              {0, 36},
+	"faro.receiver":                {0, 37},
+	"local.file_match":             {0, 35},
+	"loki.process":                 {0, 30},
+	"loki.relabel":                 {0, 30},
+	"loki.source.api":              {0, 34},
+	"loki.source.azure_event_hubs": {0, 33},
+	"loki.source.cloudflare":       {0, 31},
+	"loki.source.docker":           {0, 32},
+	"loki.source.file":             {0, 30},
+	"loki.source.gcplog":           {0, 31},
+	"loki.source.gelf":             {0, 31},
+	"loki.source.heroku":           {0, 31},
+	"loki.source.journal":          {0, 31},
+	"loki.source.kafka":            {0, 32},
+	"loki.source.syslog":           {0, 31},
+	"loki.source.windowsevent":     {0, 31},
+	"loki.write":                   {0, 30},
+
+	"prometheus.exporter.agent":         {0, 37},
+	"prometheus.exporter.apache":        {0, 32},
+	"prometheus.exporter.azure":         {0, 37},
+	"prometheus.exporter.blackbox":      {0, 33},
+	"prometheus.exporter.cadvisor":      {0, 37},
+	"prometheus.exporter.cloudwatch":    {0, 35},
+	"prometheus.exporter.consul":        {0, 32},
+	"prometheus.exporter.dnsmasq":       {0, 34},
+	"prometheus.exporter.elasticsearch": {0, 35},
+	"prometheus.exporter.gcp":           {0, 36},
+	"prometheus.exporter.github":        {0, 32},
+	"prometheus.exporter.kafka":         {0, 35},
+	"prometheus.exporter.memcached":     {0, 33},
+	"prometheus.exporter.mongodb":       {0, 35},
+	"prometheus.exporter.mssql":         {0, 34},
+	"prometheus.exporter.mysql":         {0, 33},
+	"prometheus.exporter.oracledb":      {0, 34},
+	"prometheus.exporter.postgres":      {0, 33},
+	"prometheus.exporter.process":       {0, 32},
+	"prometheus.exporter.redis":         {0, 32},
+	"prometheus.exporter.snmp":          {0, 33},
+	"prometheus.exporter.snowflake":     {0, 34},
+	"prometheus.exporter.squid":         {0, 35},
+	"prometheus.exporter.statsd":        {0, 33},
+	"prometheus.exporter.unix":          {0, 32},
+	"prometheus.exporter.vsphere":       {0, 37},
+	"prometheus.exporter.windows":       {0, 33},
+}
+
+// agentVersion is a release of Grafana Agent. Patch releases don't introduce
+// components, so they aren't tracked.
+type agentVersion struct {
+	major, minor int
+}
+
+// parseAgentVersion parses versions such as v0.35, 0.35, or v0.35.1. The
+// patch version and any pre-release suffix are ignored.
+func parseAgentVersion(s string) (agentVersion, error) {
+	invalid := fmt.Errorf("invalid version %q: expected a version such as v0.35", s)
+
+	parts := strings.SplitN(strings.TrimPrefix(s, "v"), ".", 3)
+	if len(parts) < 2 {
+		return agentVersion{}, invalid
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return agentVersion{}, invalid
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return agentVersion{}, invalid
+	}
+	return agentVersion{major: major, minor: minor}, nil
+}
+
+// before returns whether v is an older release than other.
+func (v agentVersion) before(other agentVersion) bool {
+	if v.major != other.major {
+		return v.major < other.major
+	}
+	return v.minor < other.minor
+}
+
+func (v agentVersion) String() string {
+	return fmt.Sprintf("v%d.%d", v.major, v.minor)
+}
+
+// ValidateTargetVersion returns an error-level diagnostic for every top-level
+// component in the River config in which isn't available in the target
+// version of Grafana Agent.
+func ValidateTargetVersion(in []byte, version string) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	target, err := parseAgentVersion(version)
+	if err != nil {
+		diags.Add(diag.SeverityLevelCritical, err.Error())
+		return diags
+	}
+
+	f, err := parser.ParseFile("", in)
+	if err != nil {
+		diags.Add(diag.SeverityLevelCritical, fmt.Sprintf("failed to parse the converted config: %s", err))
+		return diags
+	}
+
+	for _, stmt := range f.Body {
+		block, ok := stmt.(*ast.BlockStmt)
+		if !ok {
+			continue
+		}
+
+		name := strings.Join(block.Name, ".")
+		introduced, ok := componentVersions[name]
+		if !ok || !target.before(introduced) {
+			continue
+		}
+
+		id := name
+		if block.Label != "" {
+			id += "." + block.Label
+		}
+		diags.AddWithTarget(
+			diag.SeverityLevelError,
+			fmt.Sprintf("%s was added in %s and isn't available in the target version %s", name, introduced, target),
+			id,
+		)
+	}
+	return diags
+}
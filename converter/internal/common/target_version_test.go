@@ -0,0 +1,81 @@
+package common_test
+
+import (
+	"testing"
+
+	"github.com/grafana/agent/converter/diag"
+	"github.com/grafana/agent/converter/internal/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateTargetVersion(t *testing.T) {
+	in := []byte(`discovery.consulagent "default" {
+	server = "localhost:8500"
+}
+
+prometheus.scrape "default" {
+	targets    = discovery.consulagent.default.targets
+	forward_to = [prometheus.remote_write.default.receiver]
+}
+
+prometheus.remote_write "default" {
+	endpoint {
+		url = "http://localhost:9009/api/prom/push"
+	}
+}
+
+loki.source.api "default" {
+	forward_to = []
+}
+`)
+
+	tt := []struct {
+		name    string
+		version string
+		expect  diag.Diagnostics
+	}{
+		{
+			name:    "all available",
+			version: "v0.37.2",
+		},
+		{
+			name:    "one unavailable",
+			version: "v0.36",
+			expect: diag.Diagnostics{{
+				Severity: diag.SeverityLevelError,
+				Summary:  "discovery.consulagent was added in v0.37 and isn't available in the target version v0.36",
+				Target:   "discovery.consulagent.default",
+			}},
+		},
+		{
+			name:    "many unavailable",
+			version: "0.33",
+			expect: diag.Diagnostics{
+				{
+					Severity: diag.SeverityLevelError,
+					Summary:  "discovery.consulagent was added in v0.37 and isn't available in the target version v0.33",
+					Target:   "discovery.consulagent.default",
+				},
+				{
+					Severity: diag.SeverityLevelError,
+					Summary:  "loki.source.api was added in v0.34 and isn't available in the target version v0.33",
+					Target:   "loki.source.api.default",
+				},
+			},
+		},
+		{
+			name:    "invalid version",
+			version: "latest",
+			expect: diag.Diagnostics{{
+				Severity: diag.SeverityLevelCritical,
+				Summary:  `invalid version "latest": expected a version such as v0.35`,
+			}},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expect, common.ValidateTargetVersion(in, tc.version))
+		})
+	}
+}
@@ -20,7 +20,8 @@ const (
 // specified results in a match for value1 and value2.
 //
 // For example, if using validationType Equals and value1 is equal to value2,
-// then a diagnostic error will be returned.
+// then a diagnostic error will be returned. The diagnostic lists migrating the
+// config manually as a follow-up in the migration checklist.
 func ValidateSupported(validationType int, value1 any, value2 any, name string, message string) diag.Diagnostics {
 	var diags diag.Diagnostics
 	var isInvalid bool
@@ -39,10 +40,11 @@ func ValidateSupported(validationType int, value1 any, value2 any, name string,
 	}
 
 	if isInvalid {
+		followUp := fmt.Sprintf("Migrate the %s config manually.", name)
 		if message != "" {
-			diags.Add(diag.SeverityLevelError, fmt.Sprintf("The converter does not support converting the provided %s config: %s", name, message))
+			diags.AddWithFollowUp(diag.SeverityLevelError, fmt.Sprintf("The converter does not support converting the provided %s config: %s", name, message), followUp)
 		} else {
-			diags.Add(diag.SeverityLevelError, fmt.Sprintf("The converter does not support converting the provided %s config.", name))
+			diags.AddWithFollowUp(diag.SeverityLevelError, fmt.Sprintf("The converter does not support converting the provided %s config.", name), followUp)
 		}
 	}
 
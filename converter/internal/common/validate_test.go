@@ -107,10 +107,11 @@ func TestValidateSupported(t *testing.T) {
 			if tc.expectDiag {
 				require.Len(t, diags, 1)
 				var expectedDiags diag.Diagnostics
+				followUp := fmt.Sprintf("Migrate the %s config manually.", tc.name)
 				if tc.message != "" {
-					expectedDiags.Add(diag.SeverityLevelError, fmt.Sprintf("The converter does not support converting the provided %s config: %s", tc.name, tc.message))
+					expectedDiags.AddWithFollowUp(diag.SeverityLevelError, fmt.Sprintf("The converter does not support converting the provided %s config: %s", tc.name, tc.message), followUp)
 				} else {
-					expectedDiags.Add(diag.SeverityLevelError, fmt.Sprintf("The converter does not support converting the provided %s config.", tc.name))
+					expectedDiags.AddWithFollowUp(diag.SeverityLevelError, fmt.Sprintf("The converter does not support converting the provided %s config.", tc.name), followUp)
 				}
 
 				require.Equal(t, expectedDiags, diags)
@@ -29,6 +29,15 @@ func NewPrometheusBlocks() *PrometheusBlocks {
 	}
 }
 
+// Len returns the number of blocks in pb.
+func (pb *PrometheusBlocks) Len() int {
+	return len(pb.DiscoveryBlocks) +
+		len(pb.DiscoveryRelabelBlocks) +
+		len(pb.PrometheusScrapeBlocks) +
+		len(pb.PrometheusRelabelBlocks) +
+		len(pb.PrometheusRemoteWriteBlocks)
+}
+
 // AppendToFile attaches prometheus blocks in a specific order.
 //
 // Order of blocks:
@@ -0,0 +1,268 @@
+package prometheusconvert
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/river/ast"
+	"github.com/grafana/river/parser"
+	"gopkg.in/yaml.v3"
+)
+
+// commentAnchor identifies where in the converted config a comment from the
+// Prometheus config is written.
+type commentAnchor struct {
+	component string // Name of the component, such as "prometheus.scrape".
+	job       string // Job the component was converted from, if any.
+	rule      int    // Index of the rule block within the component, or -1.
+}
+
+// sourceComments holds the comments of a Prometheus config by the part of
+// the converted config they apply to.
+type sourceComments struct {
+	anchored map[commentAnchor][]string
+	anchors  []commentAnchor // Keys of anchored in the order they were added.
+	orphaned []string        // Comments without an anchor, written at the top.
+}
+
+// parseComments collects the comments from the Prometheus config in.
+//
+// Comments within a scrape config are anchored to the components converted
+// from that job: comments within a relabel rule are anchored to the
+// corresponding rule block, and other comments to the prometheus.scrape
+// component. Comments within remote_write are anchored to the
+// prometheus.remote_write component. Every other comment is orphaned.
+func parseComments(in []byte) (sourceComments, error) {
+	c := sourceComments{anchored: make(map[commentAnchor][]string)}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(in, &doc); err != nil {
+		return c, err
+	}
+	if len(doc.Content) == 0 {
+		c.orphaned = append(c.orphaned, nodeComments(&doc)...)
+		return c, nil
+	}
+	c.orphaned = append(c.orphaned, ownComments(&doc)...)
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		c.orphaned = append(c.orphaned, nodeComments(root)...)
+		return c, nil
+	}
+	c.orphaned = append(c.orphaned, ownComments(root)...)
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		switch {
+		case key.Value == "scrape_configs" && value.Kind == yaml.SequenceNode:
+			c.orphaned = append(c.orphaned, ownComments(key)...)
+			c.orphaned = append(c.orphaned, ownComments(value)...)
+			for _, job := range value.Content {
+				c.addScrapeConfig(job)
+			}
+		case key.Value == "remote_write":
+			anchor := commentAnchor{component: "prometheus.remote_write", rule: -1}
+			c.add(anchor, nodeComments(key)...)
+			c.add(anchor, nodeComments(value)...)
+		default:
+			c.orphaned = append(c.orphaned, nodeComments(key)...)
+			c.orphaned = append(c.orphaned, nodeComments(value)...)
+		}
+	}
+	return c, nil
+}
+
+// addScrapeConfig collects the comments of the scrape config node.
+func (c *sourceComments) addScrapeConfig(node *yaml.Node) {
+	if node.Kind != yaml.MappingNode {
+		c.orphaned = append(c.orphaned, nodeComments(node)...)
+		return
+	}
+
+	var job string
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == "job_name" {
+			job = node.Content[i+1].Value
+		}
+	}
+
+	scrape := commentAnchor{component: "prometheus.scrape", job: job, rule: -1}
+	c.add(scrape, ownComments(node)...)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+
+		var component string
+		switch key.Value {
+		case "relabel_configs":
+			component = "discovery.relabel"
+		case "metric_relabel_configs":
+			component = "prometheus.relabel"
+		}
+		if component == "" || value.Kind != yaml.SequenceNode {
+			c.add(scrape, nodeComments(key)...)
+			c.add(scrape, nodeComments(value)...)
+			continue
+		}
+
+		c.add(commentAnchor{component: component, job: job, rule: -1}, append(nodeComments(key), ownComments(value)...)...)
+		for rule, n := range value.Content {
+			c.add(commentAnchor{component: component, job: job, rule: rule}, nodeComments(n)...)
+		}
+	}
+}
+
+func (c *sourceComments) add(anchor commentAnchor, comments ...string) {
+	if len(comments) == 0 {
+		return
+	}
+	if _, ok := c.anchored[anchor]; !ok {
+		c.anchors = append(c.anchors, anchor)
+	}
+	c.anchored[anchor] = append(c.anchored[anchor], comments...)
+}
+
+// ownComments returns the comments attached to node itself, without the
+// comments of its children.
+func ownComments(node *yaml.Node) []string {
+	var lines []string
+	for _, comment := range []string{node.HeadComment, node.LineComment, node.FootComment} {
+		for _, line := range strings.Split(comment, "\n") {
+			line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "#"))
+			if line != "" {
+				lines = append(lines, line)
+			}
+		}
+	}
+	return lines
+}
+
+// nodeComments returns the comments of node and all of its children, in
+// the order they appear in.
+func nodeComments(node *yaml.Node) []string {
+	lines := ownComments(node)
+	for _, child := range node.Content {
+		lines = append(lines, nodeComments(child)...)
+	}
+	return lines
+}
+
+// annotate writes the comments into the converted River config out, above
+// the block they're anchored to. Comments whose anchor doesn't exist in out
+// are written at the top along with the orphaned comments.
+func (c sourceComments) annotate(out []byte) ([]byte, error) {
+	if len(c.anchored) == 0 && len(c.orphaned) == 0 {
+		return out, nil
+	}
+
+	f, err := parser.ParseFile("", out)
+	if err != nil {
+		return nil, err
+	}
+
+	// Components converted from a job share the label of its
+	// prometheus.scrape component.
+	jobLabels := make(map[string]string)
+	for _, stmt := range f.Body {
+		if block, ok := stmt.(*ast.BlockStmt); ok && strings.Join(block.Name, ".") == "prometheus.scrape" {
+			if job, ok := stringAttr(block.Body, "job_name"); ok {
+				jobLabels[job] = block.Label
+			}
+		}
+	}
+
+	var (
+		lineComments = make(map[int][]string)
+		used         = make(map[commentAnchor]bool)
+		remoteWrite  bool
+	)
+	for _, stmt := range f.Body {
+		block, ok := stmt.(*ast.BlockStmt)
+		if !ok {
+			continue
+		}
+		name := strings.Join(block.Name, ".")
+
+		var job string
+		switch name {
+		case "prometheus.remote_write":
+			// Only the first remote_write component is annotated.
+			if remoteWrite {
+				continue
+			}
+			remoteWrite = true
+		default:
+			var found bool
+			for j, label := range jobLabels {
+				if label == block.Label {
+					job, found = j, true
+					break
+				}
+			}
+			if !found {
+				continue
+			}
+		}
+
+		anchor := commentAnchor{component: name, job: job, rule: -1}
+		if comments, ok := c.anchored[anchor]; ok {
+			line := ast.StartPos(block).Position().Line
+			lineComments[line] = append(lineComments[line], comments...)
+			used[anchor] = true
+		}
+
+		var rule int
+		for _, inner := range block.Body {
+			if b, ok := inner.(*ast.BlockStmt); ok && strings.Join(b.Name, ".") == "rule" {
+				anchor := commentAnchor{component: name, job: job, rule: rule}
+				if comments, ok := c.anchored[anchor]; ok {
+					line := ast.StartPos(b).Position().Line
+					lineComments[line] = append(lineComments[line], comments...)
+					used[anchor] = true
+				}
+				rule++
+			}
+		}
+	}
+
+	orphaned := c.orphaned
+	for _, anchor := range c.anchors {
+		if !used[anchor] {
+			orphaned = append(orphaned, c.anchored[anchor]...)
+		}
+	}
+
+	var sb strings.Builder
+	for _, comment := range orphaned {
+		fmt.Fprintf(&sb, "// %s\n", comment)
+	}
+	if len(orphaned) > 0 {
+		sb.WriteString("\n")
+	}
+	for i, line := range strings.SplitAfter(string(out), "\n") {
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		for _, comment := range lineComments[i+1] {
+			fmt.Fprintf(&sb, "%s// %s\n", indent, comment)
+		}
+		sb.WriteString(line)
+	}
+	return []byte(sb.String()), nil
+}
+
+// stringAttr returns the value of the string attribute name in body.
+func stringAttr(body ast.Body, name string) (string, bool) {
+	for _, stmt := range body {
+		attr, ok := stmt.(*ast.AttributeStmt)
+		if !ok || attr.Name.Name != name {
+			continue
+		}
+		lit, ok := attr.Value.(*ast.LiteralExpr)
+		if !ok {
+			return "", false
+		}
+		value, err := strconv.Unquote(lit.Value)
+		return value, err == nil
+	}
+	return "", false
+}
@@ -2,11 +2,14 @@ package component
 
 import (
 	"fmt"
+	"regexp"
+	"strconv"
 
 	flow_relabel "github.com/grafana/agent/component/common/relabel"
 	"github.com/grafana/agent/component/discovery"
 	disc_relabel "github.com/grafana/agent/component/discovery/relabel"
 	"github.com/grafana/agent/component/prometheus/relabel"
+	"github.com/grafana/agent/converter/diag"
 	"github.com/grafana/agent/converter/internal/common"
 	"github.com/grafana/agent/converter/internal/prometheusconvert/build"
 	prom_relabel "github.com/prometheus/prometheus/model/relabel"
@@ -89,3 +92,94 @@ func ToFlowRelabelConfigs(relabelConfigs []*prom_relabel.Config) []*flow_relabel
 
 	return metricRelabelConfigs
 }
+
+// capturePattern matches references to numbered capture groups in a
+// replacement, such as $1 or ${1}.
+var capturePattern = regexp.MustCompile(`\$(?:\{(\d+)\}|(\d+))`)
+
+// ValidateRelabelConfigs validates relabelConfigs, which are configured by
+// field, before they're converted by ToFlowRelabelConfigs.
+//
+// Rules which can't be converted, or which convert to rules River rejects,
+// are reported as errors. Rules which are valid but likely don't do what was
+// intended, such as rules setting fields their action ignores, are reported
+// as warnings which target the converted block with the ID target. The same
+// warnings apply to the source config, since relabel rules behave the same in
+// Prometheus and River.
+func ValidateRelabelConfigs(relabelConfigs []*prom_relabel.Config, field string, target string) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	for i, rc := range relabelConfigs {
+		name := fmt.Sprintf("%s[%d]", field, i)
+
+		var action flow_relabel.Action
+		if err := action.UnmarshalText([]byte(rc.Action)); err != nil {
+			diags.AddWithFollowUp(diag.SeverityLevelError, fmt.Sprintf("%s uses the %s action, which is not supported by the converter.", name, rc.Action),
+				fmt.Sprintf("Rewrite %s without the %s action.", name, rc.Action))
+			continue
+		}
+		if err := ToFlowRelabelConfigs([]*prom_relabel.Config{rc})[0].Validate(); err != nil {
+			diags.Add(diag.SeverityLevelError, fmt.Sprintf("%s converts to an invalid relabel rule: %s", name, err))
+			continue
+		}
+
+		ignored := func(field string) {
+			diags.AddWithTarget(diag.SeverityLevelWarn, fmt.Sprintf("%s sets %s, which is ignored by the %s action.", name, field, rc.Action), target)
+		}
+
+		switch rc.Action {
+		case prom_relabel.Keep, prom_relabel.Drop:
+			if rc.TargetLabel != "" {
+				ignored("target_label")
+			}
+			if rc.Replacement != prom_relabel.DefaultRelabelConfig.Replacement {
+				ignored("replacement")
+			}
+			if len(rc.SourceLabels) == 0 {
+				diags.AddWithTarget(diag.SeverityLevelWarn, fmt.Sprintf("%s has no source_labels, so the %s action matches its regex against an empty value and applies to either everything or nothing.", name, rc.Action), target)
+			}
+
+		case prom_relabel.HashMod:
+			if rc.Regex.String() != prom_relabel.DefaultRelabelConfig.Regex.String() {
+				ignored("regex")
+			}
+			if rc.Replacement != prom_relabel.DefaultRelabelConfig.Replacement {
+				ignored("replacement")
+			}
+
+		case prom_relabel.LabelMap:
+			if len(rc.SourceLabels) > 0 {
+				ignored("source_labels")
+			}
+			if rc.TargetLabel != "" {
+				ignored("target_label")
+			}
+			diags.AddAll(validateCaptureGroups(rc, name, target))
+
+		case prom_relabel.Replace:
+			diags.AddAll(validateCaptureGroups(rc, name, target))
+		}
+	}
+
+	return diags
+}
+
+// validateCaptureGroups warns when the replacement of rc references a
+// numbered capture group which its regex doesn't define. References to
+// undefined groups are replaced with an empty value.
+func validateCaptureGroups(rc *prom_relabel.Config, name string, target string) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	groups := rc.Regex.NumSubexp()
+	for _, match := range capturePattern.FindAllStringSubmatch(rc.Replacement, -1) {
+		ref := match[1]
+		if ref == "" {
+			ref = match[2]
+		}
+		if n, err := strconv.Atoi(ref); err == nil && n > groups {
+			diags.AddWithTarget(diag.SeverityLevelWarn, fmt.Sprintf("%s has a replacement which references capture group %d, which isn't defined by its regex %q, so the reference is replaced with an empty value.", name, n, rc.Regex.String()), target)
+			break
+		}
+	}
+	return diags
+}
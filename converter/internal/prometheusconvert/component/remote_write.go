@@ -18,10 +18,7 @@ import (
 func AppendPrometheusRemoteWrite(pb *build.PrometheusBlocks, globalConfig prom_config.GlobalConfig, remoteWriteConfigs []*prom_config.RemoteWriteConfig, label string) *remotewrite.Exports {
 	remoteWriteArgs := toRemotewriteArguments(globalConfig, remoteWriteConfigs)
 
-	remoteWriteLabel := label
-	if remoteWriteLabel == "" {
-		remoteWriteLabel = "default"
-	}
+	remoteWriteLabel := RemoteWriteLabel(label)
 
 	if len(remoteWriteConfigs) > 0 {
 		name := []string{"prometheus", "remote_write"}
@@ -41,10 +38,26 @@ func AppendPrometheusRemoteWrite(pb *build.PrometheusBlocks, globalConfig prom_c
 	}
 }
 
-func ValidateRemoteWriteConfig(remoteWriteConfig *prom_config.RemoteWriteConfig) diag.Diagnostics {
+// RemoteWriteLabel returns the label of the prometheus.remote_write component
+// which AppendPrometheusRemoteWrite converts with label.
+func RemoteWriteLabel(label string) string {
+	if label == "" {
+		return "default"
+	}
+	return label
+}
+
+// ValidateRemoteWriteConfig validates remoteWriteConfig. Warnings about its
+// write_relabel_configs target the converted block with the ID target.
+func ValidateRemoteWriteConfig(remoteWriteConfig *prom_config.RemoteWriteConfig, target string) diag.Diagnostics {
 	var diags diag.Diagnostics
 
 	diags.AddAll(common.ValidateHttpClientConfig(&remoteWriteConfig.HTTPClientConfig))
+	field := "remote_write write_relabel_configs"
+	if remoteWriteConfig.Name != "" {
+		field = fmt.Sprintf("remote_write %q write_relabel_configs", remoteWriteConfig.Name)
+	}
+	diags.AddAll(ValidateRelabelConfigs(remoteWriteConfig.WriteRelabelConfigs, field, target))
 	return diags
 }
 
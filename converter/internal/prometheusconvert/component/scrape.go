@@ -27,11 +27,15 @@ func AppendPrometheusScrape(pb *build.PrometheusBlocks, scrapeConfig *prom_confi
 	pb.PrometheusScrapeBlocks = append(pb.PrometheusScrapeBlocks, build.NewPrometheusBlock(block, name, label, summary, detail))
 }
 
-func ValidatePrometheusScrape(scrapeConfig *prom_config.ScrapeConfig) diag.Diagnostics {
+// ValidatePrometheusScrape validates scrapeConfig, whose components are
+// converted with label.
+func ValidatePrometheusScrape(scrapeConfig *prom_config.ScrapeConfig, label string) diag.Diagnostics {
 	var diags diag.Diagnostics
 
 	diags.AddAll(common.ValidateSupported(common.NotEquals, scrapeConfig.NativeHistogramBucketLimit, uint(0), "scrape_configs native_histogram_bucket_limit", ""))
 	diags.AddAll(common.ValidateHttpClientConfig(&scrapeConfig.HTTPClientConfig))
+	diags.AddAll(ValidateRelabelConfigs(scrapeConfig.RelabelConfigs, fmt.Sprintf("scrape_configs job %q relabel_configs", scrapeConfig.JobName), "discovery.relabel."+label))
+	diags.AddAll(ValidateRelabelConfigs(scrapeConfig.MetricRelabelConfigs, fmt.Sprintf("scrape_configs job %q metric_relabel_configs", scrapeConfig.JobName), "prometheus.relabel."+label))
 
 	return diags
 }
@@ -2,6 +2,7 @@ package prometheusconvert
 
 import (
 	"bytes"
+	"flag"
 	"fmt"
 
 	"github.com/go-kit/log"
@@ -19,17 +20,25 @@ import (
 	_ "github.com/prometheus/prometheus/discovery/install" // Register Prometheus SDs
 )
 
+// ExtraArgs returns a flag set declaring the extra arguments accepted by
+// Convert. The Prometheus converter doesn't accept any.
+func ExtraArgs() *flag.FlagSet {
+	return flag.NewFlagSet("prometheus", flag.ContinueOnError)
+}
+
 // Convert implements a Prometheus config converter.
 //
 // extraArgs are supported to mirror the other converter params due to shared
-// testing code but they should be passed empty to this converter.
+// testing code, but the converter doesn't accept any, so a warning is
+// returned for every extra argument passed.
 func Convert(in []byte, extraArgs []string) ([]byte, diag.Diagnostics) {
-	var diags diag.Diagnostics
+	return ConvertWithProgress(in, extraArgs, nil)
+}
 
-	if len(extraArgs) > 0 {
-		diags.Add(diag.SeverityLevelCritical, fmt.Sprintf("extra arguments are not supported for the prometheus converter: %s", extraArgs))
-		return nil, diags
-	}
+// ConvertWithProgress is like Convert, but calls progress with the number of
+// blocks converted so far after each scrape config is converted.
+func ConvertWithProgress(in []byte, extraArgs []string, progress common.ProgressFunc) ([]byte, diag.Diagnostics) {
+	_, diags := common.ValidateExtraArgs("prometheus", extraArgs, ExtraArgs())
 
 	promConfig, err := prom_config.Load(string(in), false, log.NewNopLogger())
 	if err != nil {
@@ -38,7 +47,7 @@ func Convert(in []byte, extraArgs []string) ([]byte, diag.Diagnostics) {
 	}
 
 	f := builder.NewFile()
-	diags = AppendAll(f, promConfig)
+	diags.AddAll(AppendAll(f, promConfig, progress))
 	diags.AddAll(common.ValidateNodes(f))
 
 	var buf bytes.Buffer
@@ -53,24 +62,42 @@ func Convert(in []byte, extraArgs []string) ([]byte, diag.Diagnostics) {
 
 	prettyByte, newDiags := common.PrettyPrint(buf.Bytes())
 	diags.AddAll(newDiags)
+
+	// Comments are carried over on a best-effort basis; the config was
+	// already loaded successfully, so failing to read its comments doesn't
+	// fail the conversion.
+	if comments, err := parseComments(in); err == nil {
+		if annotated, err := comments.annotate(prettyByte); err == nil {
+			prettyByte = annotated
+		}
+	}
 	return prettyByte, diags
 }
 
 // AppendAll analyzes the entire prometheus config in memory and transforms it
 // into Flow Arguments. It then appends each argument to the file builder.
 // Exports from other components are correctly referenced to build the Flow
-// pipeline.
-func AppendAll(f *builder.File, promConfig *prom_config.Config) diag.Diagnostics {
-	return AppendAllNested(f, promConfig, nil, []discovery.Target{}, nil)
+// pipeline. progress, if not nil, is called as blocks are converted.
+func AppendAll(f *builder.File, promConfig *prom_config.Config, progress common.ProgressFunc) diag.Diagnostics {
+	return AppendAllNested(f, promConfig, nil, []discovery.Target{}, nil, progress)
 }
 
 // AppendAllNested analyzes the entire prometheus config in memory and transforms it
 // into Flow Arguments. It then appends each argument to the file builder.
 // Exports from other components are correctly referenced to build the Flow
 // pipeline. Additional options can be provided overriding the job name, extra
-// scrape targets, and predefined remote write exports.
-func AppendAllNested(f *builder.File, promConfig *prom_config.Config, jobNameToCompLabelsFunc func(string) string, extraScrapeTargets []discovery.Target, remoteWriteExports *remotewrite.Exports) diag.Diagnostics {
-	pb := build.NewPrometheusBlocks()
+// scrape targets, and predefined remote write exports. progress, if not nil,
+// is called with the number of blocks converted so far after each scrape
+// config.
+func AppendAllNested(f *builder.File, promConfig *prom_config.Config, jobNameToCompLabelsFunc func(string) string, extraScrapeTargets []discovery.Target, remoteWriteExports *remotewrite.Exports, progress common.ProgressFunc) diag.Diagnostics {
+	var (
+		diags diag.Diagnostics
+		pb    = build.NewPrometheusBlocks()
+
+		// remoteWriteTarget is the ID of the converted remote_write block, if
+		// the remote_write configs are converted here.
+		remoteWriteTarget string
+	)
 
 	if remoteWriteExports == nil {
 		labelPrefix := ""
@@ -81,9 +108,16 @@ func AppendAllNested(f *builder.File, promConfig *prom_config.Config, jobNameToC
 			}
 		}
 		remoteWriteExports = component.AppendPrometheusRemoteWrite(pb, promConfig.GlobalConfig, promConfig.RemoteWriteConfigs, labelPrefix)
+		remoteWriteTarget = "prometheus.remote_write." + component.RemoteWriteLabel(labelPrefix)
 	}
 	remoteWriteForwardTo := []storage.Appendable{remoteWriteExports.Receiver}
 
+	// Job names are unique, but different job names may sanitize to the same
+	// label. Track which job uses each label so that jobs are never merged.
+	jobLabels := make(map[string]string, len(promConfig.ScrapeConfigs))
+	// Track the label of each job so that warnings can target its blocks.
+	labelsByJob := make(map[string]string, len(promConfig.ScrapeConfigs))
+
 	for _, scrapeConfig := range promConfig.ScrapeConfigs {
 		scrapeForwardTo := remoteWriteForwardTo
 		label := scrapeConfig.JobName
@@ -92,6 +126,14 @@ func AppendAllNested(f *builder.File, promConfig *prom_config.Config, jobNameToC
 		}
 		label = common.SanitizeIdentifierPanics(label)
 
+		if otherJob, ok := jobLabels[label]; ok {
+			unique := uniqueJobLabel(label, jobLabels)
+			diags.Add(diag.SeverityLevelWarn, fmt.Sprintf("job names %q and %q both convert to the label %q. the components for job %q were labeled %q instead, which can be avoided by renaming one of the jobs in the source config.", otherJob, scrapeConfig.JobName, label, scrapeConfig.JobName, unique))
+			label = unique
+		}
+		jobLabels[label] = scrapeConfig.JobName
+		labelsByJob[scrapeConfig.JobName] = label
+
 		promMetricsRelabelExports := component.AppendPrometheusRelabel(pb, scrapeConfig.MetricRelabelConfigs, remoteWriteForwardTo, label)
 		if promMetricsRelabelExports != nil {
 			scrapeForwardTo = []storage.Appendable{promMetricsRelabelExports.Receiver}
@@ -106,16 +148,29 @@ func AppendAllNested(f *builder.File, promConfig *prom_config.Config, jobNameToC
 		}
 
 		component.AppendPrometheusScrape(pb, scrapeConfig, scrapeForwardTo, scrapeTargets, label)
+		progress.Report(f, pb.Len())
 	}
 
-	diags := validate(promConfig)
+	diags.AddAll(validate(promConfig, labelsByJob, remoteWriteTarget))
 	diags.AddAll(pb.GetScrapeInfo())
 
 	pb.AppendToFile(f)
+	progress.Report(f, 0)
 
 	return diags
 }
 
+// uniqueJobLabel returns the first label made of label and a numeric suffix
+// which isn't used by another job in jobLabels.
+func uniqueJobLabel(label string, jobLabels map[string]string) string {
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s_%d", label, i)
+		if _, ok := jobLabels[candidate]; !ok {
+			return candidate
+		}
+	}
+}
+
 // AppendServiceDiscoveryConfigs will loop through the service discovery
 // configs and append them to the file. This returns the scrape targets
 // and discovery targets as a result.
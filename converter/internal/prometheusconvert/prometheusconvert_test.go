@@ -1,13 +1,49 @@
 package prometheusconvert_test
 
 import (
+	"os"
+	"path/filepath"
+	"sort"
 	"testing"
 
+	"github.com/grafana/agent/converter/diag"
+	"github.com/grafana/agent/converter/internal/common"
 	"github.com/grafana/agent/converter/internal/prometheusconvert"
 	"github.com/grafana/agent/converter/internal/test_common"
 	_ "github.com/grafana/agent/pkg/metrics/instance"
+	"github.com/grafana/river/parser"
+	"github.com/stretchr/testify/require"
 )
 
 func TestConvert(t *testing.T) {
 	test_common.TestDirectory(t, "testdata", ".yaml", true, []string{}, prometheusconvert.Convert)
 }
+
+func TestConvertWithProgress(t *testing.T) {
+	in, err := os.ReadFile(filepath.Join("testdata", "scrape.yaml"))
+	require.NoError(t, err)
+
+	var reported []int
+	out, diags := prometheusconvert.ConvertWithProgress(in, []string{}, func(converted int) {
+		reported = append(reported, converted)
+	})
+	require.Zero(t, diags.CountBySeverity()[diag.SeverityLevelError])
+
+	file, err := parser.ParseFile("scrape.river", out)
+	require.NoError(t, err)
+
+	// Progress is reported for every scrape config and once all blocks are
+	// appended, and never goes backwards.
+	require.Greater(t, len(reported), 1)
+	require.True(t, sort.IntsAreSorted(reported), "progress went backwards: %v", reported)
+	require.Equal(t, len(file.Body), reported[len(reported)-1])
+}
+
+func TestConvert_AnnotateWarnings(t *testing.T) {
+	test_common.TestDirectory(t, "testdata-annotated", ".yaml", true, []string{}, func(in []byte, extraArgs []string) ([]byte, diag.Diagnostics) {
+		out, diags := prometheusconvert.Convert(in, extraArgs)
+		annotated, err := common.AnnotateWarnings(out, diags)
+		require.NoError(t, err)
+		return annotated, diags
+	})
+}
@@ -10,14 +10,17 @@ import (
 	_ "github.com/prometheus/prometheus/discovery/install" // Register Prometheus SDs
 )
 
-func validate(promConfig *prom_config.Config) diag.Diagnostics {
+// validate validates promConfig. jobLabels maps the name of each scrape job to
+// the label of its components, and remoteWriteTarget is the ID of the
+// prometheus.remote_write block the remote_write configs are converted into.
+func validate(promConfig *prom_config.Config, jobLabels map[string]string, remoteWriteTarget string) diag.Diagnostics {
 	diags := validateGlobalConfig(&promConfig.GlobalConfig)
 	diags.AddAll(validateAlertingConfig(&promConfig.AlertingConfig))
 	diags.AddAll(validateRuleFilesConfig(promConfig.RuleFiles))
-	diags.AddAll(validateScrapeConfigs(promConfig.ScrapeConfigs))
+	diags.AddAll(validateScrapeConfigs(promConfig.ScrapeConfigs, jobLabels))
 	diags.AddAll(validateStorageConfig(&promConfig.StorageConfig))
 	diags.AddAll(validateTracingConfig(&promConfig.TracingConfig))
-	diags.AddAll(validateRemoteWriteConfigs(promConfig.RemoteWriteConfigs))
+	diags.AddAll(validateRemoteWriteConfigs(promConfig.RemoteWriteConfigs, remoteWriteTarget))
 	diags.AddAll(validateRemoteReadConfigs(promConfig.RemoteReadConfigs))
 
 	return diags
@@ -41,11 +44,11 @@ func validateRuleFilesConfig(ruleFilesConfig []string) diag.Diagnostics {
 	return common.ValidateSupported(common.Equals, len(ruleFilesConfig) > 0, true, "rule_files", "")
 }
 
-func validateScrapeConfigs(scrapeConfigs []*prom_config.ScrapeConfig) diag.Diagnostics {
+func validateScrapeConfigs(scrapeConfigs []*prom_config.ScrapeConfig, jobLabels map[string]string) diag.Diagnostics {
 	var diags diag.Diagnostics
 
 	for _, scrapeConfig := range scrapeConfigs {
-		diags.AddAll(component.ValidatePrometheusScrape(scrapeConfig))
+		diags.AddAll(component.ValidatePrometheusScrape(scrapeConfig, jobLabels[scrapeConfig.JobName]))
 		diags.AddAll(ValidateServiceDiscoveryConfigs(scrapeConfig.ServiceDiscoveryConfigs))
 	}
 	return diags
@@ -70,11 +73,11 @@ func validateTracingConfig(tracingConfig *prom_config.TracingConfig) diag.Diagno
 	return common.ValidateSupported(common.NotDeepEquals, *tracingConfig, prom_config.TracingConfig{}, "tracing", "")
 }
 
-func validateRemoteWriteConfigs(remoteWriteConfigs []*prom_config.RemoteWriteConfig) diag.Diagnostics {
+func validateRemoteWriteConfigs(remoteWriteConfigs []*prom_config.RemoteWriteConfig, target string) diag.Diagnostics {
 	var diags diag.Diagnostics
 
 	for _, remoteWriteConfig := range remoteWriteConfigs {
-		diags.AddAll(component.ValidateRemoteWriteConfig(remoteWriteConfig))
+		diags.AddAll(component.ValidateRemoteWriteConfig(remoteWriteConfig, target))
 	}
 
 	return diags
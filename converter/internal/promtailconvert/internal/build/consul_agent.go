@@ -16,8 +16,8 @@ func (s *ScrapeConfigBuilder) AppendConsulAgentSDs() {
 	}
 
 	for i, sd := range s.cfg.ServiceDiscoveryConfig.ConsulAgentSDConfigs {
-		args := toDiscoveryAgentConsul(sd, s.diags)
 		compLabel := common.LabelWithIndex(i, s.globalCtx.LabelPrefix, s.cfg.JobName)
+		args := toDiscoveryAgentConsul(sd, s.diags, "discovery.consulagent."+compLabel)
 		s.f.Body().AppendBlock(common.NewBlockWithOverride(
 			[]string{"discovery", "consulagent"},
 			compLabel,
@@ -27,16 +27,17 @@ func (s *ScrapeConfigBuilder) AppendConsulAgentSDs() {
 	}
 }
 
-func toDiscoveryAgentConsul(sdConfig *promtail_consulagent.SDConfig, diags *diag.Diagnostics) *consulagent.Arguments {
+func toDiscoveryAgentConsul(sdConfig *promtail_consulagent.SDConfig, diags *diag.Diagnostics, target string) *consulagent.Arguments {
 	if sdConfig == nil {
 		return nil
 	}
 
 	// Also unused promtail.
 	if len(sdConfig.NodeMeta) != 0 {
-		diags.Add(
+		diags.AddWithTarget(
 			diag.SeverityLevelWarn,
 			"node_meta is not used by discovery.consulagent and will be ignored",
+			target,
 		)
 	}
 
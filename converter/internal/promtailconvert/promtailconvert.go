@@ -32,20 +32,27 @@ func (c *Config) Clone() flagext.Registerer {
 	}(*c)
 }
 
+// ExtraArgs returns a flag set declaring the extra arguments accepted by
+// Convert. The Promtail converter doesn't accept any.
+func ExtraArgs() *flag.FlagSet {
+	return flag.NewFlagSet("promtail", flag.ContinueOnError)
+}
+
 // Convert implements a Promtail config converter.
 //
 // extraArgs are supported to mirror the other converter params due to shared
-// testing code but they should be passed empty to this converter.
+// testing code, but the converter doesn't accept any, so a warning is
+// returned for every extra argument passed.
 func Convert(in []byte, extraArgs []string) ([]byte, diag.Diagnostics) {
-	var (
-		diags diag.Diagnostics
-		cfg   Config
-	)
+	return ConvertWithProgress(in, extraArgs, nil)
+}
 
-	if len(extraArgs) > 0 {
-		diags.Add(diag.SeverityLevelCritical, fmt.Sprintf("extra arguments are not supported for the promtail converter: %s", extraArgs))
-		return nil, diags
-	}
+// ConvertWithProgress is like Convert, but calls progress with the number of
+// blocks converted so far after each scrape config is converted.
+func ConvertWithProgress(in []byte, extraArgs []string, progress common.ProgressFunc) ([]byte, diag.Diagnostics) {
+	var cfg Config
+
+	_, diags := common.ValidateExtraArgs("promtail", extraArgs, ExtraArgs())
 
 	// Set default values first.
 	flagSet := flag.NewFlagSet("", flag.PanicOnError)
@@ -70,7 +77,7 @@ func Convert(in []byte, extraArgs []string) ([]byte, diag.Diagnostics) {
 	}
 
 	f := builder.NewFile()
-	diags = AppendAll(f, &cfg.Config, "", diags)
+	diags = AppendAll(f, &cfg.Config, "", diags, progress)
 	diags.AddAll(common.ValidateNodes(f))
 
 	var buf bytes.Buffer
@@ -90,7 +97,9 @@ func Convert(in []byte, extraArgs []string) ([]byte, diag.Diagnostics) {
 
 // AppendAll analyzes the entire promtail config in memory and transforms it
 // into Flow components. It then appends each argument to the file builder.
-func AppendAll(f *builder.File, cfg *promtailcfg.Config, labelPrefix string, diags diag.Diagnostics) diag.Diagnostics {
+// progress, if not nil, is called with the number of blocks converted so far
+// after each scrape config.
+func AppendAll(f *builder.File, cfg *promtailcfg.Config, labelPrefix string, diags diag.Diagnostics, progress common.ProgressFunc) diag.Diagnostics {
 	validateTopLevelConfig(cfg, &diags)
 
 	var writeReceivers = make([]loki.LogsReceiver, len(cfg.ClientConfigs))
@@ -109,11 +118,13 @@ func AppendAll(f *builder.File, cfg *promtailcfg.Config, labelPrefix string, dia
 
 	for _, sc := range cfg.ScrapeConfig {
 		appendScrapeConfig(f, &sc, &diags, gc, &cfg.Global.FileWatch)
+		progress.Report(f, len(writeBlocks))
 	}
 
 	for _, write := range writeBlocks {
 		f.Body().AppendBlock(write)
 	}
+	progress.Report(f, 0)
 
 	return diags
 }
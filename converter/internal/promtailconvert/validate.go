@@ -29,9 +29,10 @@ func validateTopLevelConfig(cfg *promtailcfg.Config, diags *diag.Diagnostics) {
 	// err on the safe side.
 	//TODO(thampiotr): seems like it's possible to support this using loki.process component
 	if cfg.LimitsConfig != DefaultLimitsConfig() {
-		diags.Add(
+		diags.AddWithFollowUp(
 			diag.SeverityLevelError,
 			"limits_config is not yet supported in Flow Mode",
+			"Migrate limits_config manually, for example with a loki.process component.",
 		)
 	}
 
@@ -40,10 +41,11 @@ func validateTopLevelConfig(cfg *promtailcfg.Config, diags *diag.Diagnostics) {
 	// features of tracing are configured. We'd need to have conditionals in the
 	// flow config to translate this. See https://www.jaegertracing.io/docs/1.16/client-features/
 	if cfg.Tracing.Enabled {
-		diags.Add(
+		diags.AddWithFollowUp(
 			diag.SeverityLevelWarn,
 			"If you have a tracing set up for Promtail, it cannot be migrated to Flow Mode automatically. "+
 				"Refer to the documentation on how to configure tracing in Flow Mode.",
+			"Configure tracing in the Flow mode config.",
 		)
 	}
 
@@ -59,16 +61,18 @@ func validateTopLevelConfig(cfg *promtailcfg.Config, diags *diag.Diagnostics) {
 	}
 
 	if cfg.ServerConfig.RegisterInstrumentation {
-		diags.Add(
+		diags.AddWithFollowUp(
 			diag.SeverityLevelWarn,
 			"The Agent's Flow Mode metrics are different from the metrics emitted by Promtail. If you "+
 				"rely on Promtail's metrics, you must change your configuration, for example, your alerts and dashboards.",
+			"Update alerts and dashboards which use Promtail's metrics.",
 		)
 	}
 
 	if cfg.ServerConfig.LogLevel.String() != "info" {
-		diags.Add(diag.SeverityLevelWarn, "The converter does not support converting the provided server.log_level config: "+
-			"The equivalent feature in Flow mode is to use the logging config block to set the level argument.")
+		diags.AddWithFollowUp(diag.SeverityLevelWarn, "The converter does not support converting the provided server.log_level config: "+
+			"The equivalent feature in Flow mode is to use the logging config block to set the level argument.",
+			"Set the level argument of the logging block to the server.log_level.")
 	}
 
 	if cfg.ServerConfig.PathPrefix != "" {
@@ -73,6 +73,7 @@ func (b *IntegrationsConfigBuilder) Build() {
 	b.appendLogging(b.cfg.Server)
 	b.appendServer(b.cfg.Server)
 	b.appendIntegrations()
+	b.globalCtx.Progress.Report(b.f, 0)
 }
 
 func (b *IntegrationsConfigBuilder) appendIntegrations() {
@@ -203,7 +204,7 @@ func (b *IntegrationsConfigBuilder) appendExporter(commonConfig *int_config.Comm
 		return b.jobNameToCompLabel(jobName)
 	}
 
-	b.diags.AddAll(prometheusconvert.AppendAllNested(b.f, promConfig, jobNameToCompLabelsFunc, extraTargets, b.globalCtx.RemoteWriteExports))
+	b.diags.AddAll(prometheusconvert.AppendAllNested(b.f, promConfig, jobNameToCompLabelsFunc, extraTargets, b.globalCtx.RemoteWriteExports, b.globalCtx.Progress))
 	b.globalCtx.InitializeRemoteWriteExports()
 }
 
@@ -349,7 +350,7 @@ func (b *IntegrationsConfigBuilder) appendExporterV2(commonConfig *common_v2.Met
 	}
 
 	// Need to pass in the remote write reference from the metrics config here:
-	b.diags.AddAll(prometheusconvert.AppendAllNested(b.f, promConfig, jobNameToCompLabelsFunc, extraTargets, remoteWriteExports))
+	b.diags.AddAll(prometheusconvert.AppendAllNested(b.f, promConfig, jobNameToCompLabelsFunc, extraTargets, remoteWriteExports, b.globalCtx.Progress))
 }
 
 func splitByCommaNullOnEmpty(s string) []string {
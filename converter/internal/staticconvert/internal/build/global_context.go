@@ -8,6 +8,7 @@ import (
 type GlobalContext struct {
 	LabelPrefix        string
 	RemoteWriteExports *remotewrite.Exports
+	Progress           common.ProgressFunc // Called as blocks are converted, if not nil.
 }
 
 func (g *GlobalContext) InitializeRemoteWriteExports() {
@@ -23,12 +23,33 @@ import (
 	_ "github.com/grafana/agent/pkg/integrations/install" // Install integrations
 )
 
+// ExtraArgs returns a flag set declaring the extra arguments accepted by
+// Convert: the command-line flags of the static mode agent which affect its
+// config, such as -enable-features. Flags which only affect how the agent
+// runs, such as -version, aren't accepted.
+func ExtraArgs() *flag.FlagSet {
+	fs := flag.NewFlagSet("static", flag.ContinueOnError)
+	cfg := config.DefaultConfig()
+	cfg.RegisterFlags(fs)
+	fs.Bool("config.expand-env", true, "Expands ${var} in config according to the values of the environment variables.")
+	fs.String("enable-features", "", "Comma-delimited list of features to enable.")
+	return fs
+}
+
 // Convert implements a Static config converter.
 //
 // extraArgs are supported to be passed along to the Static config parser such
-// as enabling integrations-next.
+// as enabling integrations-next. Extra arguments which aren't declared by
+// ExtraArgs are ignored with a warning.
 func Convert(in []byte, extraArgs []string) ([]byte, diag.Diagnostics) {
-	var diags diag.Diagnostics
+	return ConvertWithProgress(in, extraArgs, nil)
+}
+
+// ConvertWithProgress is like Convert, but calls progress with the number of
+// blocks converted so far after each scrape config and integration is
+// converted.
+func ConvertWithProgress(in []byte, extraArgs []string, progress common.ProgressFunc) ([]byte, diag.Diagnostics) {
+	extraArgs, diags := common.ValidateExtraArgs("static", extraArgs, ExtraArgs())
 
 	fs := flag.NewFlagSet("convert", flag.ContinueOnError)
 	args := []string{"-config.file", "convert", "-config.expand-env"}
@@ -43,7 +64,7 @@ func Convert(in []byte, extraArgs []string) ([]byte, diag.Diagnostics) {
 	}
 
 	f := builder.NewFile()
-	diags = AppendAll(f, staticConfig)
+	diags.AddAll(AppendAll(f, staticConfig, progress))
 	diags.AddAll(common.ValidateNodes(f))
 
 	var buf bytes.Buffer
@@ -64,13 +85,13 @@ func Convert(in []byte, extraArgs []string) ([]byte, diag.Diagnostics) {
 // AppendAll analyzes the entire static config in memory and transforms it
 // into Flow Arguments. It then appends each argument to the file builder.
 // Exports from other components are correctly referenced to build the Flow
-// pipeline.
-func AppendAll(f *builder.File, staticConfig *config.Config) diag.Diagnostics {
+// pipeline. progress, if not nil, is called as blocks are converted.
+func AppendAll(f *builder.File, staticConfig *config.Config, progress common.ProgressFunc) diag.Diagnostics {
 	var diags diag.Diagnostics
 
-	diags.AddAll(appendStaticPrometheus(f, staticConfig))
-	diags.AddAll(appendStaticPromtail(f, staticConfig))
-	diags.AddAll(appendStaticIntegrations(f, staticConfig))
+	diags.AddAll(appendStaticPrometheus(f, staticConfig, progress))
+	diags.AddAll(appendStaticPromtail(f, staticConfig, progress))
+	diags.AddAll(appendStaticIntegrations(f, staticConfig, progress))
 	// TODO otel
 
 	diags.AddAll(validate(staticConfig))
@@ -78,7 +99,7 @@ func AppendAll(f *builder.File, staticConfig *config.Config) diag.Diagnostics {
 	return diags
 }
 
-func appendStaticPrometheus(f *builder.File, staticConfig *config.Config) diag.Diagnostics {
+func appendStaticPrometheus(f *builder.File, staticConfig *config.Config, progress common.ProgressFunc) diag.Diagnostics {
 	var diags diag.Diagnostics
 	for _, instance := range staticConfig.Metrics.Configs {
 		promConfig := &prom_config.Config{
@@ -111,13 +132,13 @@ func appendStaticPrometheus(f *builder.File, staticConfig *config.Config) diag.D
 		//   scrape config job_name = "test_prometheus"
 		//
 		//   results in two prometheus.scrape components with the label "metrics_agent_test_prometheus"
-		diags.AddAll(prometheusconvert.AppendAllNested(f, promConfig, jobNameToCompLabelsFunc, []discovery.Target{}, nil))
+		diags.AddAll(prometheusconvert.AppendAllNested(f, promConfig, jobNameToCompLabelsFunc, []discovery.Target{}, nil, progress))
 	}
 
 	return diags
 }
 
-func appendStaticPromtail(f *builder.File, staticConfig *config.Config) diag.Diagnostics {
+func appendStaticPromtail(f *builder.File, staticConfig *config.Config, progress common.ProgressFunc) diag.Diagnostics {
 	var diags diag.Diagnostics
 
 	if staticConfig.Logs == nil {
@@ -158,16 +179,16 @@ func appendStaticPromtail(f *builder.File, staticConfig *config.Config) diag.Dia
 		//   scrape config job_name = "test_promtail"
 		//
 		//   results in two prometheus.scrape components with the label "logs_agent_test_promtail"
-		diags = promtailconvert.AppendAll(f, &promtailConfig, "logs_"+logConfig.Name, diags)
+		diags = promtailconvert.AppendAll(f, &promtailConfig, "logs_"+logConfig.Name, diags, progress)
 	}
 
 	return diags
 }
 
-func appendStaticIntegrations(f *builder.File, staticConfig *config.Config) diag.Diagnostics {
+func appendStaticIntegrations(f *builder.File, staticConfig *config.Config, progress common.ProgressFunc) diag.Diagnostics {
 	var diags diag.Diagnostics
 
-	b := build.NewIntegrationsConfigBuilder(f, &diags, staticConfig, &build.GlobalContext{LabelPrefix: "integrations"})
+	b := build.NewIntegrationsConfigBuilder(f, &diags, staticConfig, &build.GlobalContext{LabelPrefix: "integrations", Progress: progress})
 	b.Build()
 
 	return diags
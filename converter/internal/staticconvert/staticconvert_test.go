@@ -4,9 +4,11 @@ import (
 	"runtime"
 	"testing"
 
+	"github.com/grafana/agent/converter/diag"
 	"github.com/grafana/agent/converter/internal/staticconvert"
 	"github.com/grafana/agent/converter/internal/test_common"
 	_ "github.com/grafana/agent/pkg/metrics/instance" // Imported to override default values via the init function.
+	"github.com/stretchr/testify/require"
 )
 
 func TestConvert(t *testing.T) {
@@ -18,3 +20,17 @@ func TestConvert(t *testing.T) {
 		test_common.TestDirectory(t, "testdata-v2_windows", ".yaml", true, []string{"-enable-features", "integrations-next"}, staticconvert.Convert)
 	}
 }
+
+func TestConvert_ExtraArgs(t *testing.T) {
+	in := []byte(`
+metrics:
+  global:
+    scrape_interval: 60s
+`)
+	_, diags := staticconvert.Convert(in, []string{"-version", "-enable-features"})
+	require.GreaterOrEqual(t, len(diags), 2)
+	require.Equal(t, diag.Diagnostics{
+		{Severity: diag.SeverityLevelWarn, Summary: "unknown extra argument -version for the static converter is ignored"},
+		{Severity: diag.SeverityLevelError, Summary: "extra argument -enable-features for the static converter needs a value"},
+	}, diags[:2])
+}
@@ -68,7 +68,8 @@ func validate(staticConfig *config.Config) diag.Diagnostics {
 func validateCommandLine() diag.Diagnostics {
 	var diags diag.Diagnostics
 
-	diags.Add(diag.SeverityLevelWarn, "Please review your agent command line flags and ensure they are set in your Flow mode config file where necessary.")
+	diags.AddWithFollowUp(diag.SeverityLevelWarn, "Please review your agent command line flags and ensure they are set in your Flow mode config file where necessary.",
+		"Review the agent command line flags and set them in the Flow mode config file where necessary.")
 
 	return diags
 }
@@ -100,7 +101,8 @@ func validateMetrics(metricsConfig metrics.Config, grpcListenPort int) diag.Diag
 	diags.AddAll(common.ValidateSupported(common.NotEquals, metricsConfig.IdleConnTimeout, defaultMetrics.IdleConnTimeout, "http_idle_conn_timeout metrics", ""))
 
 	if metricsConfig.WALDir != defaultMetrics.WALDir {
-		diags.Add(diag.SeverityLevelWarn, "The converter does not support converting the provided metrics wal_directory config: Use the run command flag --storage.path for Flow mode instead.")
+		diags.AddWithFollowUp(diag.SeverityLevelWarn, "The converter does not support converting the provided metrics wal_directory config: Use the run command flag --storage.path for Flow mode instead.",
+			"Pass the metrics wal_directory to the run command as the --storage.path flag.")
 	}
 
 	return diags
@@ -154,7 +156,7 @@ func validateIntegrationsV1(integrationsConfig *v1.ManagerConfig) diag.Diagnosti
 		case *azure_exporter.Config:
 		case *cadvisor.Config:
 		default:
-			diags.Add(diag.SeverityLevelError, fmt.Sprintf("The converter does not support converting the provided %s integration.", itg.Name()))
+			diags.AddAll(unsupportedIntegration(itg.Name()))
 		}
 	}
 
@@ -198,10 +200,10 @@ func validateIntegrationsV2(integrationsConfig *v2.SubsystemOptions) diag.Diagno
 			case *statsd_exporter.Config:
 			case *windows_exporter.Config:
 			default:
-				diags.Add(diag.SeverityLevelError, fmt.Sprintf("The converter does not support converting the provided %s integration.", v1_itg.Name()))
+				diags.AddAll(unsupportedIntegration(v1_itg.Name()))
 			}
 		default:
-			diags.Add(diag.SeverityLevelError, fmt.Sprintf("The converter does not support converting the provided %s integration.", itg.Name()))
+			diags.AddAll(unsupportedIntegration(itg.Name()))
 		}
 	}
 
@@ -231,3 +233,12 @@ func validateAgentManagement(agentManagementConfig config.AgentManagementConfig)
 
 	return diags
 }
+
+// unsupportedIntegration returns an error for the integration name, which the
+// converter can't convert.
+func unsupportedIntegration(name string) diag.Diagnostics {
+	var diags diag.Diagnostics
+	diags.AddWithFollowUp(diag.SeverityLevelError, fmt.Sprintf("The converter does not support converting the provided %s integration.", name),
+		fmt.Sprintf("Replace the %s integration, which has no Flow mode equivalent in the converter.", name))
+	return diags
+}
@@ -0,0 +1,95 @@
+package flow
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/grafana/agent/pkg/flow/internal/controller"
+	"github.com/grafana/agent/pkg/flow/logging/level"
+)
+
+// exportsSnapshotFile is the name of the file in DataPath which holds the
+// exports snapshot when Options.SnapshotExports is set.
+const exportsSnapshotFile = "exports-snapshot.river"
+
+func (f *Flow) exportsSnapshotPath() string {
+	return filepath.Join(f.opts.DataPath, exportsSnapshotFile)
+}
+
+// SaveState writes the current exports of components to w, so they can be
+// restored by LoadState after a restart. The state is encoded as a River file
+// holding one block per component, in the same format as the snapshot
+// written by Options.SnapshotExports.
+//
+// Exports which can't be restored, such as exports holding secrets, are left
+// out of the state.
+func (f *Flow) SaveState(w io.Writer) error {
+	_, err := w.Write(controller.EncodeExportsSnapshot(f.loader.Components()))
+	return err
+}
+
+// LoadState reads state written by SaveState from r. The next call to
+// LoadSource seeds the exports of components it creates from the state, so
+// their dependants are evaluated with the last known exports instead of
+// waiting for every component to report fresh ones. Components always
+// replace restored exports once they report their own.
+//
+// LoadState must be called before the first call to LoadSource, since
+// components which already exist aren't seeded. State entries which no
+// longer match the exports of a component are discarded.
+func (f *Flow) LoadState(r io.Reader) error {
+	bb, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if err := f.loader.RestoreExports(bb); err != nil {
+		return fmt.Errorf("invalid state: %w", err)
+	}
+	return nil
+}
+
+// restoreExportsSnapshot loads the exports snapshot from DataPath, if one
+// exists, so its exports are restored by the first call to LoadSource.
+// Snapshots which can't be read are ignored.
+func (f *Flow) restoreExportsSnapshot() {
+	bb, err := os.ReadFile(f.exportsSnapshotPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	} else if err != nil {
+		level.Warn(f.log).Log("msg", "failed to read exports snapshot", "err", err)
+		return
+	}
+
+	if err := f.loader.RestoreExports(bb); err != nil {
+		level.Warn(f.log).Log("msg", "discarding invalid exports snapshot", "err", err)
+	}
+}
+
+// writeExportsSnapshot writes the current exports of components to the
+// exports snapshot in DataPath.
+func (f *Flow) writeExportsSnapshot() {
+	var buf bytes.Buffer
+	_ = f.SaveState(&buf)
+
+	// Write to a temporary file first so a failed write never leaves a
+	// truncated snapshot behind.
+	path := f.exportsSnapshotPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		level.Error(f.log).Log("msg", "failed to write exports snapshot", "err", err)
+		return
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, buf.Bytes(), 0640); err != nil {
+		level.Error(f.log).Log("msg", "failed to write exports snapshot", "err", err)
+		return
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		level.Error(f.log).Log("msg", "failed to write exports snapshot", "err", err)
+		return
+	}
+	level.Info(f.log).Log("msg", "wrote exports snapshot", "path", path)
+}
@@ -0,0 +1,146 @@
+package flow
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/pkg/flow/internal/testcomponents"
+	"github.com/stretchr/testify/require"
+)
+
+func TestController_SnapshotExports(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+
+	config := `
+		testcomponents.count "counter" {
+			frequency = "%s"
+			max       = 3
+		}
+
+		testcomponents.summation "sum" {
+			input = testcomponents.count.counter.count
+		}
+	`
+
+	opts := testOptions(t)
+	opts.SnapshotExports = true
+
+	// Run a controller until the counter reaches its max, then stop it to
+	// write the snapshot.
+	ctrl := New(opts)
+	f, err := ParseSource(t.Name(), []byte(fmt.Sprintf(config, "10ms")))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		ctrl.Run(ctx)
+		close(done)
+	}()
+	require.Eventually(t, func() bool {
+		_, exports := getFields(t, ctrl.loader.Graph(), "testcomponents.summation.sum")
+		return exports.(testcomponents.SummationExports).LastAdded == 3
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+
+	require.FileExists(t, filepath.Join(opts.DataPath, exportsSnapshotFile))
+
+	t.Run("Restores exports", func(t *testing.T) {
+		// The counter doesn't report exports until its first tick, so the
+		// exports seen by the summation come from the snapshot.
+		ctrl := New(opts)
+		defer cleanUpController(ctrl)
+
+		f, err := ParseSource(t.Name(), []byte(fmt.Sprintf(config, "1h")))
+		require.NoError(t, err)
+		require.NoError(t, ctrl.LoadSource(f, nil))
+
+		_, exports := getFields(t, ctrl.loader.Graph(), "testcomponents.count.counter")
+		require.Equal(t, testcomponents.CountExports{Count: 3}, exports)
+
+		sum, err := ctrl.GetComponent(component.ID{LocalID: "testcomponents.summation.sum"}, component.InfoOptions{GetExports: true})
+		require.NoError(t, err)
+		require.Equal(t, 3, sum.Exports.(testcomponents.SummationExports).LastAdded)
+	})
+
+	t.Run("Discards incompatible snapshots", func(t *testing.T) {
+		snapshot := `
+			testcomponents.count "counter" {
+				count   = 3
+				unknown = true
+			}
+		`
+		require.NoError(t, os.WriteFile(filepath.Join(opts.DataPath, exportsSnapshotFile), []byte(snapshot), 0640))
+
+		ctrl := New(opts)
+		defer cleanUpController(ctrl)
+
+		f, err := ParseSource(t.Name(), []byte(fmt.Sprintf(config, "1h")))
+		require.NoError(t, err)
+		require.NoError(t, ctrl.LoadSource(f, nil))
+
+		_, exports := getFields(t, ctrl.loader.Graph(), "testcomponents.count.counter")
+		require.Equal(t, testcomponents.CountExports{}, exports)
+	})
+}
+
+func TestController_SaveState(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+
+	config := `
+		testcomponents.count "counter" {
+			frequency = "%s"
+			max       = 3
+		}
+
+		testcomponents.summation "sum" {
+			input = testcomponents.count.counter.count
+		}
+	`
+
+	ctrl := New(testOptions(t))
+	f, err := ParseSource(t.Name(), []byte(fmt.Sprintf(config, "10ms")))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		ctrl.Run(ctx)
+		close(done)
+	}()
+	require.Eventually(t, func() bool {
+		_, exports := getFields(t, ctrl.loader.Graph(), "testcomponents.summation.sum")
+		return exports.(testcomponents.SummationExports).LastAdded == 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	var state bytes.Buffer
+	require.NoError(t, ctrl.SaveState(&state))
+	cancel()
+	<-done
+
+	// The counter doesn't report exports until its first tick, so the
+	// exports seen by the summation come from the state.
+	restored := New(testOptions(t))
+	defer cleanUpController(restored)
+	require.NoError(t, restored.LoadState(&state))
+
+	f, err = ParseSource(t.Name(), []byte(fmt.Sprintf(config, "1h")))
+	require.NoError(t, err)
+	require.NoError(t, restored.LoadSource(f, nil))
+
+	sum, err := restored.GetComponent(component.ID{LocalID: "testcomponents.summation.sum"}, component.InfoOptions{GetExports: true})
+	require.NoError(t, err)
+	require.Equal(t, 3, sum.Exports.(testcomponents.SummationExports).LastAdded)
+
+	require.ErrorContains(t, restored.LoadState(strings.NewReader("not river")), "invalid state")
+}
@@ -47,16 +47,27 @@ package flow
 
 import (
 	"context"
+	"crypto/sha256"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
 	"sync"
+	"time"
 
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/grafana/agent/component"
 	"github.com/grafana/agent/pkg/flow/internal/controller"
+	"github.com/grafana/agent/pkg/flow/internal/stdlib"
+	"github.com/grafana/agent/pkg/flow/internal/stepper"
 	"github.com/grafana/agent/pkg/flow/internal/worker"
 	"github.com/grafana/agent/pkg/flow/logging"
 	"github.com/grafana/agent/pkg/flow/logging/level"
 	"github.com/grafana/agent/pkg/flow/tracing"
 	"github.com/grafana/agent/service"
+	"github.com/grafana/river/diag"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tilinna/clock"
 	"go.uber.org/atomic"
 )
 
@@ -95,6 +106,203 @@ type Options struct {
 	// loaded config source.
 	OnExportsChange func(exports map[string]any)
 
+	// ReloadWebhook is an optional URL which receives a POST request with a
+	// JSON-encoded [LoadSummary] after each successful call to LoadSource.
+	// Requests are retried on failure; failing to notify the webhook doesn't
+	// affect the controller.
+	//
+	// ReloadWebhook is ignored for module controllers.
+	ReloadWebhook string
+
+	// HTTPClient optionally holds the HTTP client used for every HTTP request
+	// made by the controller, such as to notify ReloadWebhook or to read Git
+	// repositories served over HTTP with [Flow.ReadGitSource], so proxies,
+	// timeouts, and CA bundles can be configured in one place. It's passed to
+	// modules and to components, which use it unless their arguments
+	// configure their own client. Defaults are used when HTTPClient is nil.
+	HTTPClient *http.Client
+
+	// GitAuth optionally holds the credentials used by [Flow.ReadGitSource]
+	// for every request to a Git repository. Requests aren't authenticated
+	// when GitAuth is nil.
+	GitAuth transport.AuthMethod
+
+	// Resolver optionally holds the DNS resolver used by the dns_lookup and
+	// srv_lookup functions of loaded configs, including configs of modules.
+	// The default resolver is used when Resolver is nil.
+	Resolver *net.Resolver
+
+	// BestEffort allows the first call to LoadSource to start the controller
+	// even if some components failed to evaluate. Components which failed to
+	// evaluate are reported as unhealthy and aren't run until a later load
+	// evaluates them successfully.
+	//
+	// LoadSource always fails when the config can't be loaded at all, such as
+	// when it references a component which doesn't exist.
+	BestEffort bool
+
+	// AllowedComponents optionally restricts the components which may be used
+	// in loaded configs, including configs of modules. Configs which use any
+	// other component fail to load. If empty, all components are allowed
+	// unless they are denied by DeniedComponents.
+	//
+	// Entries are either component names, such as "prometheus.scrape", or a
+	// prefix followed by a wildcard, such as "prometheus.*".
+	AllowedComponents []string
+
+	// DeniedComponents lists components which may not be used in loaded
+	// configs, including configs of modules. Entries use the same format as
+	// AllowedComponents. DeniedComponents takes precedence over
+	// AllowedComponents.
+	DeniedComponents []string
+
+	// StrictReferences makes LoadSource fail if any component isn't referenced
+	// by another block, rather than logging a warning. Components registered
+	// as sinks, which aren't expected to be referenced, are always allowed.
+	// Configs which fail this check aren't evaluated.
+	//
+	// StrictReferences is ignored for module controllers.
+	StrictReferences bool
+
+	// StrictShadowing makes LoadSource fail if the namespace of a component,
+	// such as "concat" in a component named "concat.example", is also the name
+	// of a standard library function or custom function, rather than
+	// reporting a warning. Expressions using the shadowed name are ambiguous,
+	// since they may resolve to either the component or the function.
+	StrictShadowing bool
+
+	// SnapshotExports enables persisting the exports of components across
+	// restarts. When set, the exports of components are written to a snapshot
+	// in DataPath when Run exits, and restored into components created by the
+	// first call to LoadSource, so that their dependants don't have to wait for
+	// them to report fresh exports. Components always replace restored exports
+	// once they report their own.
+	//
+	// Exports which can't be restored, such as exports holding secrets, are
+	// left out of the snapshot. Snapshot entries which no longer match the
+	// exports of a component are discarded.
+	//
+	// SnapshotExports is ignored for module controllers.
+	SnapshotExports bool
+
+	// Functions optionally holds custom functions which can be called from
+	// loaded configs, including configs of modules. The same FunctionRegistry
+	// may be shared between multiple controllers. Each controller extends it
+	// with its own registry, returned by [Flow.Functions], for functions which
+	// only apply to that controller.
+	Functions *FunctionRegistry
+
+	// ConfigDir optionally holds the absolute directory of the loaded config
+	// file, which loaded configs can reference as config_dir to build paths
+	// relative to the config, such as config_dir + "/certs/ca.pem". Modules
+	// use the ConfigDir of the controller which loaded them. config_dir isn't
+	// defined when ConfigDir is empty.
+	ConfigDir string
+
+	// ErrorHistorySize is the number of recent errors kept for each component,
+	// which are returned by [Flow.ErrorHistory] and the component API. Older
+	// errors are discarded once a component reports more than
+	// ErrorHistorySize errors. Defaults to 10 when zero. Errors aren't kept
+	// when ErrorHistorySize is negative.
+	ErrorHistorySize int
+
+	// EventLogSize is the number of recent events kept in memory, such as
+	// loads of configs and failures of components, which are returned by
+	// [Flow.Events] and [EventsHandler]. Events of modules are kept in the
+	// event log of the root controller. Older events are discarded once more
+	// than EventLogSize events are recorded. Defaults to DefaultEventLogSize
+	// when zero. Events aren't kept when EventLogSize is negative.
+	//
+	// EventLogSize is ignored for module controllers.
+	EventLogSize int
+
+	// Singletons optionally shares components registered as singletons with
+	// other controllers using the same SingletonRegistry, including the
+	// modules of each controller. When nil, every controller builds its own
+	// singleton components.
+	Singletons *SingletonRegistry
+
+	// CircuitBreaker optionally quarantines components which keep failing,
+	// including components of modules. When enabled, panics of components are
+	// recovered and failed components are built again and restarted after a
+	// growing delay, until a component fails CircuitBreaker.MaxFailures times
+	// within CircuitBreaker.Window. The
+	// component is then reported as exited with the reason, and isn't
+	// restarted until [Flow.ResetCircuitBreaker] is called.
+	//
+	// Only panics in the goroutine running a component are recovered; panics
+	// in goroutines started by components still crash the process.
+	CircuitBreaker CircuitBreakerOptions
+
+	// MinReloadInterval is the minimum time between reloads applied by
+	// [Flow.Reload], including configs received by [Flow.RunWithConfigSource].
+	// Reloads requested sooner wait until the interval elapses. Reloads
+	// requested while waiting are coalesced: only the most recent source is
+	// applied, and every coalesced caller receives its result. Coalesced
+	// reloads are counted by the
+	// agent_component_controller_coalesced_reloads_total metric. Reloads
+	// still waiting when Run exits aren't applied, and fail with an error.
+	//
+	// Reloads are applied immediately when MinReloadInterval is zero.
+	// MinReloadInterval doesn't apply to LoadSource, and is ignored for module
+	// controllers.
+	MinReloadInterval time.Duration
+
+	// Clock optionally holds the clock used to time the controller and its
+	// modules: delayed reloads, periodic health and running checks, and the
+	// timestamps of events and load summaries. Tests can use a clock.Mock to
+	// control when reloads are applied and checks run. The real-time clock is
+	// used when Clock is nil.
+	Clock clock.Clock
+
+	// Parallelism is the maximum number of components of the same dependency
+	// level evaluated concurrently when a config is loaded, including configs
+	// of modules. Components in the same level don't depend on each other, so
+	// configs with many independent components load faster. Parallelism also
+	// sets the number of workers which evaluate components when their
+	// dependencies change while running.
+	//
+	// Components are evaluated one at a time when loading a config if
+	// Parallelism is zero or one, and the number of workers defaults to the
+	// number of CPUs if Parallelism is zero.
+	Parallelism int
+
+	// GraphReduction controls which loads perform a transitive reduction of
+	// the graph, including loads of modules. Reduction removes edges to
+	// dependencies which are also reachable through other dependencies, so
+	// updates propagate along fewer edges, but its cost grows with the number
+	// of nodes times the number of edges. Skipping it makes loads of large
+	// graphs faster at the expense of a denser graph; the order of evaluation
+	// is the same either way, but a component may be evaluated once for each
+	// path to an updated dependency. Every load reduces the graph by default.
+	//
+	// As measured by BenchmarkReduce in the dag package, reducing a chain of
+	// components which each depend on the previous four takes about 3ms for
+	// 100 components, 65ms for 500 components, and 270ms for 1000 components.
+	//
+	// The time spent reducing the graph is reported by [Flow.Stats] and by the
+	// "reduce" phase of the agent_component_controller_load_phase_seconds
+	// metric.
+	GraphReduction GraphReduction
+
+	// Stepper optionally takes over propagating component updates, so tests
+	// can step propagation deterministically with [stepper.Stepper.Step]
+	// instead of Run propagating updates in the background. The stepper
+	// package is internal, so Stepper can only be set by tests and test
+	// helpers such as flowtest.
+	//
+	// Stepper is ignored for module controllers.
+	Stepper *stepper.Stepper
+
+	// GraphRenderers optionally holds renderers for custom formats served by
+	// [GraphHandler], keyed by the name of the format passed in the "format"
+	// query parameter. A renderer named dot replaces GraphvizRenderer, the
+	// default renderer; the built-in graphml format takes precedence over a
+	// renderer with the same name.
+	//
+	// GraphRenderers is ignored for module controllers.
+	GraphRenderers map[string]GraphRenderer
+
 	// List of Services to run with the Flow controller.
 	//
 	// Services are configured when LoadFile is invoked. Services are started
@@ -102,6 +310,37 @@ type Options struct {
 	Services []service.Service
 }
 
+// GraphReduction controls which loads perform a transitive reduction of the
+// graph. See [Options.GraphReduction] for more information.
+type GraphReduction int
+
+const (
+	// GraphReductionAlways reduces the graph on every load.
+	GraphReductionAlways GraphReduction = iota
+
+	// GraphReductionFirstLoad only reduces the graph until a load succeeds
+	// for the first time, so the one-time initial load produces a reduced
+	// graph while later reloads are faster. Graphs of reloads keep every
+	// edge.
+	GraphReductionFirstLoad
+
+	// GraphReductionNever never reduces the graph.
+	GraphReductionNever
+)
+
+// reduce returns whether a load reduces its graph, given whether a load
+// already succeeded.
+func (r GraphReduction) reduce(loadedOnce bool) bool {
+	switch r {
+	case GraphReductionFirstLoad:
+		return !loadedOnce
+	case GraphReductionNever:
+		return false
+	default:
+		return true
+	}
+}
+
 // Flow is the Flow system.
 type Flow struct {
 	log    *logging.Logger
@@ -114,9 +353,38 @@ type Flow struct {
 	modules     *moduleRegistry
 
 	loadFinished chan struct{}
-
-	loadMut    sync.RWMutex
-	loadedOnce atomic.Bool
+	notifier     *reloadNotifier   // Set when a reload webhook is configured.
+	reloads      *reloadGuard      // Set when a minimum reload interval is configured.
+	functions    *FunctionRegistry // Extends Options.Functions.
+	lookups      *stdlib.Lookups   // Resolves dns_lookup and srv_lookup with Options.Resolver; nil if unset.
+	git          *gitConfigFetcher // Reads Git sources for ReadGitSource.
+	events       *eventLog         // Shared with modules.
+	clock        clock.Clock       // Options.Clock, or the real-time clock.
+
+	paused   atomic.Bool
+	resumeCh chan struct{}
+
+	// applyMut serializes calls to LoadSource. loadMut guards the results of
+	// the most recent load, and is only held while they're replaced, so
+	// readers aren't blocked while the loader evaluates the graph. Both are
+	// held when writing the fields below, so LoadSource may read them while
+	// only holding applyMut.
+	applyMut       sync.Mutex
+	loadMut        sync.RWMutex
+	loadedOnce     atomic.Bool
+	loaded         chan struct{}    // Closed when loadedOnce is set.
+	loadGeneration atomic.Uint64    // Incremented on every call to LoadSource.
+	lastSource     *Source          // Source passed to the most recent call to LoadSource.
+	lastDiags      diag.Diagnostics // Diagnostics from the most recent call to LoadSource.
+	lastSummary    *LoadSummary     // Summary of the most recent successful call to LoadSource.
+
+	// Checksum of the source and version of the functions from the last
+	// successful call to LoadSource, used to skip loading an unchanged source.
+	loadedHash      [sha256.Size]byte
+	loadedFunctions uint64
+	loadedHashValid bool
+
+	graphCache graphCache
 }
 
 // New creates a new, unstarted Flow controller. Call Run to run the controller.
@@ -125,10 +393,19 @@ func New(o Options) *Flow {
 		Options:        o,
 		ModuleRegistry: newModuleRegistry(),
 		IsModule:       false, // We are creating a new root controller.
-		WorkerPool:     worker.NewDefaultWorkerPool(),
+		WorkerPool:     newWorkerPool(o.Parallelism),
 	})
 }
 
+// newWorkerPool returns the worker pool of a root controller with the given
+// [Options.Parallelism].
+func newWorkerPool(parallelism int) worker.Pool {
+	if parallelism > 0 {
+		return worker.NewFixedWorkerPool(parallelism, 1024)
+	}
+	return worker.NewDefaultWorkerPool()
+}
+
 // controllerOptions are internal options used to create both root Flow
 // controller and controllers for modules.
 type controllerOptions struct {
@@ -137,6 +414,7 @@ type controllerOptions struct {
 	ComponentRegistry controller.ComponentRegistry // Custom component registry used in tests.
 	ModuleRegistry    *moduleRegistry              // Where to register created modules.
 	IsModule          bool                         // Whether this controller is for a module.
+	EventLog          *eventLog                    // Event log shared with the parent controller; created from EventLogSize if nil.
 	// A worker pool to evaluate components asynchronously. A default one will be created if this is nil.
 	WorkerPool worker.Pool
 }
@@ -176,6 +454,27 @@ func newController(o controllerOptions) *Flow {
 		modules: o.ModuleRegistry,
 
 		loadFinished: make(chan struct{}, 1),
+		loaded:       make(chan struct{}),
+		resumeCh:     make(chan struct{}, 1),
+		functions:    o.Functions.Extend(),
+		events:       o.EventLog,
+		git:          newGitConfigFetcher(o.GitAuth, o.HTTPClient),
+		clock:        o.Clock,
+	}
+
+	if f.clock == nil {
+		f.clock = clock.Realtime()
+	}
+
+	if f.events == nil {
+		f.events = newEventLog(o.EventLogSize)
+	}
+
+	if o.Resolver != nil {
+		f.lookups = stdlib.NewLookups(o.Resolver)
+	}
+	if o.ReloadWebhook != "" && !o.IsModule {
+		f.notifier = newReloadNotifier(log, o.ReloadWebhook, o.HTTPClient)
 	}
 
 	serviceMap := controller.NewServiceMap(o.Services)
@@ -188,6 +487,13 @@ func newController(o controllerOptions) *Flow {
 			OnComponentUpdate: func(cn *controller.ComponentNode) {
 				// Changed components should be queued for reevaluation.
 				f.updateQueue.Enqueue(cn)
+				f.recordEvent(EventTypeUpdate, cn.GlobalID(), "component updated its exports")
+			},
+			OnComponentError: func(cn *controller.ComponentNode, err error) {
+				f.recordEvent(EventTypeError, cn.GlobalID(), err.Error())
+			},
+			OnComponentRestart: func(cn *controller.ComponentNode, reason string) {
+				f.recordEvent(EventTypeRestart, cn.GlobalID(), reason)
 			},
 			OnExportsChange: o.OnExportsChange,
 			Registerer:      o.Reg,
@@ -203,6 +509,20 @@ func newController(o controllerOptions) *Flow {
 					ID:                id,
 					ServiceMap:        serviceMap,
 					WorkerPool:        workerPool,
+					AllowedComponents: o.AllowedComponents,
+					DeniedComponents:  o.DeniedComponents,
+					Functions:         f.functions,
+					ConfigDir:         o.ConfigDir,
+					Parallelism:       o.Parallelism,
+					GraphReduction:    o.GraphReduction,
+					ErrorHistorySize:  o.ErrorHistorySize,
+					StrictShadowing:   o.StrictShadowing,
+					Singletons:        o.Singletons,
+					CircuitBreaker:    o.CircuitBreaker,
+					HTTPClient:        o.HTTPClient,
+					Resolver:          o.Resolver,
+					Clock:             f.clock,
+					EventLog:          f.events,
 				})
 			},
 			GetServiceData: func(name string) (interface{}, error) {
@@ -212,91 +532,487 @@ func newController(o controllerOptions) *Flow {
 				}
 				return svc.Data(), nil
 			},
+			ErrorHistorySize: o.ErrorHistorySize,
+			Singletons:       o.Singletons.registry(),
+			CircuitBreaker:   controller.CircuitBreakerConfig(o.CircuitBreaker),
+			HTTPClient:       o.HTTPClient,
 		},
 
 		Services:          o.Services,
 		Host:              f,
 		ComponentRegistry: o.ComponentRegistry,
-		WorkerPool:        workerPool,
+		ComponentPolicy: controller.ComponentPolicy{
+			Allowed: o.AllowedComponents,
+			Denied:  o.DeniedComponents,
+		},
+		StrictReferences: o.StrictReferences && !o.IsModule,
+		StrictShadowing:  o.StrictShadowing,
+		Functions:        f.identifiers,
+		Lookups:          f.lookups,
+		WorkerPool:       workerPool,
+		Parallelism:      o.Parallelism,
+		ReduceGraph: func() bool {
+			return o.GraphReduction.reduce(f.loadedOnce.Load())
+		},
 	})
 
+	if o.MinReloadInterval > 0 && !o.IsModule {
+		f.reloads = newReloadGuard(o.MinReloadInterval, f.clock, func(ctx context.Context, source *Source, trigger ReloadTrigger) (bool, error) {
+			return f.loadSource(ctx, source, nil, trigger)
+		}, f.loader.ObserveCoalescedReload)
+	}
+
+	if o.SnapshotExports && !o.IsModule {
+		f.restoreExportsSnapshot()
+	}
+
 	return f
 }
 
+// Functions returns the registry of custom functions which can be called
+// from configs loaded by f. It extends [Options.Functions], so functions
+// registered to it are only available to f and the modules it runs.
+func (f *Flow) Functions() *FunctionRegistry {
+	return f.functions
+}
+
+// healthCheckInterval is how often Run checks the health of components for
+// changes which weren't reported to the controller.
+const healthCheckInterval = 5 * time.Second
+
 // Run starts the Flow controller, blocking until the provided context is
 // canceled. Run must only be called once.
 func (f *Flow) Run(ctx context.Context) {
-	defer func() { _ = f.sched.Close() }()
+	// Components are stopped before the loader is cleaned up, so singleton
+	// components shared with other controllers are only released once this
+	// controller stopped running them.
 	defer f.loader.Cleanup(!f.opts.IsModule)
+	defer func() { _ = f.sched.Close() }()
 	defer level.Debug(f.log).Log("msg", "flow controller exiting")
+	if f.notifier != nil {
+		defer f.notifier.Stop()
+	}
+	if f.opts.SnapshotExports && !f.opts.IsModule {
+		defer f.writeExportsSnapshot()
+	}
+	if f.reloads != nil {
+		// Run first on shutdown, so deferred reloads don't outlive the
+		// controller.
+		defer f.reloads.stop()
+	}
+
+	// Components may report their own health at any time without informing
+	// the controller, so their health is polled to re-evaluate dependants
+	// referencing it.
+	healthTicker := f.clock.NewTicker(healthCheckInterval)
+	defer healthTicker.Stop()
+
+	// Running goroutines are periodically checked against the graph to catch
+	// nodes which kept running after being removed.
+	runningTicker := f.clock.NewTicker(runningCheckInterval)
+	defer runningTicker.Stop()
+
+	// Updates are only propagated when requested while a stepper is in use.
+	var stepRequests <-chan stepper.Request
+	if f.opts.Stepper != nil && !f.opts.IsModule {
+		stepRequests = f.opts.Stepper.Requests()
+	}
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
 
+		case <-healthTicker.C:
+			for _, c := range f.loader.Components() {
+				c.CheckHealth()
+			}
 		case <-f.updateQueue.Chan():
+			if f.paused.Load() || stepRequests != nil {
+				// Updated components stay queued until Resume is called, or
+				// until the next step is requested.
+				continue
+			}
+
 			// Evaluate all components that have been updated. Sending the entire batch together will improve
 			// throughput - it prevents the situation where two components have the same dependency, and the first time
 			// it's picked up by the worker pool and the second time it's enqueued again, resulting in more evaluations.
 			all := f.updateQueue.DequeueAll()
 			f.loader.EvaluateDependants(ctx, all)
-		case <-f.loadFinished:
-			level.Info(f.log).Log("msg", "scheduling loaded components and services")
-
-			var (
-				components = f.loader.Components()
-				services   = f.loader.Services()
-
-				runnables = make([]controller.RunnableNode, 0, len(components)+len(services))
-			)
-			for _, c := range components {
-				runnables = append(runnables, c)
+		case req := <-stepRequests:
+			req.Respond(f.step(ctx, req.Updated))
+		case <-f.resumeCh:
+			if f.paused.Load() || stepRequests != nil {
+				// Paused again before the resume was handled.
+				continue
 			}
 
-			// Only the root controller should run services, since modules share the
-			// same service instance as the root.
-			if !f.opts.IsModule {
-				for _, svc := range services {
-					runnables = append(runnables, svc)
-				}
+			// Evaluate everything which was updated while paused as one batch.
+			if all := f.updateQueue.DequeueAll(); len(all) > 0 {
+				f.loader.EvaluateDependants(ctx, all)
 			}
+		case <-f.loadFinished:
+			level.Info(f.log).Log("msg", "scheduling loaded components and services")
 
-			err := f.sched.Synchronize(runnables)
+			err := f.sched.Synchronize(f.runnables())
 			if err != nil {
 				level.Error(f.log).Log("msg", "failed to load components and services", "err", err)
 			}
+		case <-runningTicker.C:
+			if len(f.loadFinished) > 0 {
+				// Nodes of the pending load aren't scheduled yet.
+				continue
+			}
+			f.logRunningCheck()
+		}
+	}
+}
+
+// runnables returns the nodes which should be run by the scheduler, in the
+// order they should be started.
+func (f *Flow) runnables() []controller.RunnableNode {
+	var (
+		components = f.loader.StartupOrder()
+		services   = f.loader.Services()
+
+		runnables = make([]controller.RunnableNode, 0, len(components)+len(services))
+	)
+
+	// Only the root controller should run services, since modules share the
+	// same service instance as the root. Services are started first, since
+	// components may depend on them.
+	if !f.opts.IsModule {
+		for _, svc := range services {
+			runnables = append(runnables, svc)
+		}
+	}
+	for _, c := range components {
+		runnables = append(runnables, c)
+	}
+	return runnables
+}
+
+// step runs one propagation pass requested through Options.Stepper: the
+// dependants of every queued component and of the components named by
+// updated are evaluated in order.
+func (f *Flow) step(ctx context.Context, updated []string) ([]string, error) {
+	nodes := f.updateQueue.DequeueAll()
+	if len(updated) > 0 {
+		g := f.loader.Graph()
+		for _, id := range updated {
+			cn, ok := g.GetByID(id).(*controller.ComponentNode)
+			if !ok {
+				return nil, fmt.Errorf("component %q does not exist", id)
+			}
+			nodes = append(nodes, cn)
 		}
 	}
+	return f.loader.EvaluateDependantsInOrder(ctx, nodes), nil
 }
 
 // LoadSource synchronizes the state of the controller with the current config
 // source. Components in the graph will be marked as unhealthy if there was an
-// error encountered during Load.
+// error encountered during Load, and keep running with their previous
+// arguments. Sources whose graph can't be built are discarded, and the
+// previously loaded components keep running unchanged.
 //
 // The controller will only start running components after Load is called once
-// without any configuration errors.
+// without any configuration errors. If Options.BestEffort is set, the
+// controller also starts if the only errors were from evaluating components.
+//
+// Loading a source with the same checksum as the last successful load is
+// skipped, unless functions were registered in the meantime, so components
+// aren't updated when the config didn't change. Module controllers always
+// load their source.
 func (f *Flow) LoadSource(source *Source, args map[string]any) error {
+	return f.LoadSourceContext(context.Background(), source, args)
+}
+
+// LoadReader reads a River config from r, such as a pipe, a network stream,
+// or a file of an embed.FS, and loads it like LoadSource without arguments.
+// filename is only used as the name of the config in diagnostics; nothing is
+// read from the filesystem, so include directives are reported as errors.
+func (f *Flow) LoadReader(r io.Reader, filename string) error {
+	bb, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", filename, err)
+	}
+	source, err := ParseSource(filename, bb)
+	if err != nil {
+		return err
+	}
+	return f.LoadSource(source, nil)
+}
+
+// LoadSourceContext is like LoadSource, but stops loading the source early if
+// ctx is canceled before the new graph is staged. A canceled load is
+// discarded: running components aren't updated, components created for it are
+// never run, and the controller keeps running the previously loaded graph. Loads are serialized, so a newer
+// call waits for a slow load to finish; cancel the context of the slow load
+// first to have the newer source loaded right away.
+func (f *Flow) LoadSourceContext(ctx context.Context, source *Source, args map[string]any) error {
+	_, err := f.loadSource(ctx, source, args, "")
+	return err
+}
+
+// Reload is like LoadSourceContext without arguments, but also returns
+// whether the config changed since the last successful load. When the config
+// didn't change, nothing is re-evaluated and changed is false, so callers can
+// skip acting on no-op reloads.
+//
+// Reloads are delayed and coalesced according to [Options.MinReloadInterval].
+// Reload is the same as ReloadWithTrigger with ReloadTriggerUnknown.
+func (f *Flow) Reload(ctx context.Context, source *Source) (changed bool, err error) {
+	return f.ReloadWithTrigger(ctx, source, ReloadTriggerUnknown)
+}
+
+// ReloadWithTrigger is like Reload, but also records trigger as the
+// mechanism which requested the reload, such as a signal or an HTTP request.
+// The trigger is logged, set in the LoadSummary of the reload, and counted by
+// the agent_component_controller_reloads_total metric. When reloads are
+// coalesced, the trigger of the most recent reload is recorded.
+func (f *Flow) ReloadWithTrigger(ctx context.Context, source *Source, trigger ReloadTrigger) (changed bool, err error) {
+	if trigger == "" {
+		trigger = ReloadTriggerUnknown
+	}
+	if f.reloads != nil {
+		return f.reloads.reload(ctx, source, trigger)
+	}
+	return f.loadSource(ctx, source, nil, trigger)
+}
+
+// LastLoadSummary returns the summary of the most recent successful call to
+// LoadSource or Reload, including loads skipped because the source didn't
+// change. It returns false if no source loaded successfully yet.
+func (f *Flow) LastLoadSummary() (LoadSummary, bool) {
+	f.loadMut.RLock()
+	defer f.loadMut.RUnlock()
+
+	if f.lastSummary == nil {
+		return LoadSummary{}, false
+	}
+	return *f.lastSummary, true
+}
+
+// LastLoadDiagnostics returns the diagnostics reported by the most recent
+// call to LoadSource or Reload which loaded a source, including warnings
+// from loads which succeeded.
+func (f *Flow) LastLoadDiagnostics() diag.Diagnostics {
+	f.loadMut.RLock()
+	defer f.loadMut.RUnlock()
+	return f.lastDiags
+}
+
+// loadSource implements LoadSourceContext and ReloadWithTrigger. trigger is
+// empty for calls to LoadSourceContext, which aren't reloads.
+func (f *Flow) loadSource(ctx context.Context, source *Source, args map[string]any, trigger ReloadTrigger) (changed bool, err error) {
+	f.applyMut.Lock()
+	defer f.applyMut.Unlock()
+
+	if trigger != "" {
+		defer func() { f.observeReload(trigger, changed, err) }()
+	}
+
+	if f.unchangedSource(source, args) {
+		// Reloading the same source would only re-evaluate the same blocks, so
+		// skip it to avoid updating components needlessly.
+		f.loader.ObserveUnchangedLoad()
+		level.Info(f.log).Log("msg", "config unchanged since the last successful load; skipping reload")
+		summary := newLoadSummary(f.opts.ControllerID, source, len(f.loader.Components()), len(f.lastDiags), f.clock.Now(), 0)
+		summary.Unchanged, summary.Trigger = true, trigger
+
+		f.loadMut.Lock()
+		f.lastSource, f.lastSummary = source, &summary
+		f.loadMut.Unlock()
+		if f.notifier != nil {
+			f.notifier.Notify(summary)
+		}
+		return false, nil
+	}
+
+	f.loader.ObserveParseDuration(source.parseDuration)
+	start := f.clock.Now()
+	diags := f.loader.Apply(ctx, args, source.components, source.configBlocks)
+	f.loadGeneration.Inc()
+	if f.loader.Applied() {
+		f.loader.ObserveConfigSize(source.size())
+	}
+	var summary *LoadSummary
+	if !diags.HasErrors() {
+		loadedAt := f.clock.Now()
+		s := newLoadSummary(f.opts.ControllerID, source, len(f.loader.Components()), len(diags), loadedAt, loadedAt.Sub(start))
+		s.Trigger = trigger
+		summary = &s
+	}
+
 	f.loadMut.Lock()
-	defer f.loadMut.Unlock()
+	f.lastSource, f.lastDiags = source, diags
+	f.loadedHashValid = !f.opts.IsModule && f.loader.Applied() && !diags.HasErrors()
+	if f.loadedHashValid {
+		f.loadedHash, f.loadedFunctions = source.SHA256(), f.functions.version()
+	}
+	if summary != nil {
+		f.lastSummary = summary
+	}
+	f.loadMut.Unlock()
 
-	diags := f.loader.Apply(args, source.components, source.configBlocks)
+	var triggeredBy string
+	if trigger != "" {
+		triggeredBy = fmt.Sprintf(" (triggered by %s)", trigger)
+	}
+	if summary != nil {
+		f.recordEvent(EventTypeLoad, "", fmt.Sprintf("config loaded with %d components%s", summary.Components, triggeredBy))
+	} else {
+		f.recordEvent(EventTypeLoad, "", fmt.Sprintf("config failed to load%s: %s", triggeredBy, loadError(diags)))
+	}
+
+	if !f.loader.Applied() && ctx.Err() != nil {
+		// Nothing changed, so there is nothing to notify or schedule.
+		return false, loadError(diags)
+	}
+	if summary != nil && f.notifier != nil {
+		f.notifier.Notify(*summary)
+	}
 	if !f.loadedOnce.Load() && diags.HasErrors() {
 		// The first call to Load should not run any components if there were
-		// errors in the configuration file.
-		return diags
+		// errors in the configuration file, unless running in best-effort mode
+		// and the errors were limited to evaluating components.
+		if !f.opts.BestEffort || !f.loader.Applied() {
+			return true, diags
+		}
+		level.Warn(f.log).Log("msg", "starting in degraded mode; some components failed to evaluate", "err", diags.ErrorOrNil())
+	}
+	if !f.loadedOnce.Swap(true) {
+		close(f.loaded)
 	}
-	f.loadedOnce.Store(true)
 
 	select {
 	case f.loadFinished <- struct{}{}:
 	default:
 		// A refresh is already scheduled
 	}
-	return diags.ErrorOrNil()
+	return true, loadError(diags)
+}
+
+// observeReload logs the result of a reload requested by trigger and counts
+// it by the agent_component_controller_reloads_total metric.
+func (f *Flow) observeReload(trigger ReloadTrigger, changed bool, err error) {
+	result := "reloaded"
+	switch {
+	case err != nil:
+		result = "failed"
+	case !changed:
+		result = "unchanged"
+	}
+	f.loader.ObserveReload(string(trigger), result)
+
+	logger := level.Info(f.log)
+	if err != nil {
+		logger = level.Error(f.log)
+	}
+	logger.Log("msg", "config reload finished", "trigger", trigger, "result", result)
+}
+
+// loadError returns diags as an error if they include any errors. Loads which
+// only reported warnings, such as for deprecated arguments, succeed; their
+// warnings are available from LastLoadDiagnostics.
+func loadError(diags diag.Diagnostics) error {
+	if !diags.HasErrors() {
+		return nil
+	}
+	return diags
+}
+
+// unchangedSource returns whether loading source with args would load the
+// same config as the last successful call to LoadSource. Module controllers
+// always reload, since their arguments may change without their source
+// changing. applyMut must be held when calling unchangedSource.
+func (f *Flow) unchangedSource(source *Source, args map[string]any) bool {
+	return f.loadedHashValid &&
+		args == nil &&
+		source.SHA256() == f.loadedHash &&
+		f.functions.version() == f.loadedFunctions
+}
+
+// Pause suspends the propagation of component updates. While paused, Run
+// continues to accept updates from components, but queues them instead of
+// re-evaluating the dependants of the updated components. Calling Pause on a
+// paused controller has no effect.
+//
+// Pause only affects updates reported by components. Calls to LoadSource
+// still evaluate the graph while paused.
+func (f *Flow) Pause() {
+	f.paused.Store(true)
+}
+
+// Resume resumes the propagation of component updates after a call to Pause.
+// Updates queued while paused are applied together in a single batch, so
+// dependants only observe the final state of the updated components. Calling
+// Resume on a controller which isn't paused has no effect.
+func (f *Flow) Resume() {
+	if !f.paused.CompareAndSwap(true, false) {
+		return
+	}
+	select {
+	case f.resumeCh <- struct{}{}:
+	default:
+		// A resume is already scheduled.
+	}
 }
 
-// Ready returns whether the Flow controller has finished its initial load.
+// Ready returns whether the Flow controller has finished its initial load,
+// and every component which reports readiness through
+// component.ReadyComponent is running and ready. Other components don't
+// affect readiness.
 func (f *Flow) Ready() bool {
-	return f.loadedOnce.Load()
+	if !f.loadedOnce.Load() {
+		return false
+	}
+	for _, cn := range f.loader.Components() {
+		if _, ok := cn.Component().(component.ReadyComponent); ok && !cn.Ready() {
+			return false
+		}
+	}
+	return true
+}
+
+// WaitReady waits for the controller to finish its initial load, and for
+// every component loaded to finish its initial startup: a component is
+// started once Run runs it and, if it has exports, it reported them through
+// OnStateChange. WaitReady returns an error if a component exits or fails to
+// be built before it started, or if ctx is canceled first; use a context
+// with a deadline to time out the startup.
+//
+// If the config is reloaded while waiting, WaitReady waits for the
+// components of the new config instead. Components of modules aren't waited
+// for.
+func (f *Flow) WaitReady(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for the initial load: %w", ctx.Err())
+	case <-f.loaded:
+	}
+
+	for {
+		generation := f.loadGeneration.Load()
+		err := f.waitStarted(ctx)
+		if ctx.Err() != nil || f.loadGeneration.Load() == generation {
+			return err
+		}
+		// Components of a newer load may differ, including ones which failed
+		// because they were removed before they started.
+	}
+}
+
+// waitStarted waits for every component of the current graph to start.
+func (f *Flow) waitStarted(ctx context.Context) error {
+	for _, cn := range f.loader.Components() {
+		if err := cn.WaitStarted(ctx); err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("waiting for %s to start: %w", cn.NodeID(), err)
+			}
+			return fmt.Errorf("%s failed to start: %w", cn.NodeID(), err)
+		}
+	}
+	return nil
 }
@@ -2,6 +2,7 @@ package flow
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/grafana/agent/component"
 	"github.com/grafana/agent/pkg/flow/internal/controller"
@@ -34,7 +35,75 @@ func (f *Flow) GetComponent(id component.ID, opts component.InfoOptions) (*compo
 		return nil, fmt.Errorf("%q is not a component", id)
 	}
 
-	return f.getComponentDetail(cn, graph, opts), nil
+	goroutines, err := getGoroutineCounts(opts)
+	if err != nil {
+		return nil, err
+	}
+	return f.getComponentDetail(cn, graph, opts, goroutines), nil
+}
+
+// TimestampedError is an error reported by a component along with the time
+// it was reported.
+type TimestampedError = component.TimestampedError
+
+// ErrorHistory returns up to limit of the most recent errors from evaluating
+// and running the component with the given local ID, oldest first. Every
+// kept error is returned when limit is less than 1. ErrorHistory returns nil
+// if the component doesn't exist.
+//
+// The number of errors kept for each component is bounded by
+// [Options.ErrorHistorySize].
+func (f *Flow) ErrorHistory(name string, limit int) []TimestampedError {
+	f.loadMut.RLock()
+	defer f.loadMut.RUnlock()
+
+	cn, ok := f.loader.OriginalGraph().GetByID(name).(*controller.ComponentNode)
+	if !ok {
+		return nil
+	}
+	return cn.ErrorHistory(limit)
+}
+
+// CircuitBreakerOptions configures the circuit breaker of each component.
+// The circuit breaker is disabled when MaxFailures is zero.
+type CircuitBreakerOptions struct {
+	MaxFailures int           // Failures within Window which quarantine a component.
+	Window      time.Duration // Period failures are counted over.
+}
+
+// ComponentAllowed reports whether the component with the given name, such
+// as "prometheus.scrape", may be used by a controller created with the
+// allowed and denied lists as [Options.AllowedComponents] and
+// [Options.DeniedComponents].
+func ComponentAllowed(allowed, denied []string, name string) bool {
+	return controller.ComponentPolicy{Allowed: allowed, Denied: denied}.Allows(name)
+}
+
+// ResetCircuitBreaker resets the open circuit breaker of the component with
+// the given ID, so that it's started again and its previous failures are
+// forgotten. It returns an error if the component doesn't exist or its
+// circuit breaker isn't open. See [Options.CircuitBreaker] for more
+// information.
+func (f *Flow) ResetCircuitBreaker(id component.ID) error {
+	f.loadMut.RLock()
+	defer f.loadMut.RUnlock()
+
+	if id.ModuleID != "" {
+		mod, ok := f.modules.Get(id.ModuleID)
+		if !ok {
+			return component.ErrComponentNotFound
+		}
+		return mod.f.ResetCircuitBreaker(component.ID{LocalID: id.LocalID})
+	}
+
+	cn, ok := f.loader.OriginalGraph().GetByID(id.LocalID).(*controller.ComponentNode)
+	if !ok {
+		return component.ErrComponentNotFound
+	}
+	if !cn.ResetCircuitBreaker() {
+		return fmt.Errorf("circuit breaker of %q is not open", id)
+	}
+	return nil
 }
 
 // ListComponents implements [component.Provider].
@@ -56,14 +125,30 @@ func (f *Flow) ListComponents(moduleID string, opts component.InfoOptions) ([]*c
 		graph      = f.loader.OriginalGraph()
 	)
 
+	// Collect goroutine counts once for all components, since it requires
+	// taking a goroutine profile.
+	goroutines, err := getGoroutineCounts(opts)
+	if err != nil {
+		return nil, err
+	}
+
 	detail := make([]*component.Info, len(components))
 	for i, component := range components {
-		detail[i] = f.getComponentDetail(component, graph, opts)
+		detail[i] = f.getComponentDetail(component, graph, opts, goroutines)
 	}
 	return detail, nil
 }
 
-func (f *Flow) getComponentDetail(cn *controller.ComponentNode, graph *dag.Graph, opts component.InfoOptions) *component.Info {
+// getGoroutineCounts returns the number of goroutines attributed to each
+// component when opts requests resource usage. Otherwise, it returns nil.
+func getGoroutineCounts(opts component.InfoOptions) (map[string]int, error) {
+	if !opts.GetResources {
+		return nil, nil
+	}
+	return controller.GoroutinesByComponent()
+}
+
+func (f *Flow) getComponentDetail(cn *controller.ComponentNode, graph *dag.Graph, opts component.InfoOptions, goroutines map[string]int) *component.Info {
 	var references, referencedBy []string
 
 	// Skip over any edge which isn't between two component nodes. This is a
@@ -88,13 +173,17 @@ func (f *Flow) getComponentDetail(cn *controller.ComponentNode, graph *dag.Graph
 	// Fields which are optional to set.
 	var (
 		health    component.Health
+		ready     bool
 		arguments component.Arguments
 		exports   component.Exports
 		debugInfo interface{}
+		resources *component.ResourceUsage
+		errors    []component.TimestampedError
 	)
 
 	if opts.GetHealth {
 		health = cn.CurrentHealth()
+		ready = cn.Ready()
 	}
 	if opts.GetArguments {
 		arguments = cn.Arguments()
@@ -105,6 +194,12 @@ func (f *Flow) getComponentDetail(cn *controller.ComponentNode, graph *dag.Graph
 	if opts.GetDebugInfo {
 		debugInfo = cn.DebugInfo()
 	}
+	if opts.GetResources {
+		resources = &component.ResourceUsage{Goroutines: goroutines[cn.GlobalID()]}
+	}
+	if opts.GetErrors {
+		errors = cn.ErrorHistory(0)
+	}
 
 	return &component.Info{
 		Component: cn.Component(),
@@ -115,15 +210,19 @@ func (f *Flow) getComponentDetail(cn *controller.ComponentNode, graph *dag.Graph
 			LocalID:  cn.NodeID(),
 		},
 		Label: cn.Label(),
+		Tags:  cn.Tags(),
 
 		References:   references,
 		ReferencedBy: referencedBy,
 
 		Registration: cn.Registration(),
 		Health:       health,
+		Ready:        ready,
 
 		Arguments: arguments,
 		Exports:   exports,
 		DebugInfo: debugInfo,
+		Resources: resources,
+		Errors:    errors,
 	}
 }
@@ -0,0 +1,86 @@
+package flow
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/stretchr/testify/require"
+)
+
+func TestController_ListComponents_Resources(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+	ctrl := New(testOptions(t))
+
+	f, err := ParseSource(t.Name(), []byte(`
+		testcomponents.passthrough "static" {
+			input = "hello, world!"
+		}
+	`))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		ctrl.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// The goroutine running the component is always attributed to it.
+	require.Eventually(t, func() bool {
+		infos, err := ctrl.ListComponents("", component.InfoOptions{GetResources: true})
+		require.NoError(t, err)
+		require.Len(t, infos, 1)
+		require.NotNil(t, infos[0].Resources)
+		return infos[0].Resources.Goroutines >= 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	infos, err := ctrl.ListComponents("", component.InfoOptions{})
+	require.NoError(t, err)
+	require.Nil(t, infos[0].Resources)
+}
+
+func TestController_ErrorHistory(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+	opts := testOptions(t)
+	opts.ErrorHistorySize = 2
+	ctrl := New(opts)
+	defer cleanUpController(ctrl)
+
+	// Each config fails to evaluate with a different error.
+	for i := 1; i <= 3; i++ {
+		f, err := ParseSource(t.Name(), []byte(fmt.Sprintf(`
+			testcomponents.passthrough "static" {
+				input = [%d]
+			}
+		`, i)))
+		require.NoError(t, err)
+		require.Error(t, ctrl.LoadSource(f, nil))
+	}
+
+	history := ctrl.ErrorHistory("testcomponents.passthrough.static", 0)
+	require.Len(t, history, 2)
+	require.Contains(t, history[0].Error, "[2]")
+	require.Contains(t, history[1].Error, "[3]")
+	require.False(t, history[1].Time.Before(history[0].Time))
+
+	latest := ctrl.ErrorHistory("testcomponents.passthrough.static", 1)
+	require.Equal(t, history[1:], latest)
+
+	require.Nil(t, ctrl.ErrorHistory("testcomponents.passthrough.missing", 0))
+
+	infos, err := ctrl.ListComponents("", component.InfoOptions{GetErrors: true})
+	require.NoError(t, err)
+	require.Equal(t, history, infos[0].Errors)
+
+	infos, err = ctrl.ListComponents("", component.InfoOptions{})
+	require.NoError(t, err)
+	require.Nil(t, infos[0].Errors)
+}
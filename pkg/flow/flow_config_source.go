@@ -0,0 +1,118 @@
+package flow
+
+import (
+	"context"
+
+	"github.com/grafana/agent/pkg/flow/logging/level"
+)
+
+// configSourceName is the file name used for configs received by
+// RunWithConfigSource, such as in the positions of diagnostics.
+const configSourceName = "config_source.river"
+
+// RunWithConfigSource runs f like Run and loads every config received from
+// src, blocking until ctx is canceled. It returns ctx.Err() once every
+// component has stopped.
+//
+// Configs are loaded in the same way as Reload, so receiving the same config
+// twice doesn't re-evaluate anything. Configs which fail to parse or whose
+// graph can't be built are logged and discarded, and f keeps running the last
+// config which loaded successfully. As with any other reload, the rest of a
+// config is still loaded when some of its components fail to evaluate; those
+// components are reported as unhealthy and keep running with their previous
+// arguments.
+//
+// When [Options.MinReloadInterval] is set, configs received while waiting for
+// the interval to elapse are coalesced, so only the most recently received
+// config is loaded once it elapses.
+//
+// Closing src stops receiving configs, but f keeps running the last loaded
+// config until ctx is canceled. RunWithConfigSource must be called instead of
+// Run, and only once.
+func (f *Flow) RunWithConfigSource(ctx context.Context, src <-chan []byte) error {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f.Run(ctx)
+	}()
+
+	if f.reloads != nil {
+		src = f.coalesceConfigs(ctx, src)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			<-done
+			return ctx.Err()
+
+		case bb, ok := <-src:
+			if !ok {
+				// Receiving from a nil channel blocks forever, so only ctx is
+				// waited on from now on.
+				src = nil
+				continue
+			}
+			f.loadConfigBytes(ctx, bb)
+		}
+	}
+}
+
+// coalesceConfigs keeps receiving configs from src while the previously
+// received config is being loaded, and returns a channel which only delivers
+// the most recently received config. The returned channel is closed once src
+// is closed and its last config was delivered.
+func (f *Flow) coalesceConfigs(ctx context.Context, src <-chan []byte) <-chan []byte {
+	out := make(chan []byte)
+	go func() {
+		var (
+			latest  []byte
+			pending bool
+		)
+		for {
+			var send chan<- []byte
+			if pending {
+				send = out
+			} else if src == nil {
+				close(out)
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case bb, ok := <-src:
+				if !ok {
+					src = nil
+					continue
+				}
+				if pending {
+					f.loader.ObserveCoalescedReload()
+				}
+				latest, pending = bb, true
+			case send <- latest:
+				latest, pending = nil, false
+			}
+		}
+	}()
+	return out
+}
+
+// loadConfigBytes parses and loads a config received by RunWithConfigSource.
+func (f *Flow) loadConfigBytes(ctx context.Context, bb []byte) {
+	source, err := ParseSource(configSourceName, bb)
+	if err != nil {
+		level.Error(f.log).Log("msg", "failed to parse config from source; keeping the last loaded config", "err", err)
+		return
+	}
+
+	changed, err := f.ReloadWithTrigger(ctx, source, ReloadTriggerConfigSource)
+	switch {
+	case err != nil:
+		level.Error(f.log).Log("msg", "failed to load config from source", "err", err)
+	case !changed:
+		level.Debug(f.log).Log("msg", "config from source unchanged")
+	default:
+		level.Info(f.log).Log("msg", "loaded config from source")
+	}
+}
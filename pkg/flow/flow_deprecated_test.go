@@ -0,0 +1,52 @@
+package flow
+
+import (
+	"testing"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/pkg/flow/internal/controller"
+	"github.com/grafana/agent/pkg/flow/internal/worker"
+	"github.com/grafana/river/diag"
+	"github.com/stretchr/testify/require"
+)
+
+func TestController_DeprecatedArguments(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+
+	passthrough, _ := component.Get("testcomponents.passthrough")
+	passthrough.DeprecatedArguments = map[string]component.Deprecation{
+		"lag": {Replacement: "delay", Message: "lag will be removed in v1.0"},
+	}
+
+	ctrl := newController(controllerOptions{
+		Options:           testOptions(t),
+		ModuleRegistry:    newModuleRegistry(),
+		WorkerPool:        worker.NewFixedWorkerPool(1, 100),
+		ComponentRegistry: controller.RegistryMap{passthrough.Name: passthrough},
+	})
+	defer cleanUpController(ctrl)
+
+	f, err := ParseSource(t.Name(), []byte(`
+		testcomponents.passthrough "a" {
+			input = "hello"
+			lag   = "1ms"
+		}
+	`))
+	require.NoError(t, err)
+
+	// Warnings alone don't fail the load.
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	diags := ctrl.LastLoadDiagnostics()
+	require.Len(t, diags, 1)
+	require.Equal(t, diag.SeverityLevelWarn, diags[0].Severity)
+	require.Equal(t, `testcomponents.passthrough.a sets deprecated argument "lag": use delay instead; lag will be removed in v1.0`, diags[0].Message)
+	require.Equal(t, 4, diags[0].StartPos.Line)
+	require.Equal(t, 4, diags[0].StartPos.Column)
+	require.Equal(t, 4, diags[0].EndPos.Line)
+	require.Equal(t, 16, diags[0].EndPos.Column)
+
+	summary, ok := ctrl.LastLoadSummary()
+	require.True(t, ok)
+	require.Equal(t, 1, summary.Warnings)
+}
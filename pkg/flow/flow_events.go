@@ -0,0 +1,143 @@
+package flow
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultEventLogSize is the number of events kept in the event log of a
+// controller when [Options.EventLogSize] is zero.
+const DefaultEventLogSize = 256
+
+// EventType is the type of an Event.
+type EventType string
+
+// Types of events recorded in the event log.
+const (
+	EventTypeLoad    EventType = "load"    // A config was loaded, successfully or not.
+	EventTypeUpdate  EventType = "update"  // A component updated its exports.
+	EventTypeError   EventType = "error"   // Evaluating or running a component failed.
+	EventTypeRestart EventType = "restart" // A component was restarted.
+)
+
+// Event is a structured event recorded in the event log of a controller.
+type Event struct {
+	Time       time.Time `json:"time"`
+	Type       EventType `json:"type"`
+	Controller string    `json:"controller,omitempty"` // ID of the controller, empty for the root controller.
+	Component  string    `json:"component,omitempty"`  // Global ID of the component; empty for loads.
+	Message    string    `json:"message,omitempty"`
+}
+
+// eventLog is a bounded ring buffer of the most recent events of a
+// controller and its modules. The zero value keeps no events.
+type eventLog struct {
+	mut     sync.RWMutex
+	entries []Event // Fixed capacity; oldest entry at next once full.
+	next    int     // Index of the next entry to write.
+	full    bool    // Whether every entry has been written.
+}
+
+// newEventLog returns an eventLog for the given [Options.EventLogSize].
+func newEventLog(size int) *eventLog {
+	switch {
+	case size == 0:
+		size = DefaultEventLogSize
+	case size < 0:
+		return &eventLog{}
+	}
+	return &eventLog{entries: make([]Event, size)}
+}
+
+// Add records e, discarding the oldest event once the log is full. The time
+// of e is set to the current time if unset.
+func (l *eventLog) Add(e Event) {
+	if len(l.entries) == 0 {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	l.entries[l.next] = e
+	l.next = (l.next + 1) % len(l.entries)
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+// List returns up to limit of the most recent events, oldest first. Every
+// kept event is returned when limit is less than 1.
+func (l *eventLog) List(limit int) []Event {
+	l.mut.RLock()
+	defer l.mut.RUnlock()
+
+	count := l.next
+	if l.full {
+		count = len(l.entries)
+	}
+	if limit > 0 && limit < count {
+		count = limit
+	}
+
+	res := make([]Event, count)
+	for i := range res {
+		idx := (l.next - count + i + len(l.entries)) % len(l.entries)
+		res[i] = l.entries[idx]
+	}
+	return res
+}
+
+// Events returns up to limit of the most recent events of f and its modules,
+// oldest first. Every kept event is returned when limit is less than 1. The
+// number of kept events is controlled by [Options.EventLogSize].
+func (f *Flow) Events(limit int) []Event {
+	return f.events.List(limit)
+}
+
+// recordEvent adds an event of f to its event log.
+func (f *Flow) recordEvent(typ EventType, component, message string) {
+	f.events.Add(Event{
+		Time:       f.clock.Now(),
+		Type:       typ,
+		Controller: f.opts.ControllerID,
+		Component:  component,
+		Message:    message,
+	})
+}
+
+// EventsHandler returns an http.HandlerFunc which writes the most recent
+// events of the controller and its modules as a JSON array, oldest first.
+// Events include loads of configs, updated exports, and failures and
+// restarts of components.
+//
+// The response can be limited to the newest events by providing the "limit"
+// query parameter.
+func EventsHandler(f *Flow) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var limit int
+		if s := r.URL.Query().Get("limit"); s != "" {
+			var err error
+			limit, err = strconv.Atoi(s)
+			if err != nil || limit < 1 {
+				http.Error(w, fmt.Sprintf("invalid limit %q: must be a positive integer", s), http.StatusBadRequest)
+				return
+			}
+		}
+
+		bb, err := json.Marshal(f.Events(limit))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(bb)
+	}
+}
@@ -0,0 +1,85 @@
+package flow
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventsHandler(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+	opts := testOptions(t)
+	opts.EventLogSize = 4
+	ctrl := New(opts)
+	defer cleanUpController(ctrl)
+
+	load := func(content string) error {
+		f, err := ParseSource(t.Name(), []byte(content))
+		require.NoError(t, err)
+		return ctrl.LoadSource(f, nil)
+	}
+	get := func(target string) (int, []Event) {
+		rec := httptest.NewRecorder()
+		EventsHandler(ctrl).ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		if rec.Code != http.StatusOK {
+			return rec.Code, nil
+		}
+		require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		var events []Event
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &events))
+		return rec.Code, events
+	}
+
+	require.NoError(t, load(`
+		testcomponents.passthrough "a" {
+			input = "hello, world!"
+		}
+	`))
+	_, events := get("/events")
+	require.NotEmpty(t, events)
+	last := events[len(events)-1]
+	require.Equal(t, EventTypeLoad, last.Type)
+	require.Equal(t, "config loaded with 1 components", last.Message)
+	require.False(t, last.Time.IsZero())
+
+	require.Error(t, load(`
+		testcomponents.passthrough "a" {
+			input = [1]
+		}
+	`))
+	_, events = get("/events?limit=2")
+	require.Len(t, events, 2)
+	require.Equal(t, EventTypeError, events[0].Type)
+	require.Equal(t, "testcomponents.passthrough.a", events[0].Component)
+	require.Equal(t, EventTypeLoad, events[1].Type)
+	require.Contains(t, events[1].Message, "config failed to load")
+	require.False(t, events[1].Time.Before(events[0].Time))
+
+	// Memory is bounded by the size of the event log.
+	for i := 0; i < 3; i++ {
+		require.Error(t, load(`testcomponents.passthrough "a" { input = [1] }`))
+	}
+	require.Len(t, ctrl.Events(0), 4)
+
+	code, _ := get("/events?limit=0")
+	require.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestEventLog(t *testing.T) {
+	require.Empty(t, newEventLog(-1).List(0))
+	require.Len(t, newEventLog(0).entries, DefaultEventLogSize)
+
+	l := newEventLog(2)
+	require.Empty(t, l.List(0))
+	for _, msg := range []string{"a", "b", "c"} {
+		l.Add(Event{Type: EventTypeUpdate, Message: msg})
+	}
+	events := l.List(0)
+	require.Len(t, events, 2)
+	require.Equal(t, "b", events[0].Message)
+	require.Equal(t, "c", events[1].Message)
+	require.Equal(t, events[1:], l.List(1))
+}
@@ -0,0 +1,116 @@
+package flow
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/grafana/agent/pkg/flow/internal/controller"
+	"github.com/grafana/agent/pkg/flow/internal/dag"
+	"github.com/grafana/river/token"
+)
+
+// DependencyEdge describes one block in a config source referencing another.
+type DependencyEdge struct {
+	From string // ID of the block making the references.
+	To   string // ID of the block being referenced.
+
+	// References holds the expressions in From which reference To, such as
+	// "prometheus.remote_write.default.receiver".
+	References []string
+}
+
+// ExplainDependency returns every path through which the block with ID from
+// depends on the block with ID to in source, either by referencing it directly
+// or by referencing other blocks which depend on it. Each path is a list of
+// edges starting at from and ending at to.
+//
+// ExplainDependency returns no paths if from doesn't depend on to. Blocks in
+// source are inspected without being evaluated, so source doesn't have to be
+// a valid config.
+func ExplainDependency(source *Source, from, to string) ([][]DependencyEdge, error) {
+	if from == to {
+		return nil, fmt.Errorf("cannot explain the dependency of block %q on itself", from)
+	}
+
+	g := controller.NewReferenceGraph(source.configBlocks, source.components)
+
+	fromNode, toNode := g.GetByID(from), g.GetByID(to)
+	if fromNode == nil {
+		return nil, fmt.Errorf("block %q does not exist", from)
+	} else if toNode == nil {
+		return nil, fmt.Errorf("block %q does not exist", to)
+	}
+
+	var paths [][]DependencyEdge
+	for _, nodes := range dag.Paths(g, fromNode, toNode) {
+		path := make([]DependencyEdge, 0, len(nodes)-1)
+		for i := 0; i < len(nodes)-1; i++ {
+			e := dag.Edge{From: nodes[i], To: nodes[i+1]}
+			path = append(path, DependencyEdge{
+				From:       e.From.NodeID(),
+				To:         e.To.NodeID(),
+				References: controller.EdgeReferences(g, e),
+			})
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// EdgeReference describes an expression through which one block references
+// another.
+type EdgeReference struct {
+	// Attribute is the dot-separated path of the attribute holding the
+	// expression, such as "client.url" for the url attribute of a client
+	// block.
+	Attribute string
+
+	// Expression is the referenced expression, such as
+	// "prometheus.remote_write.default.receiver".
+	Expression string
+
+	Pos  token.Position // Position of the expression.
+	Line string         // Line of the source containing the expression, without surrounding whitespace.
+}
+
+// ExplainEdge returns every expression in the block with ID from which
+// references the block with ID to in source, in the order they appear in the
+// block. ExplainEdge returns no references if from doesn't reference to
+// directly.
+//
+// Like ExplainDependency, blocks are inspected without being evaluated.
+func ExplainEdge(source *Source, from, to string) ([]EdgeReference, error) {
+	g := controller.NewReferenceGraph(source.configBlocks, source.components)
+
+	fromNode, toNode := g.GetByID(from), g.GetByID(to)
+	if fromNode == nil {
+		return nil, fmt.Errorf("block %q does not exist", from)
+	} else if toNode == nil {
+		return nil, fmt.Errorf("block %q does not exist", to)
+	}
+
+	sources := controller.EdgeSources(g, dag.Edge{From: fromNode, To: toNode})
+	refs := make([]EdgeReference, 0, len(sources))
+	for _, src := range sources {
+		refs = append(refs, EdgeReference{
+			Attribute:  src.Attribute,
+			Expression: src.Expression,
+			Pos:        src.Pos,
+			Line:       sourceLine(source.sourceMap[src.Pos.Filename], src.Pos.Line),
+		})
+	}
+	return refs, nil
+}
+
+// sourceLine returns the line with the 1-indexed number n in bb without
+// surrounding whitespace, or an empty string if bb has no such line.
+func sourceLine(bb []byte, n int) string {
+	if n < 1 {
+		return ""
+	}
+	lines := bytes.Split(bb, []byte("\n"))
+	if n > len(lines) {
+		return ""
+	}
+	return string(bytes.TrimSpace(lines[n-1]))
+}
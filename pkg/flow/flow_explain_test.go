@@ -0,0 +1,115 @@
+package flow
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplainDependency(t *testing.T) {
+	f, err := ParseSource(t.Name(), []byte(`
+		testcomponents.tick "ticker" {
+			frequency = "1s"
+		}
+
+		testcomponents.passthrough "ticker" {
+			input = testcomponents.tick.ticker.tick_time
+		}
+
+		testcomponents.passthrough "forwarded" {
+			input = testcomponents.passthrough.ticker.output
+			lag   = testcomponents.tick.ticker.frequency
+		}
+
+		testcomponents.passthrough "unrelated" {
+			input = "hello, world!"
+		}
+	`))
+	require.NoError(t, err)
+
+	t.Run("Direct and indirect paths", func(t *testing.T) {
+		paths, err := ExplainDependency(f, "testcomponents.passthrough.forwarded", "testcomponents.tick.ticker")
+		require.NoError(t, err)
+		require.Equal(t, [][]DependencyEdge{
+			{
+				{
+					From:       "testcomponents.passthrough.forwarded",
+					To:         "testcomponents.passthrough.ticker",
+					References: []string{"testcomponents.passthrough.ticker.output"},
+				},
+				{
+					From:       "testcomponents.passthrough.ticker",
+					To:         "testcomponents.tick.ticker",
+					References: []string{"testcomponents.tick.ticker.tick_time"},
+				},
+			},
+			{
+				{
+					From:       "testcomponents.passthrough.forwarded",
+					To:         "testcomponents.tick.ticker",
+					References: []string{"testcomponents.tick.ticker.frequency"},
+				},
+			},
+		}, paths)
+	})
+
+	t.Run("No dependency", func(t *testing.T) {
+		paths, err := ExplainDependency(f, "testcomponents.tick.ticker", "testcomponents.passthrough.forwarded")
+		require.NoError(t, err)
+		require.Empty(t, paths)
+
+		paths, err = ExplainDependency(f, "testcomponents.passthrough.unrelated", "testcomponents.tick.ticker")
+		require.NoError(t, err)
+		require.Empty(t, paths)
+	})
+
+	t.Run("Missing block", func(t *testing.T) {
+		_, err := ExplainDependency(f, "testcomponents.passthrough.missing", "testcomponents.tick.ticker")
+		require.EqualError(t, err, `block "testcomponents.passthrough.missing" does not exist`)
+	})
+}
+
+func TestExplainEdge(t *testing.T) {
+	f, err := ParseSource(t.Name(), []byte(`testcomponents.tick "ticker" {
+	frequency = "1s"
+}
+
+testcomponents.passthrough "forwarded" {
+	input = testcomponents.tick.ticker.tick_time
+	lag   = "1s"
+
+	nested {
+		value = testcomponents.tick.ticker.frequency + "0"
+	}
+}
+`))
+	require.NoError(t, err)
+
+	t.Run("References", func(t *testing.T) {
+		refs, err := ExplainEdge(f, "testcomponents.passthrough.forwarded", "testcomponents.tick.ticker")
+		require.NoError(t, err)
+		require.Len(t, refs, 2)
+
+		require.Equal(t, "input", refs[0].Attribute)
+		require.Equal(t, "testcomponents.tick.ticker.tick_time", refs[0].Expression)
+		require.Equal(t, 6, refs[0].Pos.Line)
+		require.Equal(t, 10, refs[0].Pos.Column)
+		require.Equal(t, "input = testcomponents.tick.ticker.tick_time", refs[0].Line)
+
+		require.Equal(t, "nested.value", refs[1].Attribute)
+		require.Equal(t, "testcomponents.tick.ticker.frequency", refs[1].Expression)
+		require.Equal(t, 10, refs[1].Pos.Line)
+		require.Equal(t, `value = testcomponents.tick.ticker.frequency + "0"`, refs[1].Line)
+	})
+
+	t.Run("No edge", func(t *testing.T) {
+		refs, err := ExplainEdge(f, "testcomponents.tick.ticker", "testcomponents.passthrough.forwarded")
+		require.NoError(t, err)
+		require.Empty(t, refs)
+	})
+
+	t.Run("Missing block", func(t *testing.T) {
+		_, err := ExplainEdge(f, "testcomponents.tick.ticker", "testcomponents.passthrough.missing")
+		require.EqualError(t, err, `block "testcomponents.passthrough.missing" does not exist`)
+	})
+}
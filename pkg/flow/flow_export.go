@@ -0,0 +1,71 @@
+package flow
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/river/ast"
+)
+
+// exportJSON is the JSON representation written by Export.
+type exportJSON struct {
+	Components []exportComponentJSON `json:"components"`
+	Edges      []exportEdgeJSON      `json:"edges"`
+}
+
+type exportComponentJSON struct {
+	Position  string          `json:"position"`
+	Component *component.Info `json:"component"`
+}
+
+type exportEdgeJSON struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Export returns a JSON document combining the graph of f with the evaluated
+// configuration of every component, for attaching to audits and reviews.
+//
+// Every component is listed with the position of its block and its details
+// in the same format as the component API, including its health and
+// evaluated arguments with sensitive values redacted. Edges point from a
+// block to the blocks it depends on, including config blocks, in the
+// original graph before it was reduced.
+//
+// The state of f is captured while holding the load lock, so the document
+// reflects a single consistent snapshot. Components of modules aren't
+// included. The format of the document is not stable and is subject to
+// change.
+func (f *Flow) Export() ([]byte, error) {
+	f.loadMut.RLock()
+	defer f.loadMut.RUnlock()
+
+	var (
+		graph = f.loader.OriginalGraph()
+		opts  = component.InfoOptions{GetHealth: true, GetArguments: true}
+		doc   = exportJSON{Edges: []exportEdgeJSON{}}
+	)
+
+	components := f.loader.Components()
+	sort.Slice(components, func(i, j int) bool { return components[i].NodeID() < components[j].NodeID() })
+	doc.Components = make([]exportComponentJSON, len(components))
+	for i, cn := range components {
+		doc.Components[i] = exportComponentJSON{
+			Position:  ast.StartPos(cn.Block()).Position().String(),
+			Component: f.getComponentDetail(cn, graph, opts, nil),
+		}
+	}
+
+	for _, e := range graph.Edges() {
+		doc.Edges = append(doc.Edges, exportEdgeJSON{From: e.From.NodeID(), To: e.To.NodeID()})
+	}
+	sort.Slice(doc.Edges, func(i, j int) bool {
+		if doc.Edges[i].From != doc.Edges[j].From {
+			return doc.Edges[i].From < doc.Edges[j].From
+		}
+		return doc.Edges[i].To < doc.Edges[j].To
+	})
+
+	return json.MarshalIndent(doc, "", "  ")
+}
@@ -0,0 +1,83 @@
+package flow
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/pkg/flow/internal/controller"
+	"github.com/grafana/agent/pkg/flow/internal/worker"
+	"github.com/stretchr/testify/require"
+)
+
+func TestController_Export(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+
+	passthrough, _ := component.Get("testcomponents.passthrough")
+	ctrl := newController(controllerOptions{
+		Options:        testOptions(t),
+		ModuleRegistry: newModuleRegistry(),
+		WorkerPool:     worker.NewFixedWorkerPool(1, 100),
+		ComponentRegistry: controller.RegistryMap{
+			passthrough.Name: passthrough,
+			"test.secret": component.Registration{
+				Name: "test.secret",
+				Args: secretArgs{},
+				Build: func(component.Options, component.Arguments) (component.Component, error) {
+					return secretComponent{}, nil
+				},
+			},
+		},
+	})
+	defer cleanUpController(ctrl)
+
+	f, err := ParseSource("config.river", []byte(`test.secret "creds" {
+	username = "admin"
+	password = "hunter2"
+}
+
+testcomponents.passthrough "a" {
+	input = "hello, world!"
+}
+
+testcomponents.passthrough "b" {
+	input = testcomponents.passthrough.a.output
+}
+`))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	bb, err := ctrl.Export()
+	require.NoError(t, err)
+	require.NotContains(t, string(bb), "hunter2")
+
+	var doc struct {
+		Components []struct {
+			Position  string `json:"position"`
+			Component struct {
+				Name      string          `json:"name"`
+				LocalID   string          `json:"localID"`
+				Health    json.RawMessage `json:"health"`
+				Arguments json.RawMessage `json:"arguments"`
+			} `json:"component"`
+		} `json:"components"`
+		Edges []struct {
+			From string `json:"from"`
+			To   string `json:"to"`
+		} `json:"edges"`
+	}
+	require.NoError(t, json.Unmarshal(bb, &doc))
+
+	require.Len(t, doc.Components, 3)
+	require.Equal(t, "config.river:1:1", doc.Components[0].Position)
+	require.Equal(t, "test.secret", doc.Components[0].Component.Name)
+	require.Equal(t, "test.secret.creds", doc.Components[0].Component.LocalID)
+	require.Contains(t, string(doc.Components[0].Component.Arguments), `"admin"`)
+	require.NotEmpty(t, doc.Components[0].Component.Health)
+	require.Equal(t, "config.river:10:1", doc.Components[2].Position)
+	require.Equal(t, "testcomponents.passthrough.b", doc.Components[2].Component.LocalID)
+
+	require.Len(t, doc.Edges, 1)
+	require.Equal(t, "testcomponents.passthrough.b", doc.Edges[0].From)
+	require.Equal(t, "testcomponents.passthrough.a", doc.Edges[0].To)
+}
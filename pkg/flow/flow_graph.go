@@ -0,0 +1,383 @@
+package flow
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/grafana/agent/pkg/flow/internal/controller"
+	"github.com/grafana/agent/pkg/flow/internal/dag"
+)
+
+// GraphHandler returns an http.HandlerFunc which writes the graph of the
+// controller in the Graphviz DOT format. The graph is the reduced graph used
+// for evaluation, where edges point from a block to the blocks it depends on.
+//
+// The encoded graph is cached until the next call to LoadSource, so repeated
+// requests between reloads are cheap. The cache can be bypassed by providing
+// the "nocache" query parameter.
+//
+// The "root" query parameter limits the graph to the nodes within "depth"
+// edges of the node with the given ID. If depth isn't provided, all nodes
+// reachable from root are included. The "direction" query parameter controls
+// which edges are followed from root, and is one of "both" (the default),
+// "dependencies", or "dependants". Limited graphs are never cached.
+//
+// The "compact" query parameter writes the DOT encoding on a single line,
+// without whitespace, for dense storage and embedding in log fields.
+//
+// The "format" query parameter may be set to "graphml" to write the graph in
+// the GraphML format instead, for use with external graph tools. GraphML
+// nodes include the type of each block, and edges include the expressions
+// which create the dependency. GraphML encodings are never cached.
+//
+// The "propagations" query parameter annotates each edge with the number of
+// updates propagated along it since it was created, from a block to the
+// blocks depending on it. DOT edges are labeled with the count and drawn
+// thicker the more updates they propagated. Graphs with propagations are
+// never cached.
+//
+// The "group" query parameter groups DOT nodes into a cluster for each value
+// of the tags with the given key, so "group=team" draws a cluster for every
+// "team:<value>" tag set by the tags meta-argument. Components without such a
+// tag aren't grouped. Grouped graphs are never cached. GraphML nodes always
+// include the tags of components.
+//
+// The dot format is written by GraphvizRenderer, and other formats by the
+// renderers in [Options.GraphRenderers], keyed by format name. Renderers
+// registered as dot replace GraphvizRenderer; the graphml format can't be
+// replaced. The "compact", "group", and "propagations" query parameters are
+// passed to renderers as [GraphRenderOptions]. When "format" isn't provided,
+// it's picked from the media types in the Accept header of the request,
+// defaulting to dot.
+func GraphHandler(f *Flow) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		format := query.Get("format")
+		if format == "" {
+			format = f.negotiateGraphFormat(r.Header.Get("Accept"))
+		}
+		if format == "" {
+			format = "dot"
+		}
+
+		var propagations func(dag.Edge) uint64
+		if _, ok := query["propagations"]; ok {
+			propagations = f.loader.EdgePropagations
+		}
+
+		if format == "graphml" {
+			f.serveGraphML(w, query, propagations)
+			return
+		}
+
+		renderer, ok := f.graphRenderer(format)
+		if !ok {
+			http.Error(w, f.invalidGraphFormat(format).Error(), http.StatusBadRequest)
+			return
+		}
+
+		var opts GraphRenderOptions
+		_, opts.Compact = query["compact"]
+		opts.GroupBy = query.Get("group")
+		if propagations != nil {
+			opts.Propagations = func(e GraphModelEdge) uint64 {
+				return propagations(dag.Edge{From: &modelNode{id: e.From}, To: &modelNode{id: e.To}})
+			}
+		}
+		f.serveRenderedGraph(w, query, format, renderer, opts)
+	}
+}
+
+// serveGraphML writes the current graph in the GraphML format.
+func (f *Flow) serveGraphML(w http.ResponseWriter, query url.Values, propagations func(dag.Edge) uint64) {
+	for _, param := range []string{"compact", "group"} {
+		if _, ok := query[param]; ok {
+			http.Error(w, fmt.Sprintf("%s isn't supported by the graphml format", param), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var (
+		bb  []byte
+		err error
+	)
+	if root := query.Get("root"); root != "" {
+		depth, dir, err := parseGraphLimits(query)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var found bool
+		bb, found, err = f.subgraphGraphML(root, depth, dir, propagations)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		} else if !found {
+			http.Error(w, fmt.Sprintf("node %q does not exist", root), http.StatusNotFound)
+			return
+		}
+	} else {
+		bb, err = f.graphGraphML(propagations)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", graphMLContentType)
+	_, _ = w.Write(bb)
+}
+
+// parseGraphLimits parses the depth and direction query parameters used to
+// limit the graph written by GraphHandler.
+func parseGraphLimits(query url.Values) (depth int, dir dag.Direction, err error) {
+	depth = -1
+	if s := query.Get("depth"); s != "" {
+		depth, err = strconv.Atoi(s)
+		if err != nil || depth < 0 {
+			return 0, 0, fmt.Errorf("invalid depth %q: must be a non-negative integer", s)
+		}
+	}
+
+	switch s := query.Get("direction"); s {
+	case "", "both":
+		dir = dag.DirectionBoth
+	case "dependencies":
+		dir = dag.DirectionDependencies
+	case "dependants":
+		dir = dag.DirectionDependants
+	default:
+		return 0, 0, fmt.Errorf("invalid direction %q: must be one of both, dependencies, or dependants", s)
+	}
+	return depth, dir, nil
+}
+
+// graphCache holds the rendered graphs of a controller for a specific load
+// generation.
+type graphCache struct {
+	mut        sync.Mutex
+	generation uint64
+	rendered   map[graphCacheKey][]byte
+}
+
+// graphCacheKey identifies a graph rendered without a root, group, or
+// propagations.
+type graphCacheKey struct {
+	format  string
+	compact bool
+}
+
+// get returns the cached rendering for key, or nil if there's none for the
+// load generation.
+func (c *graphCache) get(generation uint64, key graphCacheKey) []byte {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if c.generation != generation {
+		return nil
+	}
+	return c.rendered[key]
+}
+
+// put caches bb as the rendering for key in the load generation, dropping
+// renderings of other generations.
+func (c *graphCache) put(generation uint64, key graphCacheKey, bb []byte) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if c.rendered == nil || c.generation != generation {
+		c.generation = generation
+		c.rendered = make(map[graphCacheKey][]byte)
+	}
+	c.rendered[key] = bb
+}
+
+// dotOptions controls how graphs are encoded in the DOT format.
+type dotOptions struct {
+	compact      bool                  // Write the encoding on a single line.
+	propagations func(dag.Edge) uint64 // Labels edges with their propagation counts if set.
+	groupBy      string                // Groups nodes by the values of tags with this key if set.
+}
+
+// encodeDOT encodes g in the Graphviz DOT format. Nodes and edges are sorted
+// so the same graph always has the same encoding. Compact encodings separate
+// statements with semicolons only, so they fit on a single line.
+//
+// If opts.propagations is set, edges are labeled with the number of updates
+// it returns for them, and their width grows logarithmically with the count.
+// If opts.groupBy is set, grouped nodes are written in a cluster subgraph for
+// each group, after the nodes which aren't grouped.
+func encodeDOT(g *dag.Graph, opts dotOptions) []byte {
+	var (
+		compact      = opts.compact
+		propagations = opts.propagations
+	)
+
+	nodes := g.Nodes()
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].NodeID() < nodes[j].NodeID() })
+	nodes, groups, groupNames := groupNodes(nodes, opts.groupBy)
+
+	edges := g.Edges()
+	sort.Slice(edges, func(i, j int) bool {
+		if from1, from2 := edges[i].From.NodeID(), edges[j].From.NodeID(); from1 != from2 {
+			return from1 < from2
+		}
+		return edges[i].To.NodeID() < edges[j].To.NodeID()
+	})
+
+	var buf bytes.Buffer
+	if compact {
+		buf.WriteString("digraph{")
+		for _, n := range nodes {
+			fmt.Fprintf(&buf, "%q;", n.NodeID())
+		}
+		for _, name := range groupNames {
+			fmt.Fprintf(&buf, "subgraph %q{label=%q;", "cluster_"+name, name)
+			for _, n := range groups[name] {
+				fmt.Fprintf(&buf, "%q;", n.NodeID())
+			}
+			buf.WriteString("}")
+		}
+		for _, e := range edges {
+			fmt.Fprintf(&buf, "%q->%q%s;", e.From.NodeID(), e.To.NodeID(), edgeAttrs(e, propagations, ","))
+		}
+		buf.WriteString("}")
+		return buf.Bytes()
+	}
+
+	buf.WriteString("digraph {\n")
+	for _, n := range nodes {
+		fmt.Fprintf(&buf, "\t%q;\n", n.NodeID())
+	}
+	for _, name := range groupNames {
+		fmt.Fprintf(&buf, "\tsubgraph %q {\n\t\tlabel=%q;\n", "cluster_"+name, name)
+		for _, n := range groups[name] {
+			fmt.Fprintf(&buf, "\t\t%q;\n", n.NodeID())
+		}
+		buf.WriteString("\t}\n")
+	}
+	for _, e := range edges {
+		attrs := edgeAttrs(e, propagations, ", ")
+		if attrs != "" {
+			attrs = " " + attrs
+		}
+		fmt.Fprintf(&buf, "\t%q -> %q%s;\n", e.From.NodeID(), e.To.NodeID(), attrs)
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
+
+// taggedNode is a node with tags, such as a component.
+type taggedNode interface {
+	dag.Node
+	Tags() []string
+}
+
+// groupNodes splits the sorted nodes into the nodes which aren't grouped and
+// groups of components by the first of their tags with the key groupBy,
+// such as "team:infra" for the key "team". groupNames holds the sorted names
+// of the groups. Nodes aren't grouped if groupBy is empty.
+func groupNodes(nodes []dag.Node, groupBy string) (ungrouped []dag.Node, groups map[string][]dag.Node, groupNames []string) {
+	if groupBy == "" {
+		return nodes, nil, nil
+	}
+
+	groups = make(map[string][]dag.Node)
+	for _, n := range nodes {
+		var group string
+		if tn, ok := n.(taggedNode); ok {
+			for _, tag := range tn.Tags() {
+				if strings.HasPrefix(tag, groupBy+":") {
+					group = tag
+					break
+				}
+			}
+		}
+		if group == "" {
+			ungrouped = append(ungrouped, n)
+			continue
+		}
+		if _, ok := groups[group]; !ok {
+			groupNames = append(groupNames, group)
+		}
+		groups[group] = append(groups[group], n)
+	}
+	sort.Strings(groupNames)
+	return ungrouped, groups, groupNames
+}
+
+// edgeAttrs returns the DOT attribute list of e, with attributes separated
+// by sep, or an empty string if propagations is nil.
+func edgeAttrs(e dag.Edge, propagations func(dag.Edge) uint64, sep string) string {
+	if propagations == nil {
+		return ""
+	}
+	count := propagations(e)
+	penwidth := 1 + math.Log10(1+float64(count))
+	return fmt.Sprintf("[label=%q%spenwidth=%s]", strconv.FormatUint(count, 10), sep, strconv.FormatFloat(penwidth, 'f', 2, 64))
+}
+
+// graphGraphML returns the GraphML encoding of the current graph.
+func (f *Flow) graphGraphML(propagations func(dag.Edge) uint64) ([]byte, error) {
+	f.loadMut.RLock()
+	defer f.loadMut.RUnlock()
+
+	g := f.loader.Graph()
+	return encodeGraphML(g, g, propagations)
+}
+
+// subgraphGraphML returns the GraphML encoding of the nodes of the current
+// graph within depth edges of the node with ID root. It returns false if root
+// doesn't exist.
+func (f *Flow) subgraphGraphML(root string, depth int, dir dag.Direction, propagations func(dag.Edge) uint64) ([]byte, bool, error) {
+	f.loadMut.RLock()
+	defer f.loadMut.RUnlock()
+
+	g := f.loader.Graph()
+	n := g.GetByID(root)
+	if n == nil {
+		return nil, false, nil
+	}
+	bb, err := encodeGraphML(dag.Neighborhood(g, n, depth, dir), g, propagations)
+	return bb, true, err
+}
+
+// encodeGraphML encodes g in the GraphML format. Nodes include their type,
+// and the component name for components. Edges include the expressions which
+// reference the dependency, which are resolved against full, the graph g was
+// taken from. If propagations is non-nil, edges also include the number of
+// updates propagated along them.
+func encodeGraphML(g, full *dag.Graph, propagations func(dag.Edge) uint64) ([]byte, error) {
+	return dag.MarshalGraphML(g, dag.GraphMLOptions{
+		NodeData: func(n dag.Node) map[string]string {
+			data := map[string]string{"type": graphNodeType(n)}
+			if cn, ok := n.(*controller.ComponentNode); ok {
+				data["component"] = cn.ComponentName()
+				if tags := cn.Tags(); len(tags) > 0 {
+					data["tags"] = strings.Join(tags, ",")
+				}
+			}
+			return data
+		},
+		EdgeData: func(e dag.Edge) map[string]string {
+			data := make(map[string]string)
+			if refs := controller.EdgeReferences(full, e); len(refs) > 0 {
+				data["references"] = strings.Join(refs, ",")
+			}
+			if propagations != nil {
+				data["propagations"] = strconv.FormatUint(propagations(e), 10)
+			}
+			if len(data) == 0 {
+				return nil
+			}
+			return data
+		},
+	})
+}
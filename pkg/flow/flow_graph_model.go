@@ -0,0 +1,114 @@
+package flow
+
+import (
+	"sort"
+
+	"github.com/grafana/agent/pkg/flow/internal/controller"
+	"github.com/grafana/agent/pkg/flow/internal/dag"
+)
+
+// GraphModel is a serializable representation of the graph of a controller,
+// meant to be compared against golden files in tests. Nodes, edges, and
+// references are sorted, so the same config always has the same GraphModel.
+type GraphModel struct {
+	Nodes []GraphModelNode `json:"nodes"` // Nodes sorted by ID.
+	Edges []GraphModelEdge `json:"edges"` // Edges sorted by From, then To.
+}
+
+// GraphModelNode is a node of a GraphModel.
+type GraphModelNode struct {
+	ID string `json:"id"` // ID of the block, such as "local.file.example".
+
+	// Type is the type of block: "component", "service", or "config" for
+	// config blocks such as logging.
+	Type string `json:"type"`
+
+	// Component is the name of the component, such as "local.file". It's only
+	// set for components.
+	Component string `json:"component,omitempty"`
+
+	// Tags holds the tags of the component set by the tags meta-argument. It's
+	// only set for components.
+	Tags []string `json:"tags,omitempty"`
+
+	// References holds the sorted IDs of the nodes this node depends on.
+	References []string `json:"references"`
+}
+
+// GraphModelEdge is an edge of a GraphModel, pointing from a node to a node
+// it depends on.
+type GraphModelEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+
+	// Expressions holds the sorted expressions in the block of From which
+	// reference To. Expressions are written up to the last field accessed
+	// before any indexing or function calls.
+	Expressions []string `json:"expressions"`
+}
+
+// GraphModel returns the graph of f as of the most recent call to LoadSource.
+// The graph is the reduced graph used for evaluation, which is also written
+// by GraphHandler. Nodes of modules aren't included.
+func (f *Flow) GraphModel() GraphModel {
+	f.loadMut.RLock()
+	defer f.loadMut.RUnlock()
+
+	return newGraphModel(f.loader.Graph())
+}
+
+// newGraphModel returns the GraphModel of g.
+func newGraphModel(g *dag.Graph) GraphModel {
+	nodes := g.Nodes()
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].NodeID() < nodes[j].NodeID() })
+
+	edges := g.Edges()
+	sort.Slice(edges, func(i, j int) bool {
+		if from1, from2 := edges[i].From.NodeID(), edges[j].From.NodeID(); from1 != from2 {
+			return from1 < from2
+		}
+		return edges[i].To.NodeID() < edges[j].To.NodeID()
+	})
+
+	model := GraphModel{
+		Nodes: make([]GraphModelNode, 0, len(nodes)),
+		Edges: make([]GraphModelEdge, 0, len(edges)),
+	}
+	for _, n := range nodes {
+		node := GraphModelNode{
+			ID:         n.NodeID(),
+			Type:       graphNodeType(n),
+			References: []string{},
+		}
+		if cn, ok := n.(*controller.ComponentNode); ok {
+			node.Component = cn.ComponentName()
+			node.Tags = cn.Tags()
+		}
+		for _, dep := range g.Dependencies(n) {
+			node.References = append(node.References, dep.NodeID())
+		}
+		sort.Strings(node.References)
+		model.Nodes = append(model.Nodes, node)
+	}
+	for _, e := range edges {
+		model.Edges = append(model.Edges, GraphModelEdge{
+			From:        e.From.NodeID(),
+			To:          e.To.NodeID(),
+			Expressions: controller.EdgeReferences(g, e),
+		})
+	}
+	return model
+}
+
+// graphNodeType returns the type of block held by n: "component", "service",
+// or "config".
+func graphNodeType(n dag.Node) string {
+	switch n.(type) {
+	case *controller.ComponentNode:
+		return "component"
+	case *controller.ServiceNode:
+		return "service"
+	default:
+		return "config"
+	}
+}
@@ -0,0 +1,50 @@
+package flow
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestController_GraphModel(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+	ctrl := New(testOptions(t))
+	defer cleanUpController(ctrl)
+
+	f, err := ParseSource(t.Name(), []byte(`
+		testcomponents.passthrough "c" {
+			input = testcomponents.passthrough.a.output + testcomponents.passthrough.b.output
+		}
+
+		testcomponents.passthrough "b" {
+			input = testcomponents.passthrough.a.output
+		}
+
+		testcomponents.passthrough "a" {
+			input = "hello, world!"
+		}
+	`))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	// The model is the reduced graph, so c doesn't depend on a directly.
+	bb, err := json.MarshalIndent(ctrl.GraphModel(), "", "  ")
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"nodes": [
+			{"id": "logging", "type": "config", "references": []},
+			{"id": "testcomponents.passthrough.a", "type": "component", "component": "testcomponents.passthrough", "references": []},
+			{"id": "testcomponents.passthrough.b", "type": "component", "component": "testcomponents.passthrough", "references": ["testcomponents.passthrough.a"]},
+			{"id": "testcomponents.passthrough.c", "type": "component", "component": "testcomponents.passthrough", "references": ["testcomponents.passthrough.b"]},
+			{"id": "tracing", "type": "config", "references": []}
+		],
+		"edges": [
+			{"from": "testcomponents.passthrough.b", "to": "testcomponents.passthrough.a", "expressions": ["testcomponents.passthrough.a.output"]},
+			{"from": "testcomponents.passthrough.c", "to": "testcomponents.passthrough.b", "expressions": ["testcomponents.passthrough.b.output"]}
+		]
+	}`, string(bb))
+
+	// The same config always has the same model.
+	require.Equal(t, ctrl.GraphModel(), ctrl.GraphModel())
+}
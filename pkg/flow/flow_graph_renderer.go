@@ -0,0 +1,214 @@
+package flow
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/grafana/agent/pkg/flow/internal/dag"
+)
+
+// GraphRenderer renders the graph of a controller for GraphHandler.
+// Renderers are registered by format name in [Options.GraphRenderers].
+type GraphRenderer interface {
+	// ContentType returns the media type of rendered graphs, such as
+	// "application/json". GraphHandler uses it as the Content-Type of
+	// responses, and to pick the renderer from the Accept header of requests.
+	ContentType() string
+
+	// Render writes g to w. Renderers may ignore options they don't support.
+	Render(g GraphModel, opts GraphRenderOptions, w io.Writer) error
+}
+
+// GraphRenderOptions holds the options of a request to GraphHandler which are
+// passed to renderers.
+type GraphRenderOptions struct {
+	// Compact is set by the "compact" query parameter, requesting an encoding
+	// without whitespace.
+	Compact bool
+
+	// GroupBy is set by the "group" query parameter to the tag key to group
+	// nodes by, such as "team" for "team:<value>" tags.
+	GroupBy string
+
+	// Propagations is set by the "propagations" query parameter. It returns
+	// the number of updates propagated along an edge of the graph being
+	// rendered since it was created.
+	Propagations func(e GraphModelEdge) uint64
+}
+
+// cacheable returns whether graphs rendered with opts may be cached. Only
+// Compact is part of the cache key.
+func (o GraphRenderOptions) cacheable() bool {
+	return o.GroupBy == "" && o.Propagations == nil
+}
+
+// GraphvizRenderer is a GraphRenderer which renders graphs in the Graphviz
+// DOT format, the default format of GraphHandler. It supports all
+// GraphRenderOptions.
+type GraphvizRenderer struct{}
+
+var _ GraphRenderer = GraphvizRenderer{}
+
+// ContentType implements GraphRenderer.
+func (GraphvizRenderer) ContentType() string { return "text/vnd.graphviz" }
+
+// Render implements GraphRenderer.
+func (GraphvizRenderer) Render(g GraphModel, opts GraphRenderOptions, w io.Writer) error {
+	dotOpts := dotOptions{compact: opts.Compact, groupBy: opts.GroupBy}
+	if opts.Propagations != nil {
+		dotOpts.propagations = func(e dag.Edge) uint64 {
+			return opts.Propagations(GraphModelEdge{From: e.From.NodeID(), To: e.To.NodeID()})
+		}
+	}
+	_, err := w.Write(encodeDOT(g.dag(), dotOpts))
+	return err
+}
+
+// graphMLContentType is the media type of graphs in the GraphML format.
+const graphMLContentType = "application/graphml+xml"
+
+// modelNode is a node of a graph built from a GraphModel.
+type modelNode struct {
+	id   string
+	tags []string
+}
+
+func (n *modelNode) NodeID() string { return n.id }
+
+// Tags returns the tags of the component held by the node.
+func (n *modelNode) Tags() []string { return n.tags }
+
+// dag returns a graph with the nodes and edges of m.
+func (m GraphModel) dag() *dag.Graph {
+	var g dag.Graph
+	for _, n := range m.Nodes {
+		g.Add(&modelNode{id: n.ID, tags: n.Tags})
+	}
+	for _, e := range m.Edges {
+		g.AddEdge(dag.Edge{From: g.GetByID(e.From), To: g.GetByID(e.To)})
+	}
+	return &g
+}
+
+// graphFormats returns the names of the formats supported by GraphHandler
+// for f: the dot and graphml formats, followed by the sorted names of the
+// other renderers in [Options.GraphRenderers].
+func (f *Flow) graphFormats() []string {
+	formats := []string{"dot", "graphml"}
+	custom := make([]string, 0, len(f.opts.GraphRenderers))
+	for name := range f.opts.GraphRenderers {
+		if name != "dot" && name != "graphml" {
+			custom = append(custom, name)
+		}
+	}
+	sort.Strings(custom)
+	return append(formats, custom...)
+}
+
+// negotiateGraphFormat returns the name of the first format supported by f
+// whose media type is listed in the Accept header accept, or an empty string
+// if there's no such format. Quality values are ignored.
+func (f *Flow) negotiateGraphFormat(accept string) string {
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(mediaRange)
+		if err != nil {
+			continue
+		}
+		for _, format := range f.graphFormats() {
+			if f.graphContentType(format) == mediaType {
+				return format
+			}
+		}
+	}
+	return ""
+}
+
+// graphContentType returns the media type of graphs in the given supported
+// format.
+func (f *Flow) graphContentType(format string) string {
+	if format == "graphml" {
+		return graphMLContentType
+	}
+	renderer, _ := f.graphRenderer(format)
+	return renderer.ContentType()
+}
+
+// graphRenderer returns the renderer of format. The dot format is rendered by
+// GraphvizRenderer unless it's replaced in [Options.GraphRenderers]. It
+// returns false if there's no renderer for format.
+func (f *Flow) graphRenderer(format string) (GraphRenderer, bool) {
+	if renderer, ok := f.opts.GraphRenderers[format]; ok && format != "graphml" {
+		return renderer, true
+	}
+	if format == "dot" {
+		return GraphvizRenderer{}, true
+	}
+	return nil, false
+}
+
+// invalidGraphFormat returns the error reported by GraphHandler for an
+// unsupported format.
+func (f *Flow) invalidGraphFormat(format string) error {
+	formats := f.graphFormats()
+	last := len(formats) - 1
+	if last == 1 {
+		return fmt.Errorf("invalid format %q: must be one of dot or graphml", format)
+	}
+	return fmt.Errorf("invalid format %q: must be one of %s, or %s", format, strings.Join(formats[:last], ", "), formats[last])
+}
+
+// serveRenderedGraph writes the current graph rendered by the renderer of
+// format. Graphs without a root are cached per format until the next load
+// when opts are cacheable, unless the nocache query parameter is provided.
+func (f *Flow) serveRenderedGraph(w http.ResponseWriter, query url.Values, format string, renderer GraphRenderer, opts GraphRenderOptions) {
+	f.loadMut.RLock()
+	defer f.loadMut.RUnlock()
+
+	var (
+		g          = f.loader.Graph()
+		generation = f.loadGeneration.Load()
+		root       = query.Get("root")
+		key        = graphCacheKey{format: format, compact: opts.Compact}
+	)
+	_, bypass := query["nocache"]
+	useCache := root == "" && !bypass && opts.cacheable()
+
+	if root != "" {
+		depth, dir, err := parseGraphLimits(query)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		n := g.GetByID(root)
+		if n == nil {
+			http.Error(w, fmt.Sprintf("node %q does not exist", root), http.StatusNotFound)
+			return
+		}
+		g = dag.Neighborhood(g, n, depth, dir)
+	}
+
+	bb := []byte(nil)
+	if useCache {
+		bb = f.graphCache.get(generation, key)
+	}
+	if bb == nil {
+		var buf bytes.Buffer
+		if err := renderer.Render(newGraphModel(g), opts, &buf); err != nil {
+			http.Error(w, fmt.Sprintf("error rendering graph: %s", err), http.StatusInternalServerError)
+			return
+		}
+		bb = buf.Bytes()
+		if useCache {
+			f.graphCache.put(generation, key, bb)
+		}
+	}
+
+	w.Header().Set("Content-Type", renderer.ContentType())
+	_, _ = w.Write(bb)
+}
@@ -0,0 +1,459 @@
+package flow
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grafana/agent/pkg/flow/internal/controller"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraphHandler(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+	ctrl := New(testOptions(t))
+	defer cleanUpController(ctrl)
+
+	load := func(content string) {
+		f, err := ParseSource(t.Name(), []byte(content))
+		require.NoError(t, err)
+		require.NoError(t, ctrl.LoadSource(f, nil))
+	}
+	get := func(target string) string {
+		rec := httptest.NewRecorder()
+		GraphHandler(ctrl).ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		require.Equal(t, "text/vnd.graphviz", rec.Header().Get("Content-Type"))
+		bb, err := io.ReadAll(rec.Body)
+		require.NoError(t, err)
+		return string(bb)
+	}
+
+	load(`
+		testcomponents.passthrough "a" {
+			input = "hello, world!"
+		}
+
+		testcomponents.passthrough "b" {
+			input = testcomponents.passthrough.a.output
+		}
+	`)
+
+	expect := `digraph {
+	"logging";
+	"testcomponents.passthrough.a";
+	"testcomponents.passthrough.b";
+	"tracing";
+	"testcomponents.passthrough.b" -> "testcomponents.passthrough.a";
+}
+`
+	require.Equal(t, expect, get("/graph"))
+
+	// Cached responses must match uncached responses.
+	require.Equal(t, expect, get("/graph"))
+	require.Equal(t, expect, get("/graph?nocache"))
+
+	// Compact encodings are cached separately from regular encodings.
+	compact := `digraph{"logging";"testcomponents.passthrough.a";"testcomponents.passthrough.b";"tracing";"testcomponents.passthrough.b"->"testcomponents.passthrough.a";}`
+	require.Equal(t, compact, get("/graph?compact"))
+	require.Equal(t, compact, get("/graph?compact"))
+	require.Equal(t, compact, get("/graph?compact&nocache"))
+	require.Equal(t, expect, get("/graph"))
+	require.Equal(t, `digraph{"testcomponents.passthrough.a";"testcomponents.passthrough.b";"testcomponents.passthrough.b"->"testcomponents.passthrough.a";}`,
+		get("/graph?compact&root=testcomponents.passthrough.b"))
+
+	// Reloading invalidates the cache.
+	load(`
+		testcomponents.passthrough "a" {
+			input = "hello, world!"
+		}
+	`)
+	require.Equal(t, `digraph {
+	"logging";
+	"testcomponents.passthrough.a";
+	"tracing";
+}
+`, get("/graph"))
+	require.Equal(t, `digraph{"logging";"testcomponents.passthrough.a";"tracing";}`, get("/graph?compact"))
+}
+
+func TestGraphHandler_Root(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+	ctrl := New(testOptions(t))
+	defer cleanUpController(ctrl)
+
+	f, err := ParseSource(t.Name(), []byte(`
+		testcomponents.passthrough "a" {
+			input = "hello, world!"
+		}
+
+		testcomponents.passthrough "b" {
+			input = testcomponents.passthrough.a.output
+		}
+
+		testcomponents.passthrough "c" {
+			input = testcomponents.passthrough.b.output
+		}
+	`))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	get := func(target string) (int, string) {
+		rec := httptest.NewRecorder()
+		GraphHandler(ctrl).ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		bb, err := io.ReadAll(rec.Body)
+		require.NoError(t, err)
+		return rec.Code, string(bb)
+	}
+
+	code, body := get("/graph?root=testcomponents.passthrough.b&depth=1&direction=dependencies")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, `digraph {
+	"testcomponents.passthrough.a";
+	"testcomponents.passthrough.b";
+	"testcomponents.passthrough.b" -> "testcomponents.passthrough.a";
+}
+`, body)
+
+	code, body = get("/graph?root=testcomponents.passthrough.c&depth=1")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, `digraph {
+	"testcomponents.passthrough.b";
+	"testcomponents.passthrough.c";
+	"testcomponents.passthrough.c" -> "testcomponents.passthrough.b";
+}
+`, body)
+
+	code, body = get("/graph?root=testcomponents.passthrough.c")
+	require.Equal(t, http.StatusOK, code)
+	require.Contains(t, body, `"testcomponents.passthrough.b" -> "testcomponents.passthrough.a";`)
+
+	code, _ = get("/graph?root=testcomponents.passthrough.missing")
+	require.Equal(t, http.StatusNotFound, code)
+
+	code, _ = get("/graph?root=testcomponents.passthrough.c&depth=-1")
+	require.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = get("/graph?root=testcomponents.passthrough.c&direction=sideways")
+	require.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestGraphHandler_GraphML(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+	ctrl := New(testOptions(t))
+	defer cleanUpController(ctrl)
+
+	f, err := ParseSource(t.Name(), []byte(`
+		testcomponents.passthrough "a" {
+			input = "hello, world!"
+		}
+
+		testcomponents.passthrough "b" {
+			input = testcomponents.passthrough.a.output
+		}
+	`))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	get := func(target string) (int, string) {
+		rec := httptest.NewRecorder()
+		GraphHandler(ctrl).ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		bb, err := io.ReadAll(rec.Body)
+		require.NoError(t, err)
+		return rec.Code, string(bb)
+	}
+
+	code, body := get("/graph?format=graphml&root=testcomponents.passthrough.b&depth=1")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<graphml xmlns="http://graphml.graphdrawing.org/xmlns">
+  <key id="node_component" for="node" attr.name="component" attr.type="string"></key>
+  <key id="node_type" for="node" attr.name="type" attr.type="string"></key>
+  <key id="edge_references" for="edge" attr.name="references" attr.type="string"></key>
+  <graph edgedefault="directed">
+    <node id="testcomponents.passthrough.a">
+      <data key="node_component">testcomponents.passthrough</data>
+      <data key="node_type">component</data>
+    </node>
+    <node id="testcomponents.passthrough.b">
+      <data key="node_component">testcomponents.passthrough</data>
+      <data key="node_type">component</data>
+    </node>
+    <edge source="testcomponents.passthrough.b" target="testcomponents.passthrough.a">
+      <data key="edge_references">testcomponents.passthrough.a.output</data>
+    </edge>
+  </graph>
+</graphml>
+`, body)
+
+	code, body = get("/graph?format=graphml")
+	require.Equal(t, http.StatusOK, code)
+	require.Contains(t, body, `<node id="logging">`)
+	require.Contains(t, body, `<data key="node_type">config</data>`)
+
+	code, _ = get("/graph?format=svg")
+	require.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = get("/graph?format=graphml&compact")
+	require.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestGraphHandler_Propagations(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+	ctrl := New(testOptions(t))
+	defer cleanUpController(ctrl)
+
+	f, err := ParseSource(t.Name(), []byte(`
+		testcomponents.passthrough "a" {
+			input = "hello, world!"
+		}
+
+		testcomponents.passthrough "b" {
+			input = testcomponents.passthrough.a.output
+		}
+	`))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	get := func(target string) string {
+		rec := httptest.NewRecorder()
+		GraphHandler(ctrl).ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		bb, err := io.ReadAll(rec.Body)
+		require.NoError(t, err)
+		return string(bb)
+	}
+
+	// The controller isn't running, so updates are only propagated when
+	// requested here.
+	require.Equal(t, `digraph{"testcomponents.passthrough.a";"testcomponents.passthrough.b";"testcomponents.passthrough.b"->"testcomponents.passthrough.a"[label="0",penwidth=1.00];}`,
+		get("/graph?compact&propagations&root=testcomponents.passthrough.a"))
+
+	a := ctrl.loader.Graph().GetByID("testcomponents.passthrough.a").(*controller.ComponentNode)
+	for i := 0; i < 9; i++ {
+		ctrl.loader.EvaluateDependantsInOrder(context.Background(), []*controller.ComponentNode{a})
+	}
+
+	// Graphs with propagations aren't cached.
+	require.Equal(t, `digraph {
+	"testcomponents.passthrough.a";
+	"testcomponents.passthrough.b";
+	"testcomponents.passthrough.b" -> "testcomponents.passthrough.a" [label="9", penwidth=2.00];
+}
+`, get("/graph?propagations&root=testcomponents.passthrough.a"))
+	require.Contains(t, get("/graph?propagations"), `[label="9", penwidth=2.00]`)
+	require.NotContains(t, get("/graph"), "label")
+	require.Contains(t, get("/graph?format=graphml&propagations"), `<data key="edge_propagations">9</data>`)
+}
+
+func TestGraphHandler_Group(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+	ctrl := New(testOptions(t))
+	defer cleanUpController(ctrl)
+
+	f, err := ParseSource(t.Name(), []byte(`
+		testcomponents.passthrough "a" {
+			input = "hello"
+			tags  = ["tier:prod", "team:infra"]
+		}
+
+		testcomponents.passthrough "b" {
+			input = testcomponents.passthrough.a.output
+			tags  = ["team:apps"]
+		}
+
+		testcomponents.passthrough "c" {
+			input = "hello"
+			tags  = ["team:infra"]
+		}
+	`))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	get := func(target string) (int, string) {
+		rec := httptest.NewRecorder()
+		GraphHandler(ctrl).ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		bb, err := io.ReadAll(rec.Body)
+		require.NoError(t, err)
+		return rec.Code, string(bb)
+	}
+
+	code, body := get("/graph?group=team")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, `digraph {
+	"logging";
+	"tracing";
+	subgraph "cluster_team:apps" {
+		label="team:apps";
+		"testcomponents.passthrough.b";
+	}
+	subgraph "cluster_team:infra" {
+		label="team:infra";
+		"testcomponents.passthrough.a";
+		"testcomponents.passthrough.c";
+	}
+	"testcomponents.passthrough.b" -> "testcomponents.passthrough.a";
+}
+`, body)
+
+	_, body = get("/graph?group=tier&compact&root=testcomponents.passthrough.b")
+	require.Equal(t, `digraph{"testcomponents.passthrough.b";subgraph "cluster_tier:prod"{label="tier:prod";"testcomponents.passthrough.a";}"testcomponents.passthrough.b"->"testcomponents.passthrough.a";}`, body)
+
+	// Grouping doesn't affect the cached encoding.
+	_, body = get("/graph")
+	require.NotContains(t, body, "subgraph")
+
+	_, body = get("/graph?format=graphml")
+	require.Contains(t, body, `<data key="node_tags">tier:prod,team:infra</data>`)
+
+	code, _ = get("/graph?format=graphml&group=team")
+	require.Equal(t, http.StatusBadRequest, code)
+}
+
+// nodeListRenderer is a GraphRenderer which writes the IDs of nodes, one per
+// line. Compact lists are separated by spaces. Nodes are followed by the
+// value of their tag with the GroupBy key, and edges with their propagations
+// are listed after the nodes.
+type nodeListRenderer struct{}
+
+func (nodeListRenderer) ContentType() string { return "text/plain" }
+
+func (nodeListRenderer) Render(g GraphModel, opts GraphRenderOptions, w io.Writer) error {
+	sep := "\n"
+	if opts.Compact {
+		sep = " "
+	}
+	for _, n := range g.Nodes {
+		line := n.ID
+		for _, tag := range n.Tags {
+			if opts.GroupBy != "" && strings.HasPrefix(tag, opts.GroupBy+":") {
+				line += "=" + tag
+			}
+		}
+		if _, err := io.WriteString(w, line+sep); err != nil {
+			return err
+		}
+	}
+	if opts.Propagations != nil {
+		for _, e := range g.Edges {
+			if _, err := fmt.Fprintf(w, "%s->%s=%d%s", e.From, e.To, opts.Propagations(e), sep); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// failingRenderer is a GraphRenderer which always fails.
+type failingRenderer struct{}
+
+func (failingRenderer) ContentType() string { return "application/octet-stream" }
+
+func (failingRenderer) Render(g GraphModel, opts GraphRenderOptions, w io.Writer) error {
+	_, _ = io.WriteString(w, "partial")
+	return fmt.Errorf("renderer failed")
+}
+
+func TestGraphHandler_Renderers(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+	opts := testOptions(t)
+	opts.GraphRenderers = map[string]GraphRenderer{"nodes": nodeListRenderer{}, "failing": failingRenderer{}}
+	ctrl := New(opts)
+	defer cleanUpController(ctrl)
+
+	f, err := ParseSource(t.Name(), []byte(`
+		testcomponents.passthrough "a" {
+			input = "hello, world!"
+			tags  = ["tier:prod"]
+		}
+
+		testcomponents.passthrough "b" {
+			input = testcomponents.passthrough.a.output
+		}
+	`))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	get := func(target, accept string) (int, string, string) {
+		req := httptest.NewRequest("GET", target, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		GraphHandler(ctrl).ServeHTTP(rec, req)
+		bb, err := io.ReadAll(rec.Body)
+		require.NoError(t, err)
+		return rec.Code, rec.Header().Get("Content-Type"), string(bb)
+	}
+
+	code, contentType, body := get("/graph?format=nodes", "")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "text/plain", contentType)
+	require.Equal(t, "logging\ntestcomponents.passthrough.a\ntestcomponents.passthrough.b\ntracing\n", body)
+
+	_, _, body = get("/graph?format=nodes&root=testcomponents.passthrough.b&depth=1", "")
+	require.Equal(t, "testcomponents.passthrough.a\ntestcomponents.passthrough.b\n", body)
+
+	// Without a format, the renderer is picked from the Accept header.
+	_, contentType, body = get("/graph", "text/html, text/plain;q=0.9")
+	require.Equal(t, "text/plain", contentType)
+	require.Equal(t, "logging\ntestcomponents.passthrough.a\ntestcomponents.passthrough.b\ntracing\n", body)
+	_, contentType, _ = get("/graph", "application/graphml+xml")
+	require.Equal(t, "application/graphml+xml", contentType)
+	_, contentType, _ = get("/graph", "*/*")
+	require.Equal(t, "text/vnd.graphviz", contentType)
+
+	code, _, body = get("/graph?format=svg", "")
+	require.Equal(t, http.StatusBadRequest, code)
+	require.Equal(t, "invalid format \"svg\": must be one of dot, graphml, failing, or nodes\n", body)
+
+	// Options are passed to renderers.
+	_, _, body = get("/graph?format=nodes&compact", "")
+	require.Equal(t, "logging testcomponents.passthrough.a testcomponents.passthrough.b tracing ", body)
+	_, _, body = get("/graph?format=nodes&group=tier&root=testcomponents.passthrough.a&depth=0", "")
+	require.Equal(t, "testcomponents.passthrough.a=tier:prod\n", body)
+	_, _, body = get("/graph?format=nodes&propagations&root=testcomponents.passthrough.b&depth=1", "")
+	require.Equal(t, "testcomponents.passthrough.a\ntestcomponents.passthrough.b\ntestcomponents.passthrough.b->testcomponents.passthrough.a=0\n", body)
+
+	// Compact renderings are cached separately.
+	_, _, body = get("/graph?format=nodes", "")
+	require.Equal(t, "logging\ntestcomponents.passthrough.a\ntestcomponents.passthrough.b\ntracing\n", body)
+
+	code, _, body = get("/graph?format=failing", "")
+	require.Equal(t, http.StatusInternalServerError, code)
+	require.Equal(t, "error rendering graph: renderer failed\n", body)
+
+	// The dot format is written by GraphvizRenderer.
+	_, _, dot := get("/graph?group=tier&propagations", "")
+	var buf bytes.Buffer
+	require.NoError(t, GraphvizRenderer{}.Render(ctrl.GraphModel(), GraphRenderOptions{
+		GroupBy:      "tier",
+		Propagations: func(GraphModelEdge) uint64 { return 0 },
+	}, &buf))
+	require.Equal(t, dot, buf.String())
+}
+
+func TestGraphHandler_DotRenderer(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+	opts := testOptions(t)
+	opts.GraphRenderers = map[string]GraphRenderer{"dot": nodeListRenderer{}}
+	ctrl := New(opts)
+	defer cleanUpController(ctrl)
+
+	f, err := ParseSource(t.Name(), []byte(`
+		testcomponents.passthrough "a" {
+			input = "hello, world!"
+		}
+	`))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	rec := httptest.NewRecorder()
+	GraphHandler(ctrl).ServeHTTP(rec, httptest.NewRequest("GET", "/graph", nil))
+	require.Equal(t, "text/plain", rec.Header().Get("Content-Type"))
+	require.Equal(t, "logging\ntestcomponents.passthrough.a\ntracing\n", rec.Body.String())
+}
@@ -0,0 +1,247 @@
+package flow
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/pkg/flow/internal/controller"
+	"github.com/grafana/agent/pkg/flow/internal/dag"
+	"github.com/grafana/agent/pkg/flow/internal/stdlib"
+	"github.com/grafana/river/ast"
+	"github.com/grafana/river/diag"
+	"github.com/grafana/river/vm"
+)
+
+// LintRule is a rule checked against the components of a config by
+// [Flow.Lint] and [LintSource], such as requiring every component to have a
+// tag. Lint rules enforce conventions beyond what's required for a config to
+// load.
+type LintRule struct {
+	// Name identifies the rule, such as "require-tags". Names of registered
+	// rules must be unique.
+	Name string
+
+	// Description is a short description of what the rule enforces.
+	Description string
+
+	// Check returns a diagnostic for every violation of the rule in
+	// components, which are sorted by ID.
+	Check func(components []LintComponent) diag.Diagnostics
+
+	// OptIn rules enforce conventions which most configs don't follow, so
+	// they're left out of DefaultLintRules and only checked when requested by
+	// name.
+	OptIn bool
+}
+
+// LintComponent is a component of a config checked by lint rules.
+type LintComponent struct {
+	ID   string // ID of the component, such as "prometheus.scrape.default".
+	Name string // Name of the component, such as "prometheus.scrape".
+
+	// Block is the block of the component, without its meta-arguments once
+	// the config was loaded. It can be used to set the position of
+	// diagnostics.
+	Block *ast.BlockStmt
+
+	Tags       []string // Tags set by the tags meta-argument.
+	References []string // Sorted IDs of the blocks the component references.
+
+	// Arguments holds the evaluated arguments of the component, or nil if
+	// the component wasn't evaluated, such as when linting with LintSource.
+	Arguments component.Arguments
+}
+
+// Attribute returns the value of the top-level attribute of c with the given
+// name, such as "scrape_interval". The value is read from the evaluated
+// arguments of c when set, which include defaults for unset attributes.
+// Otherwise, the attribute is evaluated from the block of c as long as it
+// only uses the standard library, and ok is false if it references other
+// blocks.
+func (c LintComponent) Attribute(name string) (value any, ok bool) {
+	if c.Arguments != nil {
+		return argumentField(c.Arguments, name)
+	}
+
+	for _, stmt := range c.Block.Body {
+		attr, isAttr := stmt.(*ast.AttributeStmt)
+		if !isAttr || attr.Name.Name != name {
+			continue
+		}
+		if err := vm.New(attr.Value).Evaluate(&vm.Scope{Variables: stdlib.Identifiers}, &value); err != nil {
+			return nil, false
+		}
+		return value, true
+	}
+	return nil, false
+}
+
+// argumentField returns the field of args with the River attribute tag
+// name.
+func argumentField(args component.Arguments, name string) (any, bool) {
+	rv := reflect.ValueOf(args)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil, false
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, false
+	}
+
+	for i := 0; i < rv.NumField(); i++ {
+		tag, _, _ := strings.Cut(rv.Type().Field(i).Tag.Get("river"), ",")
+		if tag == name && rv.Type().Field(i).IsExported() {
+			return rv.Field(i).Interface(), true
+		}
+	}
+	return nil, false
+}
+
+var (
+	lintRulesMut sync.RWMutex
+	lintRules    = map[string]LintRule{}
+)
+
+// RegisterLintRule registers a lint rule, so it can be looked up by name with
+// GetLintRule and is listed by LintRules. RegisterLintRule is expected to be
+// called from init functions, and panics if a rule with the same name is
+// already registered.
+func RegisterLintRule(r LintRule) {
+	lintRulesMut.Lock()
+	defer lintRulesMut.Unlock()
+
+	if r.Name == "" || r.Check == nil {
+		panic("flow: lint rules must have a name and a Check function")
+	}
+	if _, exists := lintRules[r.Name]; exists {
+		panic(fmt.Sprintf("flow: lint rule %q already registered", r.Name))
+	}
+	lintRules[r.Name] = r
+}
+
+// GetLintRule returns the registered lint rule with the given name.
+func GetLintRule(name string) (LintRule, bool) {
+	lintRulesMut.RLock()
+	defer lintRulesMut.RUnlock()
+
+	r, ok := lintRules[name]
+	return r, ok
+}
+
+// LintRules returns every registered lint rule, sorted by name.
+func LintRules() []LintRule {
+	lintRulesMut.RLock()
+	defer lintRulesMut.RUnlock()
+
+	rules := make([]LintRule, 0, len(lintRules))
+	for _, r := range lintRules {
+		rules = append(rules, r)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Name < rules[j].Name })
+	return rules
+}
+
+// DefaultLintRules returns every registered lint rule which isn't OptIn,
+// sorted by name.
+func DefaultLintRules() []LintRule {
+	var rules []LintRule
+	for _, r := range LintRules() {
+		if !r.OptIn {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+// Lint checks rules against the components of f as of the most recent call to
+// LoadSource, and returns the diagnostics reported by the rules in order.
+// Components of modules aren't checked.
+func (f *Flow) Lint(rules []LintRule) diag.Diagnostics {
+	f.loadMut.RLock()
+	defer f.loadMut.RUnlock()
+
+	var (
+		g          = f.loader.Graph()
+		components []LintComponent
+	)
+	for _, n := range g.Nodes() {
+		cn, ok := n.(*controller.ComponentNode)
+		if !ok {
+			continue
+		}
+		components = append(components, LintComponent{
+			ID:         cn.NodeID(),
+			Name:       cn.ComponentName(),
+			Block:      cn.Block(),
+			Tags:       cn.Tags(),
+			References: lintReferences(g, n),
+			Arguments:  cn.Arguments(),
+		})
+	}
+	return lint(components, rules)
+}
+
+// LintSource checks rules against the components of source without loading
+// it, and returns the diagnostics reported by the rules in order. Components
+// aren't evaluated, so the Arguments of the checked components are nil, and
+// disabled components aren't checked.
+func LintSource(source *Source, rules []LintRule) diag.Diagnostics {
+	isComponent := make(map[string]bool, len(source.components))
+	for _, block := range source.components {
+		isComponent[controller.BlockComponentID(block).String()] = true
+	}
+
+	var (
+		g          = controller.NewReferenceGraph(source.configBlocks, source.components)
+		components []LintComponent
+	)
+	for _, n := range g.Nodes() {
+		if !isComponent[n.NodeID()] {
+			continue
+		}
+		block := n.(controller.BlockNode).Block()
+
+		c := LintComponent{
+			ID:         n.NodeID(),
+			Name:       block.GetBlockName(),
+			Block:      block,
+			References: lintReferences(g, n),
+		}
+		if tags, ok := c.Attribute("tags"); ok {
+			list, _ := tags.([]any)
+			for _, tag := range list {
+				if s, ok := tag.(string); ok {
+					c.Tags = append(c.Tags, s)
+				}
+			}
+		}
+		components = append(components, c)
+	}
+	return lint(components, rules)
+}
+
+// lintReferences returns the sorted IDs of the dependencies of n.
+func lintReferences(g *dag.Graph, n dag.Node) []string {
+	var refs []string
+	for _, dep := range g.Dependencies(n) {
+		refs = append(refs, dep.NodeID())
+	}
+	sort.Strings(refs)
+	return refs
+}
+
+func lint(components []LintComponent, rules []LintRule) diag.Diagnostics {
+	sort.Slice(components, func(i, j int) bool { return components[i].ID < components[j].ID })
+
+	var diags diag.Diagnostics
+	for _, r := range rules {
+		diags = append(diags, r.Check(components)...)
+	}
+	return diags
+}
@@ -0,0 +1,110 @@
+package flow
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/grafana/river/ast"
+	"github.com/grafana/river/diag"
+)
+
+func init() {
+	RegisterLintRule(RequireTagsLintRule())
+	RegisterLintRule(MinDurationLintRule("min-scrape-interval", "prometheus.scrape", "scrape_interval", 15*time.Second))
+}
+
+// RequireTagsLintRule returns the built-in "require-tags" lint rule, which
+// reports every component without any tags. Tags are optional, so the rule
+// is OptIn.
+func RequireTagsLintRule() LintRule {
+	return LintRule{
+		Name:        "require-tags",
+		Description: "Every component must have at least one tag.",
+		OptIn:       true,
+		Check: func(components []LintComponent) diag.Diagnostics {
+			var diags diag.Diagnostics
+			for _, c := range components {
+				if len(c.Tags) > 0 {
+					continue
+				}
+				diags.Add(diag.Diagnostic{
+					Severity: diag.SeverityLevelError,
+					Message:  fmt.Sprintf("%s must have at least one tag (require-tags)", c.ID),
+					StartPos: ast.StartPos(c.Block).Position(),
+					EndPos:   c.Block.LCurlyPos.Position(),
+				})
+			}
+			return diags
+		},
+	}
+}
+
+// MinDurationLintRule returns a lint rule with the given name which reports
+// every component named component whose duration attribute is shorter than
+// min, such as a prometheus.scrape component with a scrape_interval shorter
+// than 15s. The built-in "min-scrape-interval" rule is created with
+// MinDurationLintRule.
+//
+// Unset attributes are checked against the default of the component once the
+// config is loaded. Attributes which can't be evaluated without loading the
+// config, such as those referencing other components, are ignored by
+// LintSource.
+func MinDurationLintRule(name, component, attribute string, min time.Duration) LintRule {
+	return LintRule{
+		Name:        name,
+		Description: fmt.Sprintf("The %s attribute of %s components must be at least %s.", attribute, component, min),
+		Check: func(components []LintComponent) diag.Diagnostics {
+			var diags diag.Diagnostics
+			for _, c := range components {
+				if c.Name != component {
+					continue
+				}
+				value, ok := c.Attribute(attribute)
+				if !ok {
+					continue
+				}
+				d, ok := lintDuration(value)
+				if !ok || d >= min {
+					continue
+				}
+
+				startPos, endPos := ast.StartPos(c.Block).Position(), c.Block.LCurlyPos.Position()
+				if attr := lintAttribute(c.Block, attribute); attr != nil {
+					startPos, endPos = ast.StartPos(attr).Position(), ast.EndPos(attr).Position()
+				}
+				diags.Add(diag.Diagnostic{
+					Severity: diag.SeverityLevelError,
+					Message:  fmt.Sprintf("%s of %s must be at least %s, got %s (%s)", attribute, c.ID, min, d, name),
+					StartPos: startPos,
+					EndPos:   endPos,
+				})
+			}
+			return diags
+		},
+	}
+}
+
+// lintDuration returns the duration held by value, which is either an
+// evaluated argument or a string.
+func lintDuration(value any) (time.Duration, bool) {
+	switch value := value.(type) {
+	case time.Duration:
+		return value, true
+	case string:
+		d, err := time.ParseDuration(value)
+		return d, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// lintAttribute returns the top-level attribute of block with the given
+// name, or nil if it's unset.
+func lintAttribute(block *ast.BlockStmt, name string) *ast.AttributeStmt {
+	for _, stmt := range block.Body {
+		if attr, ok := stmt.(*ast.AttributeStmt); ok && attr.Name.Name == name {
+			return attr
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package flow
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/river/diag"
+	"github.com/stretchr/testify/require"
+)
+
+const lintTestConfig = `
+	testcomponents.tick "fast" {
+		frequency = "1s"
+	}
+
+	testcomponents.tick "slow" {
+		frequency = "1m"
+		tags      = ["team-a"]
+	}
+
+	testcomponents.passthrough "forwarded" {
+		input = testcomponents.tick.fast.tick_time
+		tags  = ["team-a", "team-b"]
+	}
+`
+
+func TestController_Lint(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+	ctrl := New(testOptions(t))
+	defer cleanUpController(ctrl)
+
+	f, err := ParseSource(t.Name(), []byte(lintTestConfig))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	var checked []LintComponent
+	recordRule := LintRule{
+		Name: "record",
+		Check: func(components []LintComponent) diag.Diagnostics {
+			checked = components
+			return nil
+		},
+	}
+
+	diags := ctrl.Lint([]LintRule{
+		recordRule,
+		RequireTagsLintRule(),
+		MinDurationLintRule("min-frequency", "testcomponents.tick", "frequency", 10*time.Second),
+	})
+	require.Len(t, diags, 2)
+	require.Equal(t, "testcomponents.tick.fast must have at least one tag (require-tags)", diags[0].Message)
+	require.Equal(t, "frequency of testcomponents.tick.fast must be at least 10s, got 1s (min-frequency)", diags[1].Message)
+	require.Equal(t, 3, diags[1].StartPos.Line)
+
+	require.Len(t, checked, 3)
+	require.Equal(t, "testcomponents.passthrough.forwarded", checked[0].ID)
+	require.Equal(t, "testcomponents.passthrough", checked[0].Name)
+	require.Equal(t, []string{"team-a", "team-b"}, checked[0].Tags)
+	require.Equal(t, []string{"testcomponents.tick.fast"}, checked[0].References)
+	require.NotNil(t, checked[0].Arguments)
+
+	freq, ok := checked[2].Attribute("frequency")
+	require.True(t, ok)
+	require.Equal(t, time.Minute, freq)
+}
+
+func TestLintSource(t *testing.T) {
+	f, err := ParseSource(t.Name(), []byte(lintTestConfig+`
+		testcomponents.tick "disabled" {
+			enabled   = false
+			frequency = "1s"
+		}
+
+		testcomponents.tick "referenced" {
+			frequency = testcomponents.passthrough.forwarded.output
+		}
+	`))
+	require.NoError(t, err)
+
+	rules := []LintRule{
+		RequireTagsLintRule(),
+		MinDurationLintRule("min-frequency", "testcomponents.tick", "frequency", 10*time.Second),
+	}
+	var messages []string
+	for _, d := range LintSource(f, rules) {
+		messages = append(messages, d.Message)
+	}
+
+	// Disabled components aren't checked, and attributes which reference
+	// other components are ignored.
+	require.Equal(t, []string{
+		"testcomponents.tick.fast must have at least one tag (require-tags)",
+		"testcomponents.tick.referenced must have at least one tag (require-tags)",
+		"frequency of testcomponents.tick.fast must be at least 10s, got 1s (min-frequency)",
+	}, messages)
+}
+
+func TestLintRules(t *testing.T) {
+	var names []string
+	for _, r := range LintRules() {
+		names = append(names, r.Name)
+	}
+	require.Equal(t, []string{"min-scrape-interval", "require-tags"}, names)
+
+	r, ok := GetLintRule("require-tags")
+	require.True(t, ok)
+	require.Equal(t, "Every component must have at least one tag.", r.Description)
+
+	// Tags are optional, so require-tags is only checked when requested.
+	require.True(t, r.OptIn)
+	names = nil
+	for _, r := range DefaultLintRules() {
+		names = append(names, r.Name)
+	}
+	require.Equal(t, []string{"min-scrape-interval"}, names)
+
+	require.Panics(t, func() { RegisterLintRule(RequireTagsLintRule()) })
+}
@@ -0,0 +1,70 @@
+package flow
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/grafana/agent/pkg/flow/internal/controller"
+	"github.com/grafana/river/ast"
+)
+
+// nametableJSON is the JSON representation written by NametableJSON.
+type nametableJSON struct {
+	Names map[string]nametableEntryJSON `json:"names"`
+}
+
+type nametableEntryJSON struct {
+	Kind       string   `json:"kind"`                // One of "component", "config", or "service".
+	Component  string   `json:"component,omitempty"` // Name of the component, such as "prometheus.scrape".
+	Position   string   `json:"position,omitempty"`  // Position of the block, if the config has one.
+	Fields     []string `json:"fields,omitempty"`    // Fields other blocks can reference on a component.
+	References []string `json:"references"`          // Names the block references.
+}
+
+// NametableJSON returns a JSON document describing the names registered by
+// the loaded config of f, which expressions in the config can reference.
+//
+// Every block and service in the graph is listed by its name, such as
+// "prometheus.remote_write.default", along with the names it references.
+// Components also list the fields which can be referenced on them, when
+// they're known ahead of time. Names are sorted, and so are the lists of each
+// name, so the same config always produces the same document. Components of
+// modules aren't included. The format of the document is not stable and is
+// subject to change.
+func (f *Flow) NametableJSON() ([]byte, error) {
+	f.loadMut.RLock()
+	defer f.loadMut.RUnlock()
+
+	var (
+		graph = f.loader.OriginalGraph()
+		doc   = nametableJSON{Names: make(map[string]nametableEntryJSON)}
+	)
+
+	for _, n := range graph.Nodes() {
+		var entry nametableEntryJSON
+		switch n := n.(type) {
+		case *controller.ComponentNode:
+			entry.Kind = "component"
+			entry.Component = n.ComponentName()
+			entry.Fields = n.ReferenceableFields()
+		case *controller.ServiceNode:
+			entry.Kind = "service"
+		default:
+			entry.Kind = "config"
+		}
+		if bn, ok := n.(controller.BlockNode); ok && bn.Block() != nil {
+			entry.Position = ast.StartPos(bn.Block()).Position().String()
+		}
+
+		entry.References = []string{}
+		for _, dep := range graph.Dependencies(n) {
+			entry.References = append(entry.References, dep.NodeID())
+		}
+		sort.Strings(entry.References)
+
+		doc.Names[n.NodeID()] = entry
+	}
+
+	// Maps are marshaled with sorted keys, so names are listed in order.
+	return json.MarshalIndent(doc, "", "  ")
+}
@@ -0,0 +1,62 @@
+package flow
+
+import (
+	"testing"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/pkg/flow/internal/controller"
+	"github.com/grafana/agent/pkg/flow/internal/worker"
+	"github.com/stretchr/testify/require"
+)
+
+func TestController_NametableJSON(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+
+	passthrough, _ := component.Get("testcomponents.passthrough")
+	ctrl := newController(controllerOptions{
+		Options:           testOptions(t),
+		ModuleRegistry:    newModuleRegistry(),
+		WorkerPool:        worker.NewFixedWorkerPool(1, 100),
+		ComponentRegistry: controller.RegistryMap{passthrough.Name: passthrough},
+	})
+	defer cleanUpController(ctrl)
+
+	f, err := ParseSource("config.river", []byte(`testcomponents.passthrough "b" {
+	input = testcomponents.passthrough.a.output
+}
+
+testcomponents.passthrough "a" {
+	input = "hello, world!"
+}
+`))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	bb, err := ctrl.NametableJSON()
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"names": {
+			"logging": {"kind": "config", "references": []},
+			"testcomponents.passthrough.a": {
+				"kind": "component",
+				"component": "testcomponents.passthrough",
+				"position": "config.river:5:1",
+				"fields": ["health", "output"],
+				"references": []
+			},
+			"testcomponents.passthrough.b": {
+				"kind": "component",
+				"component": "testcomponents.passthrough",
+				"position": "config.river:1:1",
+				"fields": ["health", "output"],
+				"references": ["testcomponents.passthrough.a"]
+			},
+			"tracing": {"kind": "config", "references": []}
+		}
+	}`, string(bb))
+
+	// The document doesn't depend on the order nodes are stored in.
+	again, err := ctrl.NametableJSON()
+	require.NoError(t, err)
+	require.Equal(t, string(bb), string(again))
+}
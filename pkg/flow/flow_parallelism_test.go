@@ -0,0 +1,237 @@
+package flow
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/pkg/flow/internal/controller"
+	"github.com/grafana/agent/pkg/flow/internal/testcomponents"
+	"github.com/grafana/agent/pkg/flow/internal/worker"
+	"github.com/grafana/agent/pkg/flow/logging"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+// slowArgs are the arguments of the test.slow component.
+type slowArgs struct {
+	Input string `river:"input,attr,optional"`
+}
+
+// slowExports are the exports of the test.slow component.
+type slowExports struct {
+	Output string `river:"output,attr"`
+}
+
+// slowRegistration returns a component which takes delay to build, and
+// records the highest number of concurrent builds in maxBuilds.
+func slowRegistration(delay time.Duration, maxBuilds *atomic.Int64) component.Registration {
+	var building atomic.Int64
+	return component.Registration{
+		Name:    "test.slow",
+		Args:    slowArgs{},
+		Exports: slowExports{},
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			n := building.Inc()
+			defer building.Dec()
+			for {
+				max := maxBuilds.Load()
+				if n <= max || maxBuilds.CAS(max, n) {
+					break
+				}
+			}
+
+			time.Sleep(delay)
+			opts.OnStateChange(slowExports{Output: args.(slowArgs).Input})
+			return slowComponent{}, nil
+		},
+	}
+}
+
+type slowComponent struct{}
+
+func (slowComponent) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (slowComponent) Update(component.Arguments) error { return nil }
+
+// wideConfig returns a config with width test.slow components which all
+// depend on the same passthrough component, and a passthrough component
+// concatenating the outputs of all of them.
+func wideConfig(width int) []byte {
+	var sb strings.Builder
+	sb.WriteString(`testcomponents.passthrough "root" { input = "hello" }` + "\n")
+
+	outputs := make([]string, width)
+	for i := 0; i < width; i++ {
+		fmt.Fprintf(&sb, "test.slow \"s%d\" { input = testcomponents.passthrough.root.output }\n", i)
+		outputs[i] = fmt.Sprintf("test.slow.s%d.output", i)
+	}
+	fmt.Fprintf(&sb, "testcomponents.passthrough \"sink\" { input = %s }\n", strings.Join(outputs, " + "))
+	return []byte(sb.String())
+}
+
+func newParallelController(opts Options, reg component.Registration) *Flow {
+	passthrough, _ := component.Get("testcomponents.passthrough")
+	return newController(controllerOptions{
+		Options:        opts,
+		ModuleRegistry: newModuleRegistry(),
+		WorkerPool:     worker.NewFixedWorkerPool(1, 100),
+		ComponentRegistry: controller.RegistryMap{
+			passthrough.Name: passthrough,
+			reg.Name:         reg,
+		},
+	})
+}
+
+func TestController_Parallelism(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+
+	tt := []struct {
+		parallelism int
+		expectMax   int64
+	}{
+		{parallelism: 0, expectMax: 1},
+		{parallelism: 1, expectMax: 1},
+		{parallelism: 4, expectMax: 4},
+	}
+	for _, tc := range tt {
+		t.Run(fmt.Sprintf("parallelism %d", tc.parallelism), func(t *testing.T) {
+			var maxBuilds atomic.Int64
+
+			opts := testOptions(t)
+			opts.Parallelism = tc.parallelism
+			ctrl := newParallelController(opts, slowRegistration(20*time.Millisecond, &maxBuilds))
+			defer cleanUpController(ctrl)
+
+			f, err := ParseSource(t.Name(), wideConfig(8))
+			require.NoError(t, err)
+			require.NoError(t, ctrl.LoadSource(f, nil))
+
+			// Components are always evaluated after their dependencies.
+			_, exports := getFields(t, ctrl.loader.Graph(), "testcomponents.passthrough.sink")
+			require.Equal(t, strings.Repeat("hello", 8), exports.(testcomponents.PassthroughExports).Output)
+			if tc.expectMax == 1 {
+				require.Equal(t, int64(1), maxBuilds.Load())
+			} else {
+				require.Greater(t, maxBuilds.Load(), int64(1))
+				require.LessOrEqual(t, maxBuilds.Load(), tc.expectMax)
+			}
+		})
+	}
+}
+
+// BenchmarkController_LoadWideGraph measures loading a config with many
+// independent components which each take a millisecond to build.
+func BenchmarkController_LoadWideGraph(b *testing.B) {
+	logger, err := logging.New(io.Discard, logging.DefaultOptions)
+	require.NoError(b, err)
+
+	src, err := ParseSource(b.Name(), wideConfig(64))
+	require.NoError(b, err)
+
+	for _, parallelism := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("parallelism=%d", parallelism), func(b *testing.B) {
+			var maxBuilds atomic.Int64
+			reg := slowRegistration(time.Millisecond, &maxBuilds)
+
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				ctrl := newParallelController(Options{
+					Logger:      logger,
+					DataPath:    b.TempDir(),
+					Parallelism: parallelism,
+				}, reg)
+				b.StartTimer()
+
+				if err := ctrl.LoadSource(src, nil); err != nil {
+					b.Fatal(err)
+				}
+
+				b.StopTimer()
+				cleanUpController(ctrl)
+				b.StartTimer()
+			}
+		})
+	}
+}
+
+// blockingRegistration returns a component whose Update reports on updating
+// and then blocks until release is closed.
+func blockingRegistration(updating chan<- struct{}, release <-chan struct{}) component.Registration {
+	return component.Registration{
+		Name:    "test.blocking",
+		Args:    slowArgs{},
+		Exports: slowExports{},
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return blockingComponent{updating: updating, release: release}, nil
+		},
+	}
+}
+
+type blockingComponent struct {
+	updating chan<- struct{}
+	release  <-chan struct{}
+}
+
+func (blockingComponent) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (c blockingComponent) Update(component.Arguments) error {
+	c.updating <- struct{}{}
+	<-c.release
+	return nil
+}
+
+func TestController_ReadsDuringSlowLoad(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+
+	var (
+		updating = make(chan struct{})
+		release  = make(chan struct{})
+	)
+	ctrl := newParallelController(testOptions(t), blockingRegistration(updating, release))
+	defer cleanUpController(ctrl)
+
+	f, err := ParseSource(t.Name(), []byte(`test.blocking "a" { input = "1" }`))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	f, err = ParseSource(t.Name(), []byte(`
+		test.blocking "a" { input = "2" }
+		testcomponents.passthrough "b" { input = "hello" }
+	`))
+	require.NoError(t, err)
+
+	loaded := make(chan error, 1)
+	go func() { loaded <- ctrl.LoadSource(f, nil) }()
+	<-updating
+
+	// Reads return the previous graph while the load is blocked in Update.
+	read := make(chan []*component.Info, 1)
+	go func() {
+		infos, err := ctrl.ListComponents("", component.InfoOptions{})
+		require.NoError(t, err)
+		require.NotNil(t, ctrl.loader.Graph().GetByID("test.blocking.a"))
+		read <- infos
+	}()
+	select {
+	case infos := <-read:
+		require.Len(t, infos, 1)
+		require.Equal(t, "test.blocking.a", infos[0].ID.LocalID)
+	case <-time.After(5 * time.Second):
+		t.Fatal("reading components blocked on a slow load")
+	}
+
+	close(release)
+	require.NoError(t, <-loaded)
+	require.Len(t, ctrl.loader.Components(), 2)
+}
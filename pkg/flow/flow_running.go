@@ -0,0 +1,155 @@
+package flow
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grafana/agent/pkg/flow/internal/controller"
+	"github.com/grafana/agent/pkg/flow/logging/level"
+)
+
+// runningCheckInterval is how often Run checks that the running goroutines
+// of the controller match its graph.
+const runningCheckInterval = time.Minute
+
+// RunningCheck is the result of [Flow.CheckRunning].
+type RunningCheck struct {
+	// NotRunning holds the IDs of nodes in the graph which should be running,
+	// but which aren't run by any goroutine, such as components whose Run
+	// method exited.
+	NotRunning []string `json:"not_running"`
+
+	// Orphaned holds the IDs of nodes which are still run by a goroutine
+	// despite no longer being in the graph.
+	Orphaned []string `json:"orphaned"`
+}
+
+// OK returns whether every node in the graph is run by exactly one
+// goroutine, and there are no goroutines for nodes removed from the graph.
+func (c RunningCheck) OK() bool {
+	return len(c.NotRunning) == 0 && len(c.Orphaned) == 0
+}
+
+// String returns a description of the mismatches of c.
+func (c RunningCheck) String() string {
+	if c.OK() {
+		return "every node is running"
+	}
+
+	var parts []string
+	if len(c.NotRunning) > 0 {
+		parts = append(parts, "not running: "+strings.Join(c.NotRunning, ", "))
+	}
+	if len(c.Orphaned) > 0 {
+		parts = append(parts, "orphaned: "+strings.Join(c.Orphaned, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// CheckRunning verifies that every component and service in the graph of f
+// is run by exactly one goroutine, and that no goroutines are left running
+// for nodes removed from the graph by a reload. Only the nodes of f are
+// checked; modules check their own nodes.
+//
+// Goroutines started by a component inherit the component_id pprof label of
+// the goroutine running the component, so goroutines which outlive a
+// component removed from the graph are reported as orphaned even once its
+// Run method exited. Labels are shared by every controller of the process, so
+// controllers running side by side must have different ControllerIDs.
+// Goroutines are only checked against the scheduled nodes when the goroutine
+// profile can't be read.
+//
+// Nodes of a load are scheduled shortly after the load finishes, so a
+// mismatch reported right after a load doesn't indicate a leak. Run logs a
+// warning when the check fails while no load is pending.
+func (f *Flow) CheckRunning() RunningCheck {
+	inGraph := make(map[string]struct{})
+	for _, r := range f.runnables() {
+		inGraph[r.NodeID()] = struct{}{}
+	}
+
+	var (
+		running  = make(map[string]struct{})
+		orphaned = make(map[string]struct{})
+	)
+	for _, id := range f.sched.Running() {
+		running[id] = struct{}{}
+		if _, ok := inGraph[id]; !ok {
+			orphaned[id] = struct{}{}
+		}
+	}
+	if goroutines, err := controller.GoroutinesByComponent(); err == nil {
+		for globalID := range goroutines {
+			id, ok := f.localComponentID(globalID)
+			if !ok {
+				continue
+			}
+			if _, ok := inGraph[id]; !ok {
+				orphaned[id] = struct{}{}
+			}
+		}
+	}
+
+	check := RunningCheck{
+		NotRunning: []string{},
+		Orphaned:   []string{},
+	}
+	for id := range inGraph {
+		if _, ok := running[id]; !ok {
+			check.NotRunning = append(check.NotRunning, id)
+		}
+	}
+	for id := range orphaned {
+		check.Orphaned = append(check.Orphaned, id)
+	}
+	sort.Strings(check.NotRunning)
+	sort.Strings(check.Orphaned)
+	return check
+}
+
+// localComponentID returns the ID of a component of f from its global ID,
+// the value of its component_id pprof label. ok is false for components of
+// other controllers, including the modules of f.
+func (f *Flow) localComponentID(globalID string) (id string, ok bool) {
+	id = globalID
+	if f.opts.ControllerID != "" {
+		if id, ok = strings.CutPrefix(globalID, f.opts.ControllerID+"/"); !ok {
+			return "", false
+		}
+	}
+	return id, !strings.Contains(id, "/")
+}
+
+// logRunningCheck logs a warning if CheckRunning reports a mismatch.
+func (f *Flow) logRunningCheck() {
+	if check := f.CheckRunning(); !check.OK() {
+		level.Warn(f.log).Log(
+			"msg", "running goroutines don't match the component graph",
+			"not_running", strings.Join(check.NotRunning, ","),
+			"orphaned", strings.Join(check.Orphaned, ","),
+		)
+	}
+}
+
+// RunningCheckHandler returns an http.HandlerFunc which writes the result of
+// [Flow.CheckRunning] as JSON. The response has a 500 status code if the
+// running goroutines don't match the graph.
+func RunningCheckHandler(f *Flow) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		check := f.CheckRunning()
+
+		bb, err := json.Marshal(check)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if !check.OK() {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		_, _ = w.Write(bb)
+	}
+}
@@ -0,0 +1,140 @@
+package flow
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/pkg/flow/internal/controller"
+	"github.com/grafana/agent/pkg/flow/internal/testcomponents"
+	"github.com/grafana/agent/pkg/flow/internal/worker"
+	"github.com/stretchr/testify/require"
+)
+
+func TestController_CheckRunning(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+	ctrl := New(testOptions(t))
+
+	f, err := ParseSource(t.Name(), []byte(`
+		testcomponents.passthrough "a" {
+			input = "hello, world!"
+		}
+
+		testcomponents.passthrough "b" {
+			input = testcomponents.passthrough.a.output
+		}
+	`))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	// Nothing runs until Run is called.
+	check := ctrl.CheckRunning()
+	require.False(t, check.OK())
+	require.Equal(t, []string{"testcomponents.passthrough.a", "testcomponents.passthrough.b"}, check.NotRunning)
+	require.Empty(t, check.Orphaned)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		ctrl.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	require.Eventually(t, func() bool { return ctrl.CheckRunning().OK() }, 5*time.Second, 10*time.Millisecond)
+
+	// Goroutines for nodes which aren't in the graph are reported.
+	require.NoError(t, ctrl.sched.Synchronize(append(ctrl.runnables(), orphanedRunnable("testcomponents.passthrough.removed"))))
+	check = ctrl.CheckRunning()
+	require.Equal(t, RunningCheck{NotRunning: []string{}, Orphaned: []string{"testcomponents.passthrough.removed"}}, check)
+	require.Equal(t, "orphaned: testcomponents.passthrough.removed", check.String())
+
+	rec := httptest.NewRecorder()
+	RunningCheckHandler(ctrl).ServeHTTP(rec, httptest.NewRequest("GET", "/running", nil))
+	require.Equal(t, http.StatusInternalServerError, rec.Code)
+	require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var actual RunningCheck
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &actual))
+	require.Equal(t, check, actual)
+
+	// Synchronizing with the graph stops the orphaned goroutine.
+	require.NoError(t, ctrl.sched.Synchronize(ctrl.runnables()))
+	require.True(t, ctrl.CheckRunning().OK())
+
+	rec = httptest.NewRecorder()
+	RunningCheckHandler(ctrl).ServeHTTP(rec, httptest.NewRequest("GET", "/running", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"not_running": [], "orphaned": []}`, rec.Body.String())
+}
+
+func TestController_CheckRunning_LeakedGoroutine(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+
+	// test.leaky starts a goroutine which outlives its Run method, until
+	// release is closed.
+	release := make(chan struct{})
+	leaky := component.Registration{
+		Name: "test.leaky",
+		Args: struct{}{},
+		Build: func(component.Options, component.Arguments) (component.Component, error) {
+			return &testcomponents.Fake{
+				RunFunc: func(ctx context.Context) error {
+					go func() { <-release }()
+					<-ctx.Done()
+					return nil
+				},
+			}, nil
+		},
+	}
+	ctrl := newController(controllerOptions{
+		Options:           testOptions(t),
+		ModuleRegistry:    newModuleRegistry(),
+		WorkerPool:        worker.NewFixedWorkerPool(1, 100),
+		ComponentRegistry: controller.RegistryMap{leaky.Name: leaky},
+	})
+
+	f, err := ParseSource(t.Name(), []byte(`test.leaky "a" {}`))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		ctrl.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	require.Eventually(t, func() bool { return ctrl.CheckRunning().OK() }, 5*time.Second, 10*time.Millisecond)
+
+	// Removing the component stops its task, but not the goroutine it leaked,
+	// which still carries its label.
+	empty, err := ParseSource(t.Name(), []byte(``))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(empty, nil))
+	require.Eventually(t, func() bool { return len(ctrl.sched.Running()) == 0 }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, RunningCheck{NotRunning: []string{}, Orphaned: []string{"test.leaky.a"}}, ctrl.CheckRunning())
+
+	close(release)
+	require.Eventually(t, func() bool { return ctrl.CheckRunning().OK() }, 5*time.Second, 10*time.Millisecond)
+}
+
+// orphanedRunnable is a runnable which isn't a node of any graph.
+type orphanedRunnable string
+
+func (r orphanedRunnable) NodeID() string { return string(r) }
+
+func (r orphanedRunnable) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
@@ -0,0 +1,82 @@
+package flow
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/grafana/agent/pkg/flow/internal/controller"
+	"github.com/grafana/river/encoding/riverjson"
+)
+
+// StatesHandler returns an http.HandlerFunc which writes a JSON object
+// mapping every component ID to the current exported state of that
+// component. Sensitive values are redacted in the same way as the component
+// API.
+//
+// The state of all components is captured while holding the load lock, so
+// the response reflects a single consistent snapshot of the graph. The
+// response can be limited to a subset of components by providing a
+// comma-separated list of component IDs in the "names" query parameter.
+//
+// Components which have not exported any state yet are omitted from the
+// response.
+func StatesHandler(f *Flow) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var filter map[string]struct{}
+		if names := r.URL.Query().Get("names"); names != "" {
+			filter = make(map[string]struct{})
+			for _, name := range strings.Split(names, ",") {
+				if name = strings.TrimSpace(name); name != "" {
+					filter[name] = struct{}{}
+				}
+			}
+		}
+
+		states, err := f.componentStates(filter)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		bb, err := json.Marshal(states)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(bb)
+	}
+}
+
+// componentStates returns the encoded exports of all components in f. If
+// filter is non-nil, only components whose IDs are in filter are returned.
+func (f *Flow) componentStates(filter map[string]struct{}) (map[string]json.RawMessage, error) {
+	f.loadMut.RLock()
+	defer f.loadMut.RUnlock()
+
+	states := make(map[string]json.RawMessage)
+	for _, n := range f.loader.Graph().Nodes() {
+		cn, ok := n.(*controller.ComponentNode)
+		if !ok {
+			continue
+		}
+		if filter != nil {
+			if _, include := filter[cn.NodeID()]; !include {
+				continue
+			}
+		}
+
+		exports := cn.Exports()
+		if exports == nil {
+			continue
+		}
+
+		bb, err := riverjson.MarshalBody(exports)
+		if err != nil {
+			return nil, err
+		}
+		states[cn.NodeID()] = bb
+	}
+	return states, nil
+}
@@ -0,0 +1,51 @@
+package flow
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatesHandler(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+	ctrl := New(testOptions(t))
+	defer cleanUpController(ctrl)
+
+	f, err := ParseSource(t.Name(), []byte(`
+		testcomponents.passthrough "a" {
+			input = "hello"
+		}
+
+		testcomponents.passthrough "b" {
+			input = testcomponents.passthrough.a.output
+		}
+	`))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	getStates := func(target string) map[string]json.RawMessage {
+		rec := httptest.NewRecorder()
+		StatesHandler(ctrl).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var states map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &states))
+		return states
+	}
+
+	t.Run("all components", func(t *testing.T) {
+		states := getStates("/states")
+		require.Len(t, states, 2)
+		require.JSONEq(t, `[{"name":"output","type":"attr","value":{"type":"string","value":"hello"}}]`, string(states["testcomponents.passthrough.a"]))
+		require.Contains(t, states, "testcomponents.passthrough.b")
+	})
+
+	t.Run("filtered", func(t *testing.T) {
+		states := getStates("/states?names=testcomponents.passthrough.b,missing")
+		require.Len(t, states, 1)
+		require.Contains(t, states, "testcomponents.passthrough.b")
+	})
+}
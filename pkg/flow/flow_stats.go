@@ -0,0 +1,42 @@
+package flow
+
+import "time"
+
+// GraphStats describes the size of the graph of a controller, for capacity
+// planning.
+type GraphStats struct {
+	Nodes       int // Number of nodes in the graph, including config blocks and services.
+	Edges       int // Number of edges in the graph, before transitive edges are removed.
+	ConfigBytes int // Total size of the loaded config files.
+
+	// EstimatedBytes approximates the memory retained by the graph: the size
+	// of the loaded config files plus a fixed overhead for each node and edge.
+	// It's cheap to compute, but only an estimate, and it doesn't include
+	// memory used by running components or by modules.
+	EstimatedBytes int
+
+	// Reduced reports whether the graph was transitively reduced, as
+	// controlled by [Options.GraphReduction]. ReducedEdges is the number of
+	// edges left in the graph used for evaluation, which is the same as Edges
+	// when the graph wasn't reduced, and ReduceDuration is the time spent
+	// reducing it.
+	Reduced        bool
+	ReducedEdges   int
+	ReduceDuration time.Duration
+}
+
+// Stats returns the size of the graph as of the most recent call to
+// LoadSource which loaded its config. Stats are also exposed as metrics
+// through [Options.Reg].
+func (f *Flow) Stats() GraphStats {
+	stats := f.loader.Stats()
+	return GraphStats{
+		Nodes:          stats.Nodes,
+		Edges:          stats.Edges,
+		ConfigBytes:    stats.ConfigBytes,
+		EstimatedBytes: stats.EstimatedBytes,
+		Reduced:        stats.Reduced,
+		ReducedEdges:   stats.ReducedEdges,
+		ReduceDuration: stats.ReduceDuration,
+	}
+}
@@ -0,0 +1,105 @@
+package flow
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/grafana/agent/pkg/flow/internal/testcomponents"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestController_Stats(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+	reg := prometheus.NewRegistry()
+	opts := testOptions(t)
+	opts.Reg = reg
+	ctrl := New(opts)
+	defer cleanUpController(ctrl)
+
+	require.Equal(t, GraphStats{}, ctrl.Stats())
+
+	content := `
+		testcomponents.passthrough "a" {
+			input = "hello, world!"
+		}
+
+		testcomponents.passthrough "b" {
+			input = testcomponents.passthrough.a.output
+		}
+	`
+	f, err := ParseSource(t.Name(), []byte(content))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	// The graph includes the logging and tracing config blocks.
+	stats := ctrl.Stats()
+	require.Equal(t, 4, stats.Nodes)
+	require.Equal(t, 1, stats.Edges)
+	require.Equal(t, len(content), stats.ConfigBytes)
+	require.Greater(t, stats.EstimatedBytes, stats.ConfigBytes)
+
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP agent_component_controller_graph_edges Number of edges in the graph of the most recently loaded config.
+		# TYPE agent_component_controller_graph_edges gauge
+		agent_component_controller_graph_edges{controller_id=""} 1
+		# HELP agent_component_controller_graph_nodes Number of nodes in the graph of the most recently loaded config.
+		# TYPE agent_component_controller_graph_nodes gauge
+		agent_component_controller_graph_nodes{controller_id=""} 4
+	`), "agent_component_controller_graph_edges", "agent_component_controller_graph_nodes"))
+}
+
+func TestController_GraphReduction(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+	opts := testOptions(t)
+	opts.GraphReduction = GraphReductionFirstLoad
+	ctrl := New(opts)
+	defer cleanUpController(ctrl)
+
+	// c depends on a both directly and through b, so the edge from c to a is
+	// removed by reducing the graph.
+	load := func(input string) {
+		f, err := ParseSource(t.Name(), []byte(fmt.Sprintf(`
+			testcomponents.passthrough "a" {
+				input = %q
+			}
+
+			testcomponents.passthrough "b" {
+				input = testcomponents.passthrough.a.output
+			}
+
+			testcomponents.passthrough "c" {
+				input = testcomponents.passthrough.b.output + testcomponents.passthrough.a.output
+			}
+		`, input)))
+		require.NoError(t, err)
+		require.NoError(t, ctrl.LoadSource(f, nil))
+	}
+
+	load("hello")
+	stats := ctrl.Stats()
+	require.True(t, stats.Reduced)
+	require.Equal(t, 3, stats.Edges)
+	require.Equal(t, 2, stats.ReducedEdges)
+	_, out := getFields(t, ctrl.loader.Graph(), "testcomponents.passthrough.c")
+	require.Equal(t, "hellohello", out.(testcomponents.PassthroughExports).Output)
+
+	// Reloads keep every edge, and still evaluate c after its dependencies.
+	load("world")
+	stats = ctrl.Stats()
+	require.False(t, stats.Reduced)
+	require.Zero(t, stats.ReduceDuration)
+	require.Equal(t, 3, stats.ReducedEdges)
+	_, out = getFields(t, ctrl.loader.Graph(), "testcomponents.passthrough.c")
+	require.Equal(t, "worldworld", out.(testcomponents.PassthroughExports).Output)
+}
+
+func TestGraphReduction(t *testing.T) {
+	require.True(t, GraphReductionAlways.reduce(false))
+	require.True(t, GraphReductionAlways.reduce(true))
+	require.True(t, GraphReductionFirstLoad.reduce(false))
+	require.False(t, GraphReductionFirstLoad.reduce(true))
+	require.False(t, GraphReductionNever.reduce(false))
+}
@@ -0,0 +1,478 @@
+package flow
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/pkg/flow/internal/controller"
+	"github.com/grafana/river/ast"
+	"github.com/grafana/river/diag"
+	"github.com/grafana/river/parser"
+	"github.com/grafana/river/printer"
+	"github.com/grafana/river/rivertypes"
+	"github.com/grafana/river/token"
+)
+
+// redactedValue replaces sensitive values in support bundles.
+const redactedValue = "(redacted)"
+
+// SupportBundleOptions controls the contents of a support bundle.
+type SupportBundleOptions struct {
+	// IncludeSecrets includes the loaded config files and the arguments of
+	// components as they are, rather than redacting values which may be
+	// secrets.
+	//
+	// Secrets in the arguments and exports of components are always encoded
+	// as "(secret)", regardless of IncludeSecrets.
+	IncludeSecrets bool
+}
+
+// SupportBundle writes a zip archive to w which captures the state of f for
+// troubleshooting. Sensitive values are redacted. See [Flow.WriteSupportBundle]
+// for the contents of the archive.
+func (f *Flow) SupportBundle(w io.Writer) error {
+	return f.WriteSupportBundle(w, SupportBundleOptions{})
+}
+
+// WriteSupportBundle writes a zip archive to w which captures the state of f
+// for troubleshooting. The archive contains:
+//
+//   - config/: The config files passed to the most recent call to
+//     LoadSource.
+//   - graph.dot: The current graph in the Graphviz DOT format.
+//   - components.json: The health, arguments, exports, and recent errors of
+//     every component, in the same format as the component API.
+//   - diagnostics.txt: The diagnostics reported by the most recent call to
+//     LoadSource.
+//
+// The state of f is captured while holding the load lock, so the archive
+// reflects a single consistent snapshot. Components of modules aren't
+// included.
+func (f *Flow) WriteSupportBundle(w io.Writer, opts SupportBundleOptions) error {
+	f.loadMut.RLock()
+	defer f.loadMut.RUnlock()
+
+	zw := zip.NewWriter(w)
+
+	configs := f.lastSource.RawConfigs()
+	if !opts.IncludeSecrets {
+		redacted, err := f.redactConfigs(configs)
+		if err != nil {
+			return err
+		}
+		configs = redacted
+	}
+	names := make([]string, 0, len(configs))
+	for name := range configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := writeBundleFile(zw, path.Join("config", path.Clean("/"+name)), configs[name]); err != nil {
+			return err
+		}
+	}
+
+	if err := writeBundleFile(zw, "graph.dot", encodeDOT(f.loader.Graph(), dotOptions{})); err != nil {
+		return err
+	}
+
+	components, err := json.MarshalIndent(f.bundleComponents(opts), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeBundleFile(zw, "components.json", components); err != nil {
+		return err
+	}
+
+	// Diagnostics only print the surrounding config when it's unredacted,
+	// since redacted files don't have the same positions.
+	var diagFiles map[string][]byte
+	if opts.IncludeSecrets {
+		diagFiles = configs
+	}
+	var diags bytes.Buffer
+	if err := diag.Fprint(&diags, diagFiles, f.lastDiags); err != nil {
+		return err
+	}
+	if err := writeBundleFile(zw, "diagnostics.txt", diags.Bytes()); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// SupportBundleHandler returns an http.HandlerFunc which responds with the
+// support bundle of f. Sensitive values are redacted unless the
+// "include_secrets" query parameter is set to true.
+func SupportBundleHandler(f *Flow) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var opts SupportBundleOptions
+		if raw := r.URL.Query().Get("include_secrets"); raw != "" {
+			include, err := strconv.ParseBool(raw)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid include_secrets %q", raw), http.StatusBadRequest)
+				return
+			}
+			opts.IncludeSecrets = include
+		}
+
+		var buf bytes.Buffer
+		if err := f.WriteSupportBundle(&buf, opts); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", `attachment; filename="support-bundle.zip"`)
+		_, _ = w.Write(buf.Bytes())
+	}
+}
+
+// bundleComponents returns the details of every component in f, sorted by
+// ID. Arguments which may hold secrets are redacted unless
+// opts.IncludeSecrets is set; see mayHoldSecrets. loadMut must be held when calling
+// bundleComponents.
+func (f *Flow) bundleComponents(opts SupportBundleOptions) []*component.Info {
+	var (
+		components = f.loader.Components()
+		graph      = f.loader.OriginalGraph()
+		infoOpts   = component.InfoOptions{
+			GetHealth:    true,
+			GetArguments: true,
+			GetExports:   true,
+			GetDebugInfo: true,
+			GetErrors:    true,
+		}
+	)
+
+	infos := make([]*component.Info, len(components))
+	for i, cn := range components {
+		infos[i] = f.getComponentDetail(cn, graph, infoOpts, nil)
+		if !opts.IncludeSecrets && infos[i].Arguments != nil {
+			infos[i].Arguments = redactValue(reflect.ValueOf(infos[i].Arguments)).Interface()
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID.LocalID < infos[j].ID.LocalID })
+	return infos
+}
+
+// writeBundleFile writes a file with the given name and content to zw.
+func writeBundleFile(zw *zip.Writer, name string, content []byte) error {
+	fw, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = fw.Write(content)
+	return err
+}
+
+// redactConfigs returns a copy of configs where the values of component
+// attributes which decode into secrets are replaced with redactedValue.
+// Only attributes which set a secret directly are redacted; values which
+// become secrets through expressions, such as references to other
+// components, are kept since they don't contain the secret itself.
+//
+// Values whose type can't tell whether they're secrets, such as the
+// arguments block of modules, are treated as secrets: the string literals in
+// their expressions are redacted. See mayHoldSecrets.
+//
+// Include directives are kept as comments, since the included files are
+// redacted on their own.
+func (f *Flow) redactConfigs(configs map[string][]byte) (map[string][]byte, error) {
+	var reg controller.ComponentRegistry = controller.DefaultComponentRegistry{}
+	if f.opts.ComponentRegistry != nil {
+		reg = f.opts.ComponentRegistry
+	}
+
+	redacted := make(map[string][]byte, len(configs))
+	for name, bb := range configs {
+		directives, err := findIncludes(name, bb)
+		if err != nil {
+			return nil, err
+		}
+		file, err := parser.ParseFile(name, commentIncludes(bb, directives))
+		if err != nil {
+			return nil, err
+		}
+
+		body := make(ast.Body, len(file.Body))
+		for i, stmt := range file.Body {
+			body[i] = stmt
+
+			block, ok := stmt.(*ast.BlockStmt)
+			if !ok {
+				continue
+			}
+			if registration, ok := reg.Get(block.GetBlockName()); ok && registration.Args != nil {
+				copied := *block
+				copied.Body = redactBody(block.Body, reflect.TypeOf(registration.Args))
+				body[i] = &copied
+			}
+		}
+
+		copied := *file
+		copied.Body = body
+
+		var buf bytes.Buffer
+		if err := printer.Fprint(&buf, &copied); err != nil {
+			return nil, err
+		}
+		redacted[name] = buf.Bytes()
+	}
+	return redacted, nil
+}
+
+// redactBody returns a copy of body, which is decoded into a value of type
+// ty, where attributes which decode into secrets are set to redactedValue.
+// String literals are redacted from attributes which may hold secrets, and
+// from every attribute if ty may hold secrets.
+func redactBody(body ast.Body, ty reflect.Type) ast.Body {
+	var (
+		untyped = mayHoldSecrets(ty)
+		fields  = riverFields(ty)
+	)
+
+	redacted := make(ast.Body, len(body))
+	for i, stmt := range body {
+		redacted[i] = stmt
+
+		switch stmt := stmt.(type) {
+		case *ast.AttributeStmt:
+			fieldType, ok := fields[stmt.Name.Name]
+			if untyped || (ok && mayHoldSecrets(fieldType)) {
+				copied := *stmt
+				copied.Value = redactLiterals(stmt.Value)
+				redacted[i] = &copied
+			} else if ok && isSecretType(fieldType) {
+				copied := *stmt
+				copied.Value = &ast.LiteralExpr{
+					Kind:     token.STRING,
+					Value:    strconv.Quote(redactedValue),
+					ValuePos: ast.StartPos(stmt.Value),
+				}
+				redacted[i] = &copied
+			}
+		case *ast.BlockStmt:
+			if untyped {
+				copied := *stmt
+				copied.Body = redactBody(stmt.Body, ty)
+				redacted[i] = &copied
+			} else if fieldType, ok := fields[strings.Join(stmt.Name, ".")]; ok {
+				copied := *stmt
+				copied.Body = redactBody(stmt.Body, fieldType)
+				redacted[i] = &copied
+			}
+		}
+	}
+	return redacted
+}
+
+// riverFields returns the types of the River attributes and blocks of the
+// struct held by ty, keyed by name. Squashed structs and enum blocks are
+// flattened into the result.
+func riverFields(ty reflect.Type) map[string]reflect.Type {
+	ty = indirectType(ty)
+	if ty.Kind() != reflect.Struct {
+		return nil
+	}
+
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < ty.NumField(); i++ {
+		field := ty.Field(i)
+		tag, ok := field.Tag.Lookup("river")
+		if !ok {
+			continue
+		}
+
+		name, flags, _ := strings.Cut(tag, ",")
+		switch {
+		case strings.Contains(flags, "squash"):
+			for name, ty := range riverFields(field.Type) {
+				fields[name] = ty
+			}
+		case strings.Contains(flags, "enum"):
+			// Enum blocks are named after the enum and the inner block.
+			for inner, ty := range riverFields(field.Type) {
+				fields[name+"."+inner] = ty
+			}
+		case name != "":
+			fields[name] = field.Type
+		}
+	}
+	return fields
+}
+
+// indirectType returns the type held by pointers, slices, arrays, and maps
+// of ty.
+func indirectType(ty reflect.Type) reflect.Type {
+	for {
+		switch ty.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+			ty = ty.Elem()
+		default:
+			return ty
+		}
+	}
+}
+
+var secretType = reflect.TypeOf(rivertypes.Secret(""))
+
+// isSecretType returns whether values of type ty hold secrets, including
+// collections of secrets.
+//
+// Optional secrets are only secrets when set from another secret, so literal
+// values set to them are never considered to be secrets, in the same way as
+// when they're encoded.
+func isSecretType(ty reflect.Type) bool {
+	return indirectType(ty) == secretType
+}
+
+// redactLiterals returns a copy of expr where string literals are replaced
+// with redactedValue. Object keys and references are kept.
+func redactLiterals(expr ast.Expr) ast.Expr {
+	switch expr := expr.(type) {
+	case *ast.LiteralExpr:
+		if expr.Kind != token.STRING {
+			return expr
+		}
+		return &ast.LiteralExpr{Kind: token.STRING, Value: strconv.Quote(redactedValue), ValuePos: expr.ValuePos}
+	case *ast.ArrayExpr:
+		copied := *expr
+		copied.Elements = make([]ast.Expr, len(expr.Elements))
+		for i, elem := range expr.Elements {
+			copied.Elements[i] = redactLiterals(elem)
+		}
+		return &copied
+	case *ast.ObjectExpr:
+		copied := *expr
+		copied.Fields = make([]*ast.ObjectField, len(expr.Fields))
+		for i, field := range expr.Fields {
+			copiedField := *field
+			copiedField.Value = redactLiterals(field.Value)
+			copied.Fields[i] = &copiedField
+		}
+		return &copied
+	case *ast.CallExpr:
+		copied := *expr
+		copied.Args = make([]ast.Expr, len(expr.Args))
+		for i, arg := range expr.Args {
+			copied.Args[i] = redactLiterals(arg)
+		}
+		return &copied
+	case *ast.IndexExpr:
+		copied := *expr
+		copied.Value = redactLiterals(expr.Value)
+		return &copied
+	case *ast.AccessExpr:
+		copied := *expr
+		copied.Value = redactLiterals(expr.Value)
+		return &copied
+	case *ast.UnaryExpr:
+		copied := *expr
+		copied.Value = redactLiterals(expr.Value)
+		return &copied
+	case *ast.BinaryExpr:
+		copied := *expr
+		copied.Left, copied.Right = redactLiterals(expr.Left), redactLiterals(expr.Right)
+		return &copied
+	case *ast.ParenExpr:
+		copied := *expr
+		copied.Inner = redactLiterals(expr.Inner)
+		return &copied
+	default:
+		return expr
+	}
+}
+
+// mayHoldSecrets returns whether values of type ty may hold secrets without
+// being typed as secrets: untyped values, such as the arguments of modules,
+// and maps of strings or untyped values, such as HTTP headers. These values
+// are redacted by default, since there's no way to tell whether they are
+// secrets.
+func mayHoldSecrets(ty reflect.Type) bool {
+	for ty.Kind() == reflect.Pointer || ty.Kind() == reflect.Slice || ty.Kind() == reflect.Array {
+		ty = ty.Elem()
+	}
+	switch ty.Kind() {
+	case reflect.Interface:
+		return ty.NumMethod() == 0
+	case reflect.Map:
+		elem := ty.Elem()
+		return elem.Kind() == reflect.String && elem != secretType || elem.Kind() == reflect.Interface && elem.NumMethod() == 0
+	default:
+		return false
+	}
+}
+
+// redactValue returns a copy of v, the arguments of a component, where the
+// values of River fields which may hold secrets are replaced with
+// redactedValue. Keys of maps are kept.
+func redactValue(v reflect.Value) reflect.Value {
+	if !v.IsValid() {
+		return v
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() || !mayHoldSecrets(v.Type()) {
+			return v
+		}
+		return redactedOf(v.Type())
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.New(v.Type().Elem())
+		copied.Elem().Set(redactValue(v.Elem()))
+		return copied
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return v
+		}
+		copied := reflect.New(v.Type()).Elem()
+		if v.Kind() == reflect.Slice {
+			copied.Set(reflect.MakeSlice(v.Type(), v.Len(), v.Len()))
+		}
+		for i := 0; i < v.Len(); i++ {
+			copied.Index(i).Set(redactValue(v.Index(i)))
+		}
+		return copied
+	case reflect.Map:
+		if v.IsNil() || !mayHoldSecrets(v.Type()) {
+			return v
+		}
+		copied := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			copied.SetMapIndex(iter.Key(), redactedOf(v.Type().Elem()))
+		}
+		return copied
+	case reflect.Struct:
+		copied := reflect.New(v.Type()).Elem()
+		copied.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if _, ok := v.Type().Field(i).Tag.Lookup("river"); ok && copied.Field(i).CanSet() {
+				copied.Field(i).Set(redactValue(v.Field(i)))
+			}
+		}
+		return copied
+	default:
+		return v
+	}
+}
+
+// redactedOf returns redactedValue as a value of type ty, which is either a
+// string or an untyped value.
+func redactedOf(ty reflect.Type) reflect.Value {
+	return reflect.ValueOf(redactedValue).Convert(ty)
+}
@@ -0,0 +1,138 @@
+package flow
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/pkg/flow/internal/controller"
+	"github.com/grafana/agent/pkg/flow/internal/worker"
+	"github.com/grafana/river/rivertypes"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/maps"
+)
+
+type secretArgs struct {
+	Username string             `river:"username,attr"`
+	Password rivertypes.Secret  `river:"password,attr"`
+	Auth     []secretAuthConfig `river:"auth,block,optional"`
+	Headers  map[string]string  `river:"headers,attr,optional"`
+	Extra    map[string]any     `river:"extra,block,optional"`
+}
+
+type secretAuthConfig struct {
+	Token rivertypes.Secret `river:"token,attr"`
+}
+
+type secretComponent struct{}
+
+func (secretComponent) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (secretComponent) Update(component.Arguments) error { return nil }
+
+func TestController_SupportBundle(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+
+	passthrough, _ := component.Get("testcomponents.passthrough")
+	ctrl := newController(controllerOptions{
+		Options:        testOptions(t),
+		ModuleRegistry: newModuleRegistry(),
+		WorkerPool:     worker.NewFixedWorkerPool(1, 100),
+		ComponentRegistry: controller.RegistryMap{
+			passthrough.Name: passthrough,
+			"test.secret": component.Registration{
+				Name: "test.secret",
+				Args: secretArgs{},
+				Build: func(component.Options, component.Arguments) (component.Component, error) {
+					return secretComponent{}, nil
+				},
+			},
+		},
+	})
+	defer cleanUpController(ctrl)
+
+	f, err := ParseSource("config.river", []byte(`
+		// Credentials for the example.
+		test.secret "creds" {
+			username = "admin"
+			password = "hunter2"
+
+			auth {
+				token = "letmein"
+			}
+
+			headers = {
+				"Authorization" = "Bearer " + "opensesame",
+				"X-Scope"       = testcomponents.passthrough.static.output,
+			}
+
+			extra {
+				api_key = "swordfish"
+			}
+		}
+
+		testcomponents.passthrough "static" {
+			input = "hello, world!"
+		}
+	`))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	readBundle := func(opts SupportBundleOptions) map[string]string {
+		var buf bytes.Buffer
+		require.NoError(t, ctrl.WriteSupportBundle(&buf, opts))
+
+		zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		require.NoError(t, err)
+
+		files := make(map[string]string)
+		for _, zf := range zr.File {
+			r, err := zf.Open()
+			require.NoError(t, err)
+			bb, err := io.ReadAll(r)
+			require.NoError(t, err)
+			files[zf.Name] = string(bb)
+		}
+		return files
+	}
+
+	t.Run("Redacted by default", func(t *testing.T) {
+		files := readBundle(SupportBundleOptions{})
+		require.ElementsMatch(t, []string{"config/config.river", "graph.dot", "components.json", "diagnostics.txt"}, maps.Keys(files))
+
+		config := files["config/config.river"]
+		require.Contains(t, config, "// Credentials for the example.")
+		require.Contains(t, config, `username = "admin"`)
+		require.Contains(t, config, `password = "(redacted)"`)
+		require.Contains(t, config, `token = "(redacted)"`)
+		require.NotContains(t, config, "hunter2")
+		require.NotContains(t, config, "letmein")
+
+		// Values which may hold secrets keep their keys and references.
+		require.Contains(t, config, `"Authorization" = "(redacted)" + "(redacted)"`)
+		require.Contains(t, config, `"X-Scope"       = testcomponents.passthrough.static.output`)
+		require.Contains(t, config, `api_key = "(redacted)"`)
+		require.NotContains(t, config, "opensesame")
+		require.NotContains(t, config, "swordfish")
+
+		require.Contains(t, files["graph.dot"], `"testcomponents.passthrough.static"`)
+		require.Contains(t, files["components.json"], `"localID": "testcomponents.passthrough.static"`)
+		require.Contains(t, files["components.json"], `"health"`)
+		require.NotContains(t, files["components.json"], "hunter2")
+		require.NotContains(t, files["components.json"], "opensesame")
+		require.NotContains(t, files["components.json"], "swordfish")
+		require.Contains(t, files["components.json"], "api_key")
+	})
+
+	t.Run("Secrets included on request", func(t *testing.T) {
+		files := readBundle(SupportBundleOptions{IncludeSecrets: true})
+		require.Contains(t, files["config/config.river"], `password = "hunter2"`)
+		require.Contains(t, files["components.json"], "swordfish")
+	})
+}
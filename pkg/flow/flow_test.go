@@ -2,15 +2,29 @@ package flow
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
+	"testing/iotest"
+	"time"
 
 	"github.com/grafana/agent/component"
 	"github.com/grafana/agent/pkg/flow/internal/controller"
 	"github.com/grafana/agent/pkg/flow/internal/dag"
+	"github.com/grafana/agent/pkg/flow/internal/stepper"
 	"github.com/grafana/agent/pkg/flow/internal/testcomponents"
+	"github.com/grafana/agent/pkg/flow/internal/worker"
 	"github.com/grafana/agent/pkg/flow/logging"
+	"github.com/grafana/river/diag"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
 	"go.uber.org/goleak"
 )
 
@@ -53,6 +67,37 @@ func TestController_LoadSource_Evaluation(t *testing.T) {
 	require.Equal(t, "hello, world!", out.(testcomponents.PassthroughExports).Output)
 }
 
+func TestController_LoadReader(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+	ctrl := New(testOptions(t))
+	defer cleanUpController(ctrl)
+
+	fsys := fstest.MapFS{
+		"config.river": {Data: []byte(testFile)},
+		"invalid.river": {Data: []byte(`
+			testcomponents.passthrough "static" {
+				input = [1]
+			}
+		`)},
+	}
+
+	r, err := fsys.Open("config.river")
+	require.NoError(t, err)
+	defer r.Close()
+	require.NoError(t, ctrl.LoadReader(r, "config.river"))
+	require.Len(t, ctrl.loader.Components(), 4)
+
+	// The filename is only used for diagnostics.
+	r, err = fsys.Open("invalid.river")
+	require.NoError(t, err)
+	defer r.Close()
+	err = ctrl.LoadReader(r, "embedded/invalid.river")
+	require.ErrorContains(t, err, "embedded/invalid.river:3:")
+
+	err = ctrl.LoadReader(iotest.ErrReader(errors.New("broken pipe")), "stdin")
+	require.EqualError(t, err, "reading stdin: broken pipe")
+}
+
 func getFields(t *testing.T, g *dag.Graph, nodeID string) (component.Arguments, component.Exports) {
 	t.Helper()
 
@@ -96,3 +141,1487 @@ func verifyNoGoroutineLeaks(t *testing.T) {
 		goleak.IgnoreTopFunction("go.opentelemetry.io/otel/sdk/trace.(*batchSpanProcessor).processQueue"),
 	)
 }
+
+func TestController_LoadSource_BestEffort(t *testing.T) {
+	brokenFile := `
+		testcomponents.tick "broken" {
+			frequency = "bogus"
+		}
+
+		testcomponents.passthrough "static" {
+			input = "hello, world!"
+		}
+	`
+
+	t.Run("Fails without best effort", func(t *testing.T) {
+		defer verifyNoGoroutineLeaks(t)
+		ctrl := New(testOptions(t))
+		defer cleanUpController(ctrl)
+
+		f, err := ParseSource(t.Name(), []byte(brokenFile))
+		require.NoError(t, err)
+		require.Error(t, ctrl.LoadSource(f, nil))
+		require.False(t, ctrl.Ready())
+	})
+
+	t.Run("Starts with best effort", func(t *testing.T) {
+		defer verifyNoGoroutineLeaks(t)
+		opts := testOptions(t)
+		opts.BestEffort = true
+		ctrl := New(opts)
+		defer cleanUpController(ctrl)
+
+		f, err := ParseSource(t.Name(), []byte(brokenFile))
+		require.NoError(t, err)
+		require.Error(t, ctrl.LoadSource(f, nil))
+		require.True(t, ctrl.Ready())
+
+		broken, err := ctrl.GetComponent(component.ID{LocalID: "testcomponents.tick.broken"}, component.InfoOptions{GetHealth: true})
+		require.NoError(t, err)
+		require.Equal(t, component.HealthTypeUnhealthy, broken.Health.Health)
+
+		static, err := ctrl.GetComponent(component.ID{LocalID: "testcomponents.passthrough.static"}, component.InfoOptions{GetExports: true})
+		require.NoError(t, err)
+		require.Equal(t, "hello, world!", static.Exports.(testcomponents.PassthroughExports).Output)
+	})
+
+	t.Run("Fails with best effort when the graph can't be built", func(t *testing.T) {
+		defer verifyNoGoroutineLeaks(t)
+		opts := testOptions(t)
+		opts.BestEffort = true
+		ctrl := New(opts)
+		defer cleanUpController(ctrl)
+
+		f, err := ParseSource(t.Name(), []byte(`
+			testcomponents.passthrough "static" {
+				input = testcomponents.passthrough.missing.output
+			}
+		`))
+		require.NoError(t, err)
+		require.Error(t, ctrl.LoadSource(f, nil))
+		require.False(t, ctrl.Ready())
+	})
+}
+
+func TestController_LoadSource_DuplicateComponents(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+	ctrl := New(testOptions(t))
+	defer cleanUpController(ctrl)
+
+	f, err := ParseSources(map[string][]byte{
+		"a.river": []byte(`testcomponents.passthrough "static" {
+	input = "a"
+}`),
+		"b.river": []byte(`
+
+testcomponents.passthrough "static" {
+	input = "b"
+}`),
+	})
+	require.NoError(t, err)
+
+	err = ctrl.LoadSource(f, nil)
+	require.EqualError(t, err, `b.river:3:1: duplicate component "testcomponents.passthrough.static" defined at a.river:1:1 and b.river:3:1`)
+}
+
+func TestController_LoadSource_ComponentPolicy(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+	opts := testOptions(t)
+	opts.AllowedComponents = []string{"testcomponents.*"}
+	opts.DeniedComponents = []string{"testcomponents.tick"}
+	ctrl := New(opts)
+	defer cleanUpController(ctrl)
+
+	f, err := ParseSource(t.Name(), []byte(`
+		testcomponents.tick "ticker" {
+			frequency = "1s"
+		}
+
+		testcomponents.passthrough "static" {
+			input = "hello, world!"
+		}
+	`))
+	require.NoError(t, err)
+
+	err = ctrl.LoadSource(f, nil)
+	require.ErrorContains(t, err, `Component "testcomponents.tick" is not allowed by the component policy`)
+	require.NotContains(t, err.Error(), "testcomponents.passthrough")
+}
+
+func TestController_LoadSource_TestBlocks(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+
+	config := `
+		testcomponents.passthrough "static" {
+			input = "hello, world!"
+		}
+
+		test "passing" {
+			assert = testcomponents.passthrough.static.output == "hello, world!"
+		}
+
+		test "failing" {
+			assert  = testcomponents.passthrough.static.output != "hello, world!"
+			message = "output must be changed"
+		}
+	`
+
+	ctrl := New(testOptions(t))
+	defer cleanUpController(ctrl)
+
+	f, err := ParseSource(t.Name(), []byte(config))
+	require.NoError(t, err)
+
+	err = ctrl.LoadSource(f, nil)
+	require.Error(t, err)
+
+	var diags diag.Diagnostics
+	require.ErrorAs(t, err, &diags)
+	require.Len(t, diags, 1)
+	require.Equal(t, "assertion in test.failing failed: output must be changed", diags[0].Message)
+	require.Equal(t, 11, diags[0].StartPos.Line)
+	require.Equal(t, 14, diags[0].StartPos.Column)
+	require.False(t, ctrl.Ready())
+}
+
+func TestController_PauseResume(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+
+	var setExports func(component.Exports)
+	passthrough, _ := component.Get("testcomponents.passthrough")
+
+	ctrl := newController(controllerOptions{
+		Options:        testOptions(t),
+		ModuleRegistry: newModuleRegistry(),
+		WorkerPool:     worker.NewFixedWorkerPool(1, 100),
+		ComponentRegistry: controller.RegistryMap{
+			passthrough.Name: passthrough,
+			"test.source": component.Registration{
+				Name:    "test.source",
+				Args:    struct{}{},
+				Exports: testcomponents.PassthroughExports{},
+				Build: func(o component.Options, _ component.Arguments) (component.Component, error) {
+					setExports = o.OnStateChange
+					return sourceComponent{}, nil
+				},
+			},
+		},
+	})
+
+	f, err := ParseSource(t.Name(), []byte(`
+		test.source "a" {}
+
+		testcomponents.passthrough "b" {
+			input = test.source.a.output
+		}
+	`))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		ctrl.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	output := func() string {
+		_, exports := getFields(t, ctrl.loader.Graph(), "testcomponents.passthrough.b")
+		return exports.(testcomponents.PassthroughExports).Output
+	}
+	queued := func() []string {
+		var ids []string
+		for _, cn := range ctrl.updateQueue.Queued() {
+			ids = append(ids, cn.NodeID())
+		}
+		return ids
+	}
+
+	// Wait for the update to be propagated, and for the controller to handle
+	// the update of the dependant, so no updates are in flight when pausing.
+	setExports(testcomponents.PassthroughExports{Output: "hello"})
+	require.Eventually(t, func() bool {
+		return output() == "hello" && len(queued()) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Updates stay queued while paused, so dependants aren't evaluated.
+	ctrl.Pause()
+	setExports(testcomponents.PassthroughExports{Output: "paused"})
+	require.Equal(t, []string{"test.source.a"}, queued())
+	require.Equal(t, "hello", output(), "dependants must not be evaluated while paused")
+
+	ctrl.Resume()
+	require.Eventually(t, func() bool { return output() == "paused" }, 5*time.Second, 10*time.Millisecond)
+	require.NotContains(t, queued(), "test.source.a")
+}
+
+func TestController_HealthAttribute(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+	ctrl := New(testOptions(t))
+
+	f, err := ParseSource(t.Name(), []byte(`
+		testcomponents.passthrough "a" {
+			input = "hello, world!"
+		}
+
+		testcomponents.passthrough "b" {
+			input = testcomponents.passthrough.a.health.state
+		}
+	`))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	output := func() string {
+		_, exports := getFields(t, ctrl.loader.Graph(), "testcomponents.passthrough.b")
+		return exports.(testcomponents.PassthroughExports).Output
+	}
+
+	// Components aren't running until Run is called, so their health is
+	// unknown after loading.
+	require.Equal(t, "unknown", output())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		ctrl.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// Starting a changes its health, which must re-evaluate b.
+	require.Eventually(t, func() bool { return output() == "healthy" }, 5*time.Second, 10*time.Millisecond)
+}
+
+type readyComponent struct {
+	ready *atomic.Bool
+}
+
+func (c readyComponent) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (readyComponent) Update(component.Arguments) error { return nil }
+
+func (c readyComponent) Ready() bool { return c.ready.Load() }
+
+func TestController_Ready(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+
+	var ready atomic.Bool
+	passthrough, _ := component.Get("testcomponents.passthrough")
+	ctrl := newController(controllerOptions{
+		Options:        testOptions(t),
+		ModuleRegistry: newModuleRegistry(),
+		WorkerPool:     worker.NewFixedWorkerPool(1, 100),
+		ComponentRegistry: controller.RegistryMap{
+			passthrough.Name: passthrough,
+			"test.ready": component.Registration{
+				Name: "test.ready",
+				Args: struct{}{},
+				Build: func(component.Options, component.Arguments) (component.Component, error) {
+					return readyComponent{ready: &ready}, nil
+				},
+			},
+		},
+	})
+
+	f, err := ParseSource(t.Name(), []byte(`
+		test.ready "conn" {}
+
+		testcomponents.passthrough "static" {
+			input = "hello, world!"
+		}
+	`))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	// Components aren't ready until they're running.
+	require.False(t, ctrl.Ready())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		ctrl.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	componentReady := func(id string) bool {
+		info, err := ctrl.GetComponent(component.ID{LocalID: id}, component.InfoOptions{GetHealth: true})
+		require.NoError(t, err)
+		return info.Ready
+	}
+
+	require.Eventually(t, func() bool { return componentReady("testcomponents.passthrough.static") }, 5*time.Second, 10*time.Millisecond)
+	require.False(t, componentReady("test.ready.conn"))
+	require.False(t, ctrl.Ready())
+
+	ready.Store(true)
+	require.True(t, componentReady("test.ready.conn"))
+	require.True(t, ctrl.Ready())
+}
+
+type gatedExports struct {
+	Started bool `river:"started,attr"`
+}
+
+// gatedComponent only reports its exports once release is closed.
+type gatedComponent struct {
+	opts    component.Options
+	release chan struct{}
+	err     error // Returned by Run once released, if set.
+}
+
+func (c gatedComponent) Run(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return nil
+	case <-c.release:
+	}
+	if c.err != nil {
+		return c.err
+	}
+	c.opts.OnStateChange(gatedExports{Started: true})
+	<-ctx.Done()
+	return nil
+}
+
+func (gatedComponent) Update(component.Arguments) error { return nil }
+
+func TestController_WaitReady(t *testing.T) {
+	newGatedController := func(t *testing.T, release chan struct{}, runErr error) *Flow {
+		passthrough, _ := component.Get("testcomponents.passthrough")
+		return newController(controllerOptions{
+			Options:        testOptions(t),
+			ModuleRegistry: newModuleRegistry(),
+			WorkerPool:     worker.NewFixedWorkerPool(1, 100),
+			ComponentRegistry: controller.RegistryMap{
+				passthrough.Name: passthrough,
+				"test.gated": component.Registration{
+					Name:    "test.gated",
+					Args:    struct{}{},
+					Exports: gatedExports{},
+					Build: func(opts component.Options, _ component.Arguments) (component.Component, error) {
+						return gatedComponent{opts: opts, release: release, err: runErr}, nil
+					},
+				},
+			},
+		})
+	}
+
+	source, err := ParseSource(t.Name(), []byte(`
+		test.gated "conn" {}
+
+		testcomponents.passthrough "static" {
+			input = "hello, world!"
+		}
+	`))
+	require.NoError(t, err)
+
+	waitReady := func(ctrl *Flow, timeout time.Duration) error {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		return ctrl.WaitReady(ctx)
+	}
+
+	// run runs ctrl until the returned function is called.
+	run := func(ctrl *Flow) (stop func()) {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			ctrl.Run(ctx)
+			close(done)
+		}()
+		return func() {
+			cancel()
+			<-done
+		}
+	}
+
+	t.Run("Waits for exports", func(t *testing.T) {
+		defer verifyNoGoroutineLeaks(t)
+
+		release := make(chan struct{})
+		ctrl := newGatedController(t, release, nil)
+
+		err := waitReady(ctrl, 50*time.Millisecond)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.ErrorContains(t, err, "waiting for the initial load")
+
+		require.NoError(t, ctrl.LoadSource(source, nil))
+		defer run(ctrl)()
+
+		err = waitReady(ctrl, 100*time.Millisecond)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.ErrorContains(t, err, "waiting for test.gated.conn to start")
+
+		close(release)
+		require.NoError(t, waitReady(ctrl, 5*time.Second))
+	})
+
+	t.Run("Fails if a component exits before it started", func(t *testing.T) {
+		defer verifyNoGoroutineLeaks(t)
+
+		release := make(chan struct{})
+		close(release)
+		ctrl := newGatedController(t, release, errors.New("connection refused"))
+
+		require.NoError(t, ctrl.LoadSource(source, nil))
+		defer run(ctrl)()
+
+		err := waitReady(ctrl, 5*time.Second)
+		require.EqualError(t, err, "test.gated.conn failed to start: connection refused")
+	})
+}
+
+type restartArgs struct {
+	Value string `river:"value,attr"`
+}
+
+// restartComponent records the value it's running with, and fails if it's
+// updated.
+type restartComponent struct {
+	value   string
+	running *atomic.String
+}
+
+func (c restartComponent) Run(ctx context.Context) error {
+	c.running.Store(c.value)
+	<-ctx.Done()
+	c.running.CompareAndSwap(c.value, "")
+	return nil
+}
+
+func (restartComponent) Update(component.Arguments) error {
+	return fmt.Errorf("components registered with RestartOnUpdate must not be updated")
+}
+
+func TestController_RestartOnUpdate(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+
+	var (
+		running atomic.String
+		builds  atomic.Int32
+	)
+	ctrl := newController(controllerOptions{
+		Options:        testOptions(t),
+		ModuleRegistry: newModuleRegistry(),
+		WorkerPool:     worker.NewFixedWorkerPool(1, 100),
+		ComponentRegistry: controller.RegistryMap{
+			"test.restart": component.Registration{
+				Name:            "test.restart",
+				Args:            restartArgs{},
+				RestartOnUpdate: true,
+				Build: func(_ component.Options, args component.Arguments) (component.Component, error) {
+					value := args.(restartArgs).Value
+					if value == "invalid" {
+						return nil, fmt.Errorf("invalid value")
+					}
+					builds.Inc()
+					return restartComponent{value: value, running: &running}, nil
+				},
+			},
+		},
+	})
+
+	load := func(value string) error {
+		f, err := ParseSource(t.Name(), []byte(fmt.Sprintf(`test.restart "a" { value = %q }`, value)))
+		require.NoError(t, err)
+		return ctrl.LoadSource(f, nil)
+	}
+	eventuallyRunning := func(value string) {
+		require.Eventually(t, func() bool { return running.Load() == value }, 5*time.Second, 10*time.Millisecond)
+	}
+
+	require.NoError(t, load("first"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		ctrl.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	eventuallyRunning("first")
+
+	// Changed arguments build and run a new component instead of updating.
+	require.NoError(t, load("second"))
+	eventuallyRunning("second")
+	require.Equal(t, int32(2), builds.Load())
+
+	// Nothing runs while the component fails to build, until a later load
+	// builds it.
+	require.Error(t, load("invalid"))
+	eventuallyRunning("")
+	require.NoError(t, load("third"))
+	eventuallyRunning("third")
+	require.Equal(t, int32(3), builds.Load())
+
+	// Components are restarted once by loads in which other components fail.
+	f, err := ParseSource(t.Name(), []byte(`
+		test.restart "a" { value = "fourth" }
+		test.restart "b" { value = "invalid" }
+	`))
+	require.NoError(t, err)
+	require.ErrorContains(t, ctrl.LoadSource(f, nil), "invalid value")
+	eventuallyRunning("fourth")
+	require.Equal(t, int32(4), builds.Load())
+}
+
+// crashComponent alternates between panicking and failing every time it
+// runs.
+type crashComponent struct {
+	runs *atomic.Int32
+}
+
+func (c crashComponent) Run(context.Context) error {
+	if c.runs.Inc()%2 == 1 {
+		panic("boom")
+	}
+	return fmt.Errorf("failed")
+}
+
+func (crashComponent) Update(component.Arguments) error { return nil }
+
+func TestController_CircuitBreaker(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+
+	var runs, builds atomic.Int32
+	opts := testOptions(t)
+	opts.CircuitBreaker = CircuitBreakerOptions{MaxFailures: 3, Window: time.Hour}
+	ctrl := newController(controllerOptions{
+		Options:        opts,
+		ModuleRegistry: newModuleRegistry(),
+		WorkerPool:     worker.NewFixedWorkerPool(1, 100),
+		ComponentRegistry: controller.RegistryMap{
+			"test.crash": component.Registration{
+				Name: "test.crash",
+				Args: struct{}{},
+				Build: func(component.Options, component.Arguments) (component.Component, error) {
+					builds.Inc()
+					return crashComponent{runs: &runs}, nil
+				},
+			},
+		},
+	})
+
+	f, err := ParseSource(t.Name(), []byte(`test.crash "a" {}`))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		ctrl.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	id := component.ID{LocalID: "test.crash.a"}
+	eventuallyQuarantined := func(expectRuns int32) {
+		require.Eventually(t, func() bool {
+			info, err := ctrl.GetComponent(id, component.InfoOptions{GetHealth: true})
+			require.NoError(t, err)
+			return info.Health.Health == component.HealthTypeExited
+		}, 5*time.Second, 10*time.Millisecond)
+
+		info, err := ctrl.GetComponent(id, component.InfoOptions{GetHealth: true})
+		require.NoError(t, err)
+		require.Contains(t, info.Health.Message, "circuit breaker opened after 3 failures within 1h0m0s")
+		require.Equal(t, expectRuns, runs.Load())
+	}
+
+	// Panics are recovered, and the component is rebuilt and restarted until
+	// the breaker opens.
+	eventuallyQuarantined(3)
+	require.Equal(t, int32(3), builds.Load())
+	require.Len(t, ctrl.ErrorHistory("test.crash.a", 0), 3)
+	require.Equal(t, "component panicked: boom", ctrl.ErrorHistory("test.crash.a", 0)[0].Error)
+
+	var restarts int
+	for _, e := range ctrl.Events(0) {
+		if e.Type == EventTypeRestart && e.Component == "test.crash.a" {
+			restarts++
+		}
+	}
+	require.Equal(t, 2, restarts)
+
+	// Quarantined components aren't restarted by loads.
+	require.NoError(t, ctrl.LoadSource(f, nil))
+	require.Never(t, func() bool { return runs.Load() != 3 }, 100*time.Millisecond, 10*time.Millisecond)
+
+	require.NoError(t, ctrl.ResetCircuitBreaker(id))
+	eventuallyQuarantined(6)
+	require.Equal(t, int32(6), builds.Load())
+
+	require.ErrorIs(t, ctrl.ResetCircuitBreaker(component.ID{LocalID: "test.crash.b"}), component.ErrComponentNotFound)
+}
+
+type singletonArgs struct {
+	Value string `river:"value,attr"`
+}
+
+type singletonExports struct {
+	Value string `river:"value,attr"`
+}
+
+// singletonComponent exports its value and counts how many times it's
+// running.
+type singletonComponent struct {
+	opts    component.Options
+	running *atomic.Int32
+}
+
+func (c singletonComponent) Run(ctx context.Context) error {
+	c.running.Inc()
+	defer c.running.Dec()
+	<-ctx.Done()
+	return nil
+}
+
+func (c singletonComponent) Update(args component.Arguments) error {
+	c.opts.OnStateChange(singletonExports{Value: args.(singletonArgs).Value})
+	return nil
+}
+
+func TestController_Singletons(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+
+	var (
+		builds, running atomic.Int32
+		singletons      = NewSingletonRegistry()
+	)
+	registry := controller.RegistryMap{
+		"test.singleton": component.Registration{
+			Name:      "test.singleton",
+			Args:      singletonArgs{},
+			Exports:   singletonExports{},
+			Singleton: true,
+			Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+				builds.Inc()
+				c := singletonComponent{opts: opts, running: &running}
+				return c, c.Update(args)
+			},
+		},
+	}
+	newTenant := func(value string) (ctrl *Flow, stop func()) {
+		opts := testOptions(t)
+		opts.Singletons = singletons
+		ctrl = newController(controllerOptions{
+			Options:           opts,
+			ModuleRegistry:    newModuleRegistry(),
+			WorkerPool:        worker.NewFixedWorkerPool(1, 100),
+			ComponentRegistry: registry,
+		})
+
+		f, err := ParseSource(t.Name(), []byte(fmt.Sprintf(`test.singleton "global" { value = %q }`, value)))
+		require.NoError(t, err)
+		require.NoError(t, ctrl.LoadSource(f, nil))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			ctrl.Run(ctx)
+			close(done)
+		}()
+		return ctrl, func() {
+			cancel()
+			<-done
+		}
+	}
+
+	a, stopA := newTenant("first")
+	b, stopB := newTenant("second")
+	defer stopB()
+
+	// The second controller reuses the component built by the first, and both
+	// receive its exports.
+	require.Equal(t, int32(1), builds.Load())
+	for _, ctrl := range []*Flow{a, b} {
+		_, exports := getFields(t, ctrl.loader.Graph(), "test.singleton.global")
+		require.Equal(t, singletonExports{Value: "second"}, exports)
+	}
+
+	// The shared component only runs once, and keeps running until every
+	// controller using it stops.
+	require.Eventually(t, func() bool { return running.Load() == 1 }, 5*time.Second, 10*time.Millisecond)
+	stopA()
+	require.Never(t, func() bool { return running.Load() != 1 }, 100*time.Millisecond, 10*time.Millisecond)
+	stopB()
+	require.Equal(t, int32(0), running.Load())
+}
+
+// failingSingleton fails every time it runs, counting runs of instances
+// which already ran before.
+type failingSingleton struct {
+	ran    *atomic.Bool
+	reused *atomic.Int32
+}
+
+func (c failingSingleton) Run(context.Context) error {
+	if c.ran.Swap(true) {
+		c.reused.Inc()
+	}
+	return fmt.Errorf("failed")
+}
+
+func (failingSingleton) Update(component.Arguments) error { return nil }
+
+func TestController_Singletons_Failure(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+
+	var (
+		builds, reused atomic.Int32
+		singletons     = NewSingletonRegistry()
+	)
+	registry := controller.RegistryMap{
+		"test.singleton": component.Registration{
+			Name:      "test.singleton",
+			Args:      struct{}{},
+			Singleton: true,
+			Build: func(component.Options, component.Arguments) (component.Component, error) {
+				builds.Inc()
+				return failingSingleton{ran: atomic.NewBool(false), reused: &reused}, nil
+			},
+		},
+	}
+
+	var ctrls []*Flow
+	for i := 0; i < 2; i++ {
+		opts := testOptions(t)
+		opts.Singletons = singletons
+		opts.CircuitBreaker = CircuitBreakerOptions{MaxFailures: 3, Window: time.Hour}
+		ctrl := newController(controllerOptions{
+			Options:           opts,
+			ModuleRegistry:    newModuleRegistry(),
+			WorkerPool:        worker.NewFixedWorkerPool(1, 100),
+			ComponentRegistry: registry,
+		})
+		f, err := ParseSource(t.Name(), []byte(`test.singleton "global" {}`))
+		require.NoError(t, err)
+		require.NoError(t, ctrl.LoadSource(f, nil))
+		ctrls = append(ctrls, ctrl)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for _, ctrl := range ctrls {
+		wg.Add(1)
+		go func(ctrl *Flow) {
+			defer wg.Done()
+			ctrl.Run(ctx)
+		}(ctrl)
+	}
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	for _, ctrl := range ctrls {
+		require.Eventually(t, func() bool {
+			info, err := ctrl.GetComponent(component.ID{LocalID: "test.singleton.global"}, component.InfoOptions{GetHealth: true})
+			require.NoError(t, err)
+			return info.Health.Health == component.HealthTypeExited
+		}, 5*time.Second, 10*time.Millisecond)
+	}
+
+	// The shared component is rebuilt after failures instead of running the
+	// failed component again.
+	require.Greater(t, builds.Load(), int32(1))
+	require.Equal(t, int32(0), reused.Load())
+}
+
+func TestController_Singletons_Release(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+
+	var (
+		running    atomic.Int32
+		singletons = NewSingletonRegistry()
+
+		buildsMut sync.Mutex
+		builtIn   []string // Data path of the controller which built each component.
+	)
+	registry := controller.RegistryMap{
+		"test.singleton": component.Registration{
+			Name:      "test.singleton",
+			Args:      singletonArgs{},
+			Exports:   singletonExports{},
+			Singleton: true,
+			Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+				buildsMut.Lock()
+				builtIn = append(builtIn, filepath.Dir(opts.DataPath))
+				buildsMut.Unlock()
+
+				c := singletonComponent{opts: opts, running: &running}
+				return c, c.Update(args)
+			},
+		},
+	}
+	builds := func() []string {
+		buildsMut.Lock()
+		defer buildsMut.Unlock()
+		return append([]string(nil), builtIn...)
+	}
+	load := func(ctrl *Flow, config string) {
+		f, err := ParseSource(t.Name(), []byte(config))
+		require.NoError(t, err)
+		require.NoError(t, ctrl.LoadSource(f, nil))
+	}
+	newTenant := func(value string) (ctrl *Flow, stop func()) {
+		opts := testOptions(t)
+		opts.Singletons = singletons
+		ctrl = newController(controllerOptions{
+			Options:           opts,
+			ModuleRegistry:    newModuleRegistry(),
+			WorkerPool:        worker.NewFixedWorkerPool(1, 100),
+			ComponentRegistry: registry,
+		})
+		load(ctrl, fmt.Sprintf(`test.singleton "global" { value = %q }`, value))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			ctrl.Run(ctx)
+			close(done)
+		}()
+		return ctrl, func() {
+			cancel()
+			<-done
+		}
+	}
+
+	a, stopA := newTenant("first")
+	b, stopB := newTenant("second")
+	defer stopB()
+	require.Eventually(t, func() bool { return running.Load() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	// The first controller going away releases its hold on the component,
+	// which is built again with the options of the second controller and
+	// keeps running for it.
+	require.Equal(t, []string{a.opts.DataPath}, builds())
+	stopA()
+	require.Equal(t, []string{a.opts.DataPath, b.opts.DataPath}, builds())
+	require.Eventually(t, func() bool { return running.Load() == 1 }, 5*time.Second, 10*time.Millisecond)
+	require.Never(t, func() bool { return running.Load() != 1 }, 100*time.Millisecond, 10*time.Millisecond)
+	_, exports := getFields(t, b.loader.Graph(), "test.singleton.global")
+	require.Equal(t, singletonExports{Value: "second"}, exports)
+
+	// Removing the last node using the component stops it.
+	load(b, ``)
+	require.Eventually(t, func() bool { return running.Load() == 0 }, 5*time.Second, 10*time.Millisecond)
+
+	// The released component was dropped, so the next controller builds a new
+	// component with its own options.
+	c, stopC := newTenant("third")
+	defer stopC()
+	require.Equal(t, []string{a.opts.DataPath, b.opts.DataPath, c.opts.DataPath}, builds())
+	require.Eventually(t, func() bool { return running.Load() == 1 }, 5*time.Second, 10*time.Millisecond)
+	_, exports = getFields(t, c.loader.Graph(), "test.singleton.global")
+	require.Equal(t, singletonExports{Value: "third"}, exports)
+
+	// Blocks with another label can't share the component.
+	f, err := ParseSource(t.Name(), []byte(`test.singleton "other" { value = "fourth" }`))
+	require.NoError(t, err)
+	err = b.LoadSource(f, nil)
+	require.ErrorContains(t, err, `test.singleton is a singleton component and is already used with the label "global"`)
+}
+
+// sourceComponent exports whatever value is passed to its OnStateChange
+// function by the test.
+type sourceComponent struct{}
+
+func (sourceComponent) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (sourceComponent) Update(component.Arguments) error { return nil }
+
+func TestController_Stepper(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+
+	var setExports func(component.Exports)
+	passthrough, _ := component.Get("testcomponents.passthrough")
+
+	opts := testOptions(t)
+	opts.Stepper = stepper.New()
+	ctrl := newController(controllerOptions{
+		Options:        opts,
+		ModuleRegistry: newModuleRegistry(),
+		WorkerPool:     worker.NewFixedWorkerPool(1, 100),
+		ComponentRegistry: controller.RegistryMap{
+			passthrough.Name: passthrough,
+			"test.source": component.Registration{
+				Name:    "test.source",
+				Args:    struct{}{},
+				Exports: testcomponents.PassthroughExports{},
+				Build: func(o component.Options, _ component.Arguments) (component.Component, error) {
+					setExports = o.OnStateChange
+					return sourceComponent{}, nil
+				},
+			},
+		},
+	})
+
+	f, err := ParseSource(t.Name(), []byte(`
+		test.source "a" {}
+
+		testcomponents.passthrough "b" {
+			input = test.source.a.output
+		}
+
+		testcomponents.passthrough "c" {
+			input = testcomponents.passthrough.b.output
+		}
+
+		testcomponents.passthrough "d" {
+			input = test.source.a.output
+		}
+	`))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		ctrl.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// Wait for every component to start, and propagate the updates from
+	// loading the config and starting components.
+	require.Eventually(t, func() bool {
+		for _, cn := range ctrl.loader.Components() {
+			if cn.CurrentHealth().Health != component.HealthTypeHealthy {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+	for {
+		evaluated, err := opts.Stepper.Step(ctx)
+		require.NoError(t, err)
+		if len(evaluated) == 0 {
+			break
+		}
+	}
+
+	output := func(id string) string {
+		_, exports := getFields(t, ctrl.loader.Graph(), id)
+		return exports.(testcomponents.PassthroughExports).Output
+	}
+
+	// Every step moves the update one level further through the graph.
+	setExports(testcomponents.PassthroughExports{Output: "hello"})
+
+	evaluated, err := opts.Stepper.Step(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"testcomponents.passthrough.b", "testcomponents.passthrough.d"}, evaluated)
+	require.Equal(t, "hello", output("testcomponents.passthrough.b"))
+	require.Equal(t, "", output("testcomponents.passthrough.c"))
+
+	evaluated, err = opts.Stepper.Step(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"testcomponents.passthrough.c"}, evaluated)
+	require.Equal(t, "hello", output("testcomponents.passthrough.c"))
+
+	evaluated, err = opts.Stepper.Step(ctx)
+	require.NoError(t, err)
+	require.Empty(t, evaluated)
+}
+
+func TestController_LoadSource_StrictReferences(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+	opts := testOptions(t)
+	opts.StrictReferences = true
+	ctrl := New(opts)
+	defer cleanUpController(ctrl)
+
+	f, err := ParseSource(t.Name(), []byte(`
+		testcomponents.passthrough "a" {
+			input = "hello, world!"
+		}
+
+		testcomponents.passthrough "b" {
+			input = testcomponents.passthrough.a.output
+		}
+	`))
+	require.NoError(t, err)
+
+	err = ctrl.LoadSource(f, nil)
+	require.ErrorContains(t, err, "component testcomponents.passthrough.b is not referenced by any other component")
+	require.NotContains(t, err.Error(), "testcomponents.passthrough.a")
+	require.False(t, ctrl.Ready())
+
+	// Components referenced by any block, such as a test block, are allowed.
+	f, err = ParseSource(t.Name(), []byte(`
+		testcomponents.passthrough "a" {
+			input = "hello, world!"
+		}
+
+		testcomponents.passthrough "b" {
+			input = testcomponents.passthrough.a.output
+		}
+
+		test "forwarded" {
+			assert = testcomponents.passthrough.b.output == "hello, world!"
+		}
+	`))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+}
+
+func TestController_LoadSource_Unchanged(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+	ctrl := New(testOptions(t))
+	defer cleanUpController(ctrl)
+
+	load := func(content string) {
+		f, err := ParseSource(t.Name(), []byte(content))
+		require.NoError(t, err)
+		require.NoError(t, ctrl.LoadSource(f, nil))
+	}
+	config := `
+		testcomponents.passthrough "static" {
+			input = "hello, world!"
+		}
+	`
+
+	load(config)
+	require.Equal(t, uint64(1), ctrl.loadGeneration.Load())
+
+	// Loading the same content again is skipped.
+	load(config)
+	require.Equal(t, uint64(1), ctrl.loadGeneration.Load())
+
+	// Registering a function changes what the same content may evaluate to.
+	require.NoError(t, ctrl.Functions().Register("unchanged_test", func() string { return "" }))
+	load(config)
+	require.Equal(t, uint64(2), ctrl.loadGeneration.Load())
+
+	load(config + "\n")
+	require.Equal(t, uint64(3), ctrl.loadGeneration.Load())
+}
+
+func TestController_Reload_Changed(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+	ctrl := New(testOptions(t))
+	defer cleanUpController(ctrl)
+
+	reload := func(content string) bool {
+		f, err := ParseSource(t.Name(), []byte(content))
+		require.NoError(t, err)
+		changed, err := ctrl.Reload(context.Background(), f)
+		require.NoError(t, err)
+		return changed
+	}
+	config := `
+		testcomponents.passthrough "static" {
+			input = "hello, world!"
+		}
+	`
+
+	require.True(t, reload(config))
+	require.False(t, reload(config))
+	require.Equal(t, uint64(1), ctrl.loadGeneration.Load())
+
+	require.True(t, reload(config+"\n"))
+	require.Equal(t, uint64(2), ctrl.loadGeneration.Load())
+}
+
+func TestController_ReloadWithTrigger(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+	reg := prometheus.NewRegistry()
+	opts := testOptions(t)
+	opts.Reg = reg
+	ctrl := New(opts)
+	defer cleanUpController(ctrl)
+
+	reload := func(trigger ReloadTrigger, content string) error {
+		f, err := ParseSource(t.Name(), []byte(content))
+		require.NoError(t, err)
+		_, err = ctrl.ReloadWithTrigger(context.Background(), f, trigger)
+		return err
+	}
+	config := `
+		testcomponents.passthrough "static" {
+			input = "hello, world!"
+		}
+	`
+
+	require.NoError(t, reload(ReloadTriggerStartup, config))
+	summary, ok := ctrl.LastLoadSummary()
+	require.True(t, ok)
+	require.Equal(t, ReloadTriggerStartup, summary.Trigger)
+
+	require.NoError(t, reload(ReloadTriggerSignal, config))
+	summary, _ = ctrl.LastLoadSummary()
+	require.True(t, summary.Unchanged)
+	require.Equal(t, ReloadTriggerSignal, summary.Trigger)
+
+	require.Error(t, reload(ReloadTriggerHTTP, `testcomponents.passthrough "static" { input = [1] }`))
+
+	// Loads which aren't reloads don't have a trigger, and aren't counted.
+	f, err := ParseSource(t.Name(), []byte(config))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+	summary, _ = ctrl.LastLoadSummary()
+	require.Empty(t, summary.Trigger)
+
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP agent_component_controller_reloads_total Total number of applied reloads by the mechanism which triggered them and their result
+		# TYPE agent_component_controller_reloads_total counter
+		agent_component_controller_reloads_total{controller_id="",result="failed",trigger="http"} 1
+		agent_component_controller_reloads_total{controller_id="",result="reloaded",trigger="startup"} 1
+		agent_component_controller_reloads_total{controller_id="",result="unchanged",trigger="signal"} 1
+	`), "agent_component_controller_reloads_total"))
+}
+
+func TestController_RunWithConfigSource(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+	ctrl := New(testOptions(t))
+
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		src         = make(chan []byte)
+		errCh       = make(chan error, 1)
+	)
+	go func() { errCh <- ctrl.RunWithConfigSource(ctx, src) }()
+
+	exists := func(id string) func() bool {
+		return func() bool { return ctrl.loader.Graph().GetByID(id) != nil }
+	}
+
+	first := []byte(`
+		testcomponents.passthrough "first" {
+			input = "hello, world!"
+		}
+	`)
+	src <- first
+	require.Eventually(t, exists("testcomponents.passthrough.first"), time.Second, 10*time.Millisecond)
+
+	// Invalid configs are discarded, and the last config keeps running.
+	// Configs are loaded in the order they're received, so sending another
+	// config waits for the invalid ones to be handled.
+	src <- []byte(`testcomponents.passthrough "broken" {`)
+	src <- []byte(`
+		testcomponents.passthrough "missing_reference" {
+			input = testcomponents.passthrough.missing.output
+		}
+	`)
+	src <- first
+	require.True(t, exists("testcomponents.passthrough.first")())
+	require.False(t, exists("testcomponents.passthrough.missing_reference")())
+
+	src <- []byte(`
+		testcomponents.passthrough "second" {
+			input = "hello, world!"
+		}
+	`)
+	require.Eventually(t, exists("testcomponents.passthrough.second"), time.Second, 10*time.Millisecond)
+	require.False(t, exists("testcomponents.passthrough.first")())
+
+	// Closing the source keeps the last config running until ctx is canceled.
+	close(src)
+	require.True(t, exists("testcomponents.passthrough.second")())
+
+	cancel()
+	require.ErrorIs(t, <-errCh, context.Canceled)
+}
+
+func TestController_RunWithConfigSource_MinReloadInterval(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+	reg := prometheus.NewRegistry()
+	opts := testOptions(t)
+	opts.Reg = reg
+	opts.MinReloadInterval = 200 * time.Millisecond
+	ctrl := New(opts)
+
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		src         = make(chan []byte)
+		errCh       = make(chan error, 1)
+	)
+	go func() { errCh <- ctrl.RunWithConfigSource(ctx, src) }()
+
+	exists := func(id string) func() bool {
+		return func() bool { return ctrl.loader.Graph().GetByID(id) != nil }
+	}
+	config := func(name string) []byte {
+		return []byte(fmt.Sprintf(`testcomponents.passthrough %q { input = "hello, world!" }`, name))
+	}
+
+	src <- config("first")
+	require.Eventually(t, exists("testcomponents.passthrough.first"), time.Second, 10*time.Millisecond)
+
+	// The second config waits for the interval, so the following configs are
+	// received while it's waiting and the fourth is loaded last.
+	src <- config("second")
+	src <- config("third")
+	src <- config("fourth")
+	require.Eventually(t, exists("testcomponents.passthrough.fourth"), time.Second, 10*time.Millisecond)
+
+	// Which configs are replaced depends on when the second one was picked
+	// up, but at least one of them is replaced before it's loaded.
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	var coalesced float64
+	for _, mf := range families {
+		if mf.GetName() == "agent_component_controller_coalesced_reloads_total" {
+			coalesced = mf.GetMetric()[0].GetCounter().GetValue()
+		}
+	}
+	require.GreaterOrEqual(t, coalesced, 1.0)
+
+	cancel()
+	require.ErrorIs(t, <-errCh, context.Canceled)
+}
+
+type rulesArgs struct {
+	Rules []rulesRule `river:"rule,block,optional"`
+}
+
+type rulesRule struct {
+	Label string `river:"label,attr"`
+}
+
+func TestController_LoadSource_DynamicBlocks(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+
+	passthrough, _ := component.Get("testcomponents.passthrough")
+	ctrl := newController(controllerOptions{
+		Options:        testOptions(t),
+		ModuleRegistry: newModuleRegistry(),
+		WorkerPool:     worker.NewFixedWorkerPool(1, 100),
+		ComponentRegistry: controller.RegistryMap{
+			passthrough.Name: passthrough,
+			"test.rules": component.Registration{
+				Name: "test.rules",
+				Args: rulesArgs{},
+				Build: func(component.Options, component.Arguments) (component.Component, error) {
+					return secretComponent{}, nil
+				},
+			},
+		},
+	})
+	defer cleanUpController(ctrl)
+
+	load := func(label string) {
+		f, err := ParseSource(t.Name(), []byte(fmt.Sprintf(`
+			testcomponents.passthrough "label" {
+				input = %q
+			}
+
+			test.rules "generated" {
+				dynamic "rule" {
+					for_each = [testcomponents.passthrough.label.output, "static"]
+
+					content {
+						label = rule.value
+					}
+				}
+			}
+		`, label)))
+		require.NoError(t, err)
+		require.NoError(t, ctrl.LoadSource(f, nil))
+	}
+
+	load("first")
+
+	// References from dynamic blocks are edges like any other reference.
+	g := ctrl.loader.Graph()
+	deps := g.Dependencies(g.GetByID("test.rules.generated"))
+	require.Len(t, deps, 1)
+	require.Equal(t, "testcomponents.passthrough.label", deps[0].NodeID())
+
+	args, _ := getFields(t, g, "test.rules.generated")
+	require.Equal(t, rulesArgs{Rules: []rulesRule{{Label: "first"}, {Label: "static"}}}, args)
+
+	// Blocks are generated again when the collection changes.
+	load("second")
+	args, _ = getFields(t, ctrl.loader.Graph(), "test.rules.generated")
+	require.Equal(t, rulesArgs{Rules: []rulesRule{{Label: "second"}, {Label: "static"}}}, args)
+}
+
+func TestController_LoadSource_Prev(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+	ctrl := New(testOptions(t))
+	defer cleanUpController(ctrl)
+
+	load := func(input, prevInput string) {
+		f, err := ParseSource(t.Name(), []byte(fmt.Sprintf(`
+			testcomponents.passthrough "source" {
+				input = %q
+			}
+
+			testcomponents.passthrough "prev" {
+				input = %s
+			}
+		`, input, prevInput)))
+		require.NoError(t, err)
+		require.NoError(t, ctrl.LoadSource(f, nil))
+	}
+	prevOutput := func() string {
+		_, exports := getFields(t, ctrl.loader.Graph(), "testcomponents.passthrough.prev")
+		return exports.(testcomponents.PassthroughExports).Output
+	}
+	const usePrev = `coalesce(prev(testcomponents.passthrough.source.output), "none")`
+
+	// prev is null on the first evaluation.
+	load("first", usePrev)
+	require.Equal(t, "none", prevOutput())
+
+	// Previous values are kept across reloads.
+	load("second", usePrev)
+	require.Equal(t, "first", prevOutput())
+	load("third", usePrev)
+	require.Equal(t, "second", prevOutput())
+
+	// Previous values are dropped once prev isn't called anymore.
+	load("fourth", `"static"`)
+	require.Equal(t, "static", prevOutput())
+	load("fifth", usePrev)
+	require.Equal(t, "none", prevOutput())
+}
+
+type rejectArgs struct {
+	Value string `river:"value,attr"`
+	Prev  string `river:"prev,attr"`
+}
+
+// rejectComponent exports the prev value it was given, and fails to update
+// to a value of "bad".
+type rejectComponent struct {
+	opts component.Options
+}
+
+func (c rejectComponent) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (c rejectComponent) Update(args component.Arguments) error {
+	a := args.(rejectArgs)
+	if a.Value == "bad" {
+		return fmt.Errorf("rejected value %q", a.Value)
+	}
+	c.opts.OnStateChange(testcomponents.PassthroughExports{Output: a.Prev})
+	return nil
+}
+
+func TestController_LoadSource_PrevFailedUpdate(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+
+	passthrough, _ := component.Get("testcomponents.passthrough")
+	ctrl := newController(controllerOptions{
+		Options:        testOptions(t),
+		ModuleRegistry: newModuleRegistry(),
+		WorkerPool:     worker.NewFixedWorkerPool(1, 100),
+		ComponentRegistry: controller.RegistryMap{
+			passthrough.Name: passthrough,
+			"test.reject": component.Registration{
+				Name:    "test.reject",
+				Args:    rejectArgs{},
+				Exports: testcomponents.PassthroughExports{},
+				Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+					c := rejectComponent{opts: opts}
+					return c, c.Update(args)
+				},
+			},
+		},
+	})
+	defer cleanUpController(ctrl)
+
+	load := func(input string) error {
+		f, err := ParseSource(t.Name(), []byte(fmt.Sprintf(`
+			testcomponents.passthrough "source" {
+				input = %q
+			}
+
+			test.reject "r" {
+				value = testcomponents.passthrough.source.output
+				prev  = coalesce(prev(testcomponents.passthrough.source.output), "none")
+			}
+		`, input)))
+		require.NoError(t, err)
+		return ctrl.LoadSource(f, nil)
+	}
+	prevOutput := func() string {
+		_, exports := getFields(t, ctrl.loader.Graph(), "test.reject.r")
+		return exports.(testcomponents.PassthroughExports).Output
+	}
+
+	require.NoError(t, load("first"))
+	require.NoError(t, load("second"))
+	require.Equal(t, "first", prevOutput())
+
+	// Values from an evaluation which failed to update the component don't
+	// become previous values.
+	require.Error(t, load("bad"))
+	require.NoError(t, load("third"))
+	require.Equal(t, "second", prevOutput())
+}
+
+func TestController_LoadSource_StrictShadowing(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+
+	passthrough, _ := component.Get("testcomponents.passthrough")
+	shadowing := passthrough
+	shadowing.Name = "concat.passthrough"
+	newShadowingController := func(strict bool) *Flow {
+		opts := testOptions(t)
+		opts.StrictShadowing = strict
+		return newController(controllerOptions{
+			Options:        opts,
+			ModuleRegistry: newModuleRegistry(),
+			WorkerPool:     worker.NewFixedWorkerPool(1, 100),
+			ComponentRegistry: controller.RegistryMap{
+				passthrough.Name: passthrough,
+				shadowing.Name:   shadowing,
+			},
+		})
+	}
+
+	f, err := ParseSource(t.Name(), []byte(`
+		concat.passthrough "a" {
+			input = "hello, world!"
+		}
+	`))
+	require.NoError(t, err)
+
+	// Shadowing is reported as a warning by default.
+	ctrl := newShadowingController(false)
+	defer cleanUpController(ctrl)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+	diags := ctrl.LastLoadDiagnostics()
+	require.Len(t, diags, 1)
+	require.Equal(t, diag.SeverityLevelWarn, diags[0].Severity)
+	require.Contains(t, diags[0].Message, `component concat.passthrough.a shadows the standard library identifier "concat"`)
+
+	strict := newShadowingController(true)
+	defer cleanUpController(strict)
+	err = strict.LoadSource(f, nil)
+	require.ErrorContains(t, err, `component concat.passthrough.a shadows the standard library identifier "concat"`)
+	require.False(t, strict.loader.Applied())
+}
@@ -0,0 +1,41 @@
+package flow
+
+import (
+	"context"
+
+	"github.com/grafana/agent/pkg/flow/internal/controller"
+	"github.com/grafana/river/diag"
+	"golang.org/x/exp/maps"
+)
+
+// ValidateBlock parses and evaluates the arguments of a single component
+// block in src without loading it, so a block can be checked as it's edited
+// without reloading the whole config. Expressions in the block may reference
+// the custom functions of f and the values in refs, keyed by the ID they're
+// referenced by, such as "prometheus.remote_write.default":
+//
+//	f.ValidateBlock(src, map[string]any{
+//		"prometheus.remote_write.default": map[string]any{"receiver": receiver},
+//	})
+//
+// References to anything else, including components loaded by f, are
+// reported as errors. The component isn't built, so errors only reported by
+// components when they're built or updated aren't found.
+func (f *Flow) ValidateBlock(src []byte, refs map[string]any) diag.Diagnostics {
+	functions := f.identifiers()
+	if f.lookups != nil {
+		// Lookup functions using Options.Resolver replace the standard library
+		// ones.
+		maps.Copy(functions, f.lookups.Functions(context.Background()))
+	}
+	return controller.ValidateBlock(
+		f.opts.ComponentRegistry,
+		controller.ComponentPolicy{
+			Allowed: f.opts.AllowedComponents,
+			Denied:  f.opts.DeniedComponents,
+		},
+		functions,
+		src,
+		refs,
+	)
+}
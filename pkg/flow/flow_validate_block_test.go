@@ -0,0 +1,85 @@
+package flow
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateBlock(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+
+	ctrl := New(testOptions(t))
+	defer cleanUpController(ctrl)
+	require.NoError(t, ctrl.Functions().Register("greeting", func() string { return "hello" }))
+
+	refs := map[string]any{
+		"testcomponents.passthrough.upstream": map[string]any{"output": "value"},
+	}
+
+	tt := []struct {
+		name   string
+		src    string
+		expect string // Expected error; the block is valid if empty.
+	}{
+		{
+			name: "valid",
+			src: `testcomponents.passthrough "example" {
+				input = testcomponents.passthrough.upstream.output + greeting()
+			}`,
+		},
+		{
+			name: "disabled",
+			src: `testcomponents.passthrough "example" {
+				enabled = false
+				input   = [1]
+			}`,
+		},
+		{
+			name:   "unknown reference",
+			src:    `testcomponents.passthrough "example" { input = testcomponents.passthrough.missing.output }`,
+			expect: `field "missing" does not exist`,
+		},
+		{
+			name:   "wrong type",
+			src:    `testcomponents.passthrough "example" { input = [1] }`,
+			expect: "should be string, got array",
+		},
+		{
+			name:   "missing argument",
+			src:    `testcomponents.passthrough "example" {}`,
+			expect: `missing required attribute "input"`,
+		},
+		{
+			name:   "unknown component",
+			src:    `testcomponents.missing "example" {}`,
+			expect: `Unrecognized component name "testcomponents.missing"`,
+		},
+		{
+			name:   "missing label",
+			src:    `testcomponents.passthrough { input = "" }`,
+			expect: "testcomponents.passthrough requires a label",
+		},
+		{
+			name:   "multiple blocks",
+			src:    "testcomponents.passthrough \"a\" { input = \"\" }\ntestcomponents.passthrough \"b\" { input = \"\" }",
+			expect: "Expected a single component block",
+		},
+		{
+			name:   "parse error",
+			src:    `testcomponents.passthrough "example" {`,
+			expect: "expected }",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			diags := ctrl.ValidateBlock([]byte(tc.src), refs)
+			if tc.expect == "" {
+				require.False(t, diags.HasErrors(), "unexpected errors: %s", diags)
+				return
+			}
+			require.ErrorContains(t, diags, tc.expect)
+		})
+	}
+}
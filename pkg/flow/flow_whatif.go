@@ -0,0 +1,26 @@
+package flow
+
+import (
+	"github.com/grafana/agent/component"
+)
+
+// WhatIf reports what the components depending on the component with the
+// given local ID would evaluate to if that component exported the values in
+// override, such as `output = "new value"`. override is a River body setting
+// the exports of the component, and exports which aren't set use their zero
+// values.
+//
+// WhatIf returns the evaluated arguments of every component which directly
+// depends on the component, by component ID. It fails if one of them fails
+// to evaluate.
+//
+// The live graph isn't changed, and no component is started, stopped, or
+// updated. Since dependants aren't updated, their new exports aren't known,
+// so components which only depend on the component through other components
+// aren't included.
+func (f *Flow) WhatIf(name string, override string) (map[string]component.Arguments, error) {
+	f.loadMut.RLock()
+	defer f.loadMut.RUnlock()
+
+	return f.loader.WhatIf(name, []byte(override))
+}
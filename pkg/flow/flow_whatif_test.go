@@ -0,0 +1,54 @@
+package flow
+
+import (
+	"testing"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/pkg/flow/internal/testcomponents"
+	"github.com/stretchr/testify/require"
+)
+
+func TestController_WhatIf(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+	ctrl := New(testOptions(t))
+	defer cleanUpController(ctrl)
+
+	f, err := ParseSource(t.Name(), []byte(`
+		testcomponents.passthrough "a" {
+			input = "hello"
+		}
+
+		testcomponents.passthrough "b" {
+			input = testcomponents.passthrough.a.output + ", world"
+		}
+
+		testcomponents.passthrough "c" {
+			input = testcomponents.passthrough.b.output + "!"
+		}
+
+		testcomponents.passthrough "unrelated" {
+			input = "unrelated"
+		}
+	`))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	results, err := ctrl.WhatIf("testcomponents.passthrough.a", `output = "goodbye"`)
+	require.NoError(t, err)
+
+	// c isn't included, since the new exports of b aren't known.
+	require.Equal(t, map[string]component.Arguments{
+		"testcomponents.passthrough.b": testcomponents.PassthroughConfig{Input: "goodbye, world"},
+	}, results)
+
+	// The live graph is unchanged.
+	b, err := ctrl.GetComponent(component.ID{LocalID: "testcomponents.passthrough.b"}, component.InfoOptions{GetArguments: true})
+	require.NoError(t, err)
+	require.Equal(t, testcomponents.PassthroughConfig{Input: "hello, world"}, b.Arguments)
+
+	_, err = ctrl.WhatIf("testcomponents.passthrough.a", `unknown = "goodbye"`)
+	require.ErrorContains(t, err, `decoding exports of "testcomponents.passthrough.a"`)
+
+	_, err = ctrl.WhatIf("testcomponents.passthrough.missing", `output = "goodbye"`)
+	require.ErrorContains(t, err, `component "testcomponents.passthrough.missing" does not exist`)
+}
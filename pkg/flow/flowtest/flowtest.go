@@ -0,0 +1,260 @@
+// Package flowtest provides utilities for testing Flow configs and the wiring
+// between components.
+//
+// A Controller runs a Flow config entirely in memory: the config is loaded
+// from a string rather than a file, and the controller is timed by a fake
+// clock which only moves when the test advances it. Components still write
+// their data to disk, to a temporary directory which is removed when the
+// Controller stops, since components such as prometheus.remote_write use the
+// data path through the OS.
+package flowtest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/pkg/flow"
+	"github.com/grafana/agent/pkg/flow/internal/stepper"
+	"github.com/grafana/agent/pkg/flow/logging"
+	"github.com/grafana/agent/service"
+	"github.com/grafana/agent/service/labelstore"
+	"github.com/tilinna/clock"
+)
+
+// pollInterval is how often Wait methods check the state of components.
+const pollInterval = 10 * time.Millisecond
+
+// A Controller is a testing controller which runs a Flow config.
+type Controller struct {
+	f        *flow.Flow
+	dataPath string           // Temporary data path to remove on Stop, if any.
+	stepper  *stepper.Stepper // Stepper propagating updates, if created with NewSteppedController.
+	clock    *clock.Mock      // Fake clock timing the controller, unless opts.Clock was a different clock.
+
+	runOnce sync.Once
+	cancel  context.CancelFunc
+	exited  chan struct{}
+}
+
+// NewController returns a new, unstarted Controller. opts is used to create
+// the underlying Flow controller, with the following defaults:
+//
+//   - If opts.Logger is nil, logs are discarded.
+//   - If opts.DataPath is empty, a temporary directory is used.
+//   - If opts.Clock is nil, a fake clock set to the current time is used,
+//     which only moves when Advance is called. Advance can also move a
+//     clock.Mock passed as opts.Clock.
+//   - If opts.Services is empty, the labelstore service is provided so that
+//     Prometheus components can run.
+func NewController(opts flow.Options) (*Controller, error) {
+	c := &Controller{exited: make(chan struct{})}
+
+	if opts.Logger == nil {
+		l, err := logging.New(io.Discard, logging.DefaultOptions)
+		if err != nil {
+			return nil, err
+		}
+		opts.Logger = l
+	}
+	if opts.DataPath == "" {
+		dataPath, err := os.MkdirTemp("", "flowtest-*")
+		if err != nil {
+			return nil, err
+		}
+		opts.DataPath = dataPath
+		c.dataPath = dataPath
+	}
+	if opts.Clock == nil {
+		opts.Clock = clock.NewMock(time.Now())
+	}
+	c.clock, _ = opts.Clock.(*clock.Mock)
+	if len(opts.Services) == 0 {
+		opts.Services = []service.Service{labelstore.New(nil)}
+	}
+
+	c.f = flow.New(opts)
+	return c, nil
+}
+
+// NewSteppedController returns a new, unstarted Controller like
+// NewController, except that updates reported by components are only
+// propagated through the graph when Step is called. This allows tests to
+// check how an update moves through the graph one step at a time without
+// waiting for it.
+func NewSteppedController(opts flow.Options) (*Controller, error) {
+	s := stepper.New()
+	opts.Stepper = s
+
+	c, err := NewController(opts)
+	if err != nil {
+		return nil, err
+	}
+	c.stepper = s
+	return c, nil
+}
+
+// Step propagates pending updates one step through the graph of a
+// Controller created with NewSteppedController: the dependants of every
+// component which updated its exports since the last step, and of the
+// components named by updated, are evaluated in order of their IDs. Step
+// returns the IDs of the evaluated components in the order they were
+// evaluated, once every evaluation finished.
+//
+// Run must be called before Step. Step fails if the Controller wasn't created
+// with NewSteppedController.
+func (c *Controller) Step(ctx context.Context, updated ...string) ([]string, error) {
+	if c.stepper == nil {
+		return nil, fmt.Errorf("controller wasn't created with NewSteppedController")
+	}
+	return c.stepper.Step(ctx, updated...)
+}
+
+// Advance moves the fake clock of the controller forward by d, firing the
+// timers which expire in the meantime, such as reloads delayed by
+// MinReloadInterval and the periodic health checks of components. Timers
+// fire in the background, so their effects may be observed after Advance
+// returns.
+//
+// Advance fails if the Controller was created with a clock other than a
+// clock.Mock.
+func (c *Controller) Advance(d time.Duration) error {
+	if c.clock == nil {
+		return fmt.Errorf("controller doesn't use a fake clock")
+	}
+	c.clock.Add(d)
+	return nil
+}
+
+// Flow returns the underlying Flow controller.
+func (c *Controller) Flow() *flow.Flow { return c.f }
+
+// LoadBytes parses config as River and loads it into the controller.
+// LoadBytes can be called again after Run to update the running config, which
+// re-evaluates the components whose blocks changed.
+func (c *Controller) LoadBytes(config []byte) error {
+	source, err := flow.ParseSource("flowtest.river", config)
+	if err != nil {
+		return err
+	}
+	return c.f.LoadSource(source, nil)
+}
+
+// Run starts the controller in the background. Components only start running
+// once a config has been loaded without errors. The controller runs until ctx
+// is canceled or Stop is called.
+//
+// Run may only be called once per Controller.
+func (c *Controller) Run(ctx context.Context) {
+	c.runOnce.Do(func() {
+		ctx, c.cancel = context.WithCancel(ctx)
+		go func() {
+			defer close(c.exited)
+			c.f.Run(ctx)
+		}()
+	})
+}
+
+// Stop stops the controller and waits for it to exit. Stop also removes the
+// temporary data path used by components, if one was created.
+//
+// Stop runs the controller briefly if Run was never called, so resources
+// created by loading configs are always released.
+func (c *Controller) Stop() {
+	c.Run(context.Background())
+	c.cancel()
+	<-c.exited
+
+	if c.dataPath != "" {
+		_ = os.RemoveAll(c.dataPath)
+	}
+}
+
+// GetComponent returns information about the component with the provided ID,
+// such as "testcomponents.passthrough.example". Modules can be inspected by
+// using IDs of the form "MODULE_ID/LOCAL_ID".
+func (c *Controller) GetComponent(id string, opts component.InfoOptions) (*component.Info, error) {
+	return c.f.GetComponent(component.ParseID(id), opts)
+}
+
+// Arguments returns the current arguments of the component with the provided
+// ID.
+func (c *Controller) Arguments(id string) (component.Arguments, error) {
+	info, err := c.GetComponent(id, component.InfoOptions{GetArguments: true})
+	if err != nil {
+		return nil, err
+	}
+	return info.Arguments, nil
+}
+
+// Exports returns the current exports of the component with the provided ID.
+func (c *Controller) Exports(id string) (component.Exports, error) {
+	info, err := c.GetComponent(id, component.InfoOptions{GetExports: true})
+	if err != nil {
+		return nil, err
+	}
+	return info.Exports, nil
+}
+
+// Health returns the current health of the component with the provided ID.
+func (c *Controller) Health(id string) (component.Health, error) {
+	info, err := c.GetComponent(id, component.InfoOptions{GetHealth: true})
+	if err != nil {
+		return component.Health{}, err
+	}
+	return info.Health, nil
+}
+
+// WaitExports blocks until the exports of the component with the provided ID
+// satisfy cond, up to the provided timeout. WaitExports is useful for waiting
+// for state to propagate through the graph after a component updates its
+// exports.
+func (c *Controller) WaitExports(id string, timeout time.Duration, cond func(component.Exports) bool) error {
+	return c.wait(timeout, func() (bool, error) {
+		exports, err := c.Exports(id)
+		if err != nil {
+			return false, err
+		}
+		return cond(exports), nil
+	}, fmt.Sprintf("timed out waiting for exports of %s", id))
+}
+
+// WaitHealthy blocks until the component with the provided ID reports itself
+// as healthy, up to the provided timeout.
+func (c *Controller) WaitHealthy(id string, timeout time.Duration) error {
+	return c.wait(timeout, func() (bool, error) {
+		health, err := c.Health(id)
+		if err != nil {
+			return false, err
+		}
+		return health.Health == component.HealthTypeHealthy, nil
+	}, fmt.Sprintf("timed out waiting for %s to be healthy", id))
+}
+
+// wait calls check until it returns true or an error, up to timeout.
+func (c *Controller) wait(timeout time.Duration, check func() (bool, error), timeoutMsg string) error {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		ok, err := check()
+		if err != nil {
+			return err
+		} else if ok {
+			return nil
+		}
+
+		select {
+		case <-deadline.C:
+			return fmt.Errorf("%s", timeoutMsg)
+		case <-ticker.C:
+		}
+	}
+}
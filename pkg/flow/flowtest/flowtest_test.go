@@ -0,0 +1,177 @@
+package flowtest_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/pkg/flow"
+	"github.com/grafana/agent/pkg/flow/flowtest"
+	"github.com/grafana/agent/pkg/flow/internal/testcomponents"
+	"github.com/stretchr/testify/require"
+	"github.com/tilinna/clock"
+	"go.uber.org/goleak"
+)
+
+func TestController(t *testing.T) {
+	defer goleak.VerifyNone(
+		t,
+		goleak.IgnoreTopFunction("go.opencensus.io/stats/view.(*worker).start"),
+		goleak.IgnoreTopFunction("go.opentelemetry.io/otel/sdk/trace.(*batchSpanProcessor).processQueue"),
+	)
+
+	ctrl, err := flowtest.NewController(flow.Options{})
+	require.NoError(t, err)
+	defer ctrl.Stop()
+
+	require.NoError(t, ctrl.LoadBytes([]byte(`
+		testcomponents.passthrough "a" {
+			input = "hello"
+		}
+
+		testcomponents.passthrough "b" {
+			input = testcomponents.passthrough.a.output
+		}
+	`)))
+	ctrl.Run(context.Background())
+
+	require.NoError(t, ctrl.WaitHealthy("testcomponents.passthrough.b", 5*time.Second))
+
+	outputEquals := func(expect string) func(component.Exports) bool {
+		return func(e component.Exports) bool {
+			return e.(testcomponents.PassthroughExports).Output == expect
+		}
+	}
+	require.NoError(t, ctrl.WaitExports("testcomponents.passthrough.b", 5*time.Second, outputEquals("hello")))
+
+	// Reloading the config propagates new values through the graph.
+	require.NoError(t, ctrl.LoadBytes([]byte(`
+		testcomponents.passthrough "a" {
+			input = "world"
+		}
+
+		testcomponents.passthrough "b" {
+			input = testcomponents.passthrough.a.output
+		}
+	`)))
+	require.NoError(t, ctrl.WaitExports("testcomponents.passthrough.b", 5*time.Second, outputEquals("world")))
+
+	args, err := ctrl.Arguments("testcomponents.passthrough.b")
+	require.NoError(t, err)
+	require.Equal(t, "world", args.(testcomponents.PassthroughConfig).Input)
+
+	_, err = ctrl.Exports("testcomponents.passthrough.missing")
+	require.ErrorIs(t, err, component.ErrComponentNotFound)
+}
+
+func TestController_WaitTimeout(t *testing.T) {
+	ctrl, err := flowtest.NewController(flow.Options{})
+	require.NoError(t, err)
+	defer ctrl.Stop()
+
+	require.NoError(t, ctrl.LoadBytes([]byte(`
+		testcomponents.passthrough "a" {
+			input = "hello"
+		}
+	`)))
+	ctrl.Run(context.Background())
+
+	err = ctrl.WaitExports("testcomponents.passthrough.a", 50*time.Millisecond, func(component.Exports) bool { return false })
+	require.EqualError(t, err, "timed out waiting for exports of testcomponents.passthrough.a")
+}
+
+func TestController_Step(t *testing.T) {
+	ctrl, err := flowtest.NewSteppedController(flow.Options{})
+	require.NoError(t, err)
+	defer ctrl.Stop()
+
+	require.NoError(t, ctrl.LoadBytes([]byte(`
+		testcomponents.passthrough "a" {
+			input = "hello"
+		}
+
+		testcomponents.passthrough "b" {
+			input = testcomponents.passthrough.a.output
+		}
+
+		testcomponents.passthrough "c" {
+			input = testcomponents.passthrough.b.output
+		}
+	`)))
+	ctrl.Run(context.Background())
+	for _, id := range []string{"a", "b", "c"} {
+		require.NoError(t, ctrl.WaitHealthy("testcomponents.passthrough."+id, 5*time.Second))
+	}
+
+	step := func(updated ...string) []string {
+		evaluated, err := ctrl.Step(context.Background(), updated...)
+		require.NoError(t, err)
+		return evaluated
+	}
+
+	// Propagate the updates from loading the config and starting components.
+	for len(step()) > 0 {
+	}
+
+	// Only the direct dependants of updated components are evaluated, and
+	// nothing is left to propagate since their exports didn't change.
+	require.Equal(t, []string{"testcomponents.passthrough.b"}, step("testcomponents.passthrough.a"))
+	require.Empty(t, step())
+
+	_, err = ctrl.Step(context.Background(), "testcomponents.passthrough.missing")
+	require.EqualError(t, err, `component "testcomponents.passthrough.missing" does not exist`)
+}
+
+func TestController_Advance(t *testing.T) {
+	fakeClock := clock.NewMock(time.Now())
+	ctrl, err := flowtest.NewController(flow.Options{
+		Clock:             fakeClock,
+		MinReloadInterval: time.Minute,
+	})
+	require.NoError(t, err)
+	defer ctrl.Stop()
+
+	reload := func(input string) (bool, error) {
+		source, err := flow.ParseSource("flowtest.river", []byte(fmt.Sprintf(`
+			testcomponents.passthrough "a" {
+				input = %q
+			}
+		`, input)))
+		require.NoError(t, err)
+		return ctrl.Flow().Reload(context.Background(), source)
+	}
+	inputEquals := func(expect string) {
+		args, err := ctrl.Arguments("testcomponents.passthrough.a")
+		require.NoError(t, err)
+		require.Equal(t, expect, args.(testcomponents.PassthroughConfig).Input)
+	}
+
+	changed, err := reload("hello")
+	require.NoError(t, err)
+	require.True(t, changed)
+	inputEquals("hello")
+
+	// The next reload waits for the minimum interval to elapse on the fake
+	// clock.
+	reloaded := make(chan error, 1)
+	go func() {
+		_, err := reload("world")
+		reloaded <- err
+	}()
+	require.Eventually(t, func() bool { return fakeClock.Len() == 1 }, 5*time.Second, 10*time.Millisecond)
+	inputEquals("hello")
+
+	require.NoError(t, ctrl.Advance(time.Minute))
+	require.NoError(t, <-reloaded)
+	inputEquals("world")
+}
+
+func TestController_AdvanceRealtime(t *testing.T) {
+	ctrl, err := flowtest.NewController(flow.Options{Clock: clock.Realtime()})
+	require.NoError(t, err)
+	defer ctrl.Stop()
+
+	require.EqualError(t, ctrl.Advance(time.Second), "controller doesn't use a fake clock")
+}
@@ -0,0 +1,326 @@
+package flow
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/pkg/flow/internal/stdlib"
+	"github.com/grafana/river"
+	"github.com/grafana/river/scanner"
+	"github.com/grafana/river/vm"
+)
+
+// FunctionRegistry holds custom functions which can be called from configs
+// loaded by Flow controllers, in addition to the standard library.
+//
+// A FunctionRegistry may be shared between multiple controllers through
+// [Options.Functions]. Each controller layers its own registry on top of the
+// shared one, which is returned by [Flow.Functions], so controllers can add
+// instance-specific functions without affecting other controllers.
+//
+// Functions registered after a config is loaded are available from the next
+// call to LoadSource.
+type FunctionRegistry struct {
+	parent *FunctionRegistry
+
+	mut        sync.RWMutex
+	funcs      map[string]any
+	generation uint64 // Incremented every time a function is registered.
+}
+
+// NewFunctionRegistry returns a new, empty FunctionRegistry.
+func NewFunctionRegistry() *FunctionRegistry {
+	return &FunctionRegistry{funcs: make(map[string]any)}
+}
+
+// Extend returns a new FunctionRegistry which includes every function in r.
+// Functions registered to the returned registry aren't added to r, while
+// functions later registered to r are visible in the returned registry.
+//
+// Extend may be called on a nil FunctionRegistry, in which case it's the same
+// as calling NewFunctionRegistry.
+func (r *FunctionRegistry) Extend() *FunctionRegistry {
+	child := NewFunctionRegistry()
+	child.parent = r
+	return child
+}
+
+// Register registers a function which can be called from configs using name.
+//
+// Functions follow the same rules as the River standard library: fn must be a
+// Go function with exactly one non-error return value, with an optional error
+// return value as the second return value.
+//
+// Register returns an error if name isn't a valid identifier, if fn isn't a
+// valid function, or if name is already used by the standard library, a
+// component, or a function in r or any registry r extends.
+func (r *FunctionRegistry) Register(name string, fn any) error {
+	if !scanner.IsValidIdentifier(name) {
+		return fmt.Errorf("function name %q is not a valid identifier", name)
+	}
+	if err := validateFunction(fn); err != nil {
+		return fmt.Errorf("function %q: %w", name, err)
+	}
+	if err := checkReservedName(name); err != nil {
+		return err
+	}
+
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	if r.lookup(name) {
+		return fmt.Errorf("function %q is already registered", name)
+	}
+	r.funcs[name] = fn
+	r.generation++
+	return nil
+}
+
+// lookup returns whether name is registered to r or any registry r extends.
+// The lock of r must be held when calling lookup.
+func (r *FunctionRegistry) lookup(name string) bool {
+	if _, ok := r.funcs[name]; ok {
+		return true
+	}
+	for p := r.parent; p != nil; p = p.parent {
+		p.mut.RLock()
+		_, ok := p.funcs[name]
+		p.mut.RUnlock()
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// identifiers returns every function in r and the registries it extends,
+// keyed by name.
+func (r *FunctionRegistry) identifiers() map[string]any {
+	if r == nil {
+		return nil
+	}
+
+	res := r.parent.identifiers()
+	if res == nil {
+		res = make(map[string]any)
+	}
+
+	r.mut.RLock()
+	defer r.mut.RUnlock()
+	for name, fn := range r.funcs {
+		res[name] = fn
+	}
+	return res
+}
+
+// configDirIdentifier is the identifier holding [Options.ConfigDir] in
+// loaded configs.
+const configDirIdentifier = "config_dir"
+
+// identifiers returns the custom functions and built-in values which
+// expressions in configs loaded by f can reference, keyed by name.
+func (f *Flow) identifiers() map[string]any {
+	res := f.functions.identifiers()
+	if f.opts.ConfigDir != "" {
+		res[configDirIdentifier] = f.opts.ConfigDir
+	}
+	return res
+}
+
+// version returns a number which changes every time a function is registered
+// to r or any registry r extends.
+func (r *FunctionRegistry) version() uint64 {
+	var version uint64
+	for ; r != nil; r = r.parent {
+		r.mut.RLock()
+		version += r.generation
+		r.mut.RUnlock()
+	}
+	return version
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// validateFunction returns an error if fn can't be called from River.
+func validateFunction(fn any) error {
+	ty := reflect.TypeOf(fn)
+	if ty == nil || ty.Kind() != reflect.Func {
+		return fmt.Errorf("expected a function, got %T", fn)
+	}
+	return validateFunctionType(ty)
+}
+
+// validateFunctionType returns an error if functions of type ty, which must
+// be a function type, can't be called from River.
+func validateFunctionType(ty reflect.Type) error {
+	switch {
+	case ty.NumOut() == 1 && ty.Out(0) != errorType:
+		return nil
+	case ty.NumOut() == 2 && ty.Out(0) != errorType && ty.Out(1) == errorType:
+		return nil
+	default:
+		return fmt.Errorf("must return exactly one non-error value and optionally an error")
+	}
+}
+
+// checkReservedName returns an error if name is already used by the standard
+// library or as the namespace of a component.
+func checkReservedName(name string) error {
+	// Lookup falls back to the River standard library after the Flow one.
+	if _, ok := (&vm.Scope{Variables: stdlib.Identifiers}).Lookup(name); ok {
+		return fmt.Errorf("function %q is already defined in the standard library", name)
+	}
+
+	// Module arguments are exposed as the argument identifier.
+	if name == "argument" {
+		return fmt.Errorf("function %q conflicts with module arguments", name)
+	}
+	if name == configDirIdentifier {
+		return fmt.Errorf("function %q conflicts with the config directory", name)
+	}
+	for _, componentName := range component.AllNames() {
+		if namespace, _, _ := strings.Cut(componentName, "."); namespace == name {
+			return fmt.Errorf("function %q conflicts with component %q", name, componentName)
+		}
+	}
+	return nil
+}
+
+// FunctionSignature describes the parameters and return value of a function
+// which can be called from configs, using River type names such as "string",
+// "number", "array", "object", or "any" for values of any type.
+type FunctionSignature struct {
+	Name   string
+	Params []string // Types of the parameters, in order.
+
+	// Variadic is set if the last parameter may be repeated any number of
+	// times, including none.
+	Variadic bool
+
+	Returns      string // Type of the returned value.
+	ReturnsError bool   // Whether calling the function may fail.
+}
+
+// String returns sig formatted like "select(string, object, any...) any".
+func (sig FunctionSignature) String() string {
+	params := make([]string, len(sig.Params))
+	copy(params, sig.Params)
+	if sig.Variadic && len(params) > 0 {
+		params[len(params)-1] += "..."
+	}
+	return fmt.Sprintf("%s(%s) %s", sig.Name, strings.Join(params, ", "), sig.Returns)
+}
+
+// FunctionSignatures returns the signatures of the functions which configs
+// loaded by f can call, sorted by name: the Flow standard library, such as
+// url_parse, and custom functions registered to the FunctionRegistry of f
+// and the registries it extends. Signatures are derived from the Go functions
+// implementing them, so they can be used to generate up-to-date reference
+// documentation.
+//
+// Functions of the River standard library, such as concat or env, aren't
+// included, since River doesn't expose them.
+func (f *Flow) FunctionSignatures() []FunctionSignature {
+	funcs := make(map[string]any, len(stdlib.Identifiers))
+	for name, fn := range stdlib.Identifiers {
+		funcs[name] = fn
+	}
+	for name, fn := range f.identifiers() {
+		funcs[name] = fn
+	}
+
+	sigs := make([]FunctionSignature, 0, len(funcs))
+	for name, fn := range funcs {
+		if validateFunction(fn) != nil {
+			// Values such as config_dir aren't functions.
+			continue
+		}
+		sigs = append(sigs, functionSignature(name, reflect.TypeOf(fn)))
+	}
+	sort.Slice(sigs, func(i, j int) bool { return sigs[i].Name < sigs[j].Name })
+	return sigs
+}
+
+// functionSignature returns the signature of the function named name with
+// type ty, which must be a valid function.
+func functionSignature(name string, ty reflect.Type) FunctionSignature {
+	sig := FunctionSignature{
+		Name:         name,
+		Params:       make([]string, ty.NumIn()),
+		Variadic:     ty.IsVariadic(),
+		Returns:      riverTypeName(ty.Out(0)),
+		ReturnsError: ty.NumOut() == 2,
+	}
+	for i := range sig.Params {
+		in := ty.In(i)
+		if sig.Variadic && i == ty.NumIn()-1 {
+			in = in.Elem()
+		}
+		sig.Params[i] = riverTypeName(in)
+	}
+	return sig
+}
+
+var (
+	capsuleType       = reflect.TypeOf((*river.Capsule)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	durationType      = reflect.TypeOf(time.Duration(0))
+)
+
+// riverTypeName returns the name of the River type which values of the Go
+// type ty are converted to, following the same rules as River.
+func riverTypeName(ty reflect.Type) string {
+	for ty.Kind() == reflect.Pointer {
+		switch {
+		case ty.Implements(capsuleType):
+			return "capsule"
+		case ty.Implements(textMarshalerType):
+			return "string"
+		}
+		ty = ty.Elem()
+	}
+
+	switch {
+	case ty.Implements(capsuleType):
+		return "capsule"
+	case ty.Implements(textMarshalerType), ty == durationType:
+		return "string"
+	}
+
+	switch ty.Kind() {
+	case reflect.Interface:
+		// Functions accepting or returning interfaces may use any value.
+		return "any"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "bool"
+	case reflect.Array, reflect.Slice:
+		return "array"
+	case reflect.Map:
+		if ty.Key().Kind() == reflect.String {
+			return "object"
+		}
+	case reflect.Struct:
+		for i := 0; i < ty.NumField(); i++ {
+			if _, ok := ty.Field(i).Tag.Lookup("river"); ok {
+				return "object"
+			}
+		}
+	case reflect.Func:
+		if validateFunctionType(ty) == nil {
+			return "function"
+		}
+	}
+	return "capsule"
+}
@@ -0,0 +1,226 @@
+package flow
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/pkg/flow/internal/testcomponents"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func TestFunctionRegistry_Shared(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+
+	shared := NewFunctionRegistry()
+	require.NoError(t, shared.Register("greeting", func(name string) string { return "hello, " + name }))
+
+	load := func(tenant string) *Flow {
+		opts := testOptions(t)
+		opts.Functions = shared
+		ctrl := New(opts)
+		require.NoError(t, ctrl.Functions().Register("tenant", func() string { return tenant }))
+
+		f, err := ParseSource(t.Name(), []byte(`
+			testcomponents.passthrough "greeting" {
+				input = greeting(tenant())
+			}
+		`))
+		require.NoError(t, err)
+		require.NoError(t, ctrl.LoadSource(f, nil))
+		return ctrl
+	}
+
+	ctrlA, ctrlB := load("a"), load("b")
+	defer cleanUpController(ctrlA)
+	defer cleanUpController(ctrlB)
+
+	for ctrl, expect := range map[*Flow]string{ctrlA: "hello, a", ctrlB: "hello, b"} {
+		_, exports := getFields(t, ctrl.loader.Graph(), "testcomponents.passthrough.greeting")
+		require.Equal(t, expect, exports.(testcomponents.PassthroughExports).Output)
+	}
+
+	// Instance-specific functions aren't added to the shared registry.
+	require.NoError(t, shared.Register("tenant", func() string { return "shared" }))
+}
+
+func TestFunctionRegistry_Register(t *testing.T) {
+	shared := NewFunctionRegistry()
+	require.NoError(t, shared.Register("double", func(v int) int { return v * 2 }))
+	r := shared.Extend()
+
+	tt := []struct {
+		name   string
+		fn     any
+		expect string
+	}{
+		{name: "not-valid", fn: func() int { return 0 }, expect: `function name "not-valid" is not a valid identifier`},
+		{name: "value", fn: 5, expect: `function "value": expected a function, got int`},
+		{name: "no_return", fn: func() {}, expect: `function "no_return": must return exactly one non-error value and optionally an error`},
+		{name: "concat", fn: func() int { return 0 }, expect: `function "concat" is already defined in the standard library`},
+		{name: "url_parse", fn: func() int { return 0 }, expect: `function "url_parse" is already defined in the standard library`},
+		{name: "testcomponents", fn: func() int { return 0 }, expect: `function "testcomponents" conflicts with component`},
+		{name: "config_dir", fn: func() int { return 0 }, expect: `function "config_dir" conflicts with the config directory`},
+		{name: "double", fn: func() int { return 0 }, expect: `function "double" is already registered`},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := r.Register(tc.name, tc.fn)
+			require.ErrorContains(t, err, tc.expect)
+		})
+	}
+}
+
+func TestController_FunctionSignatures(t *testing.T) {
+	shared := NewFunctionRegistry()
+	require.NoError(t, shared.Register("double", func(v int) int { return v * 2 }))
+
+	opts := testOptions(t)
+	opts.Functions = shared
+	opts.ConfigDir = t.TempDir()
+	ctrl := New(opts)
+	defer cleanUpController(ctrl)
+	require.NoError(t, ctrl.Functions().Register("join_all", func(sep string, parts ...string) (string, error) {
+		return strings.Join(parts, sep), nil
+	}))
+
+	sigs := map[string]FunctionSignature{}
+	var names []string
+	for _, sig := range ctrl.FunctionSignatures() {
+		sigs[sig.Name] = sig
+		names = append(names, sig.Name)
+	}
+	require.IsIncreasing(t, names)
+	require.NotContains(t, sigs, configDirIdentifier)
+
+	require.Equal(t, FunctionSignature{Name: "double", Params: []string{"number"}, Returns: "number"}, sigs["double"])
+	require.Equal(t, FunctionSignature{
+		Name:         "join_all",
+		Params:       []string{"string", "string"},
+		Variadic:     true,
+		Returns:      "string",
+		ReturnsError: true,
+	}, sigs["join_all"])
+
+	for name, expect := range map[string]string{
+		"url_parse":  "url_parse(string) object",
+		"contains":   "contains(array, any) bool",
+		"select":     "select(string, object, any...) any",
+		"dns_lookup": "dns_lookup(string, bool...) array",
+		"uuidv4":     "uuidv4() string",
+	} {
+		require.Contains(t, sigs, name)
+		require.Equal(t, expect, sigs[name].String())
+	}
+}
+
+func TestConfigDir(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+
+	opts := testOptions(t)
+	opts.ConfigDir = "/etc/agent"
+	ctrl := New(opts)
+	defer cleanUpController(ctrl)
+
+	f, err := ParseSource(t.Name(), []byte(`
+		testcomponents.passthrough "ca" {
+			input = config_dir + "/certs/ca.pem"
+		}
+	`))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	_, exports := getFields(t, ctrl.loader.Graph(), "testcomponents.passthrough.ca")
+	require.Equal(t, "/etc/agent/certs/ca.pem", exports.(testcomponents.PassthroughExports).Output)
+
+	// config_dir isn't defined when no directory is set.
+	ctrl = New(testOptions(t))
+	defer cleanUpController(ctrl)
+	require.ErrorContains(t, ctrl.LoadSource(f, nil), `component "config_dir" does not exist`)
+}
+
+func TestController_Resolver(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+
+	var dialed atomic.Bool
+	opts := testOptions(t)
+	opts.Resolver = &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			dialed.Store(true)
+			return nil, errors.New("no DNS server")
+		},
+	}
+	ctrl := New(opts)
+	defer cleanUpController(ctrl)
+
+	f, err := ParseSource(t.Name(), []byte(`
+		testcomponents.passthrough "addrs" {
+			input = join(dns_lookup("agent.example.invalid", false), ",")
+		}
+	`))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	_, exports := getFields(t, ctrl.loader.Graph(), "testcomponents.passthrough.addrs")
+	require.Equal(t, "", exports.(testcomponents.PassthroughExports).Output)
+	require.True(t, dialed.Load(), "dns_lookup didn't use the configured resolver")
+}
+
+func TestController_Resolver_Canceled(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+
+	opts := testOptions(t)
+	opts.Resolver = &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+	ctrl := New(opts)
+	defer cleanUpController(ctrl)
+
+	f, err := ParseSource(t.Name(), []byte(`
+		testcomponents.passthrough "addrs" {
+			input = join(dns_lookup("agent.example.invalid"), ",")
+		}
+	`))
+	require.NoError(t, err)
+
+	// Lookups made while loading are canceled with the load instead of
+	// running until they time out.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	require.Error(t, ctrl.LoadSourceContext(ctx, f, nil))
+	require.Less(t, time.Since(start), time.Second)
+}
+
+func TestController_UUIDv4_Stable(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+	ctrl := New(testOptions(t))
+	defer cleanUpController(ctrl)
+
+	load := func(prefix string) string {
+		f, err := ParseSource(t.Name(), []byte(`
+			testcomponents.passthrough "id" {
+				input = "`+prefix+`" + uuidv4()
+			}
+		`))
+		require.NoError(t, err)
+		require.NoError(t, ctrl.LoadSource(f, nil))
+
+		_, exports := getFields(t, ctrl.loader.Graph(), "testcomponents.passthrough.id")
+		return strings.TrimPrefix(exports.(testcomponents.PassthroughExports).Output, prefix)
+	}
+
+	// The UUID is kept when a changed config is loaded.
+	first := load("a-")
+	require.Len(t, first, 36)
+	require.Equal(t, first, load("b-"))
+}
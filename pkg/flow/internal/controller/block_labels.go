@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/grafana/river/ast"
+	"github.com/grafana/river/diag"
+)
+
+// labelArity is whether blocks of a kind must have a label.
+type labelArity int
+
+const (
+	labelOptional  labelArity = iota // Blocks may have a label.
+	labelRequired                    // Blocks must have a label.
+	labelForbidden                   // Blocks must not have a label.
+)
+
+// configBlockLabels holds the label arity of config blocks, by name.
+// Components always require a label, which names their instance.
+var configBlockLabels = map[string]labelArity{
+	argumentBlockID: labelRequired,
+	exportBlockID:   labelRequired,
+	loggingBlockID:  labelForbidden,
+	tracingBlockID:  labelForbidden,
+	testBlockID:     labelOptional,
+}
+
+// validateBlockLabel returns a diagnostic if the label of block doesn't
+// match arity, such as "prometheus.scrape requires a label".
+func validateBlockLabel(block *ast.BlockStmt, arity labelArity) diag.Diagnostics {
+	var (
+		diags diag.Diagnostics
+		name  = block.GetBlockName()
+	)
+
+	switch {
+	case arity == labelRequired && block.Label == "":
+		diags.Add(diag.Diagnostic{
+			Severity: diag.SeverityLevelError,
+			Message:  fmt.Sprintf("%s requires a label", name),
+			StartPos: block.NamePos.Position(),
+			EndPos:   block.NamePos.Add(len(name) - 1).Position(),
+		})
+	case arity == labelForbidden && block.Label != "":
+		diags.Add(diag.Diagnostic{
+			Severity: diag.SeverityLevelError,
+			Message:  fmt.Sprintf("%s doesn't support a label, got %q", name, block.Label),
+			StartPos: block.LabelPos.Position(),
+			EndPos:   block.LabelPos.Add(len(strconv.Quote(block.Label)) - 1).Position(),
+		})
+	}
+	return diags
+}
@@ -22,3 +22,14 @@ type BlockNode interface {
 	// decoding to arguments fails.
 	Evaluate(scope *vm.Scope) error
 }
+
+// stagedNode is a BlockNode whose evaluation updates something outside of
+// the graph, such as a service or the global logger. Apply stages the
+// evaluation of stagedNodes before it commits to a new graph.
+type stagedNode interface {
+	BlockNode
+
+	// stage evaluates the block of the node against scope without applying
+	// the result.
+	stage(scope *vm.Scope) error
+}
@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CircuitBreakerConfig configures the circuit breaker of each component. The
+// circuit breaker is disabled when MaxFailures is zero.
+type CircuitBreakerConfig struct {
+	MaxFailures int           // Failures within Window which open the breaker.
+	Window      time.Duration // Period failures are counted over.
+}
+
+// circuitBreaker quarantines a component which keeps failing. Failures are
+// recorded with fail, and the breaker opens once MaxFailures of them happened
+// within Window. An open breaker stays open until reset is called.
+type circuitBreaker struct {
+	config CircuitBreakerConfig
+	now    func() time.Time
+
+	mut      sync.Mutex
+	failures []time.Time   // Times of failures within the window, oldest first.
+	open     bool          // Whether the breaker is open.
+	reason   string        // Why the breaker opened.
+	closed   chan struct{} // Closed by reset to wake up waitClosed.
+}
+
+// newCircuitBreaker returns a closed circuitBreaker, or nil if config
+// disables the breaker.
+func newCircuitBreaker(config CircuitBreakerConfig) *circuitBreaker {
+	if config.MaxFailures <= 0 {
+		return nil
+	}
+	return &circuitBreaker{
+		config: config,
+		now:    time.Now,
+		closed: make(chan struct{}),
+	}
+}
+
+// fail records a failure caused by err and returns whether the breaker is
+// now open, along with the reason it opened.
+func (b *circuitBreaker) fail(err error) (bool, string) {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	now := b.now()
+	b.failures = append(b.failures, now)
+
+	// Forget failures which happened before the window.
+	var keep int
+	for keep < len(b.failures) && now.Sub(b.failures[keep]) >= b.config.Window {
+		keep++
+	}
+	b.failures = b.failures[keep:]
+
+	if len(b.failures) >= b.config.MaxFailures {
+		b.open = true
+		b.reason = fmt.Sprintf("circuit breaker opened after %d failures within %s, last error: %s", len(b.failures), b.config.Window, err)
+		b.closed = make(chan struct{})
+	}
+	return b.open, b.reason
+}
+
+// reset closes the breaker and forgets previous failures. It returns false if
+// the breaker wasn't open.
+func (b *circuitBreaker) reset() bool {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	if !b.open {
+		return false
+	}
+	b.open, b.reason, b.failures = false, "", nil
+	close(b.closed)
+	return true
+}
+
+// state returns whether the breaker is open and the reason it opened.
+func (b *circuitBreaker) state() (open bool, reason string) {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	return b.open, b.reason
+}
+
+// waitClosed blocks while the breaker is open. It returns false if ctx is
+// canceled first.
+func (b *circuitBreaker) waitClosed(ctx context.Context) bool {
+	b.mut.Lock()
+	open, closed := b.open, b.closed
+	b.mut.Unlock()
+	if !open {
+		return true
+	}
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-closed:
+		return true
+	}
+}
@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	require.Nil(t, newCircuitBreaker(CircuitBreakerConfig{}))
+
+	b := newCircuitBreaker(CircuitBreakerConfig{MaxFailures: 2, Window: time.Minute})
+	now := time.Now()
+	b.now = func() time.Time { return now }
+
+	open, _ := b.fail(fmt.Errorf("first"))
+	require.False(t, open)
+
+	// Failures before the window are forgotten.
+	now = now.Add(time.Minute)
+	open, _ = b.fail(fmt.Errorf("second"))
+	require.False(t, open)
+
+	now = now.Add(time.Second)
+	open, reason := b.fail(fmt.Errorf("third"))
+	require.True(t, open)
+	require.Equal(t, "circuit breaker opened after 2 failures within 1m0s, last error: third", reason)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.False(t, b.waitClosed(ctx))
+
+	require.True(t, b.reset())
+	require.False(t, b.reset())
+	require.True(t, b.waitClosed(ctx))
+
+	// Failures before the reset are forgotten.
+	open, _ = b.fail(fmt.Errorf("fourth"))
+	require.False(t, open)
+}
@@ -0,0 +1,345 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/grafana/river/ast"
+	"github.com/grafana/river/diag"
+	"github.com/grafana/river/vm"
+)
+
+// Names used by dynamic blocks, which generate repeated nested blocks from a
+// collection:
+//
+//	dynamic "rule" {
+//	  for_each = local.rules
+//
+//	  content {
+//	    source_labels = rule.value.source_labels
+//	    target_label  = rule.value.target_label
+//	  }
+//	}
+//
+// The label of a dynamic block is the name of the blocks it generates. A
+// block is generated from the content block for every element of for_each.
+// Inside content, the label refers to the element being generated:
+// label.key is the index of the element in an array or its key in an object,
+// and label.value is the element.
+const (
+	dynamicBlockName   = "dynamic"
+	dynamicForEachAttr = "for_each"
+	dynamicContentName = "content"
+)
+
+// isDynamicBlock returns whether stmt is a dynamic block.
+func isDynamicBlock(stmt ast.Stmt) bool {
+	block, ok := stmt.(*ast.BlockStmt)
+	return ok && len(block.Name) == 1 && block.Name[0] == dynamicBlockName
+}
+
+// dynamicIterator returns the identifier which refers to the element being
+// generated inside the content of the dynamic block b.
+func dynamicIterator(b *ast.BlockStmt) string { return b.Label }
+
+// hasDynamicBlocks returns whether body contains dynamic blocks at any depth.
+func hasDynamicBlocks(body ast.Body) bool {
+	for _, stmt := range body {
+		if isDynamicBlock(stmt) {
+			return true
+		}
+		if block, ok := stmt.(*ast.BlockStmt); ok && hasDynamicBlocks(block.Body) {
+			return true
+		}
+	}
+	return false
+}
+
+// dynamicBlock is a parsed dynamic block.
+type dynamicBlock struct {
+	block    *ast.BlockStmt
+	name     []string // Name of the generated blocks.
+	iterator string   // Identifier of the element in content.
+	forEach  ast.Expr // Collection to generate blocks from.
+	content  *ast.BlockStmt
+}
+
+// parseDynamicBlock validates the dynamic block b.
+func parseDynamicBlock(b *ast.BlockStmt) (*dynamicBlock, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	errorf := func(start, end ast.Node, format string, args ...any) {
+		diags.Add(diag.Diagnostic{
+			Severity: diag.SeverityLevelError,
+			Message:  fmt.Sprintf(format, args...),
+			StartPos: ast.StartPos(start).Position(),
+			EndPos:   ast.EndPos(end).Position(),
+		})
+	}
+
+	// The parser ensures labels are valid identifiers.
+	if b.Label == "" {
+		errorf(b, b, "dynamic block must be labeled with the name of the blocks to generate")
+		return nil, diags
+	}
+
+	d := &dynamicBlock{block: b, name: []string{b.Label}, iterator: dynamicIterator(b)}
+	for _, stmt := range b.Body {
+		switch stmt := stmt.(type) {
+		case *ast.AttributeStmt:
+			if stmt.Name.Name != dynamicForEachAttr {
+				errorf(stmt, stmt, "unrecognized attribute name %q in dynamic block", stmt.Name.Name)
+				continue
+			}
+			d.forEach = stmt.Value
+		case *ast.BlockStmt:
+			if strings.Join(stmt.Name, ".") != dynamicContentName || stmt.Label != "" {
+				errorf(stmt, stmt, "unrecognized block name %q in dynamic block", strings.Join(stmt.Name, "."))
+				continue
+			}
+			if d.content != nil {
+				errorf(stmt, stmt, "dynamic block must have exactly one %s block", dynamicContentName)
+				continue
+			}
+			d.content = stmt
+		}
+	}
+	if d.forEach == nil {
+		errorf(b, b, "missing required attribute %q in dynamic block", dynamicForEachAttr)
+	}
+	if d.content == nil {
+		errorf(b, b, "missing required block %q in dynamic block", dynamicContentName)
+	}
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	return d, nil
+}
+
+// expandDynamicBlocks returns a copy of body where every dynamic block is
+// replaced with the blocks it generates. Dynamic blocks are expanded at any
+// depth, including inside the content of other dynamic blocks.
+//
+// Expressions inside generated blocks refer to their element through a
+// variable of the returned scope, which extends scope.
+func expandDynamicBlocks(body ast.Body, scope *vm.Scope) (ast.Body, *vm.Scope, error) {
+	e := dynamicExpander{vars: make(map[string]any)}
+	e.scope = &vm.Scope{Parent: scope, Variables: e.vars}
+
+	expanded, err := e.expandBody(body, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return expanded, e.scope, nil
+}
+
+type dynamicExpander struct {
+	scope *vm.Scope
+	vars  map[string]any // Variables of scope, one per generated element.
+}
+
+// expandBody expands the dynamic blocks of body. renames maps the iterators
+// of enclosing dynamic blocks to the variables of the elements being
+// generated.
+func (e *dynamicExpander) expandBody(body ast.Body, renames map[string]string) (ast.Body, error) {
+	expanded := make(ast.Body, 0, len(body))
+	for _, stmt := range body {
+		switch stmt := stmt.(type) {
+		case *ast.AttributeStmt:
+			copied := *stmt
+			copied.Value = renameIdentifiers(stmt.Value, renames)
+			expanded = append(expanded, &copied)
+
+		case *ast.BlockStmt:
+			if isDynamicBlock(stmt) {
+				generated, err := e.expandDynamic(stmt, renames)
+				if err != nil {
+					return nil, err
+				}
+				expanded = append(expanded, generated...)
+				continue
+			}
+
+			inner, err := e.expandBody(stmt.Body, renames)
+			if err != nil {
+				return nil, err
+			}
+			copied := *stmt
+			copied.Body = inner
+			expanded = append(expanded, &copied)
+
+		default:
+			expanded = append(expanded, stmt)
+		}
+	}
+	return expanded, nil
+}
+
+// expandDynamic returns the blocks generated by the dynamic block b.
+func (e *dynamicExpander) expandDynamic(b *ast.BlockStmt, renames map[string]string) ([]ast.Stmt, error) {
+	d, diags := parseDynamicBlock(b)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	var collection any
+	if err := vm.New(renameIdentifiers(d.forEach, renames)).Evaluate(e.scope, &collection); err != nil {
+		return nil, err
+	}
+
+	type element struct {
+		key   any
+		value any
+	}
+	var elements []element
+	switch collection := collection.(type) {
+	case nil:
+		// A null collection generates no blocks.
+	case []any:
+		for i, value := range collection {
+			elements = append(elements, element{key: i, value: value})
+		}
+	case map[string]any:
+		keys := make([]string, 0, len(collection))
+		for key := range collection {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			elements = append(elements, element{key: key, value: collection[key]})
+		}
+	default:
+		return nil, diag.Diagnostic{
+			Severity: diag.SeverityLevelError,
+			Message:  fmt.Sprintf("%s of dynamic block must be an array or an object, got %T", dynamicForEachAttr, collection),
+			StartPos: ast.StartPos(d.forEach).Position(),
+			EndPos:   ast.EndPos(d.forEach).Position(),
+		}
+	}
+
+	generated := make([]ast.Stmt, 0, len(elements))
+	for _, elem := range elements {
+		variable := e.newVariable(d.iterator, elem.key)
+		e.vars[variable] = map[string]any{"key": elem.key, "value": elem.value}
+
+		elemRenames := make(map[string]string, len(renames)+1)
+		for from, to := range renames {
+			elemRenames[from] = to
+		}
+		elemRenames[d.iterator] = variable
+
+		body, err := e.expandBody(d.content.Body, elemRenames)
+		if err != nil {
+			return nil, err
+		}
+		generated = append(generated, &ast.BlockStmt{
+			Name:      d.name,
+			NamePos:   d.block.NamePos,
+			Body:      body,
+			LCurlyPos: d.content.LCurlyPos,
+			RCurlyPos: d.content.RCurlyPos,
+		})
+	}
+	return generated, nil
+}
+
+// newVariable returns an unused variable name for the element with the given
+// key of a dynamic block with the given iterator. Variable names aren't valid
+// identifiers, so they can never conflict with names written in configs.
+func (e *dynamicExpander) newVariable(iterator string, key any) string {
+	name := fmt.Sprintf("%s[%v]", iterator, key)
+	for i := 2; ; i++ {
+		if _, exists := e.vars[name]; !exists {
+			return name
+		}
+		name = fmt.Sprintf("%s[%v]#%d", iterator, key, i)
+	}
+}
+
+// renameIdentifiers returns a copy of expr where identifiers named after a
+// key of renames are renamed to its value.
+func renameIdentifiers(expr ast.Expr, renames map[string]string) ast.Expr {
+	if len(renames) == 0 {
+		return expr
+	}
+	return mapExpr(expr, func(expr ast.Expr) ast.Expr {
+		ident, ok := expr.(*ast.IdentifierExpr)
+		if !ok {
+			return nil
+		}
+		to, ok := renames[ident.Ident.Name]
+		if !ok {
+			return ident
+		}
+		return &ast.IdentifierExpr{Ident: &ast.Ident{Name: to, NamePos: ident.Ident.NamePos}}
+	})
+}
+
+// mapExpr returns a copy of expr where every expression for which replace
+// returns a non-nil expression is replaced by it. Expressions are passed to
+// replace before their children, and the children of replaced expressions
+// aren't walked. Literals which replace doesn't replace are shared with expr.
+func mapExpr(expr ast.Expr, replace func(ast.Expr) ast.Expr) ast.Expr {
+	if replaced := replace(expr); replaced != nil {
+		return replaced
+	}
+
+	switch expr := expr.(type) {
+	case *ast.ArrayExpr:
+		copied := *expr
+		copied.Elements = make([]ast.Expr, len(expr.Elements))
+		for i, elem := range expr.Elements {
+			copied.Elements[i] = mapExpr(elem, replace)
+		}
+		return &copied
+
+	case *ast.ObjectExpr:
+		copied := *expr
+		copied.Fields = make([]*ast.ObjectField, len(expr.Fields))
+		for i, field := range expr.Fields {
+			copiedField := *field
+			copiedField.Value = mapExpr(field.Value, replace)
+			copied.Fields[i] = &copiedField
+		}
+		return &copied
+
+	case *ast.AccessExpr:
+		copied := *expr
+		copied.Value = mapExpr(expr.Value, replace)
+		return &copied
+
+	case *ast.IndexExpr:
+		copied := *expr
+		copied.Value = mapExpr(expr.Value, replace)
+		copied.Index = mapExpr(expr.Index, replace)
+		return &copied
+
+	case *ast.CallExpr:
+		copied := *expr
+		copied.Value = mapExpr(expr.Value, replace)
+		copied.Args = make([]ast.Expr, len(expr.Args))
+		for i, arg := range expr.Args {
+			copied.Args[i] = mapExpr(arg, replace)
+		}
+		return &copied
+
+	case *ast.UnaryExpr:
+		copied := *expr
+		copied.Value = mapExpr(expr.Value, replace)
+		return &copied
+
+	case *ast.BinaryExpr:
+		copied := *expr
+		copied.Left = mapExpr(expr.Left, replace)
+		copied.Right = mapExpr(expr.Right, replace)
+		return &copied
+
+	case *ast.ParenExpr:
+		copied := *expr
+		copied.Inner = mapExpr(expr.Inner, replace)
+		return &copied
+
+	default:
+		return expr
+	}
+}
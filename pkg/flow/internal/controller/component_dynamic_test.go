@@ -0,0 +1,196 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/grafana/river/parser"
+	"github.com/grafana/river/vm"
+	"github.com/stretchr/testify/require"
+)
+
+type dynamicTestArgs struct {
+	Name  string             `river:"name,attr,optional"`
+	Rules []dynamicTestRule  `river:"rule,block,optional"`
+	Outer []dynamicTestOuter `river:"outer,block,optional"`
+}
+
+type dynamicTestRule struct {
+	Label string `river:"label,attr"`
+	Index int    `river:"index,attr,optional"`
+}
+
+type dynamicTestOuter struct {
+	Name  string            `river:"name,attr"`
+	Rules []dynamicTestRule `river:"rule,block,optional"`
+}
+
+func TestExpandDynamicBlocks(t *testing.T) {
+	scope := &vm.Scope{Variables: map[string]any{
+		"none": []any{},
+		"one":  []any{"a"},
+		"many": []any{"a", "b", "c"},
+		"byName": map[string]any{
+			"b": []any{"b1", "b2"},
+			"a": []any{"a1"},
+		},
+	}}
+
+	tt := []struct {
+		name   string
+		input  string
+		expect dynamicTestArgs
+	}{
+		{
+			name: "no blocks",
+			input: `
+				rule { label = "static" }
+				dynamic "rule" {
+					for_each = none
+					content { label = rule.value }
+				}
+			`,
+			expect: dynamicTestArgs{Rules: []dynamicTestRule{{Label: "static"}}},
+		},
+		{
+			name: "one block",
+			input: `
+				dynamic "rule" {
+					for_each = one
+					content { label = rule.value }
+				}
+			`,
+			expect: dynamicTestArgs{Rules: []dynamicTestRule{{Label: "a"}}},
+		},
+		{
+			name: "many blocks",
+			input: `
+				rule { label = "first" }
+				dynamic "rule" {
+					for_each = many
+					content {
+						label = rule.value
+						index = rule.key
+					}
+				}
+				rule { label = "last" }
+			`,
+			expect: dynamicTestArgs{Rules: []dynamicTestRule{
+				{Label: "first"},
+				{Label: "a", Index: 0},
+				{Label: "b", Index: 1},
+				{Label: "c", Index: 2},
+				{Label: "last"},
+			}},
+		},
+		{
+			name: "sibling dynamic blocks",
+			input: `
+				dynamic "rule" {
+					for_each = one
+					content { label = rule.value }
+				}
+				dynamic "rule" {
+					for_each = many
+					content { label = rule.value + "2" }
+				}
+			`,
+			expect: dynamicTestArgs{Rules: []dynamicTestRule{
+				{Label: "a"}, {Label: "a2"}, {Label: "b2"}, {Label: "c2"},
+			}},
+		},
+		{
+			name: "nested dynamic blocks over an object",
+			input: `
+				name = "unchanged"
+				dynamic "outer" {
+					for_each = byName
+					content {
+						name = outer.key
+						dynamic "rule" {
+							for_each = outer.value
+							content { label = outer.key + ":" + rule.value }
+						}
+					}
+				}
+			`,
+			expect: dynamicTestArgs{
+				Name: "unchanged",
+				Outer: []dynamicTestOuter{
+					{Name: "a", Rules: []dynamicTestRule{{Label: "a:a1"}}},
+					{Name: "b", Rules: []dynamicTestRule{{Label: "b:b1"}, {Label: "b:b2"}}},
+				},
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			file, err := parser.ParseFile(t.Name(), []byte(tc.input))
+			require.NoError(t, err)
+			require.True(t, hasDynamicBlocks(file.Body))
+
+			body, dynamicScope, err := expandDynamicBlocks(file.Body, scope)
+			require.NoError(t, err)
+
+			var actual dynamicTestArgs
+			require.NoError(t, vm.New(body).Evaluate(dynamicScope, &actual))
+			require.Equal(t, tc.expect, actual)
+		})
+	}
+}
+
+func TestExpandDynamicBlocks_Errors(t *testing.T) {
+	scope := &vm.Scope{Variables: map[string]any{"list": []any{"a"}}}
+
+	tt := []struct {
+		name   string
+		input  string
+		expect string
+	}{
+		{
+			name:   "missing for_each",
+			input:  `dynamic "rule" { content {} }`,
+			expect: `missing required attribute "for_each" in dynamic block`,
+		},
+		{
+			name:   "missing content",
+			input:  `dynamic "rule" { for_each = list }`,
+			expect: `missing required block "content" in dynamic block`,
+		},
+		{
+			name: "multiple content blocks",
+			input: `dynamic "rule" {
+				for_each = list
+				content {}
+				content {}
+			}`,
+			expect: "dynamic block must have exactly one content block",
+		},
+		{
+			name: "missing label",
+			input: `dynamic {
+				for_each = list
+				content {}
+			}`,
+			expect: "dynamic block must be labeled with the name of the blocks to generate",
+		},
+		{
+			name: "for_each is not a collection",
+			input: `dynamic "rule" {
+				for_each = 5
+				content {}
+			}`,
+			expect: "for_each of dynamic block must be an array or an object",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			file, err := parser.ParseFile(t.Name(), []byte(tc.input))
+			require.NoError(t, err)
+
+			_, _, err = expandDynamicBlocks(file.Body, scope)
+			require.ErrorContains(t, err, tc.expect)
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/grafana/agent/pkg/flow/internal/stdlib"
+	"github.com/grafana/river/ast"
+	"github.com/grafana/river/diag"
+	"github.com/grafana/river/vm"
+)
+
+// enabledAttr is the name of the meta-argument which controls whether a
+// component block is loaded.
+const enabledAttr = "enabled"
+
+// evaluateEnabled evaluates the enabled meta-argument of a component block.
+// Blocks without an enabled attribute are always enabled.
+//
+// Disabled blocks aren't added to the graph, so they contribute no nodes or
+// edges. If block is enabled, evaluateEnabled returns a copy of block with
+// the enabled attribute removed.
+func evaluateEnabled(block *ast.BlockStmt, functions *vm.Scope) (enabled bool, stripped *ast.BlockStmt, diags diag.Diagnostics) {
+	enabled = true
+	_, stripped, diags = evaluateMetaArgument(block, enabledAttr, functions, &enabled)
+	if stripped == nil || !enabled {
+		return false, nil, diags
+	}
+	return true, stripped, diags
+}
+
+// evaluateMetaArgument evaluates the top-level attribute name of a component
+// block into target, which must be a pointer. Meta-arguments, such as
+// enabled, are evaluated before the graph is built, so they may only use
+// constants and the deterministic functions in scope, such as env.
+//
+// evaluateMetaArgument returns the evaluated attribute and a copy of block
+// with the attribute removed, so the component never sees it as an argument.
+// If block has no such attribute, target is left unchanged and block is
+// returned as-is with a nil attr. stripped is nil if evaluating failed.
+func evaluateMetaArgument(block *ast.BlockStmt, name string, functions *vm.Scope, target any) (attr *ast.AttributeStmt, stripped *ast.BlockStmt, diags diag.Diagnostics) {
+	body := make(ast.Body, 0, len(block.Body))
+	for _, stmt := range block.Body {
+		if a, ok := stmt.(*ast.AttributeStmt); ok && a.Name.Name == name {
+			attr = a
+			continue
+		}
+		body = append(body, stmt)
+	}
+	if attr == nil {
+		return nil, block, nil
+	}
+
+	// The graph must be the same every time the same config is loaded, so
+	// meta-arguments can't depend on functions returning random values.
+	if diags = nondeterministicCalls(attr.Value, name); diags.HasErrors() {
+		return attr, nil, diags
+	}
+
+	// The functions scope never resolves components.
+	if err := vm.New(attr.Value).Evaluate(functions, target); err != nil {
+		var evalDiags diag.Diagnostics
+		if errors.As(err, &evalDiags) {
+			diags = append(diags, evalDiags...)
+		} else {
+			diags.Add(diag.Diagnostic{
+				Severity: diag.SeverityLevelError,
+				Message:  fmt.Sprintf("Failed to evaluate %q: %s", name, err),
+				StartPos: ast.StartPos(attr).Position(),
+				EndPos:   ast.EndPos(attr).Position(),
+			})
+		}
+		return attr, nil, diags
+	}
+
+	copied := *block
+	copied.Body = body
+	return attr, &copied, nil
+}
+
+// nondeterministicCalls returns an error diagnostic for every call in expr,
+// the value of the meta-argument attr, to a function of the standard library
+// which doesn't always return the same value, such as uuidv4, whose value
+// changes every time the controller starts.
+func nondeterministicCalls(expr ast.Expr, attr string) diag.Diagnostics {
+	var diags diag.Diagnostics
+	ast.Walk(nondeterministicVisitor{attr: attr, diags: &diags}, expr)
+	return diags
+}
+
+type nondeterministicVisitor struct {
+	attr  string
+	diags *diag.Diagnostics
+}
+
+func (v nondeterministicVisitor) Visit(node ast.Node) ast.Visitor {
+	call, ok := node.(*ast.CallExpr)
+	if !ok {
+		return v
+	}
+	if ident, ok := call.Value.(*ast.IdentifierExpr); ok {
+		if _, nondeterministic := stdlib.Nondeterministic[ident.Ident.Name]; nondeterministic {
+			v.diags.Add(diag.Diagnostic{
+				Severity: diag.SeverityLevelError,
+				Message:  fmt.Sprintf("%s can't be called from %q because it doesn't always return the same value", ident.Ident.Name, v.attr),
+				StartPos: ast.StartPos(call).Position(),
+				EndPos:   ast.EndPos(call).Position(),
+			})
+		}
+	}
+	return v
+}
@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/grafana/agent/pkg/flow/internal/dag"
+	"github.com/grafana/river/ast"
+	"github.com/grafana/river/diag"
+	"github.com/grafana/river/vm"
+)
+
+// expectTypesAttr is the name of the meta-argument which asserts the types
+// of the components referenced by the arguments of a component:
+//
+//	prometheus.scrape "default" {
+//	  targets    = discovery.kubernetes.pods.targets
+//	  forward_to = [prometheus.remote_write.default.receiver]
+//
+//	  expect_types = {
+//	    targets    = ["discovery.*"],
+//	    forward_to = ["prometheus.remote_write"],
+//	  }
+//	}
+//
+// Keys are the dot-separated paths of arguments, such as "client.url" for
+// the url attribute of a client block; a path also applies to the arguments
+// nested in it. Values list the names of the components the argument may
+// reference, or prefixes followed by a wildcard, in the same format as
+// ComponentPolicy.
+const expectTypesAttr = "expect_types"
+
+// evaluateExpectTypes evaluates the expect_types meta-argument of a component
+// block. Blocks without an expect_types attribute don't assert the types of
+// their references.
+func evaluateExpectTypes(block *ast.BlockStmt, functions *vm.Scope) (expect map[string][]string, stripped *ast.BlockStmt, diags diag.Diagnostics) {
+	_, stripped, diags = evaluateMetaArgument(block, expectTypesAttr, functions, &expect)
+	if stripped == nil {
+		return nil, nil, diags
+	}
+	return expect, stripped, diags
+}
+
+// expectedTypes returns the types expected by expect for the argument at
+// path, using the entry of the longest path which is path or one of its
+// parents. ok is false if no entry applies to path.
+func expectedTypes(expect map[string][]string, path string) (types []string, ok bool) {
+	for {
+		if types, ok := expect[path]; ok {
+			return types, true
+		}
+		idx := strings.LastIndexByte(path, '.')
+		if idx < 0 {
+			return nil, false
+		}
+		path = path[:idx]
+	}
+}
+
+// validateExpectedTypes returns a diagnostic for every reference made by cn
+// to a block whose type isn't expected by the expect_types meta-argument of
+// cn. g must have the edges of cn wired.
+func validateExpectedTypes(cn *ComponentNode, g *dag.Graph) diag.Diagnostics {
+	expect := cn.ExpectedTypes()
+	if len(expect) == 0 {
+		return nil
+	}
+
+	var diags diag.Diagnostics
+	for _, dep := range g.Dependencies(cn) {
+		typ := blockType(dep)
+		for _, src := range EdgeSources(g, dag.Edge{From: cn, To: dep}) {
+			types, ok := expectedTypes(expect, src.Attribute)
+			if !ok || (ComponentPolicy{Allowed: types}).Allows(typ) {
+				continue
+			}
+			diags.Add(diag.Diagnostic{
+				Severity: diag.SeverityLevelError,
+				Message: fmt.Sprintf("%s of %s references %s, which is a %s, but %s only allows %s",
+					src.Attribute, cn.NodeID(), dep.NodeID(), typ, expectTypesAttr, strings.Join(types, ", ")),
+				StartPos: src.Pos,
+				EndPos:   src.Pos,
+			})
+		}
+	}
+	sort.SliceStable(diags, func(i, j int) bool {
+		return diags[i].StartPos.Offset < diags[j].StartPos.Offset
+	})
+	return diags
+}
+
+// blockType returns the type of the block held by n, such as
+// "prometheus.remote_write" for components, "argument" for argument blocks,
+// or the name of services.
+func blockType(n dag.Node) string {
+	switch n := n.(type) {
+	case *ComponentNode:
+		return n.ComponentName()
+	case BlockNode:
+		if b := n.Block(); b != nil {
+			return b.GetBlockName()
+		}
+	}
+	return n.NodeID()
+}
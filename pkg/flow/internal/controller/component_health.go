@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/grafana/agent/component"
+)
+
+// healthAttr is the name of the pseudo-attribute which exposes the health of
+// a component to expressions referencing it.
+const healthAttr = "health"
+
+// healthExportsTypes caches the types built by withHealth, keyed by the
+// exports type they wrap.
+var healthExportsTypes sync.Map // reflect.Type -> reflect.Type
+
+// withHealth returns exports with an extra health attribute set to h.
+//
+// Struct exports are wrapped in a new struct which squashes the original
+// exports, so the exports keep their River encoding. Other exports are copied
+// into a map. Exports which already define a health attribute of their own
+// are returned unchanged.
+func withHealth(exports any, h component.Health) any {
+	switch v := exports.(type) {
+	case nil:
+		return map[string]any{healthAttr: h}
+	case map[string]any:
+		if _, ok := v[healthAttr]; ok {
+			return exports
+		}
+		copied := make(map[string]any, len(v)+1)
+		for k, val := range v {
+			copied[k] = val
+		}
+		copied[healthAttr] = h
+		return copied
+	}
+
+	rv := reflect.ValueOf(exports)
+	if rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return map[string]any{healthAttr: h}
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct || hasRiverField(rv.Type(), healthAttr) {
+		return exports
+	}
+
+	wrapped := reflect.New(healthExportsType(rv.Type())).Elem()
+	wrapped.Field(0).Set(rv)
+	wrapped.Field(1).Set(reflect.ValueOf(h))
+	return wrapped.Interface()
+}
+
+// healthExportsType returns a struct type which squashes the exports type ty
+// and adds a health attribute.
+func healthExportsType(ty reflect.Type) reflect.Type {
+	if cached, ok := healthExportsTypes.Load(ty); ok {
+		return cached.(reflect.Type)
+	}
+
+	wrapped := reflect.StructOf([]reflect.StructField{
+		{Name: "Exports", Type: ty, Tag: `river:",squash"`},
+		{Name: "Health", Type: reflect.TypeOf(component.Health{}), Tag: `river:"health,attr"`},
+	})
+	actual, _ := healthExportsTypes.LoadOrStore(ty, wrapped)
+	return actual.(reflect.Type)
+}
+
+// hasRiverField returns whether the struct type ty has a top-level River
+// attribute or block with the given name.
+func hasRiverField(ty reflect.Type, name string) bool {
+	for i := 0; i < ty.NumField(); i++ {
+		field, _, _ := strings.Cut(ty.Field(i).Tag.Get("river"), ",")
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
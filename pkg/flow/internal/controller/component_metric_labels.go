@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/grafana/river/ast"
+	"github.com/grafana/river/diag"
+	"github.com/grafana/river/vm"
+	"github.com/prometheus/common/model"
+)
+
+// metricLabelsAttr is the name of the meta-argument which attaches labels to
+// the per-component metrics exposed by the controller.
+const metricLabelsAttr = "metric_labels"
+
+// reservedMetricLabels are labels set by the controller on per-component
+// metrics, which metric_labels may not override.
+var reservedMetricLabels = map[string]struct{}{
+	"controller_id":  {},
+	"component_id":   {},
+	"component_name": {},
+	"health_type":    {},
+}
+
+// evaluateMetricLabels evaluates the metric_labels meta-argument of a
+// component block. Blocks without a metric_labels attribute have no metric
+// labels. Label names must be valid Prometheus label names, and can't be
+// one of the labels set by the controller.
+func evaluateMetricLabels(block *ast.BlockStmt, functions *vm.Scope) (labels map[string]string, stripped *ast.BlockStmt, diags diag.Diagnostics) {
+	attr, stripped, diags := evaluateMetaArgument(block, metricLabelsAttr, functions, &labels)
+	if stripped == nil {
+		return nil, nil, diags
+	}
+
+	for _, name := range sortedLabelNames(labels) {
+		var msg string
+		if _, reserved := reservedMetricLabels[name]; reserved {
+			msg = fmt.Sprintf("metric label %q is reserved and set by the controller", name)
+		} else if !model.LabelName(name).IsValid() || strings.HasPrefix(name, model.ReservedLabelPrefix) {
+			msg = fmt.Sprintf("%q is not a valid metric label name", name)
+		}
+		if msg != "" {
+			diags.Add(diag.Diagnostic{
+				Severity: diag.SeverityLevelError,
+				Message:  msg,
+				StartPos: ast.StartPos(attr).Position(),
+				EndPos:   ast.EndPos(attr).Position(),
+			})
+		}
+	}
+	if diags.HasErrors() {
+		return nil, nil, diags
+	}
+	return labels, stripped, diags
+}
+
+// sortedLabelNames returns the names of labels in sorted order.
+func sortedLabelNames(labels map[string]string) []string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
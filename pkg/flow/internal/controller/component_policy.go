@@ -0,0 +1,40 @@
+package controller
+
+import "strings"
+
+// ComponentPolicy restricts which components may be used in a config.
+//
+// Entries in Allowed and Denied are either component names, such as
+// "prometheus.scrape", or a prefix followed by a wildcard, such as
+// "prometheus.*". A wildcard matches any number of name identifiers, so
+// "prometheus.*" matches both "prometheus.scrape" and
+// "prometheus.exporter.unix". The entry "*" matches every component.
+type ComponentPolicy struct {
+	// Allowed lists the components which may be used. If empty, all components
+	// are allowed unless they are denied.
+	Allowed []string
+
+	// Denied lists the components which may not be used. Denied takes
+	// precedence over Allowed.
+	Denied []string
+}
+
+// Allows reports whether the component with the given name may be used.
+func (p ComponentPolicy) Allows(name string) bool {
+	if matchesAnyComponent(p.Denied, name) {
+		return false
+	}
+	return len(p.Allowed) == 0 || matchesAnyComponent(p.Allowed, name)
+}
+
+func matchesAnyComponent(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if pattern == "*" || pattern == name {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestComponentPolicy(t *testing.T) {
+	tt := []struct {
+		name   string
+		policy ComponentPolicy
+		allow  []string
+		deny   []string
+	}{
+		{
+			name:   "empty policy allows everything",
+			policy: ComponentPolicy{},
+			allow:  []string{"prometheus.scrape", "local.file"},
+		},
+		{
+			name:   "exact allowlist",
+			policy: ComponentPolicy{Allowed: []string{"prometheus.scrape"}},
+			allow:  []string{"prometheus.scrape"},
+			deny:   []string{"prometheus.scraper", "prometheus.remote_write"},
+		},
+		{
+			name:   "wildcard allowlist",
+			policy: ComponentPolicy{Allowed: []string{"prometheus.*"}},
+			allow:  []string{"prometheus.scrape", "prometheus.exporter.unix"},
+			deny:   []string{"loki.write", "prometheus"},
+		},
+		{
+			name:   "denylist takes precedence",
+			policy: ComponentPolicy{Allowed: []string{"*"}, Denied: []string{"prometheus.exporter.*", "local.file"}},
+			allow:  []string{"prometheus.scrape", "local.file_match"},
+			deny:   []string{"prometheus.exporter.unix", "local.file"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, name := range tc.allow {
+				require.True(t, tc.policy.Allows(name), "expected %s to be allowed", name)
+			}
+			for _, name := range tc.deny {
+				require.False(t, tc.policy.Allows(name), "expected %s to be denied", name)
+			}
+		})
+	}
+}
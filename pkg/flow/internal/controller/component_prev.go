@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"strings"
+
+	"github.com/grafana/river/ast"
+	"github.com/grafana/river/vm"
+)
+
+// prevFunctionName is the name of the function which returns the value a
+// reference had when the component calling it was last evaluated:
+//
+//	prev(discovery.kubernetes.pods.targets)
+//
+// Calls to prev are rewritten before evaluating the arguments of a component,
+// so the function in the standard library is only called when the argument
+// isn't a reference, or from blocks which aren't components.
+const prevFunctionName = "prev"
+
+// prevVariablePrefix prefixes the names of the variables which replace calls
+// to prev. River identifiers can't contain a colon, so the variables can't
+// conflict with identifiers written in configs.
+const prevVariablePrefix = "prev:"
+
+// prevCalls holds the references passed to prev in the body of a component,
+// keyed by their expression, such as "discovery.kubernetes.pods.targets".
+type prevCalls map[string]ast.Expr
+
+// rewritePrevCalls returns a copy of body where every call to prev with a
+// reference as its only argument is replaced by a variable holding the
+// previous value of the reference, along with the replaced references. body
+// is returned unchanged if it doesn't call prev.
+func rewritePrevCalls(body ast.Body) (ast.Body, prevCalls) {
+	calls := make(prevCalls)
+	rewritten := rewritePrevBody(body, calls)
+	if len(calls) == 0 {
+		return body, nil
+	}
+	return rewritten, calls
+}
+
+func rewritePrevBody(body ast.Body, calls prevCalls) ast.Body {
+	rewritten := make(ast.Body, len(body))
+	for i, stmt := range body {
+		switch stmt := stmt.(type) {
+		case *ast.AttributeStmt:
+			copied := *stmt
+			copied.Value = mapExpr(stmt.Value, func(expr ast.Expr) ast.Expr {
+				return rewritePrevCall(expr, calls)
+			})
+			rewritten[i] = &copied
+
+		case *ast.BlockStmt:
+			copied := *stmt
+			copied.Body = rewritePrevBody(stmt.Body, calls)
+			rewritten[i] = &copied
+
+		default:
+			rewritten[i] = stmt
+		}
+	}
+	return rewritten
+}
+
+// rewritePrevCall returns the variable which replaces expr if it's a call to
+// prev with a reference as its only argument, or nil otherwise.
+func rewritePrevCall(expr ast.Expr, calls prevCalls) ast.Expr {
+	call, ok := expr.(*ast.CallExpr)
+	if !ok || len(call.Args) != 1 {
+		return nil
+	}
+	if fn, ok := call.Value.(*ast.IdentifierExpr); !ok || fn.Ident.Name != prevFunctionName {
+		return nil
+	}
+	ref, ok := referenceString(call.Args[0])
+	if !ok {
+		return nil
+	}
+
+	calls[ref] = call.Args[0]
+	return &ast.IdentifierExpr{Ident: &ast.Ident{
+		Name:    prevVariablePrefix + ref,
+		NamePos: ast.StartPos(call),
+	}}
+}
+
+// referenceString returns the expression of expr if it's a reference made of
+// an identifier followed by field accesses, such as "a.b.c".
+func referenceString(expr ast.Expr) (string, bool) {
+	var names []string
+	for {
+		switch e := expr.(type) {
+		case *ast.AccessExpr:
+			names = append(names, e.Name.Name)
+			expr = e.Value
+			continue
+		case *ast.IdentifierExpr:
+			names = append(names, e.Ident.Name)
+		default:
+			return "", false
+		}
+		break
+	}
+
+	for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+		names[i], names[j] = names[j], names[i]
+	}
+	return strings.Join(names, "."), true
+}
+
+// scope returns a scope holding the previous values of calls, with parent as
+// its parent, and the values the references of calls have in parent, which
+// become the previous values once the component is evaluated. References
+// without a previous value are null.
+func (calls prevCalls) scope(parent *vm.Scope, previous map[string]any) (*vm.Scope, map[string]any, error) {
+	var (
+		vars    = make(map[string]any, len(calls))
+		current = make(map[string]any, len(calls))
+	)
+	for ref, expr := range calls {
+		var value any
+		if err := vm.New(expr).Evaluate(parent, &value); err != nil {
+			return nil, nil, err
+		}
+		current[ref] = value
+		vars[prevVariablePrefix+ref] = previous[ref]
+	}
+	return &vm.Scope{Parent: parent, Variables: vars}, current, nil
+}
@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/grafana/river/parser"
+	"github.com/grafana/river/vm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewritePrevCalls(t *testing.T) {
+	file, err := parser.ParseFile(t.Name(), []byte(`
+		name = coalesce(prev(values.current), "none")
+
+		rule {
+			label = prev(values.nested.label)
+			index = prev(len(values.list))
+		}
+	`))
+	require.NoError(t, err)
+
+	body, calls := rewritePrevCalls(file.Body)
+	require.Len(t, calls, 2)
+	require.Contains(t, calls, "values.current")
+	require.Contains(t, calls, "values.nested.label")
+
+	scope := &vm.Scope{Parent: stdlibScope, Variables: map[string]any{
+		"len": func(list []any) int { return len(list) },
+		"values": map[string]any{
+			"current": "new",
+			"nested":  map[string]any{"label": "new-label"},
+			"list":    []any{},
+		},
+	}}
+
+	// Only references are replaced, so prev fails for other arguments.
+	prevScope, current, err := calls.scope(scope, nil)
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"values.current": "new", "values.nested.label": "new-label"}, current)
+
+	var args dynamicTestArgs
+	err = vm.New(body).Evaluate(prevScope, &args)
+	require.ErrorContains(t, err, "prev: argument must be a reference")
+
+	// The original body is left unchanged.
+	_, calls = rewritePrevCalls(file.Body)
+	require.Len(t, calls, 2)
+}
+
+func TestPrevCalls_Scope(t *testing.T) {
+	file, err := parser.ParseFile(t.Name(), []byte(`
+		name = coalesce(prev(values.current), "none")
+	`))
+	require.NoError(t, err)
+	body, calls := rewritePrevCalls(file.Body)
+
+	scope := &vm.Scope{Parent: stdlibScope, Variables: map[string]any{
+		"values": map[string]any{"current": "new"},
+	}}
+
+	eval := func(previous map[string]any) (string, map[string]any) {
+		prevScope, current, err := calls.scope(scope, previous)
+		require.NoError(t, err)
+
+		var args dynamicTestArgs
+		require.NoError(t, vm.New(body).Evaluate(prevScope, &args))
+		return args.Name, current
+	}
+
+	name, current := eval(nil)
+	require.Equal(t, "none", name)
+
+	name, _ = eval(map[string]any{"values.current": "old"})
+	require.Equal(t, "old", name)
+
+	name, _ = eval(current)
+	require.Equal(t, "new", name)
+
+	// Bodies without calls to prev are returned as-is.
+	file, err = parser.ParseFile(t.Name(), []byte(`name = values.current`))
+	require.NoError(t, err)
+	body, calls = rewritePrevCalls(file.Body)
+	require.Nil(t, calls)
+	require.Equal(t, file.Body, body)
+}
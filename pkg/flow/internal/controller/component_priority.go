@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"container/heap"
+
+	"github.com/grafana/agent/pkg/flow/internal/dag"
+	"github.com/grafana/river/ast"
+	"github.com/grafana/river/diag"
+	"github.com/grafana/river/vm"
+)
+
+// priorityAttr is the name of the meta-argument which controls the order
+// components are started in when they don't depend on each other.
+const priorityAttr = "priority"
+
+// evaluatePriority evaluates the priority meta-argument of a component block.
+// Blocks without a priority attribute have a priority of 0.
+func evaluatePriority(block *ast.BlockStmt, functions *vm.Scope) (priority int, stripped *ast.BlockStmt, diags diag.Diagnostics) {
+	_, stripped, diags = evaluateMetaArgument(block, priorityAttr, functions, &priority)
+	if stripped == nil {
+		return 0, nil, diags
+	}
+	return priority, stripped, diags
+}
+
+// startupOrder returns the components of g in the order they should be
+// started: every component comes after the components it depends on. Among
+// components whose dependencies have all been started, those with a higher
+// priority are started first, and components with the same priority are
+// started in order of their IDs. The Scheduler delays components until the
+// components with a higher priority before them finished starting up.
+//
+// g must not contain cycles.
+func startupOrder(g *dag.Graph) []*ComponentNode {
+	var (
+		order         []*ComponentNode
+		ready         readyNodes
+		remainingDeps = make(map[dag.Node]int)
+	)
+	for _, n := range g.Nodes() {
+		if deps := len(g.Dependencies(n)); deps > 0 {
+			remainingDeps[n] = deps
+			continue
+		}
+		ready = append(ready, n)
+	}
+	heap.Init(&ready)
+
+	for ready.Len() > 0 {
+		next := heap.Pop(&ready).(dag.Node)
+
+		if cn, ok := next.(*ComponentNode); ok {
+			order = append(order, cn)
+		}
+		for _, dependant := range g.Dependants(next) {
+			remainingDeps[dependant]--
+			if remainingDeps[dependant] == 0 {
+				heap.Push(&ready, dependant)
+			}
+		}
+	}
+	return order
+}
+
+// readyNodes is a heap of nodes which are ready to start, ordered by
+// startsBefore.
+type readyNodes []dag.Node
+
+func (r readyNodes) Len() int           { return len(r) }
+func (r readyNodes) Less(i, j int) bool { return startsBefore(r[i], r[j]) }
+func (r readyNodes) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+func (r *readyNodes) Push(x any)        { *r = append(*r, x.(dag.Node)) }
+
+func (r *readyNodes) Pop() any {
+	old := *r
+	n := old[len(old)-1]
+	*r = old[:len(old)-1]
+	return n
+}
+
+// startsBefore returns whether a should be started before b when both are
+// ready to start. Nodes which aren't components, such as config blocks, are
+// processed first, since they don't need to be started.
+func startsBefore(a, b dag.Node) bool {
+	ca, aComponent := a.(*ComponentNode)
+	cb, bComponent := b.(*ComponentNode)
+	switch {
+	case aComponent != bComponent:
+		return !aComponent
+	case aComponent && ca.Priority() != cb.Priority():
+		return ca.Priority() > cb.Priority()
+	default:
+		return a.NodeID() < b.NodeID()
+	}
+}
@@ -2,6 +2,9 @@ package controller
 
 import (
 	"fmt"
+	"reflect"
+	"sort"
+	"strings"
 
 	"github.com/grafana/agent/pkg/flow/internal/dag"
 	"github.com/grafana/river/ast"
@@ -27,6 +30,12 @@ type Reference struct {
 // ComponentReferences returns the list of references a component is making to
 // other components.
 func ComponentReferences(cn dag.Node, g *dag.Graph) ([]Reference, diag.Diagnostics) {
+	return componentReferences(cn, g, stdlibScope)
+}
+
+// componentReferences returns the list of references a component is making
+// to other components. References to identifiers in functions are ignored.
+func componentReferences(cn dag.Node, g *dag.Graph, functions *vm.Scope) ([]Reference, diag.Diagnostics) {
 	var (
 		traversals []Traversal
 
@@ -36,19 +45,18 @@ func ComponentReferences(cn dag.Node, g *dag.Graph) ([]Reference, diag.Diagnosti
 	switch cn := cn.(type) {
 	case BlockNode:
 		if cn.Block() != nil {
-			traversals = expressionsFromBody(cn.Block().Body)
+			traversals, diags = expressionsFromBody(cn.Block().Body)
 		}
 	}
 
 	refs := make([]Reference, 0, len(traversals))
 	for _, t := range traversals {
-		// We use an empty scope to determine if a reference refers to something in
-		// the stdlib, since vm.Scope.Lookup will search the scope tree + the
-		// stdlib.
+		// We use the functions scope to determine if a reference refers to
+		// something in the stdlib or a custom function, since vm.Scope.Lookup
+		// will search the scope tree + the River stdlib.
 		//
-		// Any call to an stdlib function is ignored.
-		var emptyScope vm.Scope
-		if _, ok := emptyScope.Lookup(t[0].Name); ok {
+		// Any call to an stdlib or custom function is ignored.
+		if _, ok := functions.Lookup(t[0].Name); ok {
 			continue
 		}
 
@@ -57,31 +65,206 @@ func ComponentReferences(cn dag.Node, g *dag.Graph) ([]Reference, diag.Diagnosti
 		if resolveDiags.HasErrors() {
 			continue
 		}
+
+		// A block referencing itself would create an edge from its node to
+		// itself. Report it here, where the expression is still known, rather
+		// than as a cycle in the graph.
+		if ref.Target.NodeID() == cn.NodeID() {
+			diags.Add(diag.Diagnostic{
+				Severity: diag.SeverityLevelError,
+				Message:  fmt.Sprintf("%s references itself in the expression %q; a block can't reference its own exports", cn.NodeID(), traversalString(t)),
+				StartPos: ast.StartPos(t[0]).Position(),
+				EndPos:   ast.EndPos(t[len(t)-1]).Position(),
+			})
+			continue
+		}
+		if exportDiags := validateExportReference(ref, t); exportDiags.HasErrors() {
+			diags = append(diags, exportDiags...)
+			continue
+		}
 		refs = append(refs, ref)
 	}
 
 	return refs, diags
 }
 
-// expressionsFromSyntaxBody recurses through body and finds all variable
-// references.
-func expressionsFromBody(body ast.Body) []Traversal {
+// validateExportReference returns an error if the traversal t, which resolved
+// to ref, accesses an attribute which the referenced component doesn't
+// export. Only the first attribute accessed is validated, since the types of
+// nested values aren't always known before evaluation.
+func validateExportReference(ref Reference, t Traversal) diag.Diagnostics {
+	cn, ok := ref.Target.(*ComponentNode)
+	if !ok || len(ref.Traversal) == 0 {
+		return nil
+	}
+
+	fields, ok := componentFields(cn)
+	if !ok {
+		// Exports which aren't structs can't be validated ahead of time.
+		return nil
+	}
+
+	name := ref.Traversal[0].Name
+	if _, found := fields[name]; found {
+		return nil
+	}
+
+	available := make([]string, 0, len(fields))
+	for field := range fields {
+		available = append(available, field)
+	}
+	sort.Strings(available)
+
+	var diags diag.Diagnostics
+	diags.Add(diag.Diagnostic{
+		Severity: diag.SeverityLevelError,
+		Message:  fmt.Sprintf("component %q has no exported attribute %q; available: %s", cn.NodeID(), name, strings.Join(available, ", ")),
+		StartPos: ast.StartPos(t[0]).Position(),
+		EndPos:   ast.EndPos(t[len(t)-1]).Position(),
+	})
+	return diags
+}
+
+// componentFields returns the names of the fields which can be referenced on
+// cn: its exports and its health. ok is false if the exports of cn aren't a
+// struct, so its fields aren't known ahead of time.
+func componentFields(cn *ComponentNode) (fields map[string]struct{}, ok bool) {
+	// Every component exposes its health next to its exports.
+	fields = map[string]struct{}{healthAttr: {}}
+	if exportsTy := getExportsType(cn.Registration()); exportsTy != nil {
+		exports, ok := exportedFields(exportsTy)
+		if !ok {
+			return nil, false
+		}
+		for name := range exports {
+			fields[name] = struct{}{}
+		}
+	}
+	return fields, true
+}
+
+// ReferenceableFields returns the sorted names of the fields which other
+// blocks can reference on cn, including its health. It returns nil if the
+// fields aren't known ahead of time because the exports of cn aren't a
+// struct.
+func (cn *ComponentNode) ReferenceableFields() []string {
+	fields, ok := componentFields(cn)
+	if !ok {
+		return nil
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// exportedFields returns the names of the River attributes and blocks of the
+// struct held by ty. Squashed structs are flattened into the result. ok is
+// false if ty doesn't hold a struct.
+func exportedFields(ty reflect.Type) (fields map[string]struct{}, ok bool) {
+	for ty.Kind() == reflect.Pointer {
+		ty = ty.Elem()
+	}
+	if ty.Kind() != reflect.Struct {
+		return nil, false
+	}
+
+	fields = make(map[string]struct{})
+	for i := 0; i < ty.NumField(); i++ {
+		field := ty.Field(i)
+		tag, ok := field.Tag.Lookup("river")
+		if !ok {
+			continue
+		}
+
+		name, flags, _ := strings.Cut(tag, ",")
+		switch {
+		case strings.Contains(flags, "squash"):
+			inner, _ := exportedFields(field.Type)
+			for name := range inner {
+				fields[name] = struct{}{}
+			}
+		case name != "":
+			fields[name] = struct{}{}
+		}
+	}
+	return fields, true
+}
+
+// expressionsFromBody recurses through body and finds all variable
+// references, including references nested inside of blocks, function call
+// arguments, index expressions, arrays, and objects.
+//
+// River does not support string interpolation, so string literals never
+// contain references.
+//
+// References to the element being generated inside the content of a dynamic
+// block aren't references to other blocks, so they're left out.
+//
+// Nodes nested more than maxExpressionDepth levels deep aren't walked, so
+// untrusted configs can't exhaust the stack. An error is returned for the
+// first such node instead.
+func expressionsFromBody(body ast.Body) ([]Traversal, diag.Diagnostics) {
 	var w traversalWalker
 	ast.Walk(&w, body)
 
 	// Flush after the walk in case there was an in-progress traversal.
 	w.flush()
-	return w.traversals
+
+	if w.tooDeep != nil {
+		var diags diag.Diagnostics
+		diags.Add(diag.Diagnostic{
+			Severity: diag.SeverityLevelError,
+			Message:  fmt.Sprintf("expression is nested too deeply; at most %d levels of nesting are allowed", maxExpressionDepth),
+			StartPos: ast.StartPos(w.tooDeep).Position(),
+			EndPos:   ast.EndPos(w.tooDeep).Position(),
+		})
+		return w.traversals, diags
+	}
+	return w.traversals, nil
 }
 
+// maxExpressionDepth is the deepest level of nesting of nodes walked by
+// expressionsFromBody.
+const maxExpressionDepth = 1000
+
 type traversalWalker struct {
 	traversals []Traversal
+	iterators  map[string]struct{} // Iterators of the dynamic blocks being walked.
 
-	buildTraversal   bool      // Whether
+	buildTraversal   bool      // Whether a traversal is currently being built.
 	currentTraversal Traversal // currentTraversal being built.
+
+	depth   int      // Level of nesting of the node being walked.
+	tooDeep ast.Node // First node nested deeper than maxExpressionDepth, if any.
 }
 
 func (tw *traversalWalker) Visit(node ast.Node) ast.Visitor {
+	if node == nil {
+		// The children of the last node for which tw was returned have been
+		// walked.
+		tw.depth--
+		return nil
+	}
+	if tw.tooDeep != nil {
+		return nil
+	}
+	if tw.depth >= maxExpressionDepth {
+		tw.tooDeep = node
+		return nil
+	}
+
+	tw.depth++
+	if w := tw.visit(node); w != nil {
+		return w
+	}
+	tw.depth--
+	return nil
+}
+
+func (tw *traversalWalker) visit(node ast.Node) ast.Visitor {
 	switch n := node.(type) {
 	case *ast.IdentifierExpr:
 		// Identifiers always start new traversals. Pop the last one.
@@ -104,6 +287,38 @@ func (tw *traversalWalker) Visit(node ast.Node) ast.Visitor {
 		ast.Walk(tw, n.Value)
 		tw.flush()
 		ast.Walk(tw, n.Index)
+
+		// Flush again so that any traversal from the index isn't extended by an
+		// access on the result, such as a[b.c].d.
+		tw.flush()
+		return nil
+
+	case *ast.BlockStmt:
+		if !isDynamicBlock(n) {
+			return tw
+		}
+		tw.flush()
+
+		// Walk the content of the dynamic block separately, with the iterator
+		// of the block added to the ones to ignore.
+		iterators := map[string]struct{}{dynamicIterator(n): {}}
+		for name := range tw.iterators {
+			iterators[name] = struct{}{}
+		}
+		content := &traversalWalker{iterators: iterators, depth: tw.depth}
+		for _, stmt := range n.Body {
+			if block, ok := stmt.(*ast.BlockStmt); ok && strings.Join(block.Name, ".") == dynamicContentName {
+				ast.Walk(content, block.Body)
+				content.flush()
+				continue
+			}
+			ast.Walk(tw, stmt)
+			tw.flush()
+		}
+		tw.traversals = append(tw.traversals, content.traversals...)
+		if tw.tooDeep == nil {
+			tw.tooDeep = content.tooDeep
+		}
 		return nil
 
 	case *ast.CallExpr:
@@ -113,6 +328,10 @@ func (tw *traversalWalker) Visit(node ast.Node) ast.Visitor {
 		for _, arg := range n.Args {
 			ast.Walk(tw, arg)
 		}
+
+		// Flush again so that any traversal from the final argument isn't
+		// extended by an access on the result, such as f(a.b).c.
+		tw.flush()
 		return nil
 	}
 
@@ -123,7 +342,9 @@ func (tw *traversalWalker) Visit(node ast.Node) ast.Visitor {
 // the buildTraversal state.
 func (tw *traversalWalker) flush() {
 	if tw.buildTraversal && len(tw.currentTraversal) > 0 {
-		tw.traversals = append(tw.traversals, tw.currentTraversal)
+		if _, iterator := tw.iterators[tw.currentTraversal[0].Name]; !iterator {
+			tw.traversals = append(tw.traversals, tw.currentTraversal)
+		}
 	}
 	tw.buildTraversal = false
 	tw.currentTraversal = nil
@@ -163,3 +384,12 @@ func resolveTraversal(t Traversal, g *dag.Graph) (Reference, diag.Diagnostics) {
 	})
 	return Reference{}, diags
 }
+
+// traversalString returns the expression of t, such as "a.b.c".
+func traversalString(t Traversal) string {
+	names := make([]string, len(t))
+	for i, ident := range t {
+		names[i] = ident.Name
+	}
+	return strings.Join(names, ".")
+}
@@ -0,0 +1,177 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/grafana/river/parser"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpressionsFromBody(t *testing.T) {
+	tt := []struct {
+		name   string
+		input  string
+		expect []string
+	}{
+		{
+			name:   "identifier",
+			input:  `attr = foo`,
+			expect: []string{"foo"},
+		},
+		{
+			name:   "access",
+			input:  `attr = metrics.foo.x`,
+			expect: []string{"metrics.foo.x"},
+		},
+		{
+			name:   "index interrupts traversal",
+			input:  `attr = metrics.foo.list[0].inner`,
+			expect: []string{"metrics.foo.list"},
+		},
+		{
+			name:   "index expression",
+			input:  `attr = list[metrics.foo.index]`,
+			expect: []string{"list", "metrics.foo.index"},
+		},
+		{
+			name:   "access on index result",
+			input:  `attr = list[metrics.foo.index].inner`,
+			expect: []string{"list", "metrics.foo.index"},
+		},
+		{
+			name:   "function call arguments",
+			input:  `attr = concat(metrics.foo.a, metrics.bar.b)`,
+			expect: []string{"concat", "metrics.foo.a", "metrics.bar.b"},
+		},
+		{
+			name:   "access on call result",
+			input:  `attr = json_decode(local.file.x.content).field`,
+			expect: []string{"json_decode", "local.file.x.content"},
+		},
+		{
+			name:   "nested calls",
+			input:  `attr = coalesce(env(metrics.foo.name), "default")`,
+			expect: []string{"coalesce", "env", "metrics.foo.name"},
+		},
+		{
+			name:   "binary and unary",
+			input:  `attr = !metrics.foo.enabled || metrics.bar.count > 5`,
+			expect: []string{"metrics.foo.enabled", "metrics.bar.count"},
+		},
+		{
+			name:   "parenthesized",
+			input:  `attr = (metrics.foo.a + metrics.bar.b) * 2`,
+			expect: []string{"metrics.foo.a", "metrics.bar.b"},
+		},
+		{
+			name:   "array",
+			input:  `attr = [metrics.foo.a, "literal", metrics.bar.b]`,
+			expect: []string{"metrics.foo.a", "metrics.bar.b"},
+		},
+		{
+			name:   "object",
+			input:  `attr = { key = metrics.foo.a, other = { inner = metrics.bar.b } }`,
+			expect: []string{"metrics.foo.a", "metrics.bar.b"},
+		},
+		{
+			// River has no string interpolation, so template-like strings are
+			// plain literals and never reference anything.
+			name:   "template-like literal",
+			input:  `attr = "${metrics.foo.x}"`,
+			expect: nil,
+		},
+		{
+			name: "nested blocks",
+			input: `
+				attr = metrics.foo.a
+				block {
+					inner = metrics.bar.b
+					deeper {
+						innermost = metrics.baz.c
+					}
+				}
+			`,
+			expect: []string{"metrics.foo.a", "metrics.bar.b", "metrics.baz.c"},
+		},
+		{
+			name: "dynamic blocks",
+			input: `
+				dynamic "rule" {
+					for_each = local.file.rules.content
+
+					content {
+						source_labels = rule.value.labels
+						target_label  = metrics.foo.label
+
+						dynamic "inner" {
+							for_each = rule.value.inner
+
+							content {
+								a = inner.value
+								b = rule.key
+							}
+						}
+					}
+				}
+				rule = rule.value
+			`,
+			expect: []string{"local.file.rules.content", "metrics.foo.label", "rule.value"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			file, err := parser.ParseFile(t.Name(), []byte(tc.input))
+			require.NoError(t, err)
+
+			traversals, diags := expressionsFromBody(file.Body)
+			require.Empty(t, diags)
+
+			var actual []string
+			for _, traversal := range traversals {
+				names := make([]string, 0, len(traversal))
+				for _, ident := range traversal {
+					names = append(names, ident.Name)
+				}
+				actual = append(actual, strings.Join(names, "."))
+			}
+			require.Equal(t, tc.expect, actual)
+		})
+	}
+}
+
+func TestExpressionsFromBody_Depth(t *testing.T) {
+	tt := []struct {
+		name  string
+		input string
+	}{
+		{
+			name:  "nested blocks",
+			input: strings.Repeat("block {\n", 5000) + "attr = foo\n" + strings.Repeat("}\n", 5000),
+		},
+		{
+			name:  "nested arrays",
+			input: "attr = " + strings.Repeat("[", 5000) + "foo" + strings.Repeat("]", 5000),
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			file, err := parser.ParseFile(t.Name(), []byte(tc.input))
+			require.NoError(t, err)
+
+			_, diags := expressionsFromBody(file.Body)
+			require.Len(t, diags, 1)
+			require.Contains(t, diags[0].Message, "expression is nested too deeply")
+		})
+	}
+
+	// Nesting below the limit is walked as usual.
+	input := "attr = " + strings.Repeat("[", 100) + "foo" + strings.Repeat("]", 100)
+	file, err := parser.ParseFile(t.Name(), []byte(input))
+	require.NoError(t, err)
+	traversals, diags := expressionsFromBody(file.Body)
+	require.Empty(t, diags)
+	require.Len(t, traversals, 1)
+}
@@ -0,0 +1,372 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/pkg/flow/logging/level"
+)
+
+// SingletonRegistry shares components registered as singletons between every
+// controller using the registry, so each singleton component is only built
+// once per registry.
+//
+// The first ComponentNode to build a singleton component builds it with its
+// own options, and later ComponentNodes reuse that component. Each
+// ComponentNode updates the shared component with its own arguments, so the
+// most recently evaluated arguments apply, and receives its exports.
+//
+// The shared component runs while at least one ComponentNode using it is
+// running, and is stopped once the last one stops. A stopped component is
+// kept and runs again the next time a ComponentNode using it runs.
+//
+// Every block of a singleton component must use the same label, since all
+// of them share one component; ComponentNodes with another label fail to
+// build.
+//
+// ComponentNodes release the shared component once they're removed from
+// their graph or their controller shuts down. When the ComponentNode whose
+// options the component was built with releases it, the component is built
+// again with the options of one of the remaining ComponentNodes, so it never
+// logs or reports metrics through a controller which doesn't use it anymore.
+// The component is stopped and dropped from the registry once no
+// ComponentNode holds it, so the next ComponentNode to use it builds a new
+// component with its own options.
+type SingletonRegistry struct {
+	mut     sync.Mutex
+	entries map[string]*singletonEntry // Shared components by component name.
+}
+
+// NewSingletonRegistry returns a new, empty SingletonRegistry.
+func NewSingletonRegistry() *SingletonRegistry {
+	return &SingletonRegistry{entries: make(map[string]*singletonEntry)}
+}
+
+// acquire returns the shared component for cn, building it with args if it
+// doesn't exist yet, or updating it with args otherwise.
+func (r *SingletonRegistry) acquire(cn *ComponentNode, args component.Arguments) (*singletonEntry, error) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	e, ok := r.entries[cn.componentName]
+	switch {
+	case !ok:
+		e = &singletonEntry{label: cn.label, holders: make(map[*ComponentNode]struct{})}
+		managed, err := e.build(cn, args)
+		if err != nil {
+			return nil, err
+		}
+		e.managed, e.args, e.owner = managed, args, cn
+		r.entries[cn.componentName] = e
+	case e.label != cn.label:
+		return nil, fmt.Errorf("%s is a singleton component and is already used with the label %q; every block of a singleton component must use the same label", cn.componentName, e.label)
+	default:
+		if err := e.update(args); err != nil {
+			return nil, err
+		}
+	}
+
+	e.mut.Lock()
+	e.holders[cn] = struct{}{}
+	exports := e.exports
+	e.mut.Unlock()
+
+	// Give cn the exports the shared component already reported.
+	if exports != nil {
+		cn.setExports(exports)
+	}
+	return e, nil
+}
+
+// release removes cn from the holders of e. If cn was the last holder, the
+// shared component is stopped and removed from r. If cn built the shared
+// component, it's built again with the options of another holder.
+func (r *SingletonRegistry) release(cn *ComponentNode, e *singletonEntry) {
+	r.mut.Lock()
+	e.mut.Lock()
+	delete(e.holders, cn)
+	last := len(e.holders) == 0
+	var owner *ComponentNode
+	if !last && e.owner == cn {
+		owner = e.nextOwner()
+		e.owner = owner
+	}
+	e.mut.Unlock()
+	if last && r.entries[cn.componentName] == e {
+		delete(r.entries, cn.componentName)
+	}
+	r.mut.Unlock()
+
+	switch {
+	case last:
+		e.stop()
+	case owner != nil:
+		if err := e.rebind(owner); err != nil {
+			level.Error(owner.managedOpts.Logger).Log("msg", "failed to rebuild singleton component for its new owner, keeping the previous component", "err", err)
+		}
+	}
+}
+
+// singletonEntry is a component shared by a SingletonRegistry.
+type singletonEntry struct {
+	label string // Label of the blocks using the component.
+
+	// managed is replaced while both updateMut and runMut are held.
+	managed component.Component
+
+	// updateMut serializes calls to Update from different controllers. It's
+	// separate from mut since components may report exports while updating.
+	updateMut sync.Mutex
+	args      component.Arguments
+
+	mut     sync.Mutex
+	holders map[*ComponentNode]struct{} // ComponentNodes using the component.
+	owner   *ComponentNode              // Holder whose options the component was built with.
+	exports component.Exports           // Last exports reported by the component.
+
+	// runMut guards starting and stopping the component. Runs are stopped
+	// while runMut is held, so a new run never starts before the previous
+	// run exited.
+	runMut  sync.Mutex
+	runs    int           // Number of ComponentNodes running the component.
+	current *singletonRun // Current run of the component, if any.
+	failed  *singletonRun // Last run which failed, until the component is rebuilt.
+	stopped bool          // Set once the last holder released the component.
+}
+
+// singletonRun is a single run of a shared component.
+type singletonRun struct {
+	cancel   context.CancelFunc
+	done     chan struct{} // Closed once the run exits.
+	err      error         // Error returned by the component; set before done is closed.
+	replaced bool          // Set before canceling a run which is replaced by a rebuilt component.
+	rebuilt  bool          // Set once a holder rebuilds the component after the run failed.
+}
+
+// build builds a new shared component for owner from args, reporting its
+// exports to every holder of e.
+func (e *singletonEntry) build(owner *ComponentNode, args component.Arguments) (component.Component, error) {
+	opts := owner.managedOpts
+	opts.OnStateChange = e.setExports
+	return owner.reg.Build(opts, args)
+}
+
+// nextOwner returns the holder the shared component is built for once its
+// owner releases it, preferring the lowest global ID so the choice doesn't
+// depend on map order. e.mut must be held.
+func (e *singletonEntry) nextOwner() *ComponentNode {
+	var next *ComponentNode
+	for cn := range e.holders {
+		if next == nil || cn.globalID < next.globalID {
+			next = cn
+		}
+	}
+	return next
+}
+
+// rebind replaces the shared component with a new component built with the
+// options of owner and the current arguments. If the component is running,
+// the new component runs in its place, and the holders running it keep
+// running.
+func (e *singletonEntry) rebind(owner *ComponentNode) error {
+	e.updateMut.Lock()
+	defer e.updateMut.Unlock()
+	return e.rebindLocked(owner)
+}
+
+// rebuildFailed rebuilds the shared component with the options of its owner
+// after its last run failed. Only the first holder to call rebuildFailed
+// after a failure rebuilds the component; the others reuse the new one.
+func (e *singletonEntry) rebuildFailed() error {
+	e.updateMut.Lock()
+	defer e.updateMut.Unlock()
+
+	e.runMut.Lock()
+	failed := e.failed
+	if failed != nil {
+		e.failed, failed.rebuilt = nil, true
+	}
+	e.runMut.Unlock()
+	if failed == nil {
+		return nil
+	}
+
+	e.mut.Lock()
+	owner := e.owner
+	e.mut.Unlock()
+
+	if err := e.rebindLocked(owner); err != nil {
+		// Let the next holder retry the rebuild.
+		e.runMut.Lock()
+		if e.failed == nil {
+			e.failed, failed.rebuilt = failed, false
+		}
+		e.runMut.Unlock()
+		return err
+	}
+	return nil
+}
+
+// rebindLocked implements rebind. updateMut must be held.
+func (e *singletonEntry) rebindLocked(owner *ComponentNode) error {
+	managed, err := e.build(owner, e.args)
+	if err != nil {
+		return err
+	}
+
+	e.runMut.Lock()
+	if e.stopped {
+		e.runMut.Unlock()
+		return nil
+	}
+	if run := e.current; run != nil {
+		run.replaced = true
+		run.cancel()
+		<-run.done
+	}
+	e.managed = managed
+	if e.current != nil {
+		e.current = e.startRun()
+	}
+	e.runMut.Unlock()
+
+	e.mut.Lock()
+	holders := make([]*ComponentNode, 0, len(e.holders))
+	for cn := range e.holders {
+		holders = append(holders, cn)
+	}
+	e.mut.Unlock()
+
+	for _, cn := range holders {
+		cn.mut.Lock()
+		if cn.singleton == e {
+			cn.setManaged(managed)
+		}
+		cn.mut.Unlock()
+	}
+	return nil
+}
+
+// startRun starts a new run of the shared component. runMut must be held.
+func (e *singletonEntry) startRun() *singletonRun {
+	runCtx, cancel := context.WithCancel(context.Background())
+	run := &singletonRun{cancel: cancel, done: make(chan struct{})}
+	managed := e.managed
+
+	go func() {
+		defer close(run.done)
+		run.err = managed.Run(runCtx)
+	}()
+	return run
+}
+
+// update updates the shared component with args, unless they're the same as
+// the arguments it was last updated with.
+func (e *singletonEntry) update(args component.Arguments) error {
+	e.updateMut.Lock()
+	defer e.updateMut.Unlock()
+
+	if reflect.DeepEqual(e.args, args) {
+		return nil
+	}
+	if err := e.managed.Update(args); err != nil {
+		return err
+	}
+	e.args = args
+	return nil
+}
+
+// setExports passes exports reported by the shared component to every
+// ComponentNode using it.
+func (e *singletonEntry) setExports(exports component.Exports) {
+	e.mut.Lock()
+	e.exports = exports
+	holders := make([]*ComponentNode, 0, len(e.holders))
+	for cn := range e.holders {
+		holders = append(holders, cn)
+	}
+	e.mut.Unlock()
+
+	for _, cn := range holders {
+		cn.setExports(exports)
+	}
+}
+
+// run runs the shared component until ctx is canceled or the component
+// exits, starting it if no other ComponentNode is running it. A component
+// whose last run failed is rebuilt before it starts. The component is
+// stopped once every ComponentNode running it returns from run.
+func (e *singletonEntry) run(ctx context.Context) error {
+	e.runMut.Lock()
+	for e.runs == 0 && e.failed != nil && !e.stopped {
+		// The component failed while no holder was running it, so it's rebuilt
+		// before it runs again.
+		e.runMut.Unlock()
+		if err := e.rebuildFailed(); err != nil {
+			return fmt.Errorf("rebuilding component: %w", err)
+		}
+		e.runMut.Lock()
+	}
+	if e.stopped {
+		// Every holder released the component, so it never runs again.
+		e.runMut.Unlock()
+		return nil
+	}
+	if e.runs == 0 {
+		e.current = e.startRun()
+	}
+	e.runs++
+	run := e.current
+	e.runMut.Unlock()
+
+	var exited bool
+	for {
+		select {
+		case <-ctx.Done():
+		case <-run.done:
+			exited = true
+		}
+
+		e.runMut.Lock()
+		if exited && run.replaced && e.current != nil {
+			// The component was rebuilt by rebind, so follow the run of the new
+			// component instead.
+			run, exited = e.current, false
+			e.runMut.Unlock()
+			continue
+		}
+		break
+	}
+	defer e.runMut.Unlock()
+
+	if exited && run.err != nil && !run.rebuilt {
+		e.failed = run
+	}
+
+	e.runs--
+	if e.runs == 0 {
+		e.current.cancel()
+		<-e.current.done
+		e.current = nil
+	}
+	if exited {
+		return run.err
+	}
+	return nil
+}
+
+// stop stops the current run of the shared component, if any, and waits for
+// it to exit. The component doesn't run again once stopped.
+func (e *singletonEntry) stop() {
+	e.runMut.Lock()
+	defer e.runMut.Unlock()
+
+	e.stopped = true
+	if run := e.current; run != nil {
+		run.cancel()
+		<-run.done
+	}
+}
@@ -0,0 +1,31 @@
+package controller
+
+import (
+	"github.com/grafana/agent/component"
+	"github.com/grafana/river/ast"
+	"github.com/grafana/river/diag"
+	"github.com/grafana/river/vm"
+)
+
+// sinkAttr is the name of the meta-argument which overrides whether a
+// component is a sink, a component which isn't expected to be referenced by
+// other components.
+const sinkAttr = "sink"
+
+// evaluateSink evaluates the sink meta-argument of a component block. Blocks
+// without a sink attribute are sinks when their registration, reg, is one.
+//
+// Like tags, the meta-argument is left in block as an argument of components
+// which have their own sink attribute.
+func evaluateSink(block *ast.BlockStmt, reg component.Registration, functions *vm.Scope) (sink bool, stripped *ast.BlockStmt, diags diag.Diagnostics) {
+	sink = reg.IsSink()
+	if definesArgument(reg.Args, sinkAttr) {
+		return sink, block, nil
+	}
+
+	_, stripped, diags = evaluateMetaArgument(block, sinkAttr, functions, &sink)
+	if stripped == nil {
+		return false, nil, diags
+	}
+	return sink, stripped, diags
+}
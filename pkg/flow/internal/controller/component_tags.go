@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"reflect"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/river/ast"
+	"github.com/grafana/river/diag"
+	"github.com/grafana/river/vm"
+)
+
+// tagsAttr is the name of the meta-argument which attaches tags to a
+// component, for grouping and filtering components.
+const tagsAttr = "tags"
+
+// evaluateTags evaluates the tags meta-argument of a component block. Blocks
+// without a tags attribute have no tags.
+//
+// Components whose arguments, args, have their own tags attribute, such as
+// discovery.consul, can't be tagged: their tags attribute is left in block
+// as an argument.
+func evaluateTags(block *ast.BlockStmt, args component.Arguments, functions *vm.Scope) (tags []string, stripped *ast.BlockStmt, diags diag.Diagnostics) {
+	if definesArgument(args, tagsAttr) {
+		return nil, block, nil
+	}
+
+	_, stripped, diags = evaluateMetaArgument(block, tagsAttr, functions, &tags)
+	if stripped == nil {
+		return nil, nil, diags
+	}
+	return tags, stripped, diags
+}
+
+// definesArgument returns whether args, the arguments of a component, have a
+// top-level attribute or block with the given name.
+func definesArgument(args component.Arguments, name string) bool {
+	ty := reflect.TypeOf(args)
+	for ty != nil && ty.Kind() == reflect.Pointer {
+		ty = ty.Elem()
+	}
+	return ty != nil && ty.Kind() == reflect.Struct && hasRiverField(ty, name)
+}
@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/pkg/flow/internal/dag"
+	"github.com/grafana/river/ast"
+	"github.com/grafana/river/diag"
+)
+
+// DeprecatedUsage returns a warning diagnostic for every component in g
+// which is deprecated, and for every deprecated argument set in the blocks of
+// components in g. Diagnostics are sorted by component ID.
+//
+// See [component.Registration.Deprecation] and
+// [component.Registration.DeprecatedArguments] for how components are
+// marked as deprecated.
+func DeprecatedUsage(g *dag.Graph) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	nodes := g.Nodes()
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].NodeID() < nodes[j].NodeID()
+	})
+
+	for _, n := range nodes {
+		cn, ok := n.(*ComponentNode)
+		if !ok {
+			continue
+		}
+
+		var (
+			reg   = cn.Registration()
+			block = cn.Block()
+		)
+		if block == nil {
+			continue
+		}
+
+		if reg.Deprecation != nil {
+			diags.Add(diag.Diagnostic{
+				Severity: diag.SeverityLevelWarn,
+				Message:  deprecationMessage(fmt.Sprintf("%s uses deprecated component %s", cn.NodeID(), reg.Name), *reg.Deprecation),
+				StartPos: block.NamePos.Position(),
+				EndPos:   block.NamePos.Add(len(reg.Name) - 1).Position(),
+			})
+		}
+
+		if len(reg.DeprecatedArguments) == 0 {
+			continue
+		}
+		diags = append(diags, deprecatedArguments(cn.NodeID(), "", block.Body, reg.DeprecatedArguments)...)
+	}
+
+	return diags
+}
+
+// deprecatedArguments returns a warning diagnostic for every argument in body
+// which is deprecated in args. Arguments in nested blocks are named by the
+// dot-separated path of the blocks they're in, prefixed by prefix.
+func deprecatedArguments(id, prefix string, body ast.Body, args map[string]component.Deprecation) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	for _, stmt := range body {
+		var (
+			name  string
+			inner ast.Body
+		)
+		switch stmt := stmt.(type) {
+		case *ast.AttributeStmt:
+			name = prefix + stmt.Name.Name
+		case *ast.BlockStmt:
+			name = prefix + stmt.GetBlockName()
+			inner = stmt.Body
+		}
+
+		if deprecation, ok := args[name]; ok {
+			diags.Add(diag.Diagnostic{
+				Severity: diag.SeverityLevelWarn,
+				Message:  deprecationMessage(fmt.Sprintf("%s sets deprecated argument %q", id, name), deprecation),
+				StartPos: ast.StartPos(stmt).Position(),
+				EndPos:   ast.EndPos(stmt).Position(),
+			})
+		}
+		if len(inner) > 0 {
+			diags = append(diags, deprecatedArguments(id, name+".", inner, args)...)
+		}
+	}
+	return diags
+}
+
+// deprecationMessage appends the description of d to msg, if there is one.
+func deprecationMessage(msg string, d component.Deprecation) string {
+	if desc := d.String(); desc != "" {
+		return msg + ": " + desc
+	}
+	return msg
+}
@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/pkg/flow/internal/dag"
+	"github.com/grafana/river/ast"
+	"github.com/grafana/river/parser"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeprecatedUsage(t *testing.T) {
+	file, err := parser.ParseFile(t.Name(), []byte(`
+		source.old "a" {}
+		source.current "b" {
+			old_attr = 5
+			new_attr = 5
+			old_block {}
+			client {
+				bearer_token = "secret"
+				new_attr     = 5
+			}
+		}
+		source.current "c" {}
+	`))
+	require.NoError(t, err)
+
+	registrations := map[string]component.Registration{
+		"source.old": {
+			Name:        "source.old",
+			Args:        struct{}{},
+			Deprecation: &component.Deprecation{Replacement: "source.current", Message: "source.old will be removed in v1.0"},
+		},
+		"source.current": {
+			Name: "source.current",
+			Args: struct{}{},
+			DeprecatedArguments: map[string]component.Deprecation{
+				"old_attr":            {Replacement: "new_attr"},
+				"old_block":           {},
+				"client.bearer_token": {Replacement: "client.authorization"},
+			},
+		},
+	}
+	globals := ComponentGlobals{
+		NewModuleController: func(id string) ModuleController { return nil },
+	}
+
+	var g dag.Graph
+	for _, stmt := range file.Body {
+		block := stmt.(*ast.BlockStmt)
+		g.Add(NewComponentNode(globals, registrations[block.GetBlockName()], block))
+	}
+
+	var messages []string
+	diags := DeprecatedUsage(&g)
+	for _, d := range diags {
+		messages = append(messages, d.Message)
+	}
+	require.Equal(t, []string{
+		`source.current.b sets deprecated argument "old_attr": use new_attr instead`,
+		`source.current.b sets deprecated argument "old_block"`,
+		`source.current.b sets deprecated argument "client.bearer_token": use client.authorization instead`,
+		`source.old.a uses deprecated component source.old: use source.current instead; source.old will be removed in v1.0`,
+	}, messages)
+
+	// Nested arguments point at the attribute itself.
+	require.Equal(t, 8, diags[2].StartPos.Line)
+	require.Equal(t, 5, diags[2].StartPos.Column)
+	require.Equal(t, 8, diags[2].EndPos.Line)
+	require.Equal(t, 27, diags[2].EndPos.Column)
+}
@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"sync"
+
+	"github.com/grafana/agent/pkg/flow/internal/dag"
+)
+
+// edgeKey identifies an edge of the graph by the IDs of its nodes, so counts
+// survive nodes being rebuilt across calls to Apply.
+type edgeKey struct {
+	from, to string
+}
+
+// edgePropagations counts how many times updates were propagated along each
+// edge of the graph, from an updated node to a node depending on it.
+type edgePropagations struct {
+	mut    sync.Mutex
+	counts map[edgeKey]uint64
+}
+
+func newEdgePropagations() *edgePropagations {
+	return &edgePropagations{counts: make(map[edgeKey]uint64)}
+}
+
+// Inc records an update of to being propagated to from, where from depends on
+// to.
+func (p *edgePropagations) Inc(from, to dag.Node) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	p.counts[edgeKey{from: from.NodeID(), to: to.NodeID()}]++
+}
+
+// Get returns the number of updates propagated along e.
+func (p *edgePropagations) Get(e dag.Edge) uint64 {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	return p.counts[edgeKey{from: e.From.NodeID(), to: e.To.NodeID()}]
+}
+
+// Sync removes counts for edges which no longer exist in g.
+func (p *edgePropagations) Sync(g *dag.Graph) {
+	keep := make(map[edgeKey]struct{})
+	for _, e := range g.Edges() {
+		keep[edgeKey{from: e.From.NodeID(), to: e.To.NodeID()}] = struct{}{}
+	}
+
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	for k := range p.counts {
+		if _, ok := keep[k]; !ok {
+			delete(p.counts, k)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"sync"
+	"time"
+
+	"github.com/grafana/agent/component"
+)
+
+// DefaultErrorHistorySize is the number of errors kept for each component
+// when ComponentGlobals doesn't set an ErrorHistorySize.
+const DefaultErrorHistorySize = 10
+
+// errorHistory is a bounded ring buffer of the most recent errors of a
+// component. The zero value keeps no errors.
+type errorHistory struct {
+	mut     sync.RWMutex
+	entries []component.TimestampedError // Fixed capacity; oldest entry at next once full.
+	next    int                          // Index of the next entry to write.
+	full    bool                         // Whether every entry has been written.
+}
+
+// newErrorHistory returns an errorHistory which keeps up to size errors.
+// Errors aren't kept when size is less than 1.
+func newErrorHistory(size int) *errorHistory {
+	if size < 1 {
+		return &errorHistory{}
+	}
+	return &errorHistory{entries: make([]component.TimestampedError, size)}
+}
+
+// Add records err, discarding the oldest error once the history is full.
+func (h *errorHistory) Add(err error) {
+	if len(h.entries) == 0 {
+		return
+	}
+
+	h.mut.Lock()
+	defer h.mut.Unlock()
+
+	h.entries[h.next] = component.TimestampedError{Time: time.Now(), Error: err.Error()}
+	h.next = (h.next + 1) % len(h.entries)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// List returns up to limit of the most recent errors, oldest first. Every
+// kept error is returned when limit is less than 1.
+func (h *errorHistory) List(limit int) []component.TimestampedError {
+	h.mut.RLock()
+	defer h.mut.RUnlock()
+
+	count := h.next
+	if h.full {
+		count = len(h.entries)
+	}
+	if limit > 0 && limit < count {
+		count = limit
+	}
+
+	res := make([]component.TimestampedError, count)
+	for i := range res {
+		// Start count entries before the next write, so the newest errors are
+		// kept when limit is smaller than the history.
+		idx := (h.next - count + i + len(h.entries)) % len(h.entries)
+		res[i] = h.entries[idx]
+	}
+	return res
+}
@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorHistory(t *testing.T) {
+	list := func(h *errorHistory, limit int) []string {
+		var res []string
+		for _, e := range h.List(limit) {
+			res = append(res, e.Error)
+		}
+		return res
+	}
+
+	h := newErrorHistory(3)
+	require.Empty(t, list(h, 0))
+
+	h.Add(fmt.Errorf("err 1"))
+	h.Add(fmt.Errorf("err 2"))
+	require.Equal(t, []string{"err 1", "err 2"}, list(h, 0))
+	require.Equal(t, []string{"err 2"}, list(h, 1))
+
+	// The oldest errors are discarded once the history is full.
+	h.Add(fmt.Errorf("err 3"))
+	h.Add(fmt.Errorf("err 4"))
+	h.Add(fmt.Errorf("err 5"))
+	require.Equal(t, []string{"err 3", "err 4", "err 5"}, list(h, 0))
+	require.Equal(t, []string{"err 4", "err 5"}, list(h, 2))
+	require.Equal(t, []string{"err 3", "err 4", "err 5"}, list(h, 10))
+
+	t.Run("Disabled", func(t *testing.T) {
+		h := newErrorHistory(-1)
+		h.Add(fmt.Errorf("err 1"))
+		require.Empty(t, list(h, 0))
+	})
+}
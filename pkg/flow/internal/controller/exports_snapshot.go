@@ -0,0 +1,150 @@
+package controller
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/grafana/river/ast"
+	"github.com/grafana/river/parser"
+	"github.com/grafana/river/token/builder"
+	"github.com/grafana/river/vm"
+)
+
+// EncodeExportsSnapshot encodes the current exports of components as a River
+// file holding one block per component. Blocks have the same name and label
+// as the component they belong to, and set its exports as attributes.
+//
+// Components without exports are skipped, as are components whose exports
+// can't be decoded back from River, such as exports holding capsules or
+// secrets. This ensures that secrets are never written into a snapshot.
+func EncodeExportsSnapshot(components []*ComponentNode) []byte {
+	f := builder.NewFile()
+
+	for _, cn := range components {
+		exports := cn.Exports()
+		if !snapshotSupported(cn.exportsType) || isNilExports(exports) {
+			continue
+		}
+
+		block := builder.NewBlock(strings.Split(cn.ComponentName(), "."), cn.Label())
+		block.Body().AppendFrom(exports)
+
+		// Only keep blocks which can be restored later.
+		single := builder.NewFile()
+		single.Body().AppendBlock(block)
+		blocks, err := parseExportsSnapshot(single.Bytes())
+		if err != nil {
+			continue
+		}
+		if _, err := decodeExports(blocks[cn.NodeID()].Body, cn.exportsType); err != nil {
+			continue
+		}
+
+		f.Body().AppendBlock(block)
+	}
+
+	return f.Bytes()
+}
+
+// RestoreExports loads an exports snapshot created by EncodeExportsSnapshot
+// into the Loader. The next call to Apply sets the exports of newly created
+// components from the snapshot before they're built, so that their
+// dependants can be evaluated with the previous exports rather than empty
+// ones. Components replace restored exports as soon as they report their own.
+//
+// Snapshot entries which no longer decode into the exports of a component,
+// such as after the component changed its exports between versions, are
+// discarded. The snapshot is dropped once Apply evaluates the graph, so
+// components created by later calls to Apply aren't restored.
+func (l *Loader) RestoreExports(snapshot []byte) error {
+	blocks, err := parseExportsSnapshot(snapshot)
+	if err != nil {
+		return err
+	}
+
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	l.snapshot = blocks
+	return nil
+}
+
+// restoreExports sets the exports of cn from the pending snapshot, if cn
+// hasn't been built yet and the snapshot has an entry for it. It returns
+// whether exports were restored.
+func (l *Loader) restoreExports(cn *ComponentNode) (bool, error) {
+	block, ok := l.snapshot[cn.NodeID()]
+	if !ok || cn.Component() != nil || !snapshotSupported(cn.exportsType) {
+		return false, nil
+	}
+
+	exports, err := decodeExports(block.Body, cn.exportsType)
+	if err != nil {
+		return false, err
+	}
+
+	cn.exportsMut.Lock()
+	cn.exports = exports
+	cn.exportsMut.Unlock()
+	return true, nil
+}
+
+// snapshotSupported returns whether exports of type ty can be stored in a
+// snapshot.
+func snapshotSupported(ty reflect.Type) bool {
+	if ty == nil {
+		return false
+	}
+	if ty.Kind() == reflect.Pointer {
+		ty = ty.Elem()
+	}
+	return ty.Kind() == reflect.Struct
+}
+
+// isNilExports returns whether exports is nil or a nil pointer.
+func isNilExports(exports any) bool {
+	if exports == nil {
+		return true
+	}
+	rv := reflect.ValueOf(exports)
+	return rv.Kind() == reflect.Pointer && rv.IsNil()
+}
+
+// parseExportsSnapshot parses a snapshot into its blocks, keyed by the node ID
+// of the component they belong to.
+func parseExportsSnapshot(snapshot []byte) (map[string]*ast.BlockStmt, error) {
+	file, err := parser.ParseFile("exports snapshot", snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := make(map[string]*ast.BlockStmt, len(file.Body))
+	for _, stmt := range file.Body {
+		block, ok := stmt.(*ast.BlockStmt)
+		if !ok {
+			return nil, fmt.Errorf("%s: unexpected statement in exports snapshot", ast.StartPos(stmt).Position())
+		}
+		blocks[BlockComponentID(block).String()] = block
+	}
+	return blocks, nil
+}
+
+// decodeExports decodes the attributes of body into a new value of type ty.
+func decodeExports(body ast.Body, ty reflect.Type) (any, error) {
+	var (
+		isPointer = ty.Kind() == reflect.Pointer
+		target    = ty
+	)
+	if isPointer {
+		target = ty.Elem()
+	}
+
+	ptr := reflect.New(target)
+	if err := vm.New(body).Evaluate(nil, ptr.Interface()); err != nil {
+		return nil, err
+	}
+	if isPointer {
+		return ptr.Interface(), nil
+	}
+	return ptr.Elem().Interface(), nil
+}
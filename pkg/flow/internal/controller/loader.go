@@ -4,11 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/go-kit/log"
 	"github.com/grafana/agent/pkg/flow/internal/dag"
+	"github.com/grafana/agent/pkg/flow/internal/stdlib"
 	"github.com/grafana/agent/pkg/flow/internal/worker"
 	"github.com/grafana/agent/pkg/flow/logging/level"
 	"github.com/grafana/agent/pkg/flow/tracing"
@@ -30,23 +33,44 @@ type Loader struct {
 	services     []service.Service
 	host         service.Host
 	componentReg ComponentRegistry
+	policy       ComponentPolicy
+	strict       bool                  // Whether unreferenced components fail Apply.
+	strictShadow bool                  // Whether components shadowing identifiers fail Apply.
+	functions    func() map[string]any // Returns the custom functions to expose on each Apply.
 	workerPool   worker.Pool
+	parallelism  int         // Maximum number of nodes evaluated concurrently by Apply.
+	reduceGraph  func() bool // Whether Apply reduces its graph; always if nil.
 	// backoffConfig is used to backoff when an updated component's dependencies cannot be submitted to worker
 	// pool for evaluation in EvaluateDependants, because the queue is full. This is an unlikely scenario, but when
 	// it happens we should avoid retrying too often to give other goroutines a chance to progress. Having a backoff
 	// also prevents log spamming with errors.
 	backoffConfig backoff.Config
 
+	// mut serializes calls to Apply with evaluating dependants. Apply holds mut
+	// while it evaluates the graph, which includes updating components.
 	mut               sync.RWMutex
-	graph             *dag.Graph
-	originalGraph     *dag.Graph
-	componentNodes    []*ComponentNode
-	serviceNodes      []*ServiceNode
 	cache             *valueCache
 	blocks            []*ast.BlockStmt // Most recently loaded blocks, used for writing
 	cm                *controllerMetrics
 	cc                *controllerCollector
 	moduleExportIndex int
+	snapshot          map[string]*ast.BlockStmt // Exports to restore in the next call to Apply.
+	propagations      *edgePropagations         // Updates propagated along each edge.
+
+	// stateMut guards the loaded graph. Apply only swaps in a new graph once
+	// it's evaluated, and holds stateMut just for the swap, so readers see the
+	// previous graph instead of blocking while Apply evaluates. Fields guarded
+	// by stateMut are only written while mut is also held, except for
+	// configBytes, so code holding mut may read them without stateMut.
+	stateMut       sync.RWMutex
+	graph          *dag.Graph
+	originalGraph  *dag.Graph
+	componentNodes []*ComponentNode
+	serviceNodes   []*ServiceNode
+	applied        bool          // Whether the most recent call to Apply loaded its blocks.
+	configBytes    int           // Size of the config most recently passed to Apply.
+	reduceDuration time.Duration // Time spent reducing graph; zero if it wasn't reduced.
+	reduced        bool          // Whether graph is transitively reduced.
 }
 
 // LoaderOptions holds options for creating a Loader.
@@ -54,10 +78,25 @@ type LoaderOptions struct {
 	// ComponentGlobals contains data to use when creating components.
 	ComponentGlobals ComponentGlobals
 
-	Services          []service.Service // Services to load into the DAG.
-	Host              service.Host      // Service host (when running services).
-	ComponentRegistry ComponentRegistry // Registry to search for components.
-	WorkerPool        worker.Pool       // Worker pool to use for async tasks.
+	Services          []service.Service     // Services to load into the DAG.
+	Host              service.Host          // Service host (when running services).
+	ComponentRegistry ComponentRegistry     // Registry to search for components.
+	ComponentPolicy   ComponentPolicy       // Restricts which components may be used.
+	StrictReferences  bool                  // Fail Apply if any non-sink component is unreferenced.
+	StrictShadowing   bool                  // Fail Apply if any component shadows a function or built-in identifier.
+	Functions         func() map[string]any // Custom functions to expose to expressions on each Apply.
+	Lookups           *stdlib.Lookups       // Resolves dns_lookup and srv_lookup; stdlib.DefaultLookups when nil.
+	WorkerPool        worker.Pool           // Worker pool to use for async tasks.
+
+	// Parallelism is the maximum number of nodes of the same dependency level
+	// evaluated concurrently by Apply. Nodes are evaluated one at a time when
+	// Parallelism is less than two.
+	Parallelism int
+
+	// ReduceGraph reports whether the next call to Apply performs a
+	// transitive reduction of its graph. Graphs are always reduced when
+	// ReduceGraph is nil.
+	ReduceGraph func() bool
 }
 
 // NewLoader creates a new Loader. Components built by the Loader will be built
@@ -81,7 +120,13 @@ func NewLoader(opts LoaderOptions) *Loader {
 		services:     services,
 		host:         host,
 		componentReg: reg,
+		policy:       opts.ComponentPolicy,
+		strict:       opts.StrictReferences,
+		strictShadow: opts.StrictShadowing,
+		functions:    opts.Functions,
 		workerPool:   opts.WorkerPool,
+		parallelism:  opts.Parallelism,
+		reduceGraph:  opts.ReduceGraph,
 
 		// This is a reasonable default which should work for most cases. If a component is completely stuck, we would
 		// retry and log an error every 10 seconds, at most.
@@ -94,6 +139,10 @@ func NewLoader(opts LoaderOptions) *Loader {
 		originalGraph: &dag.Graph{},
 		cache:         newValueCache(),
 		cm:            newControllerMetrics(globals.ControllerID),
+		propagations:  newEdgePropagations(),
+	}
+	if opts.Lookups != nil {
+		l.cache.SetLookups(opts.Lookups)
 	}
 	l.cc = newControllerCollector(l, globals.ControllerID)
 
@@ -117,23 +166,105 @@ func NewLoader(opts LoaderOptions) *Loader {
 // The provided parentContext can be used to provide global variables and
 // functions to components. A child context will be constructed from the parent
 // to expose values of other components.
-func (l *Loader) Apply(args map[string]any, componentBlocks []*ast.BlockStmt, configBlocks []*ast.BlockStmt) diag.Diagnostics {
+//
+// The new graph is staged while it's built, and only replaces the previous
+// graph once it's built successfully. If the graph can't be built, such as
+// when a component doesn't exist or components reference each other in a
+// cycle, Apply discards the attempt and the previous graph keeps running
+// unchanged: reused components are pointed back at their previous blocks, and
+// the cached values, module arguments, and functions are restored.
+//
+// Apply returns warnings for components which shadow identifiers such as
+// standard library functions, and for deprecated components and arguments
+// used by a graph which loaded successfully. Warnings follow any errors from
+// evaluating the graph.
+//
+// Once the graph is built, Apply stages its evaluation: every block is
+// evaluated against the values staged for its dependencies, but components,
+// services, and the logging and tracing config aren't updated, and new
+// components are built without being used yet. If ctx is canceled while
+// staging, Apply discards the attempt in the same way, and the previous graph
+// keeps running with the arguments it had before.
+//
+// Apply then commits to the staged graph by evaluating it again, which
+// updates its components and services and swaps in the new graph. Committing
+// always finishes, even if ctx is canceled. Components which fail to evaluate
+// are reported as unhealthy and keep running with their previous arguments,
+// while the rest of the graph is updated.
+//
+// Components, Services, Graph, and the other accessors of the loaded graph
+// don't wait for Apply to finish; they return the previous graph until the
+// new graph replaces it.
+func (l *Loader) Apply(ctx context.Context, args map[string]any, componentBlocks []*ast.BlockStmt, configBlocks []*ast.BlockStmt) diag.Diagnostics {
 	start := time.Now()
 	l.mut.Lock()
 	defer l.mut.Unlock()
 	l.cm.controllerEvaluation.Set(1)
 	defer l.cm.controllerEvaluation.Set(0)
 
+	defer func() { l.cm.loadTime.Observe(time.Since(start).Seconds()) }()
+
+	// Remember the current state of the cache and of existing nodes, which
+	// loading the new graph replaces, in case the attempt is discarded.
+	prev := l.saveApplyState()
+
+	if l.functions != nil {
+		l.cache.SetFunctions(l.functions())
+	}
 	for key, value := range args {
 		l.cache.CacheModuleArgument(key, value)
 	}
 	l.cache.SyncModuleArgs(args)
 
+	wireStart := time.Now()
 	newGraph, diags := l.loadNewGraph(args, componentBlocks, configBlocks)
+	l.cm.loadPhaseTime.WithLabelValues(loadPhaseWire).Observe(time.Since(wireStart).Seconds())
 	if diags.HasErrors() {
+		l.discardApply(prev)
 		return diags
 	}
 
+	// Copy the original graph, this is so we can have access to the original graph for things like displaying a UI or
+	// debug information.
+	originalGraph := newGraph.Clone()
+
+	// Perform a transitive reduction of the graph to clean it up. Skipping it
+	// keeps transitive edges, which doesn't change the order of evaluation,
+	// but dependants may be evaluated once for each path to an updated node.
+	var (
+		reduced        = l.reduceGraph == nil || l.reduceGraph()
+		reduceDuration time.Duration
+	)
+	if reduced {
+		reduceStart := time.Now()
+		dag.Reduce(&newGraph)
+		reduceDuration = time.Since(reduceStart)
+		l.cm.loadPhaseTime.WithLabelValues(loadPhaseReduce).Observe(reduceDuration.Seconds())
+	}
+
+	// Unreferenced components aren't an error by default, but are likely to be
+	// a mistake in the config.
+	unreferenced := UnreferencedComponents(&newGraph)
+	if l.strict && len(unreferenced) > 0 {
+		for i := range unreferenced {
+			unreferenced[i].Severity = diag.SeverityLevelError
+		}
+		l.discardApply(prev)
+		return append(diags, unreferenced...)
+	}
+
+	// Components shadowing identifiers such as standard library functions
+	// make those identifiers impossible to use, which is hard to diagnose
+	// from evaluation errors alone.
+	shadowed := ShadowedReferences(&newGraph, l.cache.FunctionScope())
+	if l.strictShadow && len(shadowed) > 0 {
+		for i := range shadowed {
+			shadowed[i].Severity = diag.SeverityLevelError
+		}
+		l.discardApply(prev)
+		return append(diags, shadowed...)
+	}
+
 	var (
 		components   = make([]*ComponentNode, 0, len(componentBlocks))
 		componentIDs = make([]ComponentID, 0, len(componentBlocks))
@@ -141,7 +272,7 @@ func (l *Loader) Apply(args map[string]any, componentBlocks []*ast.BlockStmt, co
 	)
 
 	tracer := l.tracer.Tracer("")
-	spanCtx, span := tracer.Start(context.Background(), "GraphEvaluate", trace.WithSpanKind(trace.SpanKindInternal))
+	spanCtx, span := tracer.Start(ctx, "GraphEvaluate", trace.WithSpanKind(trace.SpanKindInternal))
 	defer span.End()
 
 	logger := log.With(l.log, "trace_id", span.SpanContext().TraceID())
@@ -152,10 +283,74 @@ func (l *Loader) Apply(args map[string]any, componentBlocks []*ast.BlockStmt, co
 		level.Info(logger).Log("msg", "finished complete graph evaluation", "duration", time.Since(start))
 	}()
 
+	for _, d := range unreferenced {
+		level.Warn(logger).Log("msg", d.Message)
+	}
+	for _, d := range shadowed {
+		level.Warn(logger).Log("msg", d.Message)
+	}
+	deprecated := DeprecatedUsage(&newGraph)
+	for _, d := range deprecated {
+		level.Warn(logger).Log("msg", d.Message)
+	}
+
 	l.cache.ClearModuleExports()
 
-	// Evaluate all the components.
-	_ = dag.WalkTopological(&newGraph, newGraph.Leaves(), func(n dag.Node) error {
+	walk := func(fn func(dag.Node) error) error {
+		if l.parallelism > 1 {
+			return dag.WalkLevels(&newGraph, l.parallelism, fn)
+		}
+		return dag.WalkTopological(&newGraph, newGraph.Leaves(), fn)
+	}
+
+	// walkMut guards components, componentIDs, services, and diags, including
+	// the diagnostics from staging, since nodes are evaluated concurrently
+	// when parallelism is enabled.
+	var walkMut sync.Mutex
+
+	// Stage the evaluation of the new graph first. Staging evaluates every
+	// block without updating components, services, or the logging and
+	// tracing config, so the running graph is left untouched if the load is
+	// canceled.
+	buildStart := time.Now()
+	var stageDiags diag.Diagnostics
+	stageNode := func(n dag.Node) error {
+		// Stop evaluating as soon as the load is canceled.
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		start := time.Now()
+		err := l.stage(ctx, logger, n)
+		if cn, ok := n.(*ComponentNode); ok {
+			cn.buildDuration.Store(time.Since(start))
+		}
+		if err != nil {
+			walkMut.Lock()
+			stageDiags = append(stageDiags, evalDiags(n, err)...)
+			walkMut.Unlock()
+		}
+		return nil
+	}
+	if walkErr := walk(stageNode); walkErr != nil {
+		l.cm.loadPhaseTime.WithLabelValues(loadPhaseBuild).Observe(time.Since(buildStart).Seconds())
+		level.Warn(logger).Log("msg", "discarding canceled graph evaluation", "err", walkErr)
+		l.discardApply(prev)
+		diags = append(diags, stageDiags...)
+		diags.Add(diag.Diagnostic{
+			Severity: diag.SeverityLevelError,
+			Message:  fmt.Sprintf("Load canceled: %s", walkErr),
+		})
+		return diags
+	}
+
+	// Commit to the staged graph by evaluating it again, which updates the
+	// nodes with the values their dependencies exported while committing.
+	// Committing doesn't stop once ctx is canceled, so every node of the new
+	// graph is evaluated. Blocks which fail to evaluate are reported, and
+	// their components keep running with their previous arguments.
+	commitCtx := context.WithoutCancel(ctx)
+	commitNode := func(n dag.Node) error {
 		_, span := tracer.Start(spanCtx, "EvaluateNode", trace.WithSpanKind(trace.SpanKindInternal))
 		span.SetAttributes(attribute.String("node_id", n.NodeID()))
 		defer span.End()
@@ -165,82 +360,179 @@ func (l *Loader) Apply(args map[string]any, componentBlocks []*ast.BlockStmt, co
 			level.Info(logger).Log("msg", "finished node evaluation", "node_id", n.NodeID(), "duration", time.Since(start))
 		}()
 
-		var err error
-
 		switch n := n.(type) {
 		case *ComponentNode:
+			walkMut.Lock()
 			components = append(components, n)
 			componentIDs = append(componentIDs, n.ID())
-
-			if err = l.evaluate(logger, n); err != nil {
-				var evalDiags diag.Diagnostics
-				if errors.As(err, &evalDiags) {
-					diags = append(diags, evalDiags...)
-				} else {
-					diags.Add(diag.Diagnostic{
-						Severity: diag.SeverityLevelError,
-						Message:  fmt.Sprintf("Failed to build component: %s", err),
-						StartPos: ast.StartPos(n.Block()).Position(),
-						EndPos:   ast.EndPos(n.Block()).Position(),
-					})
-				}
-			}
-
+			walkMut.Unlock()
 		case *ServiceNode:
+			walkMut.Lock()
 			services = append(services, n)
+			walkMut.Unlock()
+		}
 
-			if err = l.evaluate(logger, n); err != nil {
-				var evalDiags diag.Diagnostics
-				if errors.As(err, &evalDiags) {
-					diags = append(diags, evalDiags...)
-				} else {
-					diags.Add(diag.Diagnostic{
-						Severity: diag.SeverityLevelError,
-						Message:  fmt.Sprintf("Failed to evaluate service: %s", err),
-						StartPos: ast.StartPos(n.Block()).Position(),
-						EndPos:   ast.EndPos(n.Block()).Position(),
-					})
-				}
-			}
-
-		case BlockNode:
-			if err = l.evaluate(logger, n); err != nil {
-				diags.Add(diag.Diagnostic{
-					Severity: diag.SeverityLevelError,
-					Message:  fmt.Sprintf("Failed to evaluate node for config block: %s", err),
-					StartPos: ast.StartPos(n.Block()).Position(),
-					EndPos:   ast.EndPos(n.Block()).Position(),
-				})
-			}
-			if exp, ok := n.(*ExportConfigNode); ok {
-				l.cache.CacheModuleExportValue(exp.Label(), exp.Value())
-			}
+		bn, ok := n.(BlockNode)
+		if !ok {
+			return nil
+		}
+		err := l.evaluate(commitCtx, logger, bn)
+		if cn, ok := n.(*ComponentNode); ok {
+			cn.buildDuration.Add(time.Since(start))
+		}
+		if exp, ok := n.(*ExportConfigNode); ok {
+			l.cache.CacheModuleExportValue(exp.Label(), exp.Value())
 		}
 
 		// We only use the error for updating the span status; we don't return the
 		// error because we want to evaluate as many nodes as we can.
 		if err != nil {
+			walkMut.Lock()
+			diags = append(diags, evalDiags(n, err)...)
+			walkMut.Unlock()
 			span.SetStatus(codes.Error, err.Error())
 		} else {
 			span.SetStatus(codes.Ok, "")
 		}
 		return nil
-	})
+	}
+	_ = walk(commitNode)
+	l.cm.loadPhaseTime.WithLabelValues(loadPhaseBuild).Observe(time.Since(buildStart).Seconds())
+	if slowest := slowestBuilds(components, slowestBuildsCount); len(slowest) > 0 {
+		level.Info(logger).Log("msg", "slowest component builds", "components", strings.Join(slowest, ", "))
+	}
+
+	removed := l.componentNodes
 
+	l.stateMut.Lock()
 	l.componentNodes = components
 	l.serviceNodes = services
 	l.graph = &newGraph
+	l.originalGraph = originalGraph
+	l.applied = true
+	l.reduced, l.reduceDuration = reduced, reduceDuration
+	l.stateMut.Unlock()
+
+	releaseSingletons(removed, components)
+	l.propagations.Sync(l.graph)
 	l.cache.SyncIDs(componentIDs)
 	l.blocks = componentBlocks
+	l.snapshot = nil
 	if l.globals.OnExportsChange != nil && l.cache.ExportChangeIndex() != l.moduleExportIndex {
 		l.moduleExportIndex = l.cache.ExportChangeIndex()
 		l.globals.OnExportsChange(l.cache.CreateModuleExports())
 	}
-	return diags
+	// Warnings are reported after any errors from evaluating the graph.
+	diags = append(diags, shadowed...)
+	return append(diags, deprecated...)
+}
+
+// applyState is the state of a Loader from before a call to Apply, which is
+// restored if the call is discarded.
+type applyState struct {
+	cache    *valueCache
+	blocks   map[BlockNode]*ast.BlockStmt // Blocks of existing components and services.
+	priority map[*ComponentNode]int
+	tags     map[*ComponentNode][]string
+	labels   map[*ComponentNode]map[string]string
+	expect   map[*ComponentNode]map[string][]string
+	sink     map[*ComponentNode]bool
+}
+
+// saveApplyState returns the current applyState of l. mut must be held when
+// calling saveApplyState.
+func (l *Loader) saveApplyState() applyState {
+	s := applyState{
+		cache:    l.cache.clone(),
+		blocks:   make(map[BlockNode]*ast.BlockStmt, len(l.componentNodes)+len(l.serviceNodes)),
+		priority: make(map[*ComponentNode]int, len(l.componentNodes)),
+		tags:     make(map[*ComponentNode][]string, len(l.componentNodes)),
+		labels:   make(map[*ComponentNode]map[string]string, len(l.componentNodes)),
+		expect:   make(map[*ComponentNode]map[string][]string, len(l.componentNodes)),
+		sink:     make(map[*ComponentNode]bool, len(l.componentNodes)),
+	}
+	for _, cn := range l.componentNodes {
+		s.blocks[cn] = cn.Block()
+		s.priority[cn] = cn.Priority()
+		s.tags[cn] = cn.Tags()
+		s.labels[cn] = cn.MetricLabels()
+		s.expect[cn] = cn.ExpectedTypes()
+		s.sink[cn] = cn.IsSink()
+	}
+	for _, sn := range l.serviceNodes {
+		s.blocks[sn] = sn.Block()
+	}
+	return s
 }
 
-// Cleanup unregisters any existing metrics and optionally stops the worker pool.
+// discardApply reverts a discarded call to Apply. Existing components and
+// services are pointed back at their previous blocks and meta-arguments, and
+// components they staged are dropped. The cache is restored, which drops
+// components created by the call and restores the previous module arguments,
+// exports, and functions. The call is then reported as not applied by
+// Applied. mut must be held when calling discardApply.
+func (l *Loader) discardApply(prev applyState) {
+	for n, block := range prev.blocks {
+		switch n := n.(type) {
+		case *ComponentNode:
+			n.UpdateBlock(block)
+			n.setPriority(prev.priority[n])
+			n.setTags(prev.tags[n])
+			n.setMetricLabels(prev.labels[n])
+			n.setExpectedTypes(prev.expect[n])
+			n.setSink(prev.sink[n])
+			n.discardStage()
+		case *ServiceNode:
+			n.UpdateBlock(block)
+		}
+	}
+	l.cache.restore(prev.cache)
+
+	l.stateMut.Lock()
+	l.applied = false
+	l.stateMut.Unlock()
+
+	// The previous exports were already reported, so they don't need to be
+	// reported again.
+	l.moduleExportIndex = l.cache.ExportChangeIndex()
+}
+
+// ObserveParseDuration records the time spent parsing the config which is
+// about to be passed to Apply.
+func (l *Loader) ObserveParseDuration(d time.Duration) {
+	l.cm.loadPhaseTime.WithLabelValues(loadPhaseParse).Observe(d.Seconds())
+}
+
+// ObserveConfigSize records the size in bytes of the config which is about
+// to be passed to Apply, which is reported by Stats.
+func (l *Loader) ObserveConfigSize(bytes int) {
+	l.stateMut.Lock()
+	defer l.stateMut.Unlock()
+	l.configBytes = bytes
+}
+
+// ObserveUnchangedLoad records a load which was skipped because its config
+// didn't change since the last successful call to Apply.
+func (l *Loader) ObserveUnchangedLoad() {
+	l.cm.unchangedLoads.Inc()
+}
+
+// ObserveCoalescedReload records a reload which was replaced by a newer
+// reload before it was applied.
+func (l *Loader) ObserveCoalescedReload() {
+	l.cm.coalescedReloads.Inc()
+}
+
+// ObserveReload records a reload triggered by trigger, which either
+// "reloaded", was "unchanged", or "failed" as given by result.
+func (l *Loader) ObserveReload(trigger, result string) {
+	l.cm.reloads.WithLabelValues(trigger, result).Inc()
+}
+
+// Cleanup releases the singleton components held by the loaded components,
+// unregisters any existing metrics and optionally stops the worker pool.
 func (l *Loader) Cleanup(stopWorkerPool bool) {
+	releaseSingletons(l.Components(), nil)
 	if stopWorkerPool {
 		l.workerPool.Stop()
 	}
@@ -251,6 +543,20 @@ func (l *Loader) Cleanup(stopWorkerPool bool) {
 	l.globals.Registerer.Unregister(l.cc)
 }
 
+// releaseSingletons releases the singleton components held by the nodes
+// which aren't in keep, such as nodes removed from the graph.
+func releaseSingletons(nodes []*ComponentNode, keep []*ComponentNode) {
+	kept := make(map[*ComponentNode]struct{}, len(keep))
+	for _, cn := range keep {
+		kept[cn] = struct{}{}
+	}
+	for _, cn := range nodes {
+		if _, ok := kept[cn]; !ok {
+			cn.releaseSingleton()
+		}
+	}
+}
+
 // loadNewGraph creates a new graph from the provided blocks and validates it.
 func (l *Loader) loadNewGraph(args map[string]any, componentBlocks []*ast.BlockStmt, configBlocks []*ast.BlockStmt) (dag.Graph, diag.Diagnostics) {
 	var g dag.Graph
@@ -281,12 +587,6 @@ func (l *Loader) loadNewGraph(args map[string]any, componentBlocks []*ast.BlockS
 		return g, diags
 	}
 
-	// Copy the original graph, this is so we can have access to the original graph for things like displaying a UI or
-	// debug information.
-	l.originalGraph = g.Clone()
-	// Perform a transitive reduction of the graph to clean it up.
-	dag.Reduce(&g)
-
 	return g, diags
 }
 
@@ -353,7 +653,7 @@ func (l *Loader) populateServiceNodes(g *dag.Graph, serviceBlocks []*ast.BlockSt
 		if node.Block() != nil {
 			diags.Add(diag.Diagnostic{
 				Severity: diag.SeverityLevelError,
-				Message:  fmt.Sprintf("duplicate definition of %q", blockID),
+				Message:  duplicateBlockMessage("service", blockID, node.Block(), block),
 				StartPos: ast.StartPos(block).Position(),
 				EndPos:   ast.EndPos(block).Position(),
 			})
@@ -374,15 +674,19 @@ func (l *Loader) populateConfigBlockNodes(args map[string]any, g *dag.Graph, con
 	)
 
 	for _, block := range configBlocks {
+		if labelDiags := validateBlockLabel(block, configBlockLabels[block.GetBlockName()]); labelDiags.HasErrors() {
+			diags = append(diags, labelDiags...)
+			continue
+		}
+
 		node, newConfigNodeDiags := NewConfigNode(block, l.globals)
 		diags = append(diags, newConfigNodeDiags...)
 
-		if g.GetByID(node.NodeID()) != nil {
-			configBlockStartPos := ast.StartPos(block).Position()
+		if orig, ok := g.GetByID(node.NodeID()).(BlockNode); ok {
 			diags.Add(diag.Diagnostic{
 				Severity: diag.SeverityLevelError,
-				Message:  fmt.Sprintf("%q block already declared at %s", node.NodeID(), configBlockStartPos),
-				StartPos: configBlockStartPos,
+				Message:  duplicateBlockMessage("block", node.NodeID(), orig.Block(), block),
+				StartPos: ast.StartPos(block).Position(),
 				EndPos:   ast.EndPos(block).Position(),
 			})
 
@@ -426,10 +730,44 @@ func (l *Loader) populateComponentNodes(g *dag.Graph, componentBlocks []*ast.Blo
 		var c *ComponentNode
 		id := BlockComponentID(block).String()
 
+		// Disabled blocks are skipped before checking for duplicates so that
+		// alternative definitions of the same component can be toggled.
+		enabled, block, enabledDiags := evaluateEnabled(block, l.cache.FunctionScope())
+		diags = append(diags, enabledDiags...)
+		if !enabled {
+			continue
+		}
+		priority, block, priorityDiags := evaluatePriority(block, l.cache.FunctionScope())
+		diags = append(diags, priorityDiags...)
+		if priorityDiags.HasErrors() {
+			continue
+		}
+		registration, registered := l.componentReg.Get(block.GetBlockName())
+		tags, block, tagsDiags := evaluateTags(block, registration.Args, l.cache.FunctionScope())
+		diags = append(diags, tagsDiags...)
+		if tagsDiags.HasErrors() {
+			continue
+		}
+		labels, block, labelsDiags := evaluateMetricLabels(block, l.cache.FunctionScope())
+		diags = append(diags, labelsDiags...)
+		if labelsDiags.HasErrors() {
+			continue
+		}
+		expect, block, expectDiags := evaluateExpectTypes(block, l.cache.FunctionScope())
+		diags = append(diags, expectDiags...)
+		if expectDiags.HasErrors() {
+			continue
+		}
+		sink, block, sinkDiags := evaluateSink(block, registration, l.cache.FunctionScope())
+		diags = append(diags, sinkDiags...)
+		if sinkDiags.HasErrors() {
+			continue
+		}
+
 		if orig, redefined := blockMap[id]; redefined {
 			diags.Add(diag.Diagnostic{
 				Severity: diag.SeverityLevelError,
-				Message:  fmt.Sprintf("Component %s already declared at %s", id, ast.StartPos(orig).Position()),
+				Message:  duplicateBlockMessage("component", id, orig, block),
 				StartPos: block.NamePos.Position(),
 				EndPos:   block.NamePos.Add(len(id) - 1).Position(),
 			})
@@ -444,8 +782,7 @@ func (l *Loader) populateComponentNodes(g *dag.Graph, componentBlocks []*ast.Blo
 			c.UpdateBlock(block)
 		} else {
 			componentName := block.GetBlockName()
-			registration, exists := l.componentReg.Get(componentName)
-			if !exists {
+			if !registered {
 				diags.Add(diag.Diagnostic{
 					Severity: diag.SeverityLevelError,
 					Message:  fmt.Sprintf("Unrecognized component name %q", componentName),
@@ -455,19 +792,29 @@ func (l *Loader) populateComponentNodes(g *dag.Graph, componentBlocks []*ast.Blo
 				continue
 			}
 
-			if block.Label == "" {
+			if !l.policy.Allows(componentName) {
 				diags.Add(diag.Diagnostic{
 					Severity: diag.SeverityLevelError,
-					Message:  fmt.Sprintf("Component %q must have a label", componentName),
+					Message:  fmt.Sprintf("Component %q is not allowed by the component policy", componentName),
 					StartPos: block.NamePos.Position(),
 					EndPos:   block.NamePos.Add(len(componentName) - 1).Position(),
 				})
 				continue
 			}
 
+			if labelDiags := validateBlockLabel(block, labelRequired); labelDiags.HasErrors() {
+				diags = append(diags, labelDiags...)
+				continue
+			}
+
 			// Create a new component
 			c = NewComponentNode(l.globals, registration, block)
 		}
+		c.setPriority(priority)
+		c.setTags(tags)
+		c.setMetricLabels(labels)
+		c.setExpectedTypes(expect)
+		c.setSink(sink)
 
 		g.Add(c)
 	}
@@ -475,6 +822,13 @@ func (l *Loader) populateComponentNodes(g *dag.Graph, componentBlocks []*ast.Blo
 	return diags
 }
 
+// duplicateBlockMessage returns an error message for block redefining the
+// same ID as orig. The message names the location of both blocks so that
+// duplicates split across multiple files are easy to find.
+func duplicateBlockMessage(kind, id string, orig, block *ast.BlockStmt) string {
+	return fmt.Sprintf("duplicate %s %q defined at %s and %s", kind, id, ast.StartPos(orig).Position(), ast.StartPos(block).Position())
+}
+
 // Wire up all the related nodes
 func (l *Loader) wireGraphEdges(g *dag.Graph) diag.Diagnostics {
 	var diags diag.Diagnostics
@@ -498,11 +852,15 @@ func (l *Loader) wireGraphEdges(g *dag.Graph) diag.Diagnostics {
 		}
 
 		// Finally, wire component references.
-		refs, nodeDiags := ComponentReferences(n, g)
+		refs, nodeDiags := componentReferences(n, g, l.cache.FunctionScope())
 		for _, ref := range refs {
 			g.AddEdge(dag.Edge{From: n, To: ref.Target})
 		}
 		diags = append(diags, nodeDiags...)
+
+		if cn, ok := n.(*ComponentNode); ok {
+			diags = append(diags, validateExpectedTypes(cn, g)...)
+		}
 	}
 
 	return diags
@@ -511,35 +869,63 @@ func (l *Loader) wireGraphEdges(g *dag.Graph) diag.Diagnostics {
 // Variables returns the Variables the Loader exposes for other Flow components
 // to reference.
 func (l *Loader) Variables() map[string]interface{} {
-	return l.cache.BuildContext().Variables
+	return l.cache.BuildContext(context.Background()).Variables
+}
+
+// Applied reports whether the most recent call to Apply loaded its blocks.
+// Blocks aren't loaded when the graph can't be constructed, such as when a
+// component doesn't exist or components reference each other in a cycle, or
+// when the call was canceled before it committed to the new graph. Blocks
+// which failed to evaluate are still loaded.
+func (l *Loader) Applied() bool {
+	l.stateMut.RLock()
+	defer l.stateMut.RUnlock()
+	return l.applied
 }
 
 // Components returns the current set of loaded components.
 func (l *Loader) Components() []*ComponentNode {
-	l.mut.RLock()
-	defer l.mut.RUnlock()
+	l.stateMut.RLock()
+	defer l.stateMut.RUnlock()
 	return l.componentNodes
 }
 
+// StartupOrder returns the current set of components in the order they
+// should be started. Components are started after the components they depend
+// on, and the priority meta-argument orders components which are otherwise
+// ready to start at the same time.
+func (l *Loader) StartupOrder() []*ComponentNode {
+	l.stateMut.RLock()
+	defer l.stateMut.RUnlock()
+	return startupOrder(l.graph)
+}
+
 // Services returns the current set of service nodes.
 func (l *Loader) Services() []*ServiceNode {
-	l.mut.RLock()
-	defer l.mut.RUnlock()
+	l.stateMut.RLock()
+	defer l.stateMut.RUnlock()
 	return l.serviceNodes
 }
 
 // Graph returns a copy of the DAG managed by the Loader.
 func (l *Loader) Graph() *dag.Graph {
-	l.mut.RLock()
-	defer l.mut.RUnlock()
+	l.stateMut.RLock()
+	defer l.stateMut.RUnlock()
 	return l.graph.Clone()
 }
 
+// EdgePropagations returns the number of times an update of e.To was
+// propagated to e.From, which depends on it. Counts are kept across calls to
+// Apply for as long as the edge exists.
+func (l *Loader) EdgePropagations(e dag.Edge) uint64 {
+	return l.propagations.Get(e)
+}
+
 // OriginalGraph returns a copy of the graph before Reduce was called. This can be used if you want to show a UI of the
 // original graph before the reduce function was called.
 func (l *Loader) OriginalGraph() *dag.Graph {
-	l.mut.RLock()
-	defer l.mut.RUnlock()
+	l.stateMut.RLock()
+	defer l.stateMut.RUnlock()
 	return l.originalGraph.Clone()
 }
 
@@ -554,7 +940,7 @@ func (l *Loader) EvaluateDependants(ctx context.Context, updatedNodes []*Compone
 		return
 	}
 	tracer := l.tracer.Tracer("")
-	spanCtx, span := tracer.Start(context.Background(), "SubmitDependantsForEvaluation", trace.WithSpanKind(trace.SpanKindInternal))
+	spanCtx, span := tracer.Start(ctx, "SubmitDependantsForEvaluation", trace.WithSpanKind(trace.SpanKindInternal))
 	span.SetAttributes(attribute.Int("originators_count", len(updatedNodes)))
 	span.SetStatus(codes.Ok, "dependencies submitted for evaluation")
 	defer span.End()
@@ -562,19 +948,11 @@ func (l *Loader) EvaluateDependants(ctx context.Context, updatedNodes []*Compone
 	l.cm.controllerEvaluation.Set(1)
 	defer l.cm.controllerEvaluation.Set(0)
 
+	// Only hold mut while collecting the dependants. Submitting them may wait
+	// for the worker pool, which must not block Apply or other readers.
 	l.mut.RLock()
-	defer l.mut.RUnlock()
-
-	dependenciesToParentsMap := make(map[dag.Node]*ComponentNode)
-	for _, parent := range updatedNodes {
-		// Make sure we're in-sync with the current exports of parent.
-		l.cache.CacheExports(parent.ID(), parent.Exports())
-		// We collect all nodes directly incoming to parent.
-		_ = dag.WalkIncomingNodes(l.graph, parent, func(n dag.Node) error {
-			dependenciesToParentsMap[n] = parent
-			return nil
-		})
-	}
+	dependenciesToParentsMap := l.dependantsOf(updatedNodes)
+	l.mut.RUnlock()
 
 	// Submit all dependencies for asynchronous evaluation.
 	// During evaluation, if a node's exports change, Flow will add it to updated nodes queue (controller.Queue) and
@@ -622,6 +1000,60 @@ func (l *Loader) EvaluateDependants(ctx context.Context, updatedNodes []*Compone
 	l.cm.evaluationQueueSize.Set(float64(l.workerPool.QueueSize()))
 }
 
+// EvaluateDependantsInOrder evaluates the components which depend directly on
+// components in updatedNodes one at a time in the calling goroutine, ordered
+// by their node IDs, and returns the IDs of the evaluated nodes in order.
+//
+// Unlike EvaluateDependants, every evaluation has finished when
+// EvaluateDependantsInOrder returns, which makes propagation deterministic
+// for tests. Nodes whose exports change are reported through
+// OnComponentUpdate like with EvaluateDependants.
+func (l *Loader) EvaluateDependantsInOrder(ctx context.Context, updatedNodes []*ComponentNode) []string {
+	if len(updatedNodes) == 0 {
+		return nil
+	}
+	tracer := l.tracer.Tracer("")
+
+	l.mut.RLock()
+	dependenciesToParentsMap := l.dependantsOf(updatedNodes)
+	l.mut.RUnlock()
+
+	dependants := make([]dag.Node, 0, len(dependenciesToParentsMap))
+	for n := range dependenciesToParentsMap {
+		dependants = append(dependants, n)
+	}
+	sort.Slice(dependants, func(i, j int) bool { return dependants[i].NodeID() < dependants[j].NodeID() })
+
+	evaluated := make([]string, 0, len(dependants))
+	for _, n := range dependants {
+		if ctx.Err() != nil {
+			break
+		}
+		l.concurrentEvalFn(n, ctx, tracer, dependenciesToParentsMap[n])
+		evaluated = append(evaluated, n.NodeID())
+	}
+	return evaluated
+}
+
+// dependantsOf caches the exports and health of updatedNodes and returns the
+// nodes which depend directly on them, mapped to the updated node they depend
+// on. mut must be held when calling dependantsOf.
+func (l *Loader) dependantsOf(updatedNodes []*ComponentNode) map[dag.Node]*ComponentNode {
+	dependenciesToParentsMap := make(map[dag.Node]*ComponentNode)
+	for _, parent := range updatedNodes {
+		// Make sure we're in-sync with the current exports of parent.
+		l.cache.CacheExports(parent.ID(), parent.Exports())
+		l.cacheHealth(parent)
+		// We collect all nodes directly incoming to parent.
+		_ = dag.WalkIncomingNodes(l.graph, parent, func(n dag.Node) error {
+			dependenciesToParentsMap[n] = parent
+			l.propagations.Inc(n, parent)
+			return nil
+		})
+	}
+	return dependenciesToParentsMap
+}
+
 // concurrentEvalFn returns a function that evaluates a node and updates the cache. This function can be submitted to
 // a worker pool for asynchronous evaluation.
 func (l *Loader) concurrentEvalFn(n dag.Node, spanCtx context.Context, tracer trace.Tracer, parent *ComponentNode) {
@@ -640,11 +1072,20 @@ func (l *Loader) concurrentEvalFn(n dag.Node, spanCtx context.Context, tracer tr
 	var err error
 	switch n := n.(type) {
 	case BlockNode:
-		ectx := l.cache.BuildContext()
+		// Hold the loader lock for reading while evaluating the node, so it
+		// isn't evaluated against a block staged by Apply before Apply
+		// commits to it. Dependants are still evaluated concurrently.
+		l.mut.RLock()
+		ectx := l.cache.BuildContext(spanCtx)
 		evalErr := n.Evaluate(ectx)
 
-		// Only obtain loader lock after we have evaluated the node, allowing for concurrent evaluation.
-		l.mut.RLock()
+		// Evaluating a dependant may change its health, which its own
+		// dependants need to be re-evaluated for. This must be checked before
+		// postEvaluate caches the new health.
+		if cn, ok := n.(*ComponentNode); ok {
+			cn.CheckHealth()
+		}
+
 		err = l.postEvaluate(l.log, n, evalErr)
 
 		// Additional post-evaluation steps necessary for module exports.
@@ -675,14 +1116,80 @@ func (l *Loader) concurrentEvalFn(n dag.Node, spanCtx context.Context, tracer tr
 	}
 }
 
+// stage evaluates n for Apply before it commits to a new graph. Components
+// and stagedNodes are staged, which leaves the running graph untouched, and
+// other nodes are evaluated normally. The cache is updated with the staged
+// values, so dependants of n are staged against them. Errors aren't logged,
+// since committing the graph evaluates n again. mut must be held when calling
+// stage.
+func (l *Loader) stage(ctx context.Context, logger log.Logger, n dag.Node) error {
+	ectx := l.cache.BuildContext(ctx)
+
+	switch n := n.(type) {
+	case *ComponentNode:
+		if restored, err := l.restoreExports(n); err != nil {
+			level.Warn(logger).Log("msg", "discarding incompatible exports snapshot", "node_id", n.NodeID(), "err", err)
+		} else if restored {
+			level.Info(logger).Log("msg", "restored exports from snapshot", "node_id", n.NodeID())
+		}
+
+		args, err := n.stage(ectx)
+		l.cache.CacheArguments(n.ID(), args)
+		l.cache.CacheExports(n.ID(), n.Exports())
+		l.cacheHealth(n)
+		return err
+	case stagedNode:
+		return n.stage(ectx)
+	case BlockNode:
+		return l.postEvaluate(log.NewNopLogger(), n, n.Evaluate(ectx))
+	}
+	return nil
+}
+
+// evalDiags returns the diagnostics reported by Apply for err from
+// evaluating n.
+func evalDiags(n dag.Node, err error) diag.Diagnostics {
+	var diags diag.Diagnostics
+	if errors.As(err, &diags) {
+		return diags
+	}
+
+	format := "Failed to evaluate node for config block: %s"
+	switch n.(type) {
+	case *ComponentNode:
+		format = "Failed to build component: %s"
+	case *ServiceNode:
+		format = "Failed to evaluate service: %s"
+	}
+	var block *ast.BlockStmt
+	if bn, ok := n.(BlockNode); ok {
+		block = bn.Block()
+	}
+	diags.Add(diag.Diagnostic{
+		Severity: diag.SeverityLevelError,
+		Message:  fmt.Sprintf(format, err),
+		StartPos: ast.StartPos(block).Position(),
+		EndPos:   ast.EndPos(block).Position(),
+	})
+	return diags
+}
+
 // evaluate constructs the final context for the BlockNode and
-// evaluates it. mut must be held when calling evaluate.
-func (l *Loader) evaluate(logger log.Logger, bn BlockNode) error {
-	ectx := l.cache.BuildContext()
+// evaluates it, canceling DNS lookups once ctx is canceled. mut must be held
+// when calling evaluate.
+func (l *Loader) evaluate(ctx context.Context, logger log.Logger, bn BlockNode) error {
+	ectx := l.cache.BuildContext(ctx)
 	err := bn.Evaluate(ectx)
 	return l.postEvaluate(logger, bn, err)
 }
 
+// cacheHealth caches the current health of cn for expressions referencing it.
+func (l *Loader) cacheHealth(cn *ComponentNode) {
+	h := cn.CurrentHealth()
+	cn.cachedHealth.Store(uint32(h.Health))
+	l.cache.CacheHealth(cn.ID(), h)
+}
+
 // postEvaluate is called after a node has been evaluated. It updates the caches and logs any errors.
 // mut must be held when calling postEvaluate.
 func (l *Loader) postEvaluate(logger log.Logger, bn BlockNode, err error) error {
@@ -692,8 +1199,9 @@ func (l *Loader) postEvaluate(logger log.Logger, bn BlockNode, err error) error
 		// change when a component gets re-evaluated. We also want to cache the arguments and exports in case of an error
 		l.cache.CacheArguments(c.ID(), c.Arguments())
 		l.cache.CacheExports(c.ID(), c.Exports())
+		l.cacheHealth(c)
 	case *ArgumentConfigNode:
-		if _, found := l.cache.moduleArguments[c.Label()]; !found {
+		if !l.cache.HasModuleArgument(c.Label()) {
 			if c.Optional() {
 				l.cache.CacheModuleArgument(c.Label(), c.Default())
 			} else {
@@ -705,7 +1213,7 @@ func (l *Loader) postEvaluate(logger log.Logger, bn BlockNode, err error) error
 	}
 
 	if err != nil {
-		level.Error(logger).Log("msg", "failed to evaluate config", "node", bn.NodeID(), "err", err)
+		level.Error(logger).Log("msg", "failed to evaluate config", "node_id", bn.NodeID(), "err", err)
 		return err
 	}
 	return nil
@@ -728,3 +1236,27 @@ func (l *Loader) isModule() bool {
 	// Either 1 of these checks is technically sufficient but let's be extra careful.
 	return l.globals.OnExportsChange != nil && l.globals.ControllerID != ""
 }
+
+// slowestBuildsCount is the number of components logged after a load as the
+// slowest to build.
+const slowestBuildsCount = 5
+
+// slowestBuilds returns up to n of the provided components which took the
+// longest to build in the most recent load, formatted as "ID (duration)" and
+// sorted from slowest to fastest.
+func slowestBuilds(components []*ComponentNode, n int) []string {
+	sorted := make([]*ComponentNode, len(components))
+	copy(sorted, components)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].BuildDuration() > sorted[j].BuildDuration()
+	})
+	if len(sorted) > n {
+		sorted = sorted[:n]
+	}
+
+	res := make([]string, 0, len(sorted))
+	for _, cn := range sorted {
+		res = append(res, fmt.Sprintf("%s (%s)", cn.NodeID(), cn.BuildDuration()))
+	}
+	return res
+}
@@ -0,0 +1,47 @@
+package controller
+
+import "time"
+
+// Approximate memory retained by each node and edge of a graph, in addition
+// to the config they were loaded from. Nodes retain their block, evaluator,
+// arguments, exports, and metrics registry; edges retain entries in the
+// adjacency maps of the graph. The values are rough averages and don't
+// account for memory used by running components.
+const (
+	estimatedNodeBytes = 4096
+	estimatedEdgeBytes = 128
+)
+
+// GraphStats describes the size of the graph loaded by a Loader.
+type GraphStats struct {
+	Nodes       int // Number of nodes in the graph.
+	Edges       int // Number of edges in the graph, before it's reduced.
+	ConfigBytes int // Size of the loaded config.
+
+	Reduced        bool          // Whether the graph was transitively reduced.
+	ReducedEdges   int           // Number of edges in the graph used for evaluation.
+	ReduceDuration time.Duration // Time spent reducing the graph; zero if it wasn't reduced.
+
+	// EstimatedBytes is a cheap approximation of the memory retained by the
+	// graph: ConfigBytes plus a fixed overhead per node and edge. It doesn't
+	// include memory used by running components.
+	EstimatedBytes int
+}
+
+// Stats returns the size of the graph most recently loaded by Apply.
+func (l *Loader) Stats() GraphStats {
+	l.stateMut.RLock()
+	defer l.stateMut.RUnlock()
+
+	stats := GraphStats{ConfigBytes: l.configBytes}
+	if l.originalGraph != nil {
+		stats.Nodes = l.originalGraph.NodeCount()
+		stats.Edges = l.originalGraph.EdgeCount()
+	}
+	if l.graph != nil {
+		stats.ReducedEdges = l.graph.EdgeCount()
+	}
+	stats.Reduced, stats.ReduceDuration = l.reduced, l.reduceDuration
+	stats.EstimatedBytes = stats.ConfigBytes + stats.Nodes*estimatedNodeBytes + stats.Edges*estimatedEdgeBytes
+	return stats
+}
@@ -1,12 +1,15 @@
 package controller_test
 
 import (
+	"context"
 	"errors"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/discovery/consul"
 	"github.com/grafana/agent/pkg/flow/internal/controller"
 	"github.com/grafana/agent/pkg/flow/internal/dag"
 	"github.com/grafana/agent/pkg/flow/logging"
@@ -16,8 +19,9 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/otel/trace/noop"
+	"golang.org/x/exp/maps"
 
-	_ "github.com/grafana/agent/pkg/flow/internal/testcomponents" // Include test components
+	"github.com/grafana/agent/pkg/flow/internal/testcomponents" // Include test components
 )
 
 func TestLoader(t *testing.T) {
@@ -96,6 +100,68 @@ func TestLoader(t *testing.T) {
 		requireGraph(t, l.Graph(), testGraphDefinition)
 	})
 
+	t.Run("Load records timing metrics", func(t *testing.T) {
+		reg := prometheus.NewRegistry()
+		opts := newLoaderOptions()
+		opts.ComponentGlobals.Registerer = reg
+
+		l := controller.NewLoader(opts)
+		l.ObserveParseDuration(time.Millisecond)
+		diags := applyFromContent(t, l, []byte(testFile), []byte(testConfig))
+		require.NoError(t, diags.ErrorOrNil())
+
+		families, err := reg.Gather()
+		require.NoError(t, err)
+
+		phases := map[string]uint64{}
+		var loads uint64
+		for _, mf := range families {
+			switch mf.GetName() {
+			case "agent_component_controller_load_seconds":
+				loads = mf.GetMetric()[0].GetHistogram().GetSampleCount()
+			case "agent_component_controller_load_phase_seconds":
+				for _, m := range mf.GetMetric() {
+					phases[m.GetLabel()[1].GetValue()] = m.GetHistogram().GetSampleCount()
+				}
+			}
+		}
+		require.Equal(t, uint64(1), loads)
+		require.Equal(t, map[string]uint64{"parse": 1, "wire": 1, "reduce": 1, "build": 1}, phases)
+	})
+
+	t.Run("Load records component build durations", func(t *testing.T) {
+		reg := prometheus.NewRegistry()
+		opts := newLoaderOptions()
+		opts.ComponentGlobals.Registerer = reg
+
+		l := controller.NewLoader(opts)
+		diags := applyFromContent(t, l, []byte(testFile), nil)
+		require.NoError(t, diags.ErrorOrNil())
+
+		for _, cn := range l.Components() {
+			require.Positive(t, cn.BuildDuration(), "missing build duration for %s", cn.NodeID())
+		}
+
+		families, err := reg.Gather()
+		require.NoError(t, err)
+
+		var builds []string
+		for _, mf := range families {
+			if mf.GetName() != "agent_flow_component_build_seconds" {
+				continue
+			}
+			for _, m := range mf.GetMetric() {
+				builds = append(builds, m.GetLabel()[0].GetValue())
+			}
+		}
+		require.ElementsMatch(t, []string{
+			"testcomponents.tick.ticker",
+			"testcomponents.passthrough.static",
+			"testcomponents.passthrough.ticker",
+			"testcomponents.passthrough.forwarded",
+		}, builds)
+	})
+
 	t.Run("Copy existing components and delete stale ones", func(t *testing.T) {
 		startFile := `
 			// Component that should be copied over to the new graph
@@ -122,6 +188,41 @@ func TestLoader(t *testing.T) {
 		require.Nil(t, newGraph.GetByID("testcomponents.tick.remove_me")) // The new graph shouldn't have the old node
 	})
 
+	t.Run("Failed load keeps the previous graph", func(t *testing.T) {
+		l := controller.NewLoader(newLoaderOptions())
+		diags := applyFromContent(t, l, []byte(testFile), []byte(testConfig))
+		require.NoError(t, diags.ErrorOrNil())
+
+		origGraph := l.Graph()
+		origBlock := origGraph.GetByID("testcomponents.passthrough.static").(*controller.ComponentNode).Block()
+
+		// The new config changes an existing component, but fails to build
+		// since it references a component which doesn't exist.
+		invalidFile := `
+			testcomponents.passthrough "static" {
+				input = "goodbye, world!"
+			}
+
+			testcomponents.passthrough "broken" {
+				input = testcomponents.tick.doesnotexist.tick_time
+			}
+		`
+		diags = applyFromContent(t, l, []byte(invalidFile), nil)
+		require.ErrorContains(t, diags.ErrorOrNil(), `component "testcomponents.tick.doesnotexist.tick_time" does not exist`)
+		require.False(t, l.Applied())
+
+		requireGraph(t, l.Graph(), testGraphDefinition)
+		requireGraph(t, l.OriginalGraph(), testGraphDefinition)
+		require.Len(t, l.Components(), 4)
+		static := l.Graph().GetByID("testcomponents.passthrough.static").(*controller.ComponentNode)
+		require.Same(t, origBlock, static.Block())
+
+		// A valid load afterwards is applied as usual.
+		diags = applyFromContent(t, l, []byte(testFile), []byte(testConfig))
+		require.NoError(t, diags.ErrorOrNil())
+		require.True(t, l.Applied())
+	})
+
 	t.Run("Load with invalid components", func(t *testing.T) {
 		invalidFile := `
 			doesnotexist "bad_component" {
@@ -132,6 +233,54 @@ func TestLoader(t *testing.T) {
 		require.ErrorContains(t, diags.ErrorOrNil(), `Unrecognized component name "doesnotexist`)
 	})
 
+	t.Run("Block labels must match their block", func(t *testing.T) {
+		tt := []struct {
+			name       string
+			components string
+			config     string
+			expect     string
+		}{
+			{
+				name:       "missing component label",
+				components: `testcomponents.passthrough { input = "a" }`,
+				expect:     "testcomponents.passthrough requires a label",
+			},
+			{
+				name:   "missing argument label",
+				config: `argument { optional = true }`,
+				expect: "argument requires a label",
+			},
+			{
+				name:   "extra logging label",
+				config: `logging "debug" { level = "debug" }`,
+				expect: `logging doesn't support a label, got "debug"`,
+			},
+			{
+				name:   "extra tracing label",
+				config: `tracing "default" { }`,
+				expect: `tracing doesn't support a label, got "default"`,
+			},
+			{
+				name:       "correct labels",
+				components: `testcomponents.passthrough "a" { input = "a" }`,
+				config:     `logging { level = "debug" }`,
+			},
+		}
+
+		for _, tc := range tt {
+			t.Run(tc.name, func(t *testing.T) {
+				l := controller.NewLoader(newLoaderOptions())
+				diags := applyFromContent(t, l, []byte(tc.components), []byte(tc.config))
+				if tc.expect == "" {
+					require.NoError(t, diags.ErrorOrNil())
+					return
+				}
+				require.Len(t, diags, 1)
+				require.Equal(t, tc.expect, diags[0].Message)
+			})
+		}
+	})
+
 	t.Run("Partial load with invalid reference", func(t *testing.T) {
 		invalidFile := `
 			testcomponents.tick "ticker" {
@@ -156,6 +305,522 @@ func TestLoader(t *testing.T) {
 		})
 	})
 
+	t.Run("Disabled components are removed from the graph", func(t *testing.T) {
+		t.Setenv("TEST_USE_TICKER", "false")
+
+		file := `
+			testcomponents.tick "ticker" {
+				enabled   = env("TEST_USE_TICKER") == "true"
+				frequency = "1s"
+			}
+
+			testcomponents.passthrough "source" {
+				enabled = env("TEST_USE_TICKER") == "true"
+				input   = testcomponents.tick.ticker.tick_time
+			}
+
+			testcomponents.passthrough "source" {
+				enabled = env("TEST_USE_TICKER") != "true"
+				input   = "static"
+			}
+
+			testcomponents.passthrough "forwarded" {
+				input = testcomponents.passthrough.source.output
+			}
+		`
+		l := controller.NewLoader(newLoaderOptions())
+		diags := applyFromContent(t, l, []byte(file), nil)
+		require.NoError(t, diags.ErrorOrNil())
+
+		requireGraph(t, l.Graph(), graphDefinition{
+			Nodes: []string{
+				"testcomponents.passthrough.source",
+				"testcomponents.passthrough.forwarded",
+				"logging",
+				"tracing",
+			},
+			OutEdges: []edge{
+				{From: "testcomponents.passthrough.forwarded", To: "testcomponents.passthrough.source"},
+			},
+		})
+		source := l.Graph().GetByID("testcomponents.passthrough.source").(*controller.ComponentNode)
+		require.Equal(t, "static", source.Arguments().(testcomponents.PassthroughConfig).Input)
+	})
+
+	t.Run("Enabled must be a constant bool", func(t *testing.T) {
+		file := `
+			testcomponents.tick "ticker" {
+				frequency = "1s"
+			}
+
+			testcomponents.passthrough "static" {
+				enabled = testcomponents.tick.ticker.frequency != ""
+				input   = "static"
+			}
+		`
+		l := controller.NewLoader(newLoaderOptions())
+		diags := applyFromContent(t, l, []byte(file), nil)
+		require.ErrorContains(t, diags.ErrorOrNil(), `identifier "testcomponents" does not exist`)
+	})
+
+	t.Run("Enabled must be deterministic", func(t *testing.T) {
+		file := `
+			testcomponents.passthrough "static" {
+				enabled = random_id(1) != ""
+				input   = "static"
+			}
+		`
+		l := controller.NewLoader(newLoaderOptions())
+		diags := applyFromContent(t, l, []byte(file), nil)
+		require.ErrorContains(t, diags.ErrorOrNil(), `random_id can't be called from "enabled" because it doesn't always return the same value`)
+	})
+
+	t.Run("Flow stdlib functions", func(t *testing.T) {
+		file := `
+			testcomponents.passthrough "static" {
+				input = url_parse(url_canonicalize("HTTP://LOCALHOST:80")).hostname
+			}
+		`
+		l := controller.NewLoader(newLoaderOptions())
+		diags := applyFromContent(t, l, []byte(file), nil)
+		require.NoError(t, diags.ErrorOrNil())
+
+		static := l.Graph().GetByID("testcomponents.passthrough.static").(*controller.ComponentNode)
+		require.Equal(t, "localhost", static.Arguments().(testcomponents.PassthroughConfig).Input)
+	})
+
+	t.Run("Canceled load is discarded", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// testcomponents.cancel cancels the load while it's being built, so
+		// its dependants are never evaluated.
+		passthrough, _ := component.Get("testcomponents.passthrough")
+		canceling := passthrough
+		canceling.Name = "testcomponents.cancel"
+		canceling.Build = func(opts component.Options, args component.Arguments) (component.Component, error) {
+			cancel()
+			return passthrough.Build(opts, args)
+		}
+
+		opts := newLoaderOptions()
+		opts.ComponentRegistry = controller.RegistryMap{
+			passthrough.Name: passthrough,
+			canceling.Name:   canceling,
+		}
+		l := controller.NewLoader(opts)
+
+		diags := applyFromContent(t, l, []byte(`
+			testcomponents.passthrough "existing" {
+				input = "a"
+			}
+		`), nil)
+		require.NoError(t, diags.ErrorOrNil())
+		origBlock := l.Components()[0].Block()
+		origGraph := l.Graph()
+
+		blocks, diags := fileToBlock(t, []byte(`
+			testcomponents.passthrough "existing" {
+				input = "b"
+			}
+
+			testcomponents.cancel "trigger" {
+				input = "c"
+			}
+
+			testcomponents.passthrough "after" {
+				input = testcomponents.cancel.trigger.output
+			}
+		`))
+		require.NoError(t, diags.ErrorOrNil())
+
+		diags = l.Apply(ctx, nil, blocks, nil)
+		require.ErrorContains(t, diags.ErrorOrNil(), "Load canceled: context canceled")
+		require.False(t, l.Applied())
+
+		// The previous graph is kept, and existing components are pointed back
+		// at their previous blocks.
+		requireGraph(t, l.Graph(), graphDefinition{Nodes: []string{"testcomponents.passthrough.existing", "logging", "tracing"}})
+		require.Equal(t, origGraph.GetByID("testcomponents.passthrough.existing"), l.Graph().GetByID("testcomponents.passthrough.existing"))
+		require.Len(t, l.Components(), 1)
+		require.Same(t, origBlock, l.Components()[0].Block())
+
+		// The existing component was only staged with its new arguments, so
+		// it keeps running with its previous ones.
+		existing := l.Components()[0]
+		require.Equal(t, testcomponents.PassthroughConfig{Input: "a"}, existing.Arguments())
+		require.Equal(t, testcomponents.PassthroughExports{Output: "a"}, existing.Exports())
+
+		// Components created by the canceled load aren't visible to expressions.
+		require.Equal(t, []string{"passthrough"}, maps.Keys(l.Variables()["testcomponents"].(map[string]any)))
+	})
+
+	t.Run("Reload with components which fail to build", func(t *testing.T) {
+		passthrough, _ := component.Get("testcomponents.passthrough")
+		broken := passthrough
+		broken.Name = "testcomponents.broken"
+		broken.Build = func(component.Options, component.Arguments) (component.Component, error) {
+			return nil, errors.New("broken on purpose")
+		}
+
+		opts := newLoaderOptions()
+		opts.ComponentRegistry = controller.RegistryMap{
+			passthrough.Name: passthrough,
+			broken.Name:      broken,
+		}
+		l := controller.NewLoader(opts)
+
+		diags := applyFromContent(t, l, []byte(`
+			testcomponents.passthrough "existing" {
+				input = "a"
+			}
+
+			testcomponents.passthrough "failing" {
+				input = "a"
+			}
+		`), nil)
+		require.NoError(t, diags.ErrorOrNil())
+
+		diags = applyFromContent(t, l, []byte(`
+			testcomponents.passthrough "existing" {
+				input = "b"
+			}
+
+			testcomponents.passthrough "failing" {
+				input = 1 + "b"
+			}
+
+			testcomponents.broken "new" {
+				input = "c"
+			}
+		`), nil)
+		require.ErrorContains(t, diags.ErrorOrNil(), "Failed to build component: building component: broken on purpose")
+		require.True(t, l.Applied())
+
+		// The graph is loaded, and components which didn't fail are updated.
+		requireGraph(t, l.Graph(), graphDefinition{Nodes: []string{
+			"testcomponents.passthrough.existing",
+			"testcomponents.passthrough.failing",
+			"testcomponents.broken.new",
+			"logging",
+			"tracing",
+		}})
+		components := make(map[string]*controller.ComponentNode)
+		for _, cn := range l.Components() {
+			components[cn.NodeID()] = cn
+		}
+		require.Equal(t, testcomponents.PassthroughConfig{Input: "b"}, components["testcomponents.passthrough.existing"].Arguments())
+
+		// Components which failed are reported as unhealthy, and keep running
+		// with their previous arguments, if they had any.
+		require.Equal(t, testcomponents.PassthroughConfig{Input: "a"}, components["testcomponents.passthrough.failing"].Arguments())
+		require.Equal(t, component.HealthTypeUnhealthy, components["testcomponents.passthrough.failing"].CurrentHealth().Health)
+		require.Nil(t, components["testcomponents.broken.new"].Component())
+		require.Equal(t, component.HealthTypeUnhealthy, components["testcomponents.broken.new"].CurrentHealth().Health)
+	})
+
+	t.Run("Startup order", func(t *testing.T) {
+		file := `
+			testcomponents.passthrough "low" {
+				input = "low"
+			}
+
+			testcomponents.passthrough "high" {
+				input    = "high"
+				priority = 10
+			}
+
+			testcomponents.passthrough "dependant" {
+				input    = testcomponents.passthrough.low.output
+				priority = 100
+			}
+
+			testcomponents.passthrough "default" {
+				input = "default"
+			}
+		`
+		l := controller.NewLoader(newLoaderOptions())
+		diags := applyFromContent(t, l, []byte(file), nil)
+		require.NoError(t, diags.ErrorOrNil())
+
+		var order []string
+		for _, cn := range l.StartupOrder() {
+			order = append(order, cn.NodeID())
+		}
+		// Dependencies always start first, regardless of priority.
+		require.Equal(t, []string{
+			"testcomponents.passthrough.high",
+			"testcomponents.passthrough.default",
+			"testcomponents.passthrough.low",
+			"testcomponents.passthrough.dependant",
+		}, order)
+	})
+
+	t.Run("Priority must be a constant number", func(t *testing.T) {
+		invalidFile := `
+			testcomponents.passthrough "a" {
+				input    = "hello"
+				priority = "high"
+			}
+
+			testcomponents.passthrough "b" {
+				input    = "hello"
+				priority = testcomponents.passthrough.a.output
+			}
+		`
+		l := controller.NewLoader(newLoaderOptions())
+		diags := applyFromContent(t, l, []byte(invalidFile), nil)
+		require.Len(t, diags, 2)
+		require.Equal(t, `"high" should be number, got string`, diags[0].Message)
+		require.Contains(t, diags[1].Message, `identifier "testcomponents" does not exist`)
+	})
+
+	t.Run("Tags", func(t *testing.T) {
+		file := `
+			testcomponents.passthrough "tagged" {
+				input = "hello"
+				tags  = ["team:infra", "tier:" + env("TAGS_TEST_TIER")]
+			}
+
+			testcomponents.passthrough "untagged" {
+				input = "hello"
+			}
+		`
+		t.Setenv("TAGS_TEST_TIER", "prod")
+		l := controller.NewLoader(newLoaderOptions())
+		diags := applyFromContent(t, l, []byte(file), nil)
+		require.NoError(t, diags.ErrorOrNil())
+
+		tagged := l.Graph().GetByID("testcomponents.passthrough.tagged").(*controller.ComponentNode)
+		require.Equal(t, []string{"team:infra", "tier:prod"}, tagged.Tags())
+		require.Equal(t, testcomponents.PassthroughConfig{Input: "hello"}, tagged.Arguments())
+
+		untagged := l.Graph().GetByID("testcomponents.passthrough.untagged").(*controller.ComponentNode)
+		require.Empty(t, untagged.Tags())
+	})
+
+	t.Run("Tags must be a constant list of strings", func(t *testing.T) {
+		invalidFile := `
+			testcomponents.passthrough "a" {
+				input = "hello"
+				tags  = "team:infra"
+			}
+		`
+		l := controller.NewLoader(newLoaderOptions())
+		diags := applyFromContent(t, l, []byte(invalidFile), nil)
+		require.Len(t, diags, 1)
+		require.Equal(t, `"team:infra" should be array, got string`, diags[0].Message)
+	})
+
+	t.Run("Tags arguments of components aren't meta-arguments", func(t *testing.T) {
+		file := `
+			discovery.consul "default" {
+				services = ["web"]
+				tags     = ["primary"]
+			}
+		`
+		l := controller.NewLoader(newLoaderOptions())
+		diags := applyFromContent(t, l, []byte(file), nil)
+		require.NoError(t, diags.ErrorOrNil())
+
+		cn := l.Graph().GetByID("discovery.consul.default").(*controller.ComponentNode)
+		require.Empty(t, cn.Tags())
+		require.Equal(t, []string{"primary"}, cn.Arguments().(consul.Arguments).ServiceTags)
+	})
+
+	t.Run("Metric labels", func(t *testing.T) {
+		file := `
+			testcomponents.passthrough "labeled" {
+				input         = "hello"
+				metric_labels = { team = "infra", tier = "prod" }
+			}
+
+			testcomponents.passthrough "unlabeled" {
+				input = "hello"
+			}
+		`
+		reg := prometheus.NewRegistry()
+		opts := newLoaderOptions()
+		opts.ComponentGlobals.Registerer = reg
+
+		l := controller.NewLoader(opts)
+		diags := applyFromContent(t, l, []byte(file), nil)
+		require.NoError(t, diags.ErrorOrNil())
+
+		labeled := l.Graph().GetByID("testcomponents.passthrough.labeled").(*controller.ComponentNode)
+		require.Equal(t, map[string]string{"team": "infra", "tier": "prod"}, labeled.MetricLabels())
+		require.Equal(t, testcomponents.PassthroughConfig{Input: "hello"}, labeled.Arguments())
+
+		families, err := reg.Gather()
+		require.NoError(t, err)
+
+		series := map[string]map[string]string{}
+		for _, mf := range families {
+			if mf.GetName() != "agent_flow_component_evaluations_total" {
+				continue
+			}
+			for _, m := range mf.GetMetric() {
+				labels := map[string]string{}
+				for _, lp := range m.GetLabel() {
+					labels[lp.GetName()] = lp.GetValue()
+				}
+				series[labels["component_id"]] = labels
+			}
+		}
+		require.Equal(t, map[string]map[string]string{
+			"testcomponents.passthrough.labeled": {
+				"controller_id":  "",
+				"component_id":   "testcomponents.passthrough.labeled",
+				"component_name": "testcomponents.passthrough",
+				"team":           "infra",
+				"tier":           "prod",
+			},
+			"testcomponents.passthrough.unlabeled": {
+				"controller_id":  "",
+				"component_id":   "testcomponents.passthrough.unlabeled",
+				"component_name": "testcomponents.passthrough",
+				"team":           "",
+				"tier":           "",
+			},
+		}, series)
+	})
+
+	t.Run("Metric labels must be valid", func(t *testing.T) {
+		invalidFile := `
+			testcomponents.passthrough "a" {
+				input         = "hello"
+				metric_labels = { component_id = "a", "not-valid" = "b" }
+			}
+		`
+		l := controller.NewLoader(newLoaderOptions())
+		diags := applyFromContent(t, l, []byte(invalidFile), nil)
+		require.Len(t, diags, 2)
+		require.Equal(t, `metric label "component_id" is reserved and set by the controller`, diags[0].Message)
+		require.Equal(t, `"not-valid" is not a valid metric label name`, diags[1].Message)
+		require.Equal(t, 4, diags[0].StartPos.Line)
+	})
+
+	t.Run("Expected types of references", func(t *testing.T) {
+		file := `
+			testcomponents.tick "ticker" {
+				frequency = "1s"
+			}
+
+			testcomponents.passthrough "static" {
+				input = "hello"
+			}
+
+			testcomponents.passthrough "ticker" {
+				input = testcomponents.tick.ticker.tick_time
+
+				expect_types = { input = ["testcomponents.tick"] }
+			}
+
+			testcomponents.passthrough "wildcard" {
+				input = testcomponents.passthrough.static.output
+
+				expect_types = { input = ["testcomponents.*"] }
+			}
+		`
+		l := controller.NewLoader(newLoaderOptions())
+		diags := applyFromContent(t, l, []byte(file), nil)
+		require.NoError(t, diags.ErrorOrNil())
+
+		ticker := l.Graph().GetByID("testcomponents.passthrough.ticker").(*controller.ComponentNode)
+		require.Equal(t, map[string][]string{"input": {"testcomponents.tick"}}, ticker.ExpectedTypes())
+	})
+
+	t.Run("Failed load keeps the previous expected types", func(t *testing.T) {
+		file := `
+			testcomponents.tick "ticker" {
+				frequency = "1s"
+			}
+
+			testcomponents.passthrough "ticker" {
+				input = testcomponents.tick.ticker.tick_time
+
+				expect_types = { input = ["testcomponents.tick"] }
+			}
+		`
+		l := controller.NewLoader(newLoaderOptions())
+		diags := applyFromContent(t, l, []byte(file), nil)
+		require.NoError(t, diags.ErrorOrNil())
+
+		invalidFile := `
+			testcomponents.tick "ticker" {
+				frequency = "1s"
+			}
+
+			testcomponents.passthrough "ticker" {
+				input = testcomponents.tick.ticker.tick_time
+
+				expect_types = { input = ["testcomponents.*"] }
+			}
+
+			testcomponents.passthrough "broken" {
+				input = testcomponents.tick.doesnotexist.tick_time
+			}
+		`
+		diags = applyFromContent(t, l, []byte(invalidFile), nil)
+		require.Error(t, diags.ErrorOrNil())
+		require.False(t, l.Applied())
+
+		ticker := l.Graph().GetByID("testcomponents.passthrough.ticker").(*controller.ComponentNode)
+		require.Equal(t, map[string][]string{"input": {"testcomponents.tick"}}, ticker.ExpectedTypes())
+	})
+
+	t.Run("References must match their expected types", func(t *testing.T) {
+		invalidFile := `
+			testcomponents.tick "ticker" {
+				frequency = "1s"
+			}
+
+			testcomponents.passthrough "ticker" {
+				input = testcomponents.tick.ticker.tick_time
+
+				expect_types = { input = ["testcomponents.passthrough"] }
+			}
+		`
+		l := controller.NewLoader(newLoaderOptions())
+		diags := applyFromContent(t, l, []byte(invalidFile), nil)
+		require.Len(t, diags, 1)
+		require.Equal(t, `input of testcomponents.passthrough.ticker references testcomponents.tick.ticker, which is a testcomponents.tick, but expect_types only allows testcomponents.passthrough`, diags[0].Message)
+		require.Equal(t, 7, diags[0].StartPos.Line)
+		require.Equal(t, 13, diags[0].StartPos.Column)
+	})
+
+	t.Run("Self references", func(t *testing.T) {
+		invalidFile := `
+			testcomponents.passthrough "static" {
+				input = testcomponents.passthrough.static.output
+			}
+		`
+		l := controller.NewLoader(newLoaderOptions())
+		diags := applyFromContent(t, l, []byte(invalidFile), nil)
+		require.Len(t, diags, 1)
+		require.Equal(t, `testcomponents.passthrough.static references itself in the expression "testcomponents.passthrough.static.output"; a block can't reference its own exports`, diags[0].Message)
+		require.Equal(t, 3, diags[0].StartPos.Line)
+		require.Equal(t, 13, diags[0].StartPos.Column)
+	})
+
+	t.Run("References to missing exports", func(t *testing.T) {
+		invalidFile := `
+			testcomponents.tick "ticker" {
+				frequency = "1s"
+			}
+
+			testcomponents.passthrough "static" {
+				input = testcomponents.tick.ticker.targets
+			}
+		`
+		l := controller.NewLoader(newLoaderOptions())
+		diags := applyFromContent(t, l, []byte(invalidFile), nil)
+		require.Len(t, diags, 1)
+		require.Equal(t, `component "testcomponents.tick.ticker" has no exported attribute "targets"; available: health, tick_time`, diags[0].Message)
+		require.Equal(t, 7, diags[0].StartPos.Line)
+		require.Equal(t, 13, diags[0].StartPos.Column)
+	})
+
 	t.Run("File has cycles", func(t *testing.T) {
 		invalidFile := `
 			testcomponents.tick "ticker" {
@@ -244,7 +909,7 @@ func applyFromContent(t *testing.T, l *controller.Loader, componentBytes []byte,
 		}
 	}
 
-	applyDiags := l.Apply(nil, componentBlocks, configBlocks)
+	applyDiags := l.Apply(context.Background(), nil, componentBlocks, configBlocks)
 	diags = append(diags, applyDiags...)
 
 	return diags
@@ -15,8 +15,21 @@ type controllerMetrics struct {
 	evaluationQueueSize         prometheus.Gauge
 	slowComponentThreshold      time.Duration
 	slowComponentEvaluationTime *prometheus.CounterVec
+	loadTime                    prometheus.Histogram
+	loadPhaseTime               *prometheus.HistogramVec
+	unchangedLoads              prometheus.Counter
+	coalescedReloads            prometheus.Counter
+	reloads                     *prometheus.CounterVec
 }
 
+// Phases of a load tracked by the loadPhaseTime metric.
+const (
+	loadPhaseParse  = "parse"  // Parsing config sources.
+	loadPhaseWire   = "wire"   // Building the graph and wiring its edges.
+	loadPhaseReduce = "reduce" // Transitively reducing the graph, if enabled.
+	loadPhaseBuild  = "build"  // Evaluating the nodes in the graph.
+)
+
 // newControllerMetrics inits the metrics for the components controller
 func newControllerMetrics(id string) *controllerMetrics {
 	cm := &controllerMetrics{
@@ -63,6 +76,34 @@ func newControllerMetrics(id string) *controllerMetrics {
 		ConstLabels: map[string]string{"controller_id": id},
 	}, []string{"component_id"})
 
+	cm.loadTime = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:        "agent_component_controller_load_seconds",
+		Help:        "Time spent loading a new config into the controller",
+		ConstLabels: map[string]string{"controller_id": id},
+		Buckets:     evaluationTimesBuckets,
+	})
+	cm.loadPhaseTime = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:        "agent_component_controller_load_phase_seconds",
+		Help:        "Time spent in each phase of loading a new config into the controller",
+		ConstLabels: map[string]string{"controller_id": id},
+		Buckets:     evaluationTimesBuckets,
+	}, []string{"phase"})
+	cm.unchangedLoads = prometheus.NewCounter(prometheus.CounterOpts{
+		Name:        "agent_component_controller_unchanged_loads_total",
+		Help:        "Total number of loads skipped because the config didn't change since the last successful load",
+		ConstLabels: map[string]string{"controller_id": id},
+	})
+	cm.coalescedReloads = prometheus.NewCounter(prometheus.CounterOpts{
+		Name:        "agent_component_controller_coalesced_reloads_total",
+		Help:        "Total number of reloads replaced by a newer reload before the minimum reload interval elapsed",
+		ConstLabels: map[string]string{"controller_id": id},
+	})
+	cm.reloads = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        "agent_component_controller_reloads_total",
+		Help:        "Total number of applied reloads by the mechanism which triggered them and their result",
+		ConstLabels: map[string]string{"controller_id": id},
+	}, []string{"trigger", "result"})
+
 	return cm
 }
 
@@ -79,6 +120,11 @@ func (cm *controllerMetrics) Collect(ch chan<- prometheus.Metric) {
 	cm.dependenciesWaitTime.Collect(ch)
 	cm.evaluationQueueSize.Collect(ch)
 	cm.slowComponentEvaluationTime.Collect(ch)
+	cm.loadTime.Collect(ch)
+	cm.loadPhaseTime.Collect(ch)
+	cm.unchangedLoads.Collect(ch)
+	cm.coalescedReloads.Collect(ch)
+	cm.reloads.Collect(ch)
 }
 
 func (cm *controllerMetrics) Describe(ch chan<- *prometheus.Desc) {
@@ -87,39 +133,151 @@ func (cm *controllerMetrics) Describe(ch chan<- *prometheus.Desc) {
 	cm.dependenciesWaitTime.Describe(ch)
 	cm.evaluationQueueSize.Describe(ch)
 	cm.slowComponentEvaluationTime.Describe(ch)
+	cm.loadTime.Describe(ch)
+	cm.loadPhaseTime.Describe(ch)
+	cm.unchangedLoads.Describe(ch)
+	cm.coalescedReloads.Describe(ch)
+	cm.reloads.Describe(ch)
 }
 
 type controllerCollector struct {
 	l                      *Loader
+	id                     string
 	runningComponentsTotal *prometheus.Desc
+	graphNodes             *prometheus.Desc
+	graphEdges             *prometheus.Desc
+	graphEstimatedBytes    *prometheus.Desc
 }
 
 func newControllerCollector(l *Loader, id string) *controllerCollector {
 	return &controllerCollector{
-		l: l,
+		l:  l,
+		id: id,
 		runningComponentsTotal: prometheus.NewDesc(
 			"agent_component_controller_running_components",
 			"Total number of running components.",
 			[]string{"health_type"},
 			map[string]string{"controller_id": id},
 		),
+		graphNodes: prometheus.NewDesc(
+			"agent_component_controller_graph_nodes",
+			"Number of nodes in the graph of the most recently loaded config.",
+			nil,
+			map[string]string{"controller_id": id},
+		),
+		graphEdges: prometheus.NewDesc(
+			"agent_component_controller_graph_edges",
+			"Number of edges in the graph of the most recently loaded config.",
+			nil,
+			map[string]string{"controller_id": id},
+		),
+		graphEstimatedBytes: prometheus.NewDesc(
+			"agent_component_controller_graph_estimated_bytes",
+			"Approximate memory retained by the graph of the most recently loaded config.",
+			nil,
+			map[string]string{"controller_id": id},
+		),
 	}
 }
 
+// componentDescs describes the per-component metrics. Their labels depend on
+// the metric_labels of the loaded components, so they're created on every
+// collection.
+type componentDescs struct {
+	labelNames []string // Names of the labels from metric_labels, in order.
+
+	buildSeconds       *prometheus.Desc
+	evaluations        *prometheus.Desc
+	evaluationDuration *prometheus.Desc
+	health             *prometheus.Desc
+}
+
+func newComponentDescs(id string, labelNames []string) componentDescs {
+	names := append([]string{"component_id", "component_name"}, labelNames...)
+	constLabels := map[string]string{"controller_id": id}
+
+	return componentDescs{
+		labelNames: labelNames,
+
+		buildSeconds: prometheus.NewDesc(
+			"agent_flow_component_build_seconds",
+			"Time spent evaluating each component during the most recent config load.",
+			names, constLabels,
+		),
+		evaluations: prometheus.NewDesc(
+			"agent_flow_component_evaluations_total",
+			"Total number of times each component was evaluated.",
+			names, constLabels,
+		),
+		evaluationDuration: prometheus.NewDesc(
+			"agent_flow_component_evaluation_duration_seconds",
+			"Time spent in the most recent evaluation of each component.",
+			names, constLabels,
+		),
+		health: prometheus.NewDesc(
+			"agent_flow_component_health",
+			"Current health of each component. The value is 1 for the health_type the component reports.",
+			append(names, "health_type"), constLabels,
+		),
+	}
+}
+
+// labelValues returns the label values of the per-component metrics of cn.
+func (d componentDescs) labelValues(cn *ComponentNode) []string {
+	labels := cn.MetricLabels()
+
+	values := make([]string, 0, 2+len(d.labelNames))
+	values = append(values, cn.NodeID(), cn.componentName)
+	for _, name := range d.labelNames {
+		values = append(values, labels[name])
+	}
+	return values
+}
+
 func (cc *controllerCollector) Collect(ch chan<- prometheus.Metric) {
-	componentsByHealth := make(map[string]int)
+	var (
+		components         = cc.l.Components()
+		componentsByHealth = make(map[string]int)
+		labelNames         = make(map[string]string)
+	)
+	for _, component := range components {
+		for name := range component.MetricLabels() {
+			labelNames[name] = ""
+		}
+	}
+	descs := newComponentDescs(cc.id, sortedLabelNames(labelNames))
 
-	for _, component := range cc.l.Components() {
+	for _, component := range components {
 		health := component.CurrentHealth().Health.String()
 		componentsByHealth[health]++
 		component.registry.Collect(ch)
+
+		values := descs.labelValues(component)
+		if d := component.BuildDuration(); d > 0 {
+			ch <- prometheus.MustNewConstMetric(descs.buildSeconds, prometheus.GaugeValue, d.Seconds(), values...)
+		}
+		if count, last := component.Evaluations(); count > 0 {
+			ch <- prometheus.MustNewConstMetric(descs.evaluations, prometheus.CounterValue, float64(count), values...)
+			ch <- prometheus.MustNewConstMetric(descs.evaluationDuration, prometheus.GaugeValue, last.Seconds(), values...)
+		}
+		ch <- prometheus.MustNewConstMetric(descs.health, prometheus.GaugeValue, 1, append(values, health)...)
 	}
 
 	for health, count := range componentsByHealth {
 		ch <- prometheus.MustNewConstMetric(cc.runningComponentsTotal, prometheus.GaugeValue, float64(count), health)
 	}
+
+	stats := cc.l.Stats()
+	ch <- prometheus.MustNewConstMetric(cc.graphNodes, prometheus.GaugeValue, float64(stats.Nodes))
+	ch <- prometheus.MustNewConstMetric(cc.graphEdges, prometheus.GaugeValue, float64(stats.Edges))
+	ch <- prometheus.MustNewConstMetric(cc.graphEstimatedBytes, prometheus.GaugeValue, float64(stats.EstimatedBytes))
 }
 
+// Describe implements prometheus.Collector. The per-component metrics aren't
+// described, since their labels depend on the loaded components.
 func (cc *controllerCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- cc.runningComponentsTotal
+	ch <- cc.graphNodes
+	ch <- cc.graphEdges
+	ch <- cc.graphEstimatedBytes
 }
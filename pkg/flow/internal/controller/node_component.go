@@ -5,9 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"net/http"
 	"path"
 	"path/filepath"
 	"reflect"
+	"runtime/debug"
+	"runtime/pprof"
 	"strings"
 	"sync"
 	"time"
@@ -17,6 +20,7 @@ import (
 	"github.com/grafana/agent/pkg/flow/logging"
 	"github.com/grafana/agent/pkg/flow/logging/level"
 	"github.com/grafana/agent/pkg/flow/tracing"
+	"github.com/grafana/dskit/backoff"
 	"github.com/grafana/river/ast"
 	"github.com/grafana/river/vm"
 	"github.com/prometheus/client_golang/prometheus"
@@ -72,6 +76,12 @@ type ComponentGlobals struct {
 	ControllerID        string                                 // ID of controller.
 	NewModuleController func(id string) ModuleController       // Func to generate a module controller.
 	GetServiceData      func(name string) (interface{}, error) // Get data for a service.
+	ErrorHistorySize    int                                    // Number of errors kept per component. DefaultErrorHistorySize if zero; none if negative.
+	Singletons          *SingletonRegistry                     // Registry of shared singleton components. Singletons aren't shared if nil.
+	CircuitBreaker      CircuitBreakerConfig                   // Circuit breaker of each component. Disabled if MaxFailures is zero.
+	HTTPClient          *http.Client                           // HTTP client shared with managed components; may be nil.
+	OnComponentError    func(cn *ComponentNode, err error)     // Invoked when evaluating or running a component fails; may be nil.
+	OnComponentRestart  func(cn *ComponentNode, reason string) // Invoked when a managed component is restarted; may be nil.
 }
 
 // ComponentNode is a controller node which manages a user-defined component.
@@ -90,14 +100,38 @@ type ComponentNode struct {
 	registry          *prometheus.Registry
 	exportsType       reflect.Type
 	moduleController  ModuleController
+	singletons        *SingletonRegistry
 	OnComponentUpdate func(cn *ComponentNode) // Informs controller that we need to reevaluate
+	onError           func(cn *ComponentNode, err error)
+	onRestart         func(cn *ComponentNode, reason string)
 	lastUpdateTime    atomic.Time
-
-	mut     sync.RWMutex
-	block   *ast.BlockStmt // Current River block to derive args from
-	eval    *vm.Evaluator
-	managed component.Component // Inner managed component
-	args    component.Arguments // Evaluated arguments for the managed component
+	buildDuration     atomic.Duration // Time spent evaluating the component in the most recent load.
+	cachedHealth      atomic.Uint32   // Health state last exposed to dependants.
+	running           atomic.Bool     // Whether the managed component is running.
+	priority          atomic.Int64    // Startup priority from the priority meta-argument.
+	evaluations       atomic.Uint64   // Number of times the component was evaluated.
+	evalDuration      atomic.Duration // Time spent in the most recent evaluation.
+
+	// updateMut serializes evaluations, so the managed component can be
+	// updated without holding mut.
+	updateMut sync.Mutex
+
+	mut        sync.RWMutex
+	block      *ast.BlockStmt // Current River block to derive args from
+	body       ast.Body       // Body of block with calls to prev replaced
+	eval       *vm.Evaluator
+	dynamic    bool                // Whether block has dynamic blocks, which are expanded on every evaluation
+	prev       prevCalls           // References passed to prev in block
+	prevValues map[string]any      // Values of prev references at the last successful evaluation
+	managed    component.Component // Inner managed component
+	args       component.Arguments // Evaluated arguments for the managed component
+	tags       []string            // Tags from the tags meta-argument
+	labels     map[string]string   // Labels from the metric_labels meta-argument
+	expect     map[string][]string // Expected types of references from the expect_types meta-argument
+	sink       bool                // Whether the component is a sink, from its registration or sink meta-argument
+
+	singleton *singletonEntry // Shared entry of managed, for components registered as singletons
+	staged    *componentStage // Component built by stage, used by the next evaluation
 
 	// NOTE(rfratto): health and exports have their own mutex because they may be
 	// set asynchronously while mut is still being held (i.e., when calling Evaluate
@@ -109,9 +143,42 @@ type ComponentNode struct {
 
 	exportsMut sync.RWMutex
 	exports    component.Exports // Evaluated exports for the managed component
+
+	errors  *errorHistory   // Recent errors from evaluating and running the managed component
+	breaker *circuitBreaker // Quarantines the managed component once it keeps failing; nil if disabled
+
+	// runMut guards the current run of the managed component, so the run can be
+	// stopped when a component registered with RestartOnUpdate is rebuilt.
+	runMut     sync.Mutex
+	currentRun *componentRun
+	rebuilt    chan struct{} // Signaled when the managed component is built after a failed restart.
+
+	// started is closed once the managed component finished its initial
+	// startup, or startErr is set if Run exited before it did.
+	started   chan struct{}
+	startOnce sync.Once
+	startErr  error
+	reported  atomic.Bool // Whether the managed component called OnStateChange.
+}
+
+// componentRun is a single run of a managed component.
+type componentRun struct {
+	cancel  context.CancelFunc
+	exited  chan struct{}
+	stopped bool // Whether the run was stopped for a restart.
 }
 
-var _ BlockNode = (*ComponentNode)(nil)
+// restartBackoff configures the delay before a failed component is restarted
+// by its circuit breaker.
+var restartBackoff = backoff.Config{
+	MinBackoff: 100 * time.Millisecond,
+	MaxBackoff: 10 * time.Second,
+}
+
+var (
+	_ BlockNode       = (*ComponentNode)(nil)
+	_ PrioritizedNode = (*ComponentNode)(nil)
+)
 
 // NewComponentNode creates a new ComponentNode from an initial ast.BlockStmt.
 // The underlying managed component isn't created until Evaluate is called.
@@ -146,19 +213,26 @@ func NewComponentNode(globals ComponentGlobals, reg component.Registration, b *a
 		reg:               reg,
 		exportsType:       getExportsType(reg),
 		moduleController:  globals.NewModuleController(globalID),
+		singletons:        globals.Singletons,
 		OnComponentUpdate: globals.OnComponentUpdate,
-
-		block: b,
-		eval:  vm.New(b.Body),
+		onError:           globals.OnComponentError,
+		onRestart:         globals.OnComponentRestart,
 
 		// Prepopulate arguments and exports with their zero values.
 		args:    reg.Args,
 		exports: reg.Exports,
+		sink:    reg.IsSink(),
 
 		evalHealth: initHealth,
 		runHealth:  initHealth,
+
+		errors:  newErrorHistory(errorHistorySize(globals)),
+		breaker: newCircuitBreaker(globals.CircuitBreaker),
+		rebuilt: make(chan struct{}, 1),
+		started: make(chan struct{}),
 	}
 	cn.managedOpts = getManagedOptions(globals, cn)
+	cn.setBlock(b)
 
 	return cn
 }
@@ -181,9 +255,20 @@ func getManagedOptions(globals ComponentGlobals, cn *ComponentNode) component.Op
 		GetServiceData: func(name string) (interface{}, error) {
 			return globals.GetServiceData(name)
 		},
+
+		HTTPClient: globals.HTTPClient,
 	}
 }
 
+// errorHistorySize returns the number of errors to keep per component from
+// globals.
+func errorHistorySize(globals ComponentGlobals) int {
+	if globals.ErrorHistorySize == 0 {
+		return DefaultErrorHistorySize
+	}
+	return globals.ErrorHistorySize
+}
+
 func getExportsType(reg component.Registration) reflect.Type {
 	if reg.Exports != nil {
 		return reflect.TypeOf(reg.Exports)
@@ -191,6 +276,70 @@ func getExportsType(reg component.Registration) reflect.Type {
 	return nil
 }
 
+// Priority returns the startup priority of the component, set by its
+// priority meta-argument. Components with a higher priority are started first
+// when they don't depend on each other.
+func (cn *ComponentNode) Priority() int { return int(cn.priority.Load()) }
+
+func (cn *ComponentNode) setPriority(priority int) { cn.priority.Store(int64(priority)) }
+
+// Tags returns the tags of the component, set by its tags meta-argument.
+func (cn *ComponentNode) Tags() []string {
+	cn.mut.RLock()
+	defer cn.mut.RUnlock()
+	return cn.tags
+}
+
+func (cn *ComponentNode) setTags(tags []string) {
+	cn.mut.Lock()
+	defer cn.mut.Unlock()
+	cn.tags = tags
+}
+
+// MetricLabels returns the labels attached to the per-component metrics of
+// the component, set by its metric_labels meta-argument.
+func (cn *ComponentNode) MetricLabels() map[string]string {
+	cn.mut.RLock()
+	defer cn.mut.RUnlock()
+	return cn.labels
+}
+
+func (cn *ComponentNode) setMetricLabels(labels map[string]string) {
+	cn.mut.Lock()
+	defer cn.mut.Unlock()
+	cn.labels = labels
+}
+
+// ExpectedTypes returns the types of the components which the arguments of
+// the component may reference, keyed by argument path, set by its
+// expect_types meta-argument.
+func (cn *ComponentNode) ExpectedTypes() map[string][]string {
+	cn.mut.RLock()
+	defer cn.mut.RUnlock()
+	return cn.expect
+}
+
+func (cn *ComponentNode) setExpectedTypes(expect map[string][]string) {
+	cn.mut.Lock()
+	defer cn.mut.Unlock()
+	cn.expect = expect
+}
+
+// IsSink returns whether the component is a sink, which other components
+// aren't expected to reference. Components are sinks when registered as one,
+// unless overridden by their sink meta-argument.
+func (cn *ComponentNode) IsSink() bool {
+	cn.mut.RLock()
+	defer cn.mut.RUnlock()
+	return cn.sink
+}
+
+func (cn *ComponentNode) setSink(sink bool) {
+	cn.mut.Lock()
+	defer cn.mut.Unlock()
+	cn.sink = sink
+}
+
 // Registration returns the original registration of the component.
 func (cn *ComponentNode) Registration() component.Registration { return cn.reg }
 
@@ -202,6 +351,10 @@ func (cn *ComponentNode) Component() component.Component {
 	return cn.managed
 }
 
+// GlobalID returns the globally unique ID of the managed component, which
+// includes the ID of the controller the component belongs to.
+func (cn *ComponentNode) GlobalID() string { return cn.globalID }
+
 // ID returns the component ID of the managed component from its River block.
 func (cn *ComponentNode) ID() ComponentID { return cn.id }
 
@@ -229,8 +382,16 @@ func (cn *ComponentNode) UpdateBlock(b *ast.BlockStmt) {
 
 	cn.mut.Lock()
 	defer cn.mut.Unlock()
+	cn.setBlock(b)
+}
+
+// setBlock sets the block of cn. Previous values of references passed to
+// prev are kept, so they survive reloads. cn.mut must be held.
+func (cn *ComponentNode) setBlock(b *ast.BlockStmt) {
 	cn.block = b
-	cn.eval = vm.New(b.Body)
+	cn.body, cn.prev = rewritePrevCalls(b.Body)
+	cn.eval = vm.New(cn.body)
+	cn.dynamic = hasDynamicBlocks(cn.body)
 }
 
 // Evaluate implements BlockNode and updates the arguments for the managed component
@@ -240,7 +401,10 @@ func (cn *ComponentNode) UpdateBlock(b *ast.BlockStmt) {
 // Evaluate will return an error if the River block cannot be evaluated or if
 // decoding to arguments fails.
 func (cn *ComponentNode) Evaluate(scope *vm.Scope) error {
+	start := time.Now()
 	err := cn.evaluate(scope)
+	cn.evalDuration.Store(time.Since(start))
+	cn.evaluations.Inc()
 
 	switch err {
 	case nil:
@@ -248,83 +412,563 @@ func (cn *ComponentNode) Evaluate(scope *vm.Scope) error {
 	default:
 		msg := fmt.Sprintf("component evaluation failed: %s", err)
 		cn.setEvalHealth(component.HealthTypeUnhealthy, msg)
+		cn.recordError(err)
 	}
 	return err
 }
 
 func (cn *ComponentNode) evaluate(scope *vm.Scope) error {
+	cn.updateMut.Lock()
+	defer cn.updateMut.Unlock()
+
+	args, prevValues, err := cn.prepareEvaluate(scope)
+	if err != nil || args == nil {
+		return err
+	}
+
+	// The managed component is updated without holding mut, so reading cn,
+	// such as from the component API or metrics, doesn't block on a slow
+	// Update. Readers see the previous arguments until Update returns.
+	cn.mut.RLock()
+	managed, singleton := cn.managed, cn.singleton
+	cn.mut.RUnlock()
+
+	if singleton != nil {
+		err = singleton.update(args)
+	} else {
+		err = managed.Update(args)
+	}
+	if err != nil {
+		return fmt.Errorf("updating component: %w", err)
+	}
+
+	cn.mut.Lock()
+	cn.args = args
+	cn.prevValues = prevValues
+	cn.mut.Unlock()
+	return nil
+}
+
+// componentStage is a component built by stage for a ComponentNode which
+// wasn't built yet, along with the arguments it was built from.
+type componentStage struct {
+	args    component.Arguments
+	managed component.Component
+}
+
+// stage decodes the arguments of cn from scope without changing cn or its
+// managed component, so Apply can evaluate a new graph before committing to
+// it. If cn hasn't been built yet, a component is built from the arguments
+// without running it, and the next evaluation uses it if it decodes the same
+// arguments. Singletons are only acquired by the next evaluation.
+//
+// stage returns the decoded arguments, or the current arguments of cn if
+// decoding failed.
+func (cn *ComponentNode) stage(scope *vm.Scope) (component.Arguments, error) {
+	cn.updateMut.Lock()
+	defer cn.updateMut.Unlock()
 	cn.mut.Lock()
 	defer cn.mut.Unlock()
 
-	argsPointer := cn.reg.CloneArguments()
-	if err := cn.eval.Evaluate(scope, argsPointer); err != nil {
-		return fmt.Errorf("decoding River: %w", err)
+	cn.staged = nil
+	args, _, err := cn.decodeArguments(scope)
+	if err != nil {
+		return cn.args, err
+	}
+	if cn.managed != nil || (cn.reg.Singleton && cn.singletons != nil) {
+		return args, nil
 	}
 
-	// args is always a pointer to the args type, so we want to deference it since
-	// components expect a non-pointer.
-	argsCopyValue := reflect.ValueOf(argsPointer).Elem().Interface()
+	managed, err := cn.reg.Build(cn.managedOpts, args)
+	if err != nil {
+		return args, fmt.Errorf("building component: %w", err)
+	}
+	cn.staged = &componentStage{args: args, managed: managed}
+	return args, nil
+}
+
+// discardStage drops the component built by stage, once the call to Apply
+// which staged it is discarded.
+func (cn *ComponentNode) discardStage() {
+	cn.mut.Lock()
+	defer cn.mut.Unlock()
+	cn.staged = nil
+}
+
+// prepareEvaluate decodes the arguments of cn from scope while holding mut,
+// building or restarting the managed component if needed. It returns the
+// arguments the managed component must still be updated with, along with
+// the prev values to keep once that update succeeds, or nil arguments if
+// there's nothing left to update. updateMut must be held.
+func (cn *ComponentNode) prepareEvaluate(scope *vm.Scope) (component.Arguments, map[string]any, error) {
+	cn.mut.Lock()
+	defer cn.mut.Unlock()
+
+	staged := cn.staged
+	cn.staged = nil
+
+	argsCopyValue, prevValues, err := cn.decodeArguments(scope)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	if cn.managed == nil {
-		// We haven't built the managed component successfully yet.
-		managed, err := cn.reg.Build(cn.managedOpts, argsCopyValue)
+		// We haven't built the managed component successfully yet. The
+		// component built by stage is used if it was built from the same
+		// arguments.
+		var managed component.Component
+		if staged != nil && reflect.DeepEqual(staged.args, argsCopyValue) {
+			managed = staged.managed
+		} else {
+			managed, err = cn.build(argsCopyValue)
+		}
 		if err != nil {
-			return fmt.Errorf("building component: %w", err)
+			return nil, nil, fmt.Errorf("building component: %w", err)
 		}
-		cn.managed = managed
+		cn.setManaged(managed)
 		cn.args = argsCopyValue
+		cn.prevValues = prevValues
 
-		return nil
+		// Wake up Run if it's waiting for a component which failed to build
+		// after a restart.
+		select {
+		case cn.rebuilt <- struct{}{}:
+		default:
+		}
+		return nil, nil, nil
 	}
 
 	if reflect.DeepEqual(cn.args, argsCopyValue) {
 		// Ignore components which haven't changed. This reduces the cost of
 		// calling evaluate for components where evaluation is expensive (e.g., if
 		// re-evaluating requires re-starting some internal logic).
-		return nil
+		cn.prevValues = prevValues
+		return nil, nil, nil
 	}
 
-	// Update the existing managed component
-	if err := cn.managed.Update(argsCopyValue); err != nil {
-		return fmt.Errorf("updating component: %w", err)
+	if cn.singleton == nil && cn.reg.RestartOnUpdate {
+		if err := cn.restart(argsCopyValue); err != nil {
+			return nil, nil, err
+		}
+		cn.prevValues = prevValues
+		return nil, nil, nil
+	}
+
+	// The existing managed component is updated by evaluate.
+	return argsCopyValue, prevValues, nil
+}
+
+// decodeArguments evaluates the block of cn against scope into a new value
+// of the arguments type. It also returns the current values of the
+// references passed to prev, which become their previous values once the
+// arguments are used. cn.mut must be held, at least for reading.
+func (cn *ComponentNode) decodeArguments(scope *vm.Scope) (component.Arguments, map[string]any, error) {
+	var prevValues map[string]any
+	if len(cn.prev) > 0 {
+		var err error
+		scope, prevValues, err = cn.prev.scope(scope, cn.prevValues)
+		if err != nil {
+			return nil, nil, fmt.Errorf("decoding River: %w", err)
+		}
+	}
+
+	eval := cn.eval
+	if cn.dynamic {
+		// Dynamic blocks depend on values from scope, so the blocks they
+		// generate must be expanded again on every evaluation.
+		body, dynamicScope, err := expandDynamicBlocks(cn.body, scope)
+		if err != nil {
+			return nil, nil, fmt.Errorf("decoding River: %w", err)
+		}
+		eval, scope = vm.New(body), dynamicScope
+	}
+
+	argsPointer := cn.reg.CloneArguments()
+	if err := eval.Evaluate(scope, argsPointer); err != nil {
+		return nil, nil, fmt.Errorf("decoding River: %w", err)
+	}
+
+	// args is always a pointer to the args type, so we want to deference it since
+	// components expect a non-pointer.
+	return reflect.ValueOf(argsPointer).Elem().Interface(), prevValues, nil
+}
+
+// build builds the managed component from args. Components registered as
+// singletons are shared through cn.singletons, so they're only built if no
+// other controller built them yet. cn.mut must be held.
+func (cn *ComponentNode) build(args component.Arguments) (component.Component, error) {
+	if !cn.reg.Singleton || cn.singletons == nil {
+		return cn.reg.Build(cn.managedOpts, args)
+	}
+
+	e, err := cn.singletons.acquire(cn, args)
+	if err != nil {
+		return nil, err
+	}
+	cn.singleton = e
+	return e.managed, nil
+}
+
+// releaseSingleton releases the shared component used by cn, if any, once cn
+// is removed from its graph or its controller shuts down. The managed
+// component is cleared, so cn doesn't run the shared component again.
+func (cn *ComponentNode) releaseSingleton() {
+	cn.mut.Lock()
+	e := cn.singleton
+	if e != nil {
+		cn.singleton = nil
+		cn.setManaged(nil)
+	}
+	cn.mut.Unlock()
+
+	if e != nil {
+		cn.singletons.release(cn, e)
+	}
+}
+
+// restart replaces the managed component with a new component built from
+// args, for components registered with RestartOnUpdate. The running
+// component is stopped before the new one is built, and Run starts the new
+// component once it's built. cn.mut must be held.
+//
+// If the new component fails to build, no component runs until a later
+// evaluation builds one successfully.
+func (cn *ComponentNode) restart(args component.Arguments) error {
+	level.Info(cn.managedOpts.Logger).Log("msg", "restarting component to apply new arguments")
+	cn.stopRun()
+	if cn.onRestart != nil {
+		cn.onRestart(cn, "restarting component to apply new arguments")
 	}
 
-	cn.args = argsCopyValue
+	managed, err := cn.reg.Build(cn.managedOpts, args)
+	if err != nil {
+		cn.setManaged(nil)
+		return fmt.Errorf("building component: %w", err)
+	}
+	cn.setManaged(managed)
+	cn.args = args
 	return nil
 }
 
+// setManaged sets the managed component. cn.mut must be held. healthMut is
+// also held, since CurrentHealth reads the managed component without cn.mut.
+func (cn *ComponentNode) setManaged(managed component.Component) {
+	cn.healthMut.Lock()
+	defer cn.healthMut.Unlock()
+	cn.managed = managed
+}
+
+// stopRun stops the current run of the managed component, if any, and waits
+// for it to exit.
+func (cn *ComponentNode) stopRun() {
+	cn.runMut.Lock()
+	run := cn.currentRun
+	if run != nil {
+		run.stopped = true
+	}
+	cn.runMut.Unlock()
+
+	if run != nil {
+		run.cancel()
+		<-run.exited
+	}
+}
+
 // Run runs the managed component in the calling goroutine until ctx is
 // canceled. Evaluate must have been called at least once without returning an
 // error before calling Run.
 //
 // Run will immediately return ErrUnevaluated if Evaluate has never been called
 // successfully. Otherwise, Run will return nil.
+//
+// If the circuit breaker is enabled, panics of the managed component are
+// recovered, and the managed component is restarted whenever it fails until
+// the breaker opens. Failed components are built again from the current
+// arguments before they restart, after a delay which grows with every
+// consecutive failure. The managed component doesn't run again while the
+// breaker is open, until ResetCircuitBreaker is called.
 func (cn *ComponentNode) Run(ctx context.Context) error {
 	cn.mut.RLock()
 	managed := cn.managed
 	cn.mut.RUnlock()
 
 	if managed == nil {
+		cn.markStarted(ErrUnevaluated)
 		return ErrUnevaluated
 	}
 
-	cn.setRunHealth(component.HealthTypeHealthy, "started component")
-	err := cn.managed.Run(ctx)
+	var (
+		err    error
+		failed bool // Whether the managed component failed and must be rebuilt.
+		bo     = backoff.New(ctx, restartBackoff)
+	)
+	for {
+		if cn.breaker != nil && !cn.breaker.waitClosed(ctx) {
+			break
+		}
+
+		if failed {
+			if buildErr := cn.rebuildFailed(); buildErr != nil {
+				cn.recordFailure(fmt.Errorf("rebuilding component: %w", buildErr))
+				if !cn.waitRestart(ctx, bo) {
+					break
+				}
+				continue
+			}
+			failed = false
+		}
+
+		var stopped bool
+		started := time.Now()
+		stopped, err = cn.runManaged(ctx)
+		if stopped {
+			// The component was stopped to be rebuilt with new arguments. If the
+			// rebuild failed, wait for a later evaluation to build the component.
+			cn.setRunHealth(component.HealthTypeUnknown, "component stopped for restart")
+			if !cn.waitRebuilt(ctx) {
+				break
+			}
+			continue
+		}
+
+		if err == nil || ctx.Err() != nil || cn.breaker == nil {
+			break
+		}
+		cn.recordFailure(err)
+		err, failed = nil, true
+
+		// Components which ran for a while before failing restart quickly again.
+		if time.Since(started) >= restartBackoff.MaxBackoff {
+			bo.Reset()
+		}
+		if !cn.waitRestart(ctx, bo) {
+			break
+		}
+	}
 
 	var exitMsg string
 	logger := cn.managedOpts.Logger
 	if err != nil {
 		level.Error(logger).Log("msg", "component exited with error", "err", err)
 		exitMsg = fmt.Sprintf("component shut down with error: %s", err)
+		cn.recordError(err)
 	} else {
 		level.Info(logger).Log("msg", "component exited")
 		exitMsg = "component shut down normally"
 	}
 
 	cn.setRunHealth(component.HealthTypeExited, exitMsg)
+
+	// Components which exit before they started never will.
+	startErr := err
+	if startErr == nil {
+		startErr = errors.New("component exited before it started")
+	}
+	cn.markStarted(startErr)
 	return err
 }
 
+// runManaged runs the managed component until ctx is canceled or the run is
+// stopped by stopRun, returning whether the run was stopped.
+func (cn *ComponentNode) runManaged(ctx context.Context) (stopped bool, err error) {
+	runCtx, cancel := context.WithCancel(ctx)
+	run := &componentRun{cancel: cancel, exited: make(chan struct{})}
+
+	// The run is registered while cn.mut is held, so a concurrent restart
+	// either stops this run or finishes before the managed component is read.
+	cn.mut.RLock()
+	managed, singleton := cn.managed, cn.singleton
+	if managed != nil {
+		cn.runMut.Lock()
+		cn.currentRun = run
+		cn.runMut.Unlock()
+	}
+	cn.mut.RUnlock()
+
+	if managed == nil {
+		// A restart failed to build the component after Run last read it.
+		cancel()
+		return ctx.Err() == nil, nil
+	}
+
+	defer func() {
+		cn.runMut.Lock()
+		cn.currentRun = nil
+		stopped = run.stopped && ctx.Err() == nil
+		cn.runMut.Unlock()
+
+		cancel()
+		close(run.exited)
+	}()
+
+	cn.setRunHealth(component.HealthTypeHealthy, "started component")
+	cn.running.Store(true)
+	defer cn.running.Store(false)
+	if cn.exportsType == nil || cn.reported.Load() {
+		cn.markStarted(nil)
+	}
+
+	// Label the goroutine running the component so that it and any goroutines
+	// it spawns can be attributed to the component.
+	pprof.Do(runCtx, pprof.Labels(componentLabel, cn.globalID), func(ctx context.Context) {
+		if cn.breaker != nil {
+			defer cn.recoverPanic(&err)
+		}
+		if singleton != nil {
+			err = singleton.run(ctx)
+			return
+		}
+		err = managed.Run(ctx)
+	})
+	return stopped, err
+}
+
+// waitRestart waits for bo before a failed component restarts, unless its
+// circuit breaker just opened, in which case the delay starts over once the
+// breaker is reset. It returns false if ctx is canceled.
+func (cn *ComponentNode) waitRestart(ctx context.Context, bo *backoff.Backoff) bool {
+	if open, _ := cn.breaker.state(); open {
+		bo.Reset()
+		return ctx.Err() == nil
+	}
+	bo.Wait()
+	return ctx.Err() == nil
+}
+
+// rebuildFailed replaces the managed component, which failed, with a new
+// component built from the current arguments, so the restarted component
+// doesn't reuse the state of the failed one. The failed component is kept if
+// the build fails. Shared singleton components are rebuilt once for all of
+// their holders.
+func (cn *ComponentNode) rebuildFailed() error {
+	cn.mut.Lock()
+	if e := cn.singleton; e != nil {
+		cn.mut.Unlock()
+		return e.rebuildFailed()
+	}
+	defer cn.mut.Unlock()
+
+	managed, err := cn.reg.Build(cn.managedOpts, cn.args)
+	if err != nil {
+		return err
+	}
+	cn.setManaged(managed)
+	return nil
+}
+
+// recoverPanic recovers a panic of the managed component, setting *err to an
+// error describing it. It must be deferred.
+func (cn *ComponentNode) recoverPanic(err *error) {
+	if r := recover(); r != nil {
+		level.Error(cn.managedOpts.Logger).Log("msg", "recovered panic from component", "panic", r, "stack", string(debug.Stack()))
+		*err = fmt.Errorf("component panicked: %v", r)
+	}
+}
+
+// recordFailure records a failed run of the managed component with the
+// circuit breaker, and updates the run health to either report the restart
+// or the open breaker.
+func (cn *ComponentNode) recordFailure(err error) {
+	logger := cn.managedOpts.Logger
+	cn.recordError(err)
+
+	if open, reason := cn.breaker.fail(err); open {
+		level.Error(logger).Log("msg", "quarantining component until its circuit breaker is reset", "reason", reason)
+		cn.setRunHealth(component.HealthTypeExited, reason)
+		return
+	}
+	level.Warn(logger).Log("msg", "restarting failed component", "err", err)
+	reason := fmt.Sprintf("restarting component after failure: %s", err)
+	cn.setRunHealth(component.HealthTypeUnhealthy, reason)
+	if cn.onRestart != nil {
+		cn.onRestart(cn, reason)
+	}
+}
+
+// recordError adds err to the error history and reports it to the
+// OnComponentError callback of the ComponentGlobals.
+func (cn *ComponentNode) recordError(err error) {
+	cn.errors.Add(err)
+	if cn.onError != nil {
+		cn.onError(cn, err)
+	}
+}
+
+// ResetCircuitBreaker closes the open circuit breaker of the component, so
+// that Run starts the managed component again. It returns false if the
+// breaker is disabled or wasn't open.
+func (cn *ComponentNode) ResetCircuitBreaker() bool {
+	if cn.breaker == nil || !cn.breaker.reset() {
+		return false
+	}
+	level.Info(cn.managedOpts.Logger).Log("msg", "circuit breaker reset")
+	return true
+}
+
+// waitRebuilt waits for the managed component to be built after a restart.
+// It returns false if ctx is canceled first.
+func (cn *ComponentNode) waitRebuilt(ctx context.Context) bool {
+	for {
+		cn.mut.RLock()
+		built := cn.managed != nil
+		cn.mut.RUnlock()
+		if built {
+			return true
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-cn.rebuilt:
+		}
+	}
+}
+
+// Ready returns whether the managed component is running and ready. Managed
+// components which don't implement component.ReadyComponent are ready as
+// soon as they're running.
+func (cn *ComponentNode) Ready() bool {
+	if !cn.running.Load() {
+		return false
+	}
+
+	cn.mut.RLock()
+	managed := cn.managed
+	cn.mut.RUnlock()
+
+	if rc, ok := managed.(component.ReadyComponent); ok {
+		return rc.Ready()
+	}
+	return true
+}
+
+// WaitStarted waits for the managed component to finish its initial startup:
+// it's started once it's running and, if it has exports, it reported them
+// through OnStateChange at least once. Exports reported while the component
+// is built count, so components which export their initial state from their
+// constructor are started as soon as they run.
+//
+// WaitStarted returns an error if Run exits before the component started, or
+// ctx.Err() if ctx is canceled first. Components which are never run block
+// until ctx is canceled.
+func (cn *ComponentNode) WaitStarted(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-cn.started:
+		return cn.startErr
+	}
+}
+
+// markStarted completes the initial startup of the managed component, failing
+// it with err if non-nil. Only the first call has an effect.
+func (cn *ComponentNode) markStarted(err error) {
+	cn.startOnce.Do(func() {
+		cn.startErr = err
+		close(cn.started)
+	})
+}
+
 // ErrUnevaluated is returned if ComponentNode.Run is called before a managed
 // component is built.
 var ErrUnevaluated = errors.New("managed component not built")
@@ -370,6 +1014,14 @@ func (cn *ComponentNode) setExports(e component.Exports) {
 	// exports.
 	var changed bool
 
+	// The first exports reported while running complete the startup of the
+	// component. runManaged checks reported after setting running, so at
+	// least one of them marks the component as started.
+	cn.reported.Store(true)
+	if cn.running.Load() {
+		cn.markStarted(nil)
+	}
+
 	cn.exportsMut.Lock()
 	if !reflect.DeepEqual(cn.exports, e) {
 		changed = true
@@ -384,6 +1036,19 @@ func (cn *ComponentNode) setExports(e component.Exports) {
 	}
 }
 
+// BuildDuration returns how long the component took to evaluate in the most
+// recent config load. BuildDuration returns 0 if the component hasn't been
+// loaded yet.
+func (cn *ComponentNode) BuildDuration() time.Duration {
+	return cn.buildDuration.Load()
+}
+
+// Evaluations returns the number of times the component was evaluated, and
+// how long its most recent evaluation took.
+func (cn *ComponentNode) Evaluations() (count uint64, last time.Duration) {
+	return cn.evaluations.Load(), cn.evalDuration.Load()
+}
+
 // CurrentHealth returns the current health of the ComponentNode.
 //
 // The health of a ComponentNode is determined by combining:
@@ -408,6 +1073,18 @@ func (cn *ComponentNode) CurrentHealth() component.Health {
 	return component.LeastHealthy(runHealth, evalHealth)
 }
 
+// CheckHealth informs the controller through OnComponentUpdate if the health
+// state of the component changed since it was last exposed to dependants, so
+// that they're re-evaluated with the new health.
+//
+// Only changes to the health state are reported; a changed message or update
+// time alone doesn't cause dependants to be re-evaluated.
+func (cn *ComponentNode) CheckHealth() {
+	if component.HealthType(cn.cachedHealth.Load()) != cn.CurrentHealth().Health {
+		cn.OnComponentUpdate(cn)
+	}
+}
+
 // DebugInfo returns debugging information from the managed component (if any).
 func (cn *ComponentNode) DebugInfo() interface{} {
 	cn.mut.RLock()
@@ -419,6 +1096,13 @@ func (cn *ComponentNode) DebugInfo() interface{} {
 	return nil
 }
 
+// ErrorHistory returns up to limit of the most recent errors from evaluating
+// and running the managed component, oldest first. Every kept error is
+// returned when limit is less than 1.
+func (cn *ComponentNode) ErrorHistory(limit int) []component.TimestampedError {
+	return cn.errors.List(limit)
+}
+
 // setEvalHealth sets the internal health from a call to Evaluate. See Health
 // for information on how overall health is calculated.
 func (cn *ComponentNode) setEvalHealth(t component.HealthType, msg string) {
@@ -436,13 +1120,14 @@ func (cn *ComponentNode) setEvalHealth(t component.HealthType, msg string) {
 // information on how overall health is calculated.
 func (cn *ComponentNode) setRunHealth(t component.HealthType, msg string) {
 	cn.healthMut.Lock()
-	defer cn.healthMut.Unlock()
-
 	cn.runHealth = component.Health{
 		Health:     t,
 		Message:    msg,
 		UpdateTime: time.Now(),
 	}
+	cn.healthMut.Unlock()
+
+	cn.CheckHealth()
 }
 
 // ModuleIDs returns the current list of modules that this component is
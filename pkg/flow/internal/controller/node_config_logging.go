@@ -55,13 +55,9 @@ func NewDefaultLoggingConfigNode(globals ComponentGlobals) *LoggingConfigNode {
 // Evaluate will return an error if the River block cannot be evaluated or if
 // decoding to arguments fails.
 func (cn *LoggingConfigNode) Evaluate(scope *vm.Scope) error {
-	cn.mut.RLock()
-	defer cn.mut.RUnlock()
-	args := logging.DefaultOptions
-	if cn.eval != nil {
-		if err := cn.eval.Evaluate(scope, &args); err != nil {
-			return fmt.Errorf("decoding River: %w", err)
-		}
+	args, err := cn.decodeArgs(scope)
+	if err != nil {
+		return err
 	}
 
 	if err := cn.l.(*logging.Logger).Update(args); err != nil {
@@ -71,6 +67,27 @@ func (cn *LoggingConfigNode) Evaluate(scope *vm.Scope) error {
 	return nil
 }
 
+// stage evaluates the logging block without updating the logger, so Apply
+// can evaluate a new graph before committing to it.
+func (cn *LoggingConfigNode) stage(scope *vm.Scope) error {
+	_, err := cn.decodeArgs(scope)
+	return err
+}
+
+// decodeArgs evaluates the block of cn against scope, starting from the
+// default options.
+func (cn *LoggingConfigNode) decodeArgs(scope *vm.Scope) (logging.Options, error) {
+	cn.mut.RLock()
+	defer cn.mut.RUnlock()
+	args := logging.DefaultOptions
+	if cn.eval != nil {
+		if err := cn.eval.Evaluate(scope, &args); err != nil {
+			return args, fmt.Errorf("decoding River: %w", err)
+		}
+	}
+	return args, nil
+}
+
 // Block implements BlockNode and returns the current block of the managed config node.
 func (cn *LoggingConfigNode) Block() *ast.BlockStmt {
 	cn.mut.RLock()
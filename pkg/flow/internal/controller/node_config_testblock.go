@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/grafana/river/ast"
+	"github.com/grafana/river/diag"
+	"github.com/grafana/river/vm"
+)
+
+// testAssertAttr is the name of the attribute of test blocks holding the
+// assertion to check.
+const testAssertAttr = "assert"
+
+// TestConfigNode is a config node for a test block, which asserts that an
+// expression is true whenever it's evaluated. Test blocks may reference
+// components to verify how they're wired together.
+type TestConfigNode struct {
+	label  string
+	nodeID string
+
+	mut   sync.RWMutex
+	block *ast.BlockStmt // Current River blocks to derive config from
+	eval  *vm.Evaluator
+}
+
+var _ BlockNode = (*TestConfigNode)(nil)
+
+// NewTestConfigNode creates a new TestConfigNode from an initial
+// ast.BlockStmt. The assertion isn't checked until Evaluate is called.
+func NewTestConfigNode(block *ast.BlockStmt, globals ComponentGlobals) *TestConfigNode {
+	return &TestConfigNode{
+		label:  block.Label,
+		nodeID: BlockComponentID(block).String(),
+
+		block: block,
+		eval:  vm.New(block.Body),
+	}
+}
+
+type testBlock struct {
+	Assert  bool   `river:"assert,attr"`
+	Message string `river:"message,attr,optional"`
+}
+
+// Evaluate implements BlockNode and checks the assertion of the test block
+// with the provided scope.
+//
+// Evaluate returns an error if the River block cannot be evaluated or if the
+// assertion is false. Failed assertions are returned as diagnostics pointing
+// at the asserted expression.
+func (cn *TestConfigNode) Evaluate(scope *vm.Scope) error {
+	cn.mut.Lock()
+	defer cn.mut.Unlock()
+
+	var test testBlock
+	if err := cn.eval.Evaluate(scope, &test); err != nil {
+		return fmt.Errorf("decoding River: %w", err)
+	}
+	if test.Assert {
+		return nil
+	}
+
+	msg := fmt.Sprintf("assertion in %s failed", cn.nodeID)
+	if test.Message != "" {
+		msg += ": " + test.Message
+	}
+
+	var expr ast.Node = cn.block
+	for _, stmt := range cn.block.Body {
+		if attr, ok := stmt.(*ast.AttributeStmt); ok && attr.Name.Name == testAssertAttr {
+			expr = attr.Value
+		}
+	}
+
+	return diag.Diagnostics{{
+		Severity: diag.SeverityLevelError,
+		Message:  msg,
+		StartPos: ast.StartPos(expr).Position(),
+		EndPos:   ast.EndPos(expr).Position(),
+	}}
+}
+
+func (cn *TestConfigNode) Label() string { return cn.label }
+
+// Block implements BlockNode and returns the current block of the managed config node.
+func (cn *TestConfigNode) Block() *ast.BlockStmt {
+	cn.mut.RLock()
+	defer cn.mut.RUnlock()
+	return cn.block
+}
+
+// NodeID implements dag.Node and returns the unique ID for the config node.
+func (cn *TestConfigNode) NodeID() string { return cn.nodeID }
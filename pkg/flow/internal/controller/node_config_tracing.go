@@ -55,13 +55,9 @@ func NewDefaulTracingConfigNode(globals ComponentGlobals) *TracingConfigNode {
 // Evaluate will return an error if the River block cannot be evaluated or if
 // decoding to arguments fails.
 func (cn *TracingConfigNode) Evaluate(scope *vm.Scope) error {
-	cn.mut.RLock()
-	defer cn.mut.RUnlock()
-	args := tracing.DefaultOptions
-	if cn.eval != nil {
-		if err := cn.eval.Evaluate(scope, &args); err != nil {
-			return fmt.Errorf("decoding River: %w", err)
-		}
+	args, err := cn.decodeArgs(scope)
+	if err != nil {
+		return err
 	}
 
 	t, ok := cn.traceProvider.(*tracing.Tracer)
@@ -75,6 +71,27 @@ func (cn *TracingConfigNode) Evaluate(scope *vm.Scope) error {
 	return nil
 }
 
+// stage evaluates the tracing block without updating the tracer, so Apply
+// can evaluate a new graph before committing to it.
+func (cn *TracingConfigNode) stage(scope *vm.Scope) error {
+	_, err := cn.decodeArgs(scope)
+	return err
+}
+
+// decodeArgs evaluates the block of cn against scope, starting from the
+// default options.
+func (cn *TracingConfigNode) decodeArgs(scope *vm.Scope) (tracing.Options, error) {
+	cn.mut.RLock()
+	defer cn.mut.RUnlock()
+	args := tracing.DefaultOptions
+	if cn.eval != nil {
+		if err := cn.eval.Evaluate(scope, &args); err != nil {
+			return args, fmt.Errorf("decoding River: %w", err)
+		}
+	}
+	return args, nil
+}
+
 // Block implements BlockNode and returns the current block of the managed config node.
 func (cn *TracingConfigNode) Block() *ast.BlockStmt {
 	cn.mut.RLock()
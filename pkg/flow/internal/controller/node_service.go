@@ -89,24 +89,11 @@ func (sn *ServiceNode) Evaluate(scope *vm.Scope) error {
 	sn.mut.Lock()
 	defer sn.mut.Unlock()
 
-	switch {
-	case sn.block != nil && sn.def.ConfigType == nil:
-		return fmt.Errorf("service %q does not support being configured", sn.NodeID())
-
-	case sn.def.ConfigType == nil:
-		return nil // Do nothing; no configuration.
-	}
-
-	argsPointer := reflect.New(reflect.TypeOf(sn.def.ConfigType)).Interface()
-
-	if err := sn.eval.Evaluate(scope, argsPointer); err != nil {
-		return fmt.Errorf("decoding River: %w", err)
+	argsCopyValue, err := sn.decodeArgs(scope)
+	if err != nil || argsCopyValue == nil {
+		return err
 	}
 
-	// args is always a pointer to the args type, so we want to deference it
-	// since services expect a non-pointer.
-	argsCopyValue := reflect.ValueOf(argsPointer).Elem().Interface()
-
 	if reflect.DeepEqual(sn.args, argsCopyValue) {
 		// Ignore arguments which haven't changed. This reduces the cost of calling
 		// evaluate for services where evaluation is expensive (e.g., if
@@ -123,6 +110,39 @@ func (sn *ServiceNode) Evaluate(scope *vm.Scope) error {
 	return nil
 }
 
+// stage evaluates the configuration for a service without updating the
+// service, so Apply can evaluate a new graph before committing to it.
+func (sn *ServiceNode) stage(scope *vm.Scope) error {
+	sn.mut.RLock()
+	defer sn.mut.RUnlock()
+
+	_, err := sn.decodeArgs(scope)
+	return err
+}
+
+// decodeArgs evaluates the block of sn against scope into a new value of the
+// config type of the service. It returns nil arguments if the service has no
+// configuration. sn.mut must be held, at least for reading.
+func (sn *ServiceNode) decodeArgs(scope *vm.Scope) (any, error) {
+	switch {
+	case sn.block != nil && sn.def.ConfigType == nil:
+		return nil, fmt.Errorf("service %q does not support being configured", sn.NodeID())
+
+	case sn.def.ConfigType == nil:
+		return nil, nil // Do nothing; no configuration.
+	}
+
+	argsPointer := reflect.New(reflect.TypeOf(sn.def.ConfigType)).Interface()
+
+	if err := sn.eval.Evaluate(scope, argsPointer); err != nil {
+		return nil, fmt.Errorf("decoding River: %w", err)
+	}
+
+	// args is always a pointer to the args type, so we want to deference it
+	// since services expect a non-pointer.
+	return reflect.ValueOf(argsPointer).Elem().Interface(), nil
+}
+
 func (sn *ServiceNode) Run(ctx context.Context) error {
 	return sn.svc.Run(ctx, sn.host)
 }
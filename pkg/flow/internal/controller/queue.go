@@ -47,6 +47,15 @@ func (q *Queue) Enqueue(c *ComponentNode) {
 // Chan returns a channel which is written to when the queue is non-empty.
 func (q *Queue) Chan() <-chan struct{} { return q.updateCh }
 
+// Queued returns the components in the queue, in the order they were
+// inserted, without removing them.
+func (q *Queue) Queued() []*ComponentNode {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+
+	return append([]*ComponentNode(nil), q.queuedOrder...)
+}
+
 // DequeueAll removes all components from the queue and returns them.
 func (q *Queue) DequeueAll() []*ComponentNode {
 	q.mut.Lock()
@@ -40,6 +40,17 @@ func TestDequeue_InOrder(t *testing.T) {
 	require.Same(t, c3, all[2])
 }
 
+func TestQueued(t *testing.T) {
+	c1, c2 := &ComponentNode{}, &ComponentNode{}
+	q := NewQueue()
+	q.Enqueue(c1)
+	q.Enqueue(c2)
+	require.Equal(t, []*ComponentNode{c1, c2}, q.Queued())
+	require.Len(t, q.queuedSet, 2, "Queued must not remove components")
+	require.Len(t, q.DequeueAll(), 2)
+	require.Empty(t, q.Queued())
+}
+
 func TestDequeue_NoDuplicates(t *testing.T) {
 	c1, c2 := &ComponentNode{}, &ComponentNode{}
 	q := NewQueue()
@@ -0,0 +1,155 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/grafana/agent/pkg/flow/internal/dag"
+	"github.com/grafana/river/ast"
+	"github.com/grafana/river/token"
+	"github.com/grafana/river/vm"
+)
+
+// NewReferenceGraph builds a graph from config and component blocks where
+// each edge represents one block referencing another. Unlike the graph built
+// by the Loader, blocks are never built or evaluated, and the graph isn't
+// reduced, so every reference made by a block is kept as an edge.
+//
+// Component blocks which are disabled through their enabled meta-argument
+// are left out, in the same way as the Loader. References which don't resolve
+// to any of the blocks are ignored. If multiple blocks have the same ID, only
+// the first is added to the graph.
+func NewReferenceGraph(configBlocks, componentBlocks []*ast.BlockStmt) *dag.Graph {
+	var g dag.Graph
+
+	add := func(block *ast.BlockStmt) {
+		id := BlockComponentID(block).String()
+		if g.GetByID(id) != nil {
+			return
+		}
+		g.Add(&referenceNode{id: id, block: block})
+	}
+
+	for _, block := range configBlocks {
+		add(block)
+	}
+	for _, block := range componentBlocks {
+		enabled, stripped, diags := evaluateEnabled(block, stdlibScope)
+		switch {
+		case diags.HasErrors():
+			// Keep blocks whose enabled attribute can't be evaluated so they can
+			// still be inspected.
+			add(block)
+		case enabled:
+			add(stripped)
+		}
+	}
+
+	for _, n := range g.Nodes() {
+		refs, _ := ComponentReferences(n, &g)
+		for _, ref := range refs {
+			g.AddEdge(dag.Edge{From: n, To: ref.Target})
+		}
+	}
+
+	return &g
+}
+
+// EdgeReferences returns the expressions in the block of e.From which
+// reference e.To, sorted and with duplicates removed. Expressions are returned
+// up to the last field accessed before any indexing or function calls.
+func EdgeReferences(g *dag.Graph, e dag.Edge) []string {
+	refs, _ := ComponentReferences(e.From, g)
+
+	seen := make(map[string]struct{}, len(refs))
+	for _, ref := range refs {
+		if ref.Target != e.To {
+			continue
+		}
+
+		parts := []string{ref.Target.NodeID()}
+		for _, ident := range ref.Traversal {
+			parts = append(parts, ident.Name)
+		}
+		seen[strings.Join(parts, ".")] = struct{}{}
+	}
+
+	exprs := make([]string, 0, len(seen))
+	for expr := range seen {
+		exprs = append(exprs, expr)
+	}
+	sort.Strings(exprs)
+	return exprs
+}
+
+// ReferenceSource describes an expression in a block which references another
+// block.
+type ReferenceSource struct {
+	// Attribute is the dot-separated path of the attribute holding the
+	// expression, such as "client.url" for the url attribute of a client
+	// block.
+	Attribute string
+
+	// Expression is the referenced expression, up to the last field accessed
+	// before any indexing or function calls.
+	Expression string
+
+	Pos token.Position // Position of the expression.
+}
+
+// EdgeSources returns every expression in the block of e.From which
+// references e.To, in the order they appear in the block.
+func EdgeSources(g *dag.Graph, e dag.Edge) []ReferenceSource {
+	bn, ok := e.From.(BlockNode)
+	if !ok || bn.Block() == nil {
+		return nil
+	}
+	return edgeSources(g, e.To, "", bn.Block().Body)
+}
+
+// edgeSources returns the expressions in body which reference target.
+// Attributes in body are named relative to prefix.
+func edgeSources(g *dag.Graph, target dag.Node, prefix string, body ast.Body) []ReferenceSource {
+	var sources []ReferenceSource
+	for _, stmt := range body {
+		switch stmt := stmt.(type) {
+		case *ast.BlockStmt:
+			sources = append(sources, edgeSources(g, target, prefix+stmt.GetBlockName()+".", stmt.Body)...)
+
+		case *ast.AttributeStmt:
+			traversals, _ := expressionsFromBody(ast.Body{stmt})
+			for _, t := range traversals {
+				if _, ok := stdlibScope.Lookup(t[0].Name); ok {
+					continue
+				}
+				ref, diags := resolveTraversal(t, g)
+				if diags.HasErrors() || ref.Target != target {
+					continue
+				}
+				sources = append(sources, ReferenceSource{
+					Attribute:  prefix + stmt.Name.Name,
+					Expression: traversalString(t),
+					Pos:        ast.StartPos(t[0]).Position(),
+				})
+			}
+		}
+	}
+	return sources
+}
+
+// referenceNode is a BlockNode used for inspecting the references between
+// blocks without building them.
+type referenceNode struct {
+	id    string
+	block *ast.BlockStmt
+}
+
+var _ BlockNode = (*referenceNode)(nil)
+
+func (n *referenceNode) NodeID() string        { return n.id }
+func (n *referenceNode) Block() *ast.BlockStmt { return n.block }
+
+func (n *referenceNode) Evaluate(*vm.Scope) error {
+	return fmt.Errorf("block %s can't be evaluated from a reference graph", n.id)
+}
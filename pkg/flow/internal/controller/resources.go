@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"bytes"
+	"runtime/pprof"
+
+	"github.com/google/pprof/profile"
+)
+
+// componentLabel is the pprof label used to attribute goroutines to the
+// component which started them.
+const componentLabel = "component_id"
+
+// GoroutinesByComponent returns the number of running goroutines attributed to
+// each component, keyed by the global ID of the component.
+//
+// Goroutines are attributed to a component when they are started from the
+// goroutine running the component or any of its descendants. Goroutines which
+// are started by shared code on behalf of a component (such as a shared
+// worker pool) are not attributed, so the counts are an approximation.
+func GoroutinesByComponent() (map[string]int, error) {
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 0); err != nil {
+		return nil, err
+	}
+	p, err := profile.Parse(&buf)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	for _, sample := range p.Sample {
+		ids := sample.Label[componentLabel]
+		if len(ids) == 0 || len(sample.Value) == 0 {
+			continue
+		}
+		counts[ids[0]] += int(sample.Value[0])
+	}
+	return counts, nil
+}
@@ -3,7 +3,9 @@ package controller
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
+	"time"
 )
 
 // RunnableNode is any dag.Node which can also be run.
@@ -12,6 +14,24 @@ type RunnableNode interface {
 	Run(ctx context.Context) error
 }
 
+// PrioritizedNode is a RunnableNode with a startup priority. A
+// PrioritizedNode is only run once the PrioritizedNodes with a higher
+// priority which precede it in a call to Scheduler.Synchronize finished
+// starting up.
+type PrioritizedNode interface {
+	RunnableNode
+	Priority() int
+
+	// WaitStarted waits for the node to finish starting up. It returns an
+	// error if the node failed to start or ctx is canceled.
+	WaitStarted(ctx context.Context) error
+}
+
+// priorityWaitTimeout is the longest a PrioritizedNode waits for nodes with a
+// higher priority to start before it runs anyway, so a node which never
+// finishes starting up doesn't block the rest of the graph.
+const priorityWaitTimeout = 30 * time.Second
+
 // Scheduler runs components.
 type Scheduler struct {
 	ctx     context.Context
@@ -38,12 +58,17 @@ func NewScheduler() *Scheduler {
 
 // Synchronize synchronizes the running components to those defined by rr.
 //
-// New RunnableNodes will be launched as new goroutines. RunnableNodes already
-// managed by Scheduler will be kept running, while running RunnableNodes that
-// are not in rr will be shut down and removed.
+// New RunnableNodes will be launched as new goroutines, in the order they
+// appear in rr. RunnableNodes already managed by Scheduler will be kept
+// running, while running RunnableNodes that are not in rr will be shut down
+// and removed.
 //
 // Existing components will be restarted if they stopped since the previous
 // call to Synchronize.
+//
+// A new PrioritizedNode waits, for up to 30 seconds, for the
+// PrioritizedNodes with a higher priority which precede it in rr to finish
+// starting up before it's run. Failures to start don't delay other nodes.
 func (s *Scheduler) Synchronize(rr []RunnableNode) error {
 	s.tasksMut.Lock()
 	defer s.tasksMut.Unlock()
@@ -72,7 +97,15 @@ func (s *Scheduler) Synchronize(rr []RunnableNode) error {
 	}
 
 	// Launch new runnables that have appeared.
-	for id, r := range newRunnables {
+	var preceding priorityGroups
+	for _, r := range rr {
+		var waitFor []PrioritizedNode
+		if p, ok := r.(PrioritizedNode); ok {
+			waitFor = preceding.higherThan(p.Priority())
+			preceding.add(p)
+		}
+
+		id := r.NodeID()
 		if _, exist := s.tasks[id]; exist {
 			continue
 		}
@@ -85,6 +118,7 @@ func (s *Scheduler) Synchronize(rr []RunnableNode) error {
 		opts := taskOptions{
 			Context:  s.ctx,
 			Runnable: newRunnable,
+			WaitFor:  waitFor,
 			OnDone: func() {
 				defer s.running.Done()
 
@@ -103,6 +137,27 @@ func (s *Scheduler) Synchronize(rr []RunnableNode) error {
 	return nil
 }
 
+// Running returns the sorted IDs of the runnables which are still running.
+// Every runnable is run by a single goroutine, which is removed from the
+// Scheduler shortly after the runnable exits.
+func (s *Scheduler) Running() []string {
+	s.tasksMut.Lock()
+	defer s.tasksMut.Unlock()
+
+	ids := make([]string, 0, len(s.tasks))
+	for id, t := range s.tasks {
+		select {
+		case <-t.exited:
+			// Exited, but not removed yet.
+			continue
+		default:
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
 // Close stops the Scheduler and returns after all running goroutines have
 // exited.
 func (s *Scheduler) Close() error {
@@ -121,6 +176,7 @@ type task struct {
 type taskOptions struct {
 	Context  context.Context
 	Runnable RunnableNode
+	WaitFor  []PrioritizedNode // Nodes which must start before Runnable runs.
 	OnDone   func()
 }
 
@@ -137,6 +193,9 @@ func newTask(opts taskOptions) *task {
 	go func() {
 		defer opts.OnDone()
 		defer close(t.exited)
+		if len(opts.WaitFor) > 0 {
+			waitStarted(t.ctx, opts.WaitFor)
+		}
 		_ = opts.Runnable.Run(t.ctx)
 	}()
 	return t
@@ -146,3 +205,35 @@ func (t *task) Stop() {
 	t.cancel()
 	<-t.exited
 }
+
+// waitStarted waits for every node in nodes to finish starting up, or to fail
+// to, for at most priorityWaitTimeout.
+func waitStarted(ctx context.Context, nodes []PrioritizedNode) {
+	ctx, cancel := context.WithTimeout(ctx, priorityWaitTimeout)
+	defer cancel()
+	for _, n := range nodes {
+		_ = n.WaitStarted(ctx)
+	}
+}
+
+// priorityGroups groups PrioritizedNodes by their priority.
+type priorityGroups map[int][]PrioritizedNode
+
+// add adds n to the group of its priority.
+func (g *priorityGroups) add(n PrioritizedNode) {
+	if *g == nil {
+		*g = make(priorityGroups)
+	}
+	(*g)[n.Priority()] = append((*g)[n.Priority()], n)
+}
+
+// higherThan returns the nodes with a priority higher than priority.
+func (g priorityGroups) higherThan(priority int) []PrioritizedNode {
+	var nodes []PrioritizedNode
+	for p, group := range g {
+		if p > priority {
+			nodes = append(nodes, group...)
+		}
+	}
+	return nodes
+}
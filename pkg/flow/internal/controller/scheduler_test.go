@@ -4,6 +4,7 @@ import (
 	"context"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/grafana/agent/component"
 	"github.com/grafana/agent/pkg/flow/internal/controller"
@@ -86,6 +87,44 @@ func TestScheduler_Synchronize(t *testing.T) {
 	})
 }
 
+func TestScheduler_Running(t *testing.T) {
+	var started sync.WaitGroup
+	started.Add(2)
+
+	blocking := func(ctx context.Context) error {
+		started.Done()
+		<-ctx.Done()
+		return nil
+	}
+	exited := make(chan struct{})
+	exiting := func(ctx context.Context) error {
+		defer close(exited)
+		return nil
+	}
+
+	sched := controller.NewScheduler()
+	defer sched.Close()
+
+	sched.Synchronize([]controller.RunnableNode{
+		fakeRunnable{ID: "component-b", Component: mockComponent{RunFunc: blocking}},
+		fakeRunnable{ID: "component-a", Component: mockComponent{RunFunc: blocking}},
+		fakeRunnable{ID: "component-c", Component: mockComponent{RunFunc: exiting}},
+	})
+	started.Wait()
+	<-exited
+
+	// Runnables which exited aren't running anymore.
+	require.Eventually(t, func() bool {
+		return len(sched.Running()) == 2
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{"component-a", "component-b"}, sched.Running())
+
+	sched.Synchronize([]controller.RunnableNode{
+		fakeRunnable{ID: "component-a", Component: mockComponent{RunFunc: blocking}},
+	})
+	require.Equal(t, []string{"component-a"}, sched.Running())
+}
+
 type fakeRunnable struct {
 	ID        string
 	Component component.Component
@@ -105,3 +144,75 @@ var _ component.Component = (*mockComponent)(nil)
 
 func (mc mockComponent) Run(ctx context.Context) error              { return mc.RunFunc(ctx) }
 func (mc mockComponent) Update(newConfig component.Arguments) error { return mc.UpdateFunc(newConfig) }
+
+func TestScheduler_Priority(t *testing.T) {
+	var (
+		mut   sync.Mutex
+		order []string
+	)
+	run := func(id string) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			mut.Lock()
+			order = append(order, id)
+			mut.Unlock()
+			<-ctx.Done()
+			return nil
+		}
+	}
+
+	high := &prioritizedRunnable{
+		fakeRunnable: fakeRunnable{ID: "high", Component: mockComponent{RunFunc: run("high")}},
+		priority:     10,
+		started:      make(chan struct{}),
+	}
+	low := &prioritizedRunnable{
+		fakeRunnable: fakeRunnable{ID: "low", Component: mockComponent{RunFunc: run("low")}},
+		started:      make(chan struct{}),
+	}
+
+	sched := controller.NewScheduler()
+	defer sched.Close()
+	sched.Synchronize([]controller.RunnableNode{high, low})
+
+	// low waits for high to finish starting up, even though high is already
+	// running.
+	require.Eventually(t, func() bool {
+		mut.Lock()
+		defer mut.Unlock()
+		return len(order) == 1
+	}, time.Second, 10*time.Millisecond)
+	require.Never(t, func() bool {
+		mut.Lock()
+		defer mut.Unlock()
+		return len(order) != 1
+	}, 100*time.Millisecond, 10*time.Millisecond)
+
+	close(high.started)
+	require.Eventually(t, func() bool {
+		mut.Lock()
+		defer mut.Unlock()
+		return len(order) == 2
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{"high", "low"}, order)
+}
+
+// prioritizedRunnable is a fakeRunnable with a priority, which has started
+// once started is closed.
+type prioritizedRunnable struct {
+	fakeRunnable
+	priority int
+	started  chan struct{}
+}
+
+var _ controller.PrioritizedNode = (*prioritizedRunnable)(nil)
+
+func (pr *prioritizedRunnable) Priority() int { return pr.priority }
+
+func (pr *prioritizedRunnable) WaitStarted(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-pr.started:
+		return nil
+	}
+}
@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/grafana/agent/pkg/flow/internal/dag"
+	"github.com/grafana/river/ast"
+	"github.com/grafana/river/diag"
+	"github.com/grafana/river/vm"
+)
+
+// ShadowedReferences returns a warning diagnostic for every component in g
+// whose namespace, the first part of its name, is also the name of an
+// identifier in functions, such as a standard library function. Diagnostics
+// are sorted by component ID.
+//
+// Expressions using such a name are ambiguous: the graph treats them as
+// references to the identifier, so they don't make the referencing block
+// depend on the component, while evaluation may resolve them to the
+// component.
+func ShadowedReferences(g *dag.Graph, functions *vm.Scope) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	nodes := g.Nodes()
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].NodeID() < nodes[j].NodeID()
+	})
+
+	for _, n := range nodes {
+		cn, ok := n.(*ComponentNode)
+		if !ok {
+			continue
+		}
+
+		namespace := cn.ID()[0]
+		kind := shadowedIdentifierKind(functions, namespace)
+		if kind == "" {
+			continue
+		}
+
+		block := cn.Block()
+		diags.Add(diag.Diagnostic{
+			Severity: diag.SeverityLevelWarn,
+			Message: fmt.Sprintf("component %s shadows the %s %q; expressions using %q are ambiguous and may not reference the component",
+				cn.NodeID(), kind, namespace, namespace),
+			StartPos: ast.StartPos(block).Position(),
+			EndPos:   ast.EndPos(block).Position(),
+		})
+	}
+
+	return diags
+}
+
+// shadowedIdentifierKind describes the identifier called name in functions,
+// or returns an empty string if there's no such identifier.
+func shadowedIdentifierKind(functions *vm.Scope, name string) string {
+	// Custom identifiers may replace standard library functions, such as
+	// dns_lookup when a controller configures its own resolver, so the
+	// standard library is checked first.
+	if _, ok := stdlibScope.Lookup(name); ok {
+		return "standard library identifier"
+	}
+	if _, ok := functions.Lookup(name); ok {
+		return "custom identifier"
+	}
+	return ""
+}
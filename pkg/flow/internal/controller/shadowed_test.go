@@ -0,0 +1,44 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/pkg/flow/internal/dag"
+	"github.com/grafana/river/ast"
+	"github.com/grafana/river/parser"
+	"github.com/grafana/river/vm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShadowedReferences(t *testing.T) {
+	file, err := parser.ParseFile(t.Name(), []byte(`
+		concat.join "a" {}
+		custom.fn "b" {}
+		source.exports "c" {}
+	`))
+	require.NoError(t, err)
+
+	globals := ComponentGlobals{
+		NewModuleController: func(id string) ModuleController { return nil },
+	}
+
+	var g dag.Graph
+	for _, stmt := range file.Body {
+		block := stmt.(*ast.BlockStmt)
+		name := block.GetBlockName()
+		g.Add(NewComponentNode(globals, component.Registration{Name: name, Args: struct{}{}}, block))
+	}
+
+	functions := &vm.Scope{
+		Parent:    stdlibScope,
+		Variables: map[string]any{"custom": func() string { return "" }},
+	}
+	diags := ShadowedReferences(&g, functions)
+	require.Len(t, diags, 2)
+	require.Equal(t, `component concat.join.a shadows the standard library identifier "concat"; expressions using "concat" are ambiguous and may not reference the component`, diags[0].Message)
+	require.Equal(t, `component custom.fn.b shadows the custom identifier "custom"; expressions using "custom" are ambiguous and may not reference the component`, diags[1].Message)
+
+	// Without custom functions, only the standard library can be shadowed.
+	require.Len(t, ShadowedReferences(&g, stdlibScope), 1)
+}
@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/grafana/agent/pkg/flow/internal/dag"
+	"github.com/grafana/river/ast"
+	"github.com/grafana/river/diag"
+)
+
+// UnreferencedComponents returns a warning diagnostic for every component in
+// g which is not referenced by any other node in g. Diagnostics are sorted by
+// component ID.
+//
+// Sinks are never reported, since they are not expected to be referenced by
+// other components. Components are sinks when registered as one, or when
+// their block sets the sink meta-argument.
+func UnreferencedComponents(g *dag.Graph) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	roots := g.Roots()
+	sort.Slice(roots, func(i, j int) bool {
+		return roots[i].NodeID() < roots[j].NodeID()
+	})
+
+	for _, n := range roots {
+		cn, ok := n.(*ComponentNode)
+		if !ok || cn.IsSink() {
+			continue
+		}
+
+		block := cn.Block()
+		diags.Add(diag.Diagnostic{
+			Severity: diag.SeverityLevelWarn,
+			Message:  fmt.Sprintf("component %s is not referenced by any other component", cn.NodeID()),
+			StartPos: ast.StartPos(block).Position(),
+			EndPos:   ast.EndPos(block).Position(),
+		})
+	}
+
+	return diags
+}
@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/pkg/flow/internal/dag"
+	"github.com/grafana/river/ast"
+	"github.com/grafana/river/parser"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnreferencedComponents(t *testing.T) {
+	file, err := parser.ParseFile(t.Name(), []byte(`
+		source.exports "used" {}
+		source.exports "unused" {}
+		source.noexports "leaf" {}
+		source.sink "writer" {}
+		source.exports "declared" { sink = true }
+		source.sink "overridden" { sink = false }
+	`))
+	require.NoError(t, err)
+
+	registrations := map[string]component.Registration{
+		"source.exports":   {Name: "source.exports", Args: struct{}{}, Exports: struct{}{}},
+		"source.noexports": {Name: "source.noexports", Args: struct{}{}},
+		"source.sink":      {Name: "source.sink", Args: struct{}{}, Exports: struct{}{}, Sink: true},
+	}
+	globals := ComponentGlobals{
+		NewModuleController: func(id string) ModuleController { return nil },
+	}
+
+	var g dag.Graph
+	for _, stmt := range file.Body {
+		block := stmt.(*ast.BlockStmt)
+		reg := registrations[block.GetBlockName()]
+		sink, block, sinkDiags := evaluateSink(block, reg, nil)
+		require.NoError(t, sinkDiags.ErrorOrNil())
+
+		cn := NewComponentNode(globals, reg, block)
+		cn.setSink(sink)
+		g.Add(cn)
+	}
+	g.AddEdge(dag.Edge{
+		From: g.GetByID("source.noexports.leaf"),
+		To:   g.GetByID("source.exports.used"),
+	})
+
+	diags := UnreferencedComponents(&g)
+	require.Len(t, diags, 2)
+	require.Equal(t, "component source.exports.unused is not referenced by any other component", diags[0].Message)
+	require.Equal(t, "component source.sink.overridden is not referenced by any other component", diags[1].Message)
+}
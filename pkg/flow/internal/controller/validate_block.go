@@ -0,0 +1,176 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/grafana/river/ast"
+	"github.com/grafana/river/diag"
+	"github.com/grafana/river/parser"
+	"github.com/grafana/river/vm"
+)
+
+// ValidateBlock parses src, which must hold a single component block, and
+// evaluates its arguments without building the component. Expressions in the
+// block may reference the standard library, the custom functions in
+// functions, and the values in refs, which are keyed by the ID of the
+// component or value they're referenced by, such as
+// "prometheus.remote_write.default". References to anything else are reported
+// as errors.
+//
+// Components which don't exist in reg or which aren't allowed by policy are
+// reported in the same way as when loading a config.
+func ValidateBlock(reg ComponentRegistry, policy ComponentPolicy, functions map[string]any, src []byte, refs map[string]any) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	if reg == nil {
+		reg = DefaultComponentRegistry{}
+	}
+	funcScope := stdlibScope
+	if len(functions) > 0 {
+		funcScope = &vm.Scope{Parent: stdlibScope, Variables: functions}
+	}
+
+	file, err := parser.ParseFile("block", src)
+	if err != nil {
+		var parseDiags diag.Diagnostics
+		if errors.As(err, &parseDiags) {
+			return parseDiags
+		}
+		diags.Add(diag.Diagnostic{
+			Severity: diag.SeverityLevelError,
+			Message:  fmt.Sprintf("Failed to parse block: %s", err),
+		})
+		return diags
+	}
+
+	var block *ast.BlockStmt
+	if len(file.Body) == 1 {
+		block, _ = file.Body[0].(*ast.BlockStmt)
+	}
+	if block == nil {
+		diags.Add(diag.Diagnostic{
+			Severity: diag.SeverityLevelError,
+			Message:  "Expected a single component block",
+		})
+		return diags
+	}
+
+	componentName := strings.Join(block.Name, ".")
+	namePos := func(msg string) diag.Diagnostic {
+		return diag.Diagnostic{
+			Severity: diag.SeverityLevelError,
+			Message:  msg,
+			StartPos: block.NamePos.Position(),
+			EndPos:   block.NamePos.Add(len(componentName) - 1).Position(),
+		}
+	}
+
+	registration, exists := reg.Get(componentName)
+	switch {
+	case !exists:
+		diags.Add(namePos(fmt.Sprintf("Unrecognized component name %q", componentName)))
+		return diags
+	case !policy.Allows(componentName):
+		diags.Add(namePos(fmt.Sprintf("Component %q is not allowed by the component policy", componentName)))
+		return diags
+	}
+	if labelDiags := validateBlockLabel(block, labelRequired); labelDiags.HasErrors() {
+		return append(diags, labelDiags...)
+	}
+
+	// Meta-arguments are evaluated and removed in the same order as when
+	// loading a config.
+	enabled, block, enabledDiags := evaluateEnabled(block, funcScope)
+	diags = append(diags, enabledDiags...)
+	if diags.HasErrors() || !enabled {
+		return diags
+	}
+	_, block, priorityDiags := evaluatePriority(block, funcScope)
+	diags = append(diags, priorityDiags...)
+	if diags.HasErrors() {
+		return diags
+	}
+	_, block, tagsDiags := evaluateTags(block, registration.Args, funcScope)
+	diags = append(diags, tagsDiags...)
+	if diags.HasErrors() {
+		return diags
+	}
+	_, block, labelsDiags := evaluateMetricLabels(block, funcScope)
+	diags = append(diags, labelsDiags...)
+	if diags.HasErrors() {
+		return diags
+	}
+	_, block, expectDiags := evaluateExpectTypes(block, funcScope)
+	diags = append(diags, expectDiags...)
+	if diags.HasErrors() {
+		return diags
+	}
+	_, block, sinkDiags := evaluateSink(block, registration, funcScope)
+	diags = append(diags, sinkDiags...)
+	if diags.HasErrors() {
+		return diags
+	}
+
+	// Calls to prev evaluate to null, as in the first evaluation of a
+	// component.
+	scope := refsScope(funcScope, refs)
+	body, prev := rewritePrevCalls(block.Body)
+	if len(prev) > 0 {
+		if scope, _, err = prev.scope(scope, nil); err != nil {
+			return append(diags, blockDiagnostics(block, err)...)
+		}
+	}
+	if hasDynamicBlocks(body) {
+		if body, scope, err = expandDynamicBlocks(body, scope); err != nil {
+			return append(diags, blockDiagnostics(block, err)...)
+		}
+	}
+	if err := vm.New(body).Evaluate(scope, registration.CloneArguments()); err != nil {
+		return append(diags, blockDiagnostics(block, err)...)
+	}
+	return diags
+}
+
+// refsScope returns a scope holding refs, keyed by the dot-separated path
+// they're referenced by, with parent as its parent.
+func refsScope(parent *vm.Scope, refs map[string]any) *vm.Scope {
+	vars := make(map[string]any)
+	for id, value := range refs {
+		var (
+			parts = strings.Split(id, ".")
+			obj   = vars
+		)
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := obj[part].(map[string]any)
+			if !ok {
+				child = make(map[string]any)
+				obj[part] = child
+			}
+			obj = child
+		}
+		obj[parts[len(parts)-1]] = value
+	}
+	return &vm.Scope{Parent: parent, Variables: vars}
+}
+
+// blockDiagnostics converts an error from evaluating block into diagnostics.
+func blockDiagnostics(block *ast.BlockStmt, err error) diag.Diagnostics {
+	var (
+		evalDiags diag.Diagnostics
+		evalDiag  diag.Diagnostic
+	)
+	switch {
+	case errors.As(err, &evalDiags):
+		return evalDiags
+	case errors.As(err, &evalDiag):
+		return diag.Diagnostics{evalDiag}
+	}
+	return diag.Diagnostics{{
+		Severity: diag.SeverityLevelError,
+		Message:  fmt.Sprintf("Failed to evaluate component: %s", err),
+		StartPos: ast.StartPos(block).Position(),
+		EndPos:   ast.EndPos(block).Position(),
+	}}
+}
@@ -1,11 +1,14 @@
 package controller
 
 import (
+	"context"
 	"reflect"
 	"sync"
 
 	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/pkg/flow/internal/stdlib"
 	"github.com/grafana/river/vm"
+	"golang.org/x/exp/maps"
 )
 
 // valueCache caches component arguments and exports to expose as variables for
@@ -15,12 +18,16 @@ import (
 // components to be evaluated.
 type valueCache struct {
 	mut                sync.RWMutex
-	components         map[string]ComponentID // NodeID -> ComponentID
-	args               map[string]interface{} // NodeID -> component arguments value
-	exports            map[string]interface{} // NodeID -> component exports value
-	moduleArguments    map[string]any         // key -> module arguments value
-	moduleExports      map[string]any         // name -> value for the value of module exports
-	moduleChangedIndex int                    // Everytime a change occurs this is incremented
+	components         map[string]ComponentID      // NodeID -> ComponentID
+	args               map[string]interface{}      // NodeID -> component arguments value
+	exports            map[string]interface{}      // NodeID -> component exports value
+	health             map[string]component.Health // NodeID -> component health
+	moduleArguments    map[string]any              // key -> module arguments value
+	moduleExports      map[string]any              // name -> value for the value of module exports
+	moduleChangedIndex int                         // Everytime a change occurs this is incremented
+	functions          map[string]any              // Custom functions resolved before the stdlib
+	lookups            *stdlib.Lookups             // Resolves dns_lookup and srv_lookup
+	random             map[string]any              // uuidv4 and random_id, returning the same values for the lifetime of the cache
 }
 
 // newValueCache creates a new ValueCache.
@@ -29,11 +36,50 @@ func newValueCache() *valueCache {
 		components:      make(map[string]ComponentID),
 		args:            make(map[string]interface{}),
 		exports:         make(map[string]interface{}),
+		health:          make(map[string]component.Health),
 		moduleArguments: make(map[string]any),
 		moduleExports:   make(map[string]any),
+		lookups:         stdlib.DefaultLookups,
+		random:          stdlib.NewRandomValues().Functions(),
 	}
 }
 
+// clone returns a copy of vc which can be changed without affecting vc.
+// Cached values themselves aren't copied.
+func (vc *valueCache) clone() *valueCache {
+	vc.mut.RLock()
+	defer vc.mut.RUnlock()
+
+	return &valueCache{
+		components:         maps.Clone(vc.components),
+		args:               maps.Clone(vc.args),
+		exports:            maps.Clone(vc.exports),
+		health:             maps.Clone(vc.health),
+		moduleArguments:    maps.Clone(vc.moduleArguments),
+		moduleExports:      maps.Clone(vc.moduleExports),
+		moduleChangedIndex: vc.moduleChangedIndex,
+		functions:          vc.functions,
+		lookups:            vc.lookups,
+		random:             vc.random,
+	}
+}
+
+// restore replaces the contents of vc with the contents of from, which must
+// be a clone of vc that no longer changes.
+func (vc *valueCache) restore(from *valueCache) {
+	vc.mut.Lock()
+	defer vc.mut.Unlock()
+
+	vc.components = from.components
+	vc.args = from.args
+	vc.exports = from.exports
+	vc.health = from.health
+	vc.moduleArguments = from.moduleArguments
+	vc.moduleExports = from.moduleExports
+	vc.moduleChangedIndex = from.moduleChangedIndex
+	vc.functions = from.functions
+}
+
 // CacheArguments will cache the provided arguments by the given id. args may
 // be nil to store an empty object.
 func (vc *valueCache) CacheArguments(id ComponentID, args component.Arguments) {
@@ -66,6 +112,17 @@ func (vc *valueCache) CacheExports(id ComponentID, exports component.Exports) {
 	vc.exports[nodeID] = exportsVal
 }
 
+// CacheHealth will cache the health of the component with the given id. The
+// health is exposed to expressions as the health attribute of the component.
+func (vc *valueCache) CacheHealth(id ComponentID, h component.Health) {
+	vc.mut.Lock()
+	defer vc.mut.Unlock()
+
+	nodeID := id.String()
+	vc.components[nodeID] = id
+	vc.health[nodeID] = h
+}
+
 // CacheModuleArgument will cache the provided exports using the given id.
 func (vc *valueCache) CacheModuleArgument(key string, value any) {
 	vc.mut.Lock()
@@ -78,6 +135,15 @@ func (vc *valueCache) CacheModuleArgument(key string, value any) {
 	}
 }
 
+// HasModuleArgument returns whether a value for the module argument key is
+// cached.
+func (vc *valueCache) HasModuleArgument(key string) bool {
+	vc.mut.RLock()
+	defer vc.mut.RUnlock()
+	_, ok := vc.moduleArguments[key]
+	return ok
+}
+
 // CacheModuleExportValue saves the value to the map
 func (vc *valueCache) CacheModuleExportValue(name string, value any) {
 	vc.mut.Lock()
@@ -142,6 +208,7 @@ func (vc *valueCache) SyncIDs(ids []ComponentID) {
 		delete(vc.components, id)
 		delete(vc.args, id)
 		delete(vc.exports, id)
+		delete(vc.health, id)
 	}
 }
 
@@ -158,14 +225,59 @@ func (vc *valueCache) SyncModuleArgs(args map[string]any) {
 	}
 }
 
+// stdlibScope holds the Flow standard library. It's the root of every scope
+// built by valueCache, so its identifiers are resolved after components,
+// module arguments, and custom functions, and before the River standard
+// library.
+var stdlibScope = &vm.Scope{Variables: stdlib.Identifiers}
+
+// SetFunctions sets the custom functions which expressions can call in
+// addition to the standard library. Custom functions are resolved after
+// components and module arguments, and before the standard library.
+func (vc *valueCache) SetFunctions(funcs map[string]any) {
+	vc.mut.Lock()
+	defer vc.mut.Unlock()
+	vc.functions = funcs
+}
+
+// SetLookups sets the Lookups used by the dns_lookup and srv_lookup
+// functions.
+func (vc *valueCache) SetLookups(lookups *stdlib.Lookups) {
+	vc.mut.Lock()
+	defer vc.mut.Unlock()
+	vc.lookups = lookups
+}
+
+// FunctionScope returns a scope which only resolves custom functions and the
+// standard library. Lookups made by expressions evaluated with the returned
+// scope aren't canceled before they time out.
+func (vc *valueCache) FunctionScope() *vm.Scope {
+	vc.mut.RLock()
+	defer vc.mut.RUnlock()
+	return vc.functionScope(context.Background())
+}
+
+// functionScope returns a scope resolving custom functions and the standard
+// library, with lookups bound to ctx. mut must be held.
+func (vc *valueCache) functionScope(ctx context.Context) *vm.Scope {
+	scope := &vm.Scope{Parent: stdlibScope, Variables: vc.random}
+	scope = &vm.Scope{Parent: scope, Variables: vc.lookups.Functions(ctx)}
+	if len(vc.functions) > 0 {
+		scope = &vm.Scope{Parent: scope, Variables: vc.functions}
+	}
+	return scope
+}
+
 // BuildContext builds a vm.Scope based on the current set of cached values.
 // The arguments and exports for the same ID are merged into one object.
-func (vc *valueCache) BuildContext() *vm.Scope {
+// DNS lookups made by expressions evaluated with the scope are canceled once
+// ctx is canceled.
+func (vc *valueCache) BuildContext(ctx context.Context) *vm.Scope {
 	vc.mut.RLock()
 	defer vc.mut.RUnlock()
 
 	scope := &vm.Scope{
-		Parent:    nil,
+		Parent:    vc.functionScope(ctx),
 		Variables: make(map[string]interface{}),
 	}
 
@@ -212,6 +324,9 @@ func (vc *valueCache) buildValue(from []ComponentID, offset int) interface{} {
 		if !ok {
 			exports = make(map[string]interface{})
 		}
+		if h, ok := vc.health[name]; ok {
+			exports = withHealth(exports, h)
+		}
 		return exports
 	}
 
@@ -1,8 +1,12 @@
 package controller
 
 import (
+	"context"
 	"testing"
 
+	"github.com/grafana/agent/component"
+	"github.com/grafana/river/parser"
+	"github.com/grafana/river/vm"
 	"github.com/stretchr/testify/require"
 )
 
@@ -58,7 +62,7 @@ func TestValueCache(t *testing.T) {
 	vc.CacheArguments(ComponentID{"bar", "label_a"}, barArgs{Number: 12})
 	vc.CacheArguments(ComponentID{"bar", "label_b"}, barArgs{Number: 34})
 
-	res := vc.BuildContext()
+	res := vc.BuildContext(context.Background())
 
 	var (
 		expectKeys = []string{"foo", "bar"}
@@ -80,6 +84,41 @@ func TestValueCache(t *testing.T) {
 	require.Equal(t, expectBar, res.Variables["bar"])
 }
 
+func TestValueCache_Health(t *testing.T) {
+	vc := newValueCache()
+
+	vc.CacheExports(ComponentID{"foo"}, fooExports{SomethingElse: true})
+	vc.CacheHealth(ComponentID{"foo"}, component.Health{Health: component.HealthTypeHealthy, Message: "started"})
+	vc.CacheArguments(ComponentID{"bar", "label_a"}, barArgs{Number: 12})
+	vc.CacheHealth(ComponentID{"bar", "label_a"}, component.Health{Health: component.HealthTypeUnhealthy, Message: "broken"})
+
+	tt := []struct {
+		expr   string
+		expect any
+	}{
+		{expr: `foo.something_else`, expect: true},
+		{expr: `foo.health.state`, expect: "healthy"},
+		{expr: `foo.health.message`, expect: "started"},
+		{expr: `bar.label_a.health.state`, expect: "unhealthy"},
+	}
+
+	scope := vc.BuildContext(context.Background())
+	for _, tc := range tt {
+		t.Run(tc.expr, func(t *testing.T) {
+			expr, err := parser.ParseExpression(tc.expr)
+			require.NoError(t, err)
+
+			var actual any
+			require.NoError(t, vm.New(expr).Evaluate(scope, &actual))
+			require.Equal(t, tc.expect, actual)
+		})
+	}
+
+	// Health is removed along with the component.
+	vc.SyncIDs([]ComponentID{{"foo"}})
+	require.NotContains(t, vc.health, "bar.label_a")
+}
+
 func TestExportValueCache(t *testing.T) {
 	vc := newValueCache()
 	vc.CacheModuleExportValue("t1", 1)
@@ -156,20 +195,20 @@ func TestModuleArgumentCache(t *testing.T) {
 			vc.CacheModuleArgument("arg", tc.argValue)
 
 			// Build the scope and validate it
-			res := vc.BuildContext()
+			res := vc.BuildContext(context.Background())
 			expected := map[string]any{"arg": map[string]any{"value": tc.argValue}}
 			require.Equal(t, expected, res.Variables["argument"])
 
 			// Sync arguments where the arg shouldn't change
 			syncArgs := map[string]any{"arg": tc.argValue}
 			vc.SyncModuleArgs(syncArgs)
-			res = vc.BuildContext()
+			res = vc.BuildContext(context.Background())
 			require.Equal(t, expected, res.Variables["argument"])
 
 			// Sync arguments where the arg should clear out
 			syncArgs = map[string]any{}
 			vc.SyncModuleArgs(syncArgs)
-			res = vc.BuildContext()
+			res = vc.BuildContext(context.Background())
 			require.Equal(t, map[string]any{}, res.Variables)
 		})
 	}
@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/river/parser"
+)
+
+// WhatIf evaluates the arguments of every component which directly depends
+// on the component with the given ID, as if that component exported the
+// attributes set by the River body override. It returns the arguments by
+// component ID.
+//
+// Evaluation uses a copy of the cached values, so the live graph isn't
+// changed: no component is built, updated, started, or stopped. Components
+// which only depend on the overridden component through other components
+// aren't evaluated, since the exports of the components in between can't be
+// known without updating them.
+func (l *Loader) WhatIf(id string, override []byte) (map[string]component.Arguments, error) {
+	l.mut.RLock()
+	defer l.mut.RUnlock()
+
+	cn, ok := l.graph.GetByID(id).(*ComponentNode)
+	if !ok {
+		return nil, fmt.Errorf("component %q does not exist", id)
+	}
+	if cn.exportsType == nil {
+		return nil, fmt.Errorf("component %q has no exports", id)
+	}
+
+	file, err := parser.ParseFile("override", override)
+	if err != nil {
+		return nil, err
+	}
+	exports, err := decodeExports(file.Body, cn.exportsType)
+	if err != nil {
+		return nil, fmt.Errorf("decoding exports of %q: %w", id, err)
+	}
+
+	cache := l.cache.clone()
+	cache.CacheExports(cn.ID(), exports)
+	scope := cache.BuildContext(context.Background())
+
+	results := make(map[string]component.Arguments)
+	for _, n := range l.graph.Dependants(cn) {
+		dep, ok := n.(*ComponentNode)
+		if !ok {
+			continue
+		}
+
+		dep.mut.RLock()
+		args, _, err := dep.decodeArguments(scope)
+		dep.mut.RUnlock()
+		if err != nil {
+			return nil, fmt.Errorf("evaluating %q: %w", dep.NodeID(), err)
+		}
+		results[dep.NodeID()] = args
+	}
+	return results, nil
+}
@@ -137,6 +137,18 @@ func (g *Graph) RemoveEdge(e Edge) {
 	}
 }
 
+// NodeCount returns the number of Nodes in g.
+func (g *Graph) NodeCount() int { return len(g.nodes) }
+
+// EdgeCount returns the number of edges in g.
+func (g *Graph) EdgeCount() int {
+	var count int
+	for _, tos := range g.outEdges {
+		count += len(tos)
+	}
+	return count
+}
+
 // Nodes returns the set of Nodes in g.
 func (g *Graph) Nodes() []Node {
 	nodes := make([]Node, 0, len(g.nodes))
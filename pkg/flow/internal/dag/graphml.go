@@ -0,0 +1,156 @@
+package dag
+
+import (
+	"bytes"
+	"encoding/xml"
+	"sort"
+)
+
+// GraphMLOptions customizes the data written by MarshalGraphML.
+type GraphMLOptions struct {
+	// NodeData optionally returns data to attach to a node, keyed by name.
+	NodeData func(n Node) map[string]string
+
+	// EdgeData optionally returns data to attach to an edge, keyed by name.
+	EdgeData func(e Edge) map[string]string
+}
+
+// MarshalGraphML encodes g as a directed graph in the GraphML format. Nodes
+// are identified by their node ID, and edges point from a node to the node it
+// depends on. Every name returned by opts is declared as a GraphML string
+// key.
+//
+// Nodes, edges, and keys are sorted so the same graph always has the same
+// encoding.
+func MarshalGraphML(g *Graph, opts GraphMLOptions) ([]byte, error) {
+	nodes := g.Nodes()
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].NodeID() < nodes[j].NodeID() })
+
+	edges := g.Edges()
+	sort.Slice(edges, func(i, j int) bool {
+		if from1, from2 := edges[i].From.NodeID(), edges[j].From.NodeID(); from1 != from2 {
+			return from1 < from2
+		}
+		return edges[i].To.NodeID() < edges[j].To.NodeID()
+	})
+
+	var (
+		doc = graphMLDocument{
+			XMLNS: "http://graphml.graphdrawing.org/xmlns",
+			Graph: graphMLGraph{EdgeDefault: "directed"},
+		}
+		nodeKeys = make(map[string]struct{})
+		edgeKeys = make(map[string]struct{})
+	)
+
+	for _, n := range nodes {
+		var data map[string]string
+		if opts.NodeData != nil {
+			data = opts.NodeData(n)
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{
+			ID:   n.NodeID(),
+			Data: graphMLData("node", data, nodeKeys),
+		})
+	}
+	for _, e := range edges {
+		var data map[string]string
+		if opts.EdgeData != nil {
+			data = opts.EdgeData(e)
+		}
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			Source: e.From.NodeID(),
+			Target: e.To.NodeID(),
+			Data:   graphMLData("edge", data, edgeKeys),
+		})
+	}
+
+	doc.Keys = append(graphMLKeys("node", nodeKeys), graphMLKeys("edge", edgeKeys)...)
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+type graphMLDocument struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string         `xml:"id,attr"`
+	Data []graphMLDatum `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string         `xml:"source,attr"`
+	Target string         `xml:"target,attr"`
+	Data   []graphMLDatum `xml:"data"`
+}
+
+type graphMLDatum struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// graphMLData converts data of an element of kind ("node" or "edge") into
+// sorted GraphML data, and records the names used into keys.
+func graphMLData(kind string, data map[string]string, keys map[string]struct{}) []graphMLDatum {
+	names := make([]string, 0, len(data))
+	for name := range data {
+		names = append(names, name)
+		keys[name] = struct{}{}
+	}
+	sort.Strings(names)
+
+	res := make([]graphMLDatum, 0, len(names))
+	for _, name := range names {
+		res = append(res, graphMLDatum{Key: graphMLKeyID(kind, name), Value: data[name]})
+	}
+	return res
+}
+
+// graphMLKeys returns the sorted key declarations for elements of kind.
+func graphMLKeys(kind string, keys map[string]struct{}) []graphMLKey {
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	res := make([]graphMLKey, 0, len(names))
+	for _, name := range names {
+		res = append(res, graphMLKey{
+			ID:       graphMLKeyID(kind, name),
+			For:      kind,
+			AttrName: name,
+			AttrType: "string",
+		})
+	}
+	return res
+}
+
+// graphMLKeyID returns the ID of the key for a name of elements of kind. Node
+// and edge keys have different IDs so they can share names.
+func graphMLKeyID(kind, name string) string { return kind + "_" + name }
@@ -0,0 +1,50 @@
+package dag
+
+import "testing"
+
+func TestMarshalGraphML(t *testing.T) {
+	var g Graph
+	var (
+		nodeA = stringNode("a")
+		nodeB = stringNode("b<&>")
+	)
+	g.Add(nodeA)
+	g.Add(nodeB)
+	g.AddEdge(Edge{nodeB, nodeA})
+
+	bb, err := MarshalGraphML(&g, GraphMLOptions{
+		NodeData: func(n Node) map[string]string {
+			return map[string]string{"type": "string", "name": n.NodeID()}
+		},
+		EdgeData: func(e Edge) map[string]string {
+			return map[string]string{"name": e.From.NodeID() + "->" + e.To.NodeID()}
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expect := `<?xml version="1.0" encoding="UTF-8"?>
+<graphml xmlns="http://graphml.graphdrawing.org/xmlns">
+  <key id="node_name" for="node" attr.name="name" attr.type="string"></key>
+  <key id="node_type" for="node" attr.name="type" attr.type="string"></key>
+  <key id="edge_name" for="edge" attr.name="name" attr.type="string"></key>
+  <graph edgedefault="directed">
+    <node id="a">
+      <data key="node_name">a</data>
+      <data key="node_type">string</data>
+    </node>
+    <node id="b&lt;&amp;&gt;">
+      <data key="node_name">b&lt;&amp;&gt;</data>
+      <data key="node_type">string</data>
+    </node>
+    <edge source="b&lt;&amp;&gt;" target="a">
+      <data key="edge_name">b&lt;&amp;&gt;-&gt;a</data>
+    </edge>
+  </graph>
+</graphml>
+`
+	if actual := string(bb); actual != expect {
+		t.Fatalf("unexpected encoding:\n%s", actual)
+	}
+}
@@ -2,6 +2,7 @@ package dag
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/hashicorp/go-multierror"
@@ -55,3 +56,146 @@ func Validate(g *Graph) error {
 
 	return err
 }
+
+// Paths returns every path of edges in g which leads from node from to node
+// to. Each path starts with from and ends with to. Paths are returned sorted
+// by the IDs of the nodes along them.
+//
+// Nodes are never visited twice in the same path, so Paths terminates even if
+// g contains cycles.
+func Paths(g *Graph, from, to Node) [][]Node {
+	var (
+		paths   [][]Node
+		path    []Node
+		visited = make(nodeSet)
+	)
+
+	var visit func(n Node)
+	visit = func(n Node) {
+		if visited.Has(n) {
+			return
+		}
+		visited.Add(n)
+		path = append(path, n)
+		defer func() {
+			visited.Remove(n)
+			path = path[:len(path)-1]
+		}()
+
+		if n == to {
+			paths = append(paths, append([]Node(nil), path...))
+			return
+		}
+		for _, dep := range g.Dependencies(n) {
+			visit(dep)
+		}
+	}
+	visit(from)
+
+	sort.Slice(paths, func(i, j int) bool {
+		return pathString(paths[i]) < pathString(paths[j])
+	})
+	return paths
+}
+
+func pathString(path []Node) string {
+	ids := make([]string, len(path))
+	for i, n := range path {
+		ids[i] = n.NodeID()
+	}
+	return strings.Join(ids, " ")
+}
+
+// Direction is the direction in which edges are followed when searching a
+// graph.
+type Direction int
+
+const (
+	// DirectionBoth follows edges to both dependencies and dependants.
+	DirectionBoth Direction = iota
+	// DirectionDependencies follows edges from nodes to their dependencies.
+	DirectionDependencies
+	// DirectionDependants follows edges from nodes to their dependants.
+	DirectionDependants
+)
+
+// Neighborhood returns a new graph holding root and every node of g which can
+// be reached from root by following at most depth edges in the direction
+// dir. If depth is negative, the number of edges followed is unlimited. Edges
+// of g between the returned nodes are kept.
+func Neighborhood(g *Graph, root Node, depth int, dir Direction) *Graph {
+	var (
+		sub      Graph
+		frontier = []Node{root}
+	)
+	sub.Add(root)
+
+	for hops := 0; len(frontier) > 0 && (depth < 0 || hops < depth); hops++ {
+		var next []Node
+		for _, n := range frontier {
+			var neighbors []Node
+			if dir != DirectionDependants {
+				neighbors = append(neighbors, g.Dependencies(n)...)
+			}
+			if dir != DirectionDependencies {
+				neighbors = append(neighbors, g.Dependants(n)...)
+			}
+
+			for _, neighbor := range neighbors {
+				if sub.GetByID(neighbor.NodeID()) != nil {
+					continue
+				}
+				sub.Add(neighbor)
+				next = append(next, neighbor)
+			}
+		}
+		frontier = next
+	}
+
+	for _, e := range g.Edges() {
+		if sub.GetByID(e.From.NodeID()) != nil && sub.GetByID(e.To.NodeID()) != nil {
+			sub.AddEdge(e)
+		}
+	}
+	return &sub
+}
+
+// TopologicalLevels groups the nodes of g into levels of dependencies. The
+// first level holds every node with no dependencies, and every other node is
+// placed in the level after its deepest dependency. Nodes in the same level
+// never depend on each other, so they can be evaluated in parallel once every
+// previous level has been evaluated. Nodes in each level are sorted by ID.
+//
+// Nodes which are part of a cycle, or which depend on a node in a cycle, are
+// never placed in a level and are left out. Use Validate to check g for
+// cycles first.
+func TopologicalLevels(g *Graph) [][]Node {
+	var (
+		levels  [][]Node
+		pending = make(map[Node]int, len(g.nodes)) // Dependencies not yet placed in a level.
+		level   []Node
+	)
+	for n := range g.nodes {
+		pending[n] = len(g.outEdges[n])
+		if pending[n] == 0 {
+			level = append(level, n)
+		}
+	}
+
+	for len(level) > 0 {
+		sort.Slice(level, func(i, j int) bool { return level[i].NodeID() < level[j].NodeID() })
+		levels = append(levels, level)
+
+		var next []Node
+		for _, n := range level {
+			for dependant := range g.inEdges[n] {
+				pending[dependant]--
+				if pending[dependant] == 0 {
+					next = append(next, dependant)
+				}
+			}
+		}
+		level = next
+	}
+	return levels
+}
@@ -1,6 +1,11 @@
 package dag
 
-import "testing"
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
 
 func TestValidateWithoutCycle(t *testing.T) {
 	var g Graph
@@ -52,3 +57,199 @@ func TestValidateSelfReference(t *testing.T) {
 		t.Fatal("graph with self reference")
 	}
 }
+
+func TestPaths(t *testing.T) {
+	var g Graph
+	var (
+		nodeA = stringNode("a")
+		nodeB = stringNode("b")
+		nodeC = stringNode("c")
+		nodeD = stringNode("d")
+	)
+	g.Add(nodeA)
+	g.Add(nodeB)
+	g.Add(nodeC)
+	g.Add(nodeD)
+	g.AddEdge(Edge{nodeA, nodeB})
+	g.AddEdge(Edge{nodeA, nodeC})
+	g.AddEdge(Edge{nodeB, nodeD})
+	g.AddEdge(Edge{nodeC, nodeD})
+	g.AddEdge(Edge{nodeD, nodeA})
+
+	paths := Paths(&g, nodeA, nodeD)
+	expect := []string{"a b d", "a c d"}
+	if len(paths) != len(expect) {
+		t.Fatalf("expected %d paths, got %d", len(expect), len(paths))
+	}
+	for i, path := range paths {
+		if actual := pathString(path); actual != expect[i] {
+			t.Errorf("path %d: expected %q, got %q", i, expect[i], actual)
+		}
+	}
+
+	// Cycles are followed, but no node is visited twice.
+	paths = Paths(&g, nodeB, nodeC)
+	if len(paths) != 1 || pathString(paths[0]) != "b d a c" {
+		t.Fatalf("expected a single path through the cycle, got %d", len(paths))
+	}
+
+	nodeE := stringNode("e")
+	g.Add(nodeE)
+	if paths := Paths(&g, nodeA, nodeE); len(paths) != 0 {
+		t.Fatalf("expected no paths, got %d", len(paths))
+	}
+}
+
+func TestNeighborhood(t *testing.T) {
+	// a -> b -> c -> d, with e -> c.
+	var g Graph
+	var (
+		nodeA = stringNode("a")
+		nodeB = stringNode("b")
+		nodeC = stringNode("c")
+		nodeD = stringNode("d")
+		nodeE = stringNode("e")
+	)
+	for _, n := range []Node{nodeA, nodeB, nodeC, nodeD, nodeE} {
+		g.Add(n)
+	}
+	g.AddEdge(Edge{nodeA, nodeB})
+	g.AddEdge(Edge{nodeB, nodeC})
+	g.AddEdge(Edge{nodeC, nodeD})
+	g.AddEdge(Edge{nodeE, nodeC})
+
+	tt := []struct {
+		name   string
+		root   Node
+		depth  int
+		dir    Direction
+		expect []string
+	}{
+		{"depth zero", nodeC, 0, DirectionBoth, []string{"c"}},
+		{"both directions", nodeC, 1, DirectionBoth, []string{"b", "c", "d", "e"}},
+		{"dependencies", nodeB, 1, DirectionDependencies, []string{"b", "c"}},
+		{"dependants", nodeC, 1, DirectionDependants, []string{"b", "c", "e"}},
+		{"deeper", nodeD, 2, DirectionDependants, []string{"b", "c", "d", "e"}},
+		{"unlimited", nodeD, -1, DirectionDependants, []string{"a", "b", "c", "d", "e"}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			sub := Neighborhood(&g, tc.root, tc.depth, tc.dir)
+
+			var actual []string
+			for _, n := range sub.Nodes() {
+				actual = append(actual, n.NodeID())
+			}
+			sort.Strings(actual)
+			if strings.Join(actual, " ") != strings.Join(tc.expect, " ") {
+				t.Fatalf("expected nodes %v, got %v", tc.expect, actual)
+			}
+
+			// Edges between nodes in the subgraph are kept.
+			var expectEdges int
+			for _, e := range g.Edges() {
+				if sub.GetByID(e.From.NodeID()) != nil && sub.GetByID(e.To.NodeID()) != nil {
+					expectEdges++
+				}
+			}
+			if edges := len(sub.Edges()); edges != expectEdges {
+				t.Errorf("expected %d edges, got %d", expectEdges, edges)
+			}
+		})
+	}
+}
+
+func TestTopologicalLevels(t *testing.T) {
+	newGraph := func(edges ...Edge) *Graph {
+		var g Graph
+		for _, e := range edges {
+			g.Add(e.From)
+			g.Add(e.To)
+			g.AddEdge(e)
+		}
+		return &g
+	}
+
+	var (
+		nodeA = stringNode("a")
+		nodeB = stringNode("b")
+		nodeC = stringNode("c")
+		nodeD = stringNode("d")
+	)
+
+	tt := []struct {
+		name   string
+		g      *Graph
+		expect []string
+	}{
+		{
+			// a -> b -> c -> d
+			name:   "chain",
+			g:      newGraph(Edge{nodeA, nodeB}, Edge{nodeB, nodeC}, Edge{nodeC, nodeD}),
+			expect: []string{"d", "c", "b", "a"},
+		},
+		{
+			// a depends on b and c, which both depend on d.
+			name:   "diamond",
+			g:      newGraph(Edge{nodeA, nodeB}, Edge{nodeA, nodeC}, Edge{nodeB, nodeD}, Edge{nodeC, nodeD}),
+			expect: []string{"d", "b c", "a"},
+		},
+		{
+			// a is placed after its deepest dependency, d.
+			name:   "uneven",
+			g:      newGraph(Edge{nodeA, nodeB}, Edge{nodeA, nodeD}, Edge{nodeB, nodeC}, Edge{nodeC, nodeD}),
+			expect: []string{"d", "c", "b", "a"},
+		},
+		{
+			// b and c form a cycle, so neither they nor a are placed.
+			name:   "cycle",
+			g:      newGraph(Edge{nodeA, nodeB}, Edge{nodeB, nodeC}, Edge{nodeC, nodeB}, Edge{nodeC, nodeD}),
+			expect: []string{"d"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var actual []string
+			for _, level := range TopologicalLevels(tc.g) {
+				actual = append(actual, pathString(level))
+			}
+			if strings.Join(actual, ", ") != strings.Join(tc.expect, ", ") {
+				t.Fatalf("expected levels %q, got %q", tc.expect, actual)
+			}
+		})
+	}
+}
+
+// BenchmarkReduce measures the transitive reduction of graphs where every
+// node depends on the previous four nodes, so most edges are transitive.
+func BenchmarkReduce(b *testing.B) {
+	for _, size := range []int{100, 500, 1000} {
+		b.Run(fmt.Sprintf("nodes=%d", size), func(b *testing.B) {
+			nodes := make([]Node, size)
+			for i := range nodes {
+				nodes[i] = stringNode(fmt.Sprintf("n%d", i))
+			}
+			build := func() *Graph {
+				var g Graph
+				for i, n := range nodes {
+					g.Add(n)
+					for j := i - 4; j < i; j++ {
+						if j >= 0 {
+							g.AddEdge(Edge{From: n, To: nodes[j]})
+						}
+					}
+				}
+				return &g
+			}
+
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				g := build()
+				b.StartTimer()
+				Reduce(g)
+			}
+		})
+	}
+}
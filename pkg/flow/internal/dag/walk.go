@@ -1,5 +1,7 @@
 package dag
 
+import "sync"
+
 // WalkFunc is a function that gets invoked when walking a Graph. Walking will
 // stop if WalkFunc returns a non-nil error.
 type WalkFunc func(n Node) error
@@ -105,3 +107,50 @@ func WalkTopological(g *Graph, start []Node, fn WalkFunc) error {
 
 	return nil
 }
+
+// WalkLevels walks every node of g in the levels returned by
+// [TopologicalLevels], calling fn for up to parallelism nodes of the same
+// level concurrently. A level is only walked once fn returned for every node
+// of the previous level, so fn is never called for a node before it returned
+// for the dependencies of that node. fn must be safe to call concurrently
+// when parallelism is greater than one.
+//
+// If fn returns an error, the rest of the level is still walked, but no
+// further levels are, and WalkLevels returns the first error returned by fn.
+func WalkLevels(g *Graph, parallelism int, fn WalkFunc) error {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	for _, level := range TopologicalLevels(g) {
+		var (
+			wg    sync.WaitGroup
+			errMu sync.Mutex
+			err   error
+			slots = make(chan struct{}, parallelism)
+		)
+		for _, n := range level {
+			slots <- struct{}{}
+			wg.Add(1)
+			go func(n Node) {
+				defer func() {
+					<-slots
+					wg.Done()
+				}()
+				if nodeErr := fn(n); nodeErr != nil {
+					errMu.Lock()
+					if err == nil {
+						err = nodeErr
+					}
+					errMu.Unlock()
+				}
+			}(n)
+		}
+		wg.Wait()
+
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
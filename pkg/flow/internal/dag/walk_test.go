@@ -0,0 +1,68 @@
+package dag
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestWalkLevels(t *testing.T) {
+	// a depends on b and c, which both depend on d.
+	var g Graph
+	var (
+		nodeA = stringNode("a")
+		nodeB = stringNode("b")
+		nodeC = stringNode("c")
+		nodeD = stringNode("d")
+	)
+	for _, n := range []Node{nodeA, nodeB, nodeC, nodeD} {
+		g.Add(n)
+	}
+	g.AddEdge(Edge{nodeA, nodeB})
+	g.AddEdge(Edge{nodeA, nodeC})
+	g.AddEdge(Edge{nodeB, nodeD})
+	g.AddEdge(Edge{nodeC, nodeD})
+
+	var (
+		mut     sync.Mutex
+		visited = make(nodeSet)
+	)
+	err := WalkLevels(&g, 2, func(n Node) error {
+		mut.Lock()
+		defer mut.Unlock()
+
+		for _, dep := range g.Dependencies(n) {
+			if !visited.Has(dep) {
+				t.Errorf("%s visited before its dependency %s", n.NodeID(), dep.NodeID())
+			}
+		}
+		visited.Add(n)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(visited) != 4 {
+		t.Fatalf("expected 4 nodes to be visited, got %d", len(visited))
+	}
+
+	// Levels after the one which failed aren't walked.
+	visited = make(nodeSet)
+	errFailed := errors.New("failed")
+	err = WalkLevels(&g, 2, func(n Node) error {
+		mut.Lock()
+		defer mut.Unlock()
+
+		visited.Add(n)
+		if n == nodeB {
+			return errFailed
+		}
+		return nil
+	})
+	if !errors.Is(err, errFailed) {
+		t.Fatalf("expected error %q, got %v", errFailed, err)
+	}
+	if !visited.Has(nodeC) || visited.Has(nodeA) {
+		t.Fatalf("expected the rest of the failed level and no later levels to be walked")
+	}
+}
@@ -0,0 +1,214 @@
+package stdlib
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// lookupTimeout is the longest a DNS lookup may take before failing.
+	lookupTimeout = 5 * time.Second
+
+	// lookupCacheTTL is how long the result of a DNS lookup is reused before
+	// the name is resolved again.
+	lookupCacheTTL = 30 * time.Second
+)
+
+// resolver resolves DNS names. It's satisfied by *net.Resolver.
+type resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// lookups is the cache used by dnsLookup and srvLookup.
+var lookups = newLookupCache(net.DefaultResolver, lookupCacheTTL)
+
+// Lookups resolves the names passed to the dns_lookup and srv_lookup
+// functions, caching the results.
+type Lookups struct {
+	cache *lookupCache
+}
+
+// DefaultLookups resolves names with the default resolver. It shares its
+// cache with the dns_lookup and srv_lookup functions in Identifiers.
+var DefaultLookups = &Lookups{cache: lookups}
+
+// NewLookups returns Lookups which resolve names with r instead of the
+// default resolver, using a cache of their own.
+func NewLookups(r *net.Resolver) *Lookups {
+	return &Lookups{cache: newLookupCache(r, lookupCacheTTL)}
+}
+
+// Functions returns the dns_lookup and srv_lookup functions, which replace
+// the functions in Identifiers. Lookups made by the returned functions are
+// canceled once ctx is canceled.
+func (l *Lookups) Functions(ctx context.Context) map[string]interface{} {
+	return map[string]interface{}{
+		"dns_lookup": func(host string, strict ...bool) ([]string, error) {
+			return l.cache.dnsLookup(ctx, host, strict...)
+		},
+		"srv_lookup": func(name string, strict ...bool) ([]string, error) {
+			return l.cache.srvLookup(ctx, name, strict...)
+		},
+	}
+}
+
+// dnsLookup returns the addresses of host. If the lookup fails, an error is
+// returned when strict is true or not given, and an empty list otherwise.
+func dnsLookup(host string, strict ...bool) ([]string, error) {
+	return lookups.dnsLookup(context.Background(), host, strict...)
+}
+
+// srvLookup returns the targets of the SRV records of name, such as
+// _http._tcp.example.com, as host:port addresses. If the lookup fails, an
+// error is returned when strict is true or not given, and an empty list
+// otherwise.
+func srvLookup(name string, strict ...bool) ([]string, error) {
+	return lookups.srvLookup(context.Background(), name, strict...)
+}
+
+// dnsLookup implements the dns_lookup function using c.
+func (c *lookupCache) dnsLookup(ctx context.Context, host string, strict ...bool) ([]string, error) {
+	isStrict, err := strictArg("dns_lookup", strict)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := c.lookupHost(ctx, host)
+	return lookupResult("dns_lookup", addrs, err, isStrict)
+}
+
+// srvLookup implements the srv_lookup function using c.
+func (c *lookupCache) srvLookup(ctx context.Context, name string, strict ...bool) ([]string, error) {
+	isStrict, err := strictArg("srv_lookup", strict)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := c.lookupSRV(ctx, name)
+	return lookupResult("srv_lookup", addrs, err, isStrict)
+}
+
+// strictArg returns the optional strict argument of the function fn, which
+// defaults to true.
+func strictArg(fn string, strict []bool) (bool, error) {
+	switch len(strict) {
+	case 0:
+		return true, nil
+	case 1:
+		return strict[0], nil
+	default:
+		return false, fmt.Errorf("%s: expected at most 2 arguments, got %d", fn, len(strict)+1)
+	}
+}
+
+// lookupResult returns the result of a lookup by the function fn, replacing
+// errors with an empty list when strict is false.
+func lookupResult(fn string, addrs []string, err error, strict bool) ([]string, error) {
+	switch {
+	case err == nil:
+		return addrs, nil
+	case strict:
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	default:
+		return []string{}, nil
+	}
+}
+
+// lookupCache caches the results of DNS lookups, including failed ones, so
+// that evaluating many expressions which resolve the same name doesn't
+// repeatedly query the network.
+type lookupCache struct {
+	resolver resolver
+	ttl      time.Duration
+	now      func() time.Time
+
+	mut     sync.Mutex
+	entries map[string]lookupEntry
+}
+
+type lookupEntry struct {
+	addrs   []string
+	err     error
+	expires time.Time
+}
+
+func newLookupCache(r resolver, ttl time.Duration) *lookupCache {
+	return &lookupCache{
+		resolver: r,
+		ttl:      ttl,
+		now:      time.Now,
+		entries:  make(map[string]lookupEntry),
+	}
+}
+
+// lookupHost returns the sorted addresses of host.
+func (c *lookupCache) lookupHost(ctx context.Context, host string) ([]string, error) {
+	return c.get(ctx, "host:"+host, func(ctx context.Context) ([]string, error) {
+		addrs, err := c.resolver.LookupHost(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(addrs)
+		return addrs, nil
+	})
+}
+
+// lookupSRV returns the targets of the SRV records of name as host:port
+// addresses, ordered by priority and then by weight as returned by the
+// resolver.
+func (c *lookupCache) lookupSRV(ctx context.Context, name string) ([]string, error) {
+	return c.get(ctx, "srv:"+name, func(ctx context.Context) ([]string, error) {
+		_, records, err := c.resolver.LookupSRV(ctx, "", "", name)
+		if err != nil {
+			return nil, err
+		}
+		addrs := make([]string, 0, len(records))
+		for _, r := range records {
+			host := strings.TrimSuffix(r.Target, ".")
+			addrs = append(addrs, net.JoinHostPort(host, strconv.Itoa(int(r.Port))))
+		}
+		return addrs, nil
+	})
+}
+
+// get returns the cached result for key, calling lookup with a context
+// derived from ctx with a timeout when there's no result or the result
+// expired. The cache isn't locked during lookups, so slow lookups don't block
+// lookups of other names.
+//
+// Lookups which fail because ctx was canceled aren't cached, so the name is
+// resolved again by the next caller.
+func (c *lookupCache) get(ctx context.Context, key string, lookup func(ctx context.Context) ([]string, error)) ([]string, error) {
+	c.mut.Lock()
+	e, ok := c.entries[key]
+	c.mut.Unlock()
+	if ok && c.now().Before(e.expires) {
+		return e.addrs, e.err
+	}
+
+	lookupCtx, cancel := context.WithTimeout(ctx, lookupTimeout)
+	defer cancel()
+	addrs, err := lookup(lookupCtx)
+	if err != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	// Remove expired entries so names which are no longer looked up don't
+	// stay in the cache forever.
+	now := c.now()
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = lookupEntry{addrs: addrs, err: err, expires: now.Add(c.ttl)}
+	return addrs, err
+}
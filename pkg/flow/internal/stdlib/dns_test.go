@@ -0,0 +1,141 @@
+package stdlib
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/grafana/river/parser"
+	"github.com/grafana/river/vm"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeResolver struct {
+	hosts map[string][]string
+	srvs  map[string][]*net.SRV
+	calls int
+}
+
+func (r *fakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	r.calls++
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	addrs, ok := r.hosts[host]
+	if !ok {
+		return nil, fmt.Errorf("no such host %q", host)
+	}
+	return addrs, nil
+}
+
+func (r *fakeResolver) LookupSRV(_ context.Context, _, _, name string) (string, []*net.SRV, error) {
+	r.calls++
+	records, ok := r.srvs[name]
+	if !ok {
+		return "", nil, fmt.Errorf("no such host %q", name)
+	}
+	return name, records, nil
+}
+
+func TestDNSLookup(t *testing.T) {
+	r := useFakeResolver(t)
+
+	var addrs []string
+	evalDNS(t, `dns_lookup("example.com")`, &addrs)
+	require.Equal(t, []string{"10.0.0.1", "10.0.0.2"}, addrs)
+
+	evalDNS(t, `dns_lookup("missing.example.com", false)`, &addrs)
+	require.Equal(t, []string{}, addrs)
+
+	expr, err := parser.ParseExpression(`dns_lookup("missing.example.com")`)
+	require.NoError(t, err)
+	err = vm.New(expr).Evaluate(&vm.Scope{Variables: Identifiers}, &addrs)
+	require.ErrorContains(t, err, `dns_lookup: no such host "missing.example.com"`)
+
+	// Failed lookups are cached too.
+	require.Equal(t, 2, r.calls)
+}
+
+func TestSRVLookup(t *testing.T) {
+	useFakeResolver(t)
+
+	var addrs []string
+	evalDNS(t, `srv_lookup("_http._tcp.example.com")`, &addrs)
+	require.Equal(t, []string{"a.example.com:8080", "b.example.com:9090"}, addrs)
+
+	evalDNS(t, `srv_lookup("_http._tcp.missing.example.com", false)`, &addrs)
+	require.Equal(t, []string{}, addrs)
+}
+
+func TestLookupCache_Expiry(t *testing.T) {
+	r := &fakeResolver{hosts: map[string][]string{"example.com": {"10.0.0.1"}}}
+	c := newLookupCache(r, time.Minute)
+
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	_, err := c.lookupHost(context.Background(), "example.com")
+	require.NoError(t, err)
+	_, err = c.lookupHost(context.Background(), "example.com")
+	require.NoError(t, err)
+	require.Equal(t, 1, r.calls)
+
+	// Lookups are repeated once the cached result expires.
+	r.hosts["example.com"] = []string{"10.0.0.2"}
+	now = now.Add(time.Minute)
+	addrs, err := c.lookupHost(context.Background(), "example.com")
+	require.NoError(t, err)
+	require.Equal(t, []string{"10.0.0.2"}, addrs)
+	require.Equal(t, 2, r.calls)
+}
+
+func TestLookups_Canceled(t *testing.T) {
+	r := &fakeResolver{hosts: map[string][]string{"example.com": {"10.0.0.1"}}}
+	l := &Lookups{cache: newLookupCache(r, time.Minute)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	lookup := l.Functions(ctx)["dns_lookup"].(func(string, ...bool) ([]string, error))
+	_, err := lookup("example.com")
+	require.ErrorIs(t, err, context.Canceled)
+
+	// Canceled lookups aren't cached.
+	lookup = l.Functions(context.Background())["dns_lookup"].(func(string, ...bool) ([]string, error))
+	addrs, err := lookup("example.com")
+	require.NoError(t, err)
+	require.Equal(t, []string{"10.0.0.1"}, addrs)
+	require.Equal(t, 2, r.calls)
+}
+
+// useFakeResolver replaces the resolver used by dns_lookup and srv_lookup for
+// the duration of the test.
+func useFakeResolver(t *testing.T) *fakeResolver {
+	t.Helper()
+
+	r := &fakeResolver{
+		hosts: map[string][]string{
+			"example.com": {"10.0.0.2", "10.0.0.1"},
+		},
+		srvs: map[string][]*net.SRV{
+			"_http._tcp.example.com": {
+				{Target: "a.example.com.", Port: 8080, Priority: 1},
+				{Target: "b.example.com.", Port: 9090, Priority: 2},
+			},
+		},
+	}
+
+	orig := lookups
+	lookups = newLookupCache(r, time.Minute)
+	t.Cleanup(func() { lookups = orig })
+	return r
+}
+
+func evalDNS(t *testing.T, input string, v interface{}) {
+	t.Helper()
+
+	expr, err := parser.ParseExpression(input)
+	require.NoError(t, err)
+	require.NoError(t, vm.New(expr).Evaluate(&vm.Scope{Variables: Identifiers}, v))
+}
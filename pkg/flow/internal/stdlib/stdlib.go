@@ -0,0 +1,321 @@
+// Package stdlib contains standard library functions exposed to Flow configs
+// in addition to the functions provided by River.
+package stdlib
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Identifiers holds a list of stdlib identifiers by name. All interface{}
+// values are River-compatible values.
+//
+// Function identifiers follow the same rules as the River standard library:
+// they are Go functions with exactly one non-error return value, with an
+// optionally supported error return value as the second return value.
+var Identifiers = map[string]interface{}{
+	"url_parse":        urlParse,
+	"url_canonicalize": urlCanonicalize,
+	"uuidv4":           uuidV4,
+	"random_id":        randomID,
+	"dns_lookup":       dnsLookup,
+	"srv_lookup":       srvLookup,
+	"contains":         contains,
+	"index":            index,
+	"select":           selectValue,
+	"flatten":          flatten,
+	"prev":             prev,
+}
+
+// Nondeterministic holds the names of functions in Identifiers which may
+// return a different value each time they're called, even with the same
+// arguments. Controllers replace uuidv4 and random_id with the functions of
+// RandomValues, but their values still change every time a controller
+// starts. They must not be called from expressions which are required to
+// always have the same value.
+var Nondeterministic = map[string]struct{}{
+	"uuidv4":     {},
+	"random_id":  {},
+	"dns_lookup": {},
+	"srv_lookup": {},
+}
+
+// urlParse parses an absolute URL into an object of its components.
+func urlParse(in string) (map[string]interface{}, error) {
+	u, err := parseAbsoluteURL(in)
+	if err != nil {
+		return nil, err
+	}
+
+	query := make(map[string]interface{}, len(u.Query()))
+	for key, values := range u.Query() {
+		query[key] = values
+	}
+
+	return map[string]interface{}{
+		"scheme":    u.Scheme,
+		"username":  u.User.Username(),
+		"host":      u.Host,
+		"hostname":  u.Hostname(),
+		"port":      u.Port(),
+		"path":      u.Path,
+		"raw_query": u.RawQuery,
+		"query":     query,
+		"fragment":  u.Fragment,
+	}, nil
+}
+
+// defaultPorts maps schemes to the port used when a URL doesn't specify one.
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// urlCanonicalize returns the canonical form of an absolute URL, so that
+// equivalent URLs can be compared as strings. The scheme and host are
+// lowercased, default ports are removed, dot segments in the path are
+// resolved, and query parameters are sorted by key.
+func urlCanonicalize(in string) (string, error) {
+	u, err := parseAbsoluteURL(in)
+	if err != nil {
+		return "", err
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if port := u.Port(); port != "" && defaultPorts[u.Scheme] == port {
+		u.Host = strings.TrimSuffix(u.Host, ":"+port)
+	}
+
+	if u.Path != "" {
+		cleaned := path.Clean(u.Path)
+		if strings.HasSuffix(u.Path, "/") && cleaned != "/" {
+			cleaned += "/"
+		}
+		u.Path, u.RawPath = cleaned, ""
+	} else if u.Host != "" {
+		u.Path = "/"
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = u.Query().Encode()
+	}
+
+	return u.String(), nil
+}
+
+func parseAbsoluteURL(in string) (*url.URL, error) {
+	u, err := url.Parse(in)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" {
+		return nil, fmt.Errorf("URL %q is missing a scheme", in)
+	}
+	if u.Opaque != "" {
+		// Opaque URLs are usually a host and port which is missing a scheme,
+		// such as localhost:9009.
+		return nil, fmt.Errorf("URL %q must be of the form scheme://host/path", in)
+	}
+	return u, nil
+}
+
+// uuidV4 returns a random version 4 UUID.
+func uuidV4() (string, error) {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return "", err
+	}
+	return id.String(), nil
+}
+
+// maxRandomIDBytes is the largest number of bytes randomID generates.
+const maxRandomIDBytes = 1024
+
+// randomID returns n random bytes encoded as a hexadecimal string of length
+// 2*n.
+func randomID(n int) (string, error) {
+	if n <= 0 || n > maxRandomIDBytes {
+		return "", fmt.Errorf("random_id: number of bytes must be between 1 and %d, got %d", maxRandomIDBytes, n)
+	}
+
+	bb := make([]byte, n)
+	if _, err := rand.Read(bb); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bb), nil
+}
+
+// RandomValues remembers the values returned by the uuidv4 and random_id
+// functions it provides, so each function returns the same value every time
+// it's called with the same arguments.
+type RandomValues struct {
+	mut  sync.Mutex
+	uuid string
+	ids  map[int]string
+}
+
+// NewRandomValues returns RandomValues which haven't generated any value yet.
+func NewRandomValues() *RandomValues {
+	return &RandomValues{ids: make(map[int]string)}
+}
+
+// Functions returns the uuidv4 and random_id functions, which replace the
+// functions in Identifiers. Values are generated by the first call and reused
+// by later calls.
+func (r *RandomValues) Functions() map[string]interface{} {
+	return map[string]interface{}{
+		"uuidv4":    r.uuidV4,
+		"random_id": r.randomID,
+	}
+}
+
+func (r *RandomValues) uuidV4() (string, error) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	if r.uuid == "" {
+		id, err := uuidV4()
+		if err != nil {
+			return "", err
+		}
+		r.uuid = id
+	}
+	return r.uuid, nil
+}
+
+func (r *RandomValues) randomID(n int) (string, error) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	if id, ok := r.ids[n]; ok {
+		return id, nil
+	}
+	id, err := randomID(n)
+	if err != nil {
+		return "", err
+	}
+	r.ids[n] = id
+	return id, nil
+}
+
+// contains returns whether list has an element equal to value.
+func contains(list []interface{}, value interface{}) bool {
+	return index(list, value) >= 0
+}
+
+// index returns the index of the first element of list equal to value, or -1
+// if there's no such element.
+//
+// Elements are compared by their River value: numbers are equal if they have
+// the same numeric value, arrays and objects are equal if all of their
+// elements are equal, and values of different types are never equal.
+func index(list []interface{}, value interface{}) int {
+	for i, elem := range list {
+		if valuesEqual(elem, value) {
+			return i
+		}
+	}
+	return -1
+}
+
+// selectValue returns the value of options with the given key. If options
+// has no such key, the fallback is returned when given, and an error
+// otherwise.
+func selectValue(key string, options map[string]interface{}, fallback ...interface{}) (interface{}, error) {
+	if len(fallback) > 1 {
+		return nil, fmt.Errorf("select: expected at most 3 arguments, got %d", len(fallback)+2)
+	}
+	if value, ok := options[key]; ok {
+		return value, nil
+	}
+	if len(fallback) == 1 {
+		return fallback[0], nil
+	}
+	return nil, fmt.Errorf("select: key %q not found and no default given", key)
+}
+
+// flatten returns the elements of list with every nested list replaced by its
+// elements, recursively, so the result has no nested lists.
+func flatten(list []interface{}) []interface{} {
+	res := make([]interface{}, 0, len(list))
+	for _, elem := range list {
+		if nested, ok := elem.([]interface{}); ok {
+			res = append(res, flatten(nested)...)
+			continue
+		}
+		res = append(res, elem)
+	}
+	return res
+}
+
+// prev is called for calls to prev which weren't replaced by the previous
+// value of their argument. Calls are only replaced in the arguments of
+// components, when the argument is a reference such as
+// prev(discovery.kubernetes.pods.targets).
+func prev(interface{}) (interface{}, error) {
+	return nil, fmt.Errorf("prev: argument must be a reference to a value, and prev can only be used in the arguments of components")
+}
+
+// valuesEqual returns whether the River values a and b are equal.
+func valuesEqual(a, b interface{}) bool {
+	if an, ok := toFloat(a); ok {
+		bn, ok := toFloat(b)
+		return ok && an == bn
+	}
+
+	switch a := a.(type) {
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !valuesEqual(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for key, av := range a {
+			bv, ok := b[key]
+			if !ok || !valuesEqual(av, bv) {
+				return false
+			}
+		}
+		return true
+
+	default:
+		return reflect.DeepEqual(a, b)
+	}
+}
+
+// toFloat returns the River number v as a float64. River decodes numbers
+// into different Go types depending on how they're written, such as int for
+// 1 and float64 for 1.0.
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
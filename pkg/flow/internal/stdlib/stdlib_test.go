@@ -0,0 +1,238 @@
+package stdlib_test
+
+import (
+	"testing"
+
+	"github.com/grafana/agent/pkg/flow/internal/stdlib"
+	"github.com/grafana/river/parser"
+	"github.com/grafana/river/vm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLParse(t *testing.T) {
+	var actual map[string]interface{}
+	eval(t, `url_parse("https://user@example.com:8443/api/v1?b=2&a=1&a=3#frag")`, &actual)
+
+	require.Equal(t, map[string]interface{}{
+		"scheme":    "https",
+		"username":  "user",
+		"host":      "example.com:8443",
+		"hostname":  "example.com",
+		"port":      "8443",
+		"path":      "/api/v1",
+		"raw_query": "b=2&a=1&a=3",
+		"query": map[string]interface{}{
+			"a": []interface{}{"1", "3"},
+			"b": []interface{}{"2"},
+		},
+		"fragment": "frag",
+	}, actual)
+}
+
+func TestURLParse_Fields(t *testing.T) {
+	var actual string
+	eval(t, `url_parse("http://localhost:9009/api/prom/push").hostname`, &actual)
+	require.Equal(t, "localhost", actual)
+}
+
+func TestURLCanonicalize(t *testing.T) {
+	tt := []struct {
+		input  string
+		expect string
+	}{
+		{"http://example.com", "http://example.com/"},
+		{"HTTP://Example.COM:80/a/./b/../c", "http://example.com/a/c"},
+		{"https://example.com:443/path/", "https://example.com/path/"},
+		{"https://example.com:8443/path", "https://example.com:8443/path"},
+		{"http://example.com/?b=2&a=1", "http://example.com/?a=1&b=2"},
+		{"file:///etc/agent/config.river", "file:///etc/agent/config.river"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.input, func(t *testing.T) {
+			var actual string
+			eval(t, `url_canonicalize("`+tc.input+`")`, &actual)
+			require.Equal(t, tc.expect, actual)
+		})
+	}
+}
+
+func TestURLErrors(t *testing.T) {
+	tt := []struct {
+		expr      string
+		expectErr string
+	}{
+		{`url_parse("localhost:9009")`, `URL "localhost:9009" must be of the form scheme://host/path`},
+		{`url_parse("/api/v1/push")`, `URL "/api/v1/push" is missing a scheme`},
+		{`url_canonicalize("http://[::1")`, `missing ']' in host`},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.expr, func(t *testing.T) {
+			expr, err := parser.ParseExpression(tc.expr)
+			require.NoError(t, err)
+
+			var actual interface{}
+			err = vm.New(expr).Evaluate(&vm.Scope{Variables: stdlib.Identifiers}, &actual)
+			require.ErrorContains(t, err, tc.expectErr)
+		})
+	}
+}
+
+func TestUUIDV4(t *testing.T) {
+	var a, b string
+	eval(t, `uuidv4()`, &a)
+	eval(t, `uuidv4()`, &b)
+
+	require.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, a)
+	require.NotEqual(t, a, b)
+}
+
+func TestRandomID(t *testing.T) {
+	var a, b string
+	eval(t, `random_id(16)`, &a)
+	eval(t, `random_id(16)`, &b)
+
+	require.Regexp(t, `^[0-9a-f]{32}$`, a)
+	require.NotEqual(t, a, b)
+
+	expr, err := parser.ParseExpression(`random_id(0)`)
+	require.NoError(t, err)
+	require.ErrorContains(t, vm.New(expr).Evaluate(&vm.Scope{Variables: stdlib.Identifiers}, &a), "number of bytes must be between 1 and 1024")
+}
+
+func TestRandomValues(t *testing.T) {
+	r := stdlib.NewRandomValues()
+	scope := &vm.Scope{Variables: r.Functions()}
+	evalWith := func(input string) string {
+		expr, err := parser.ParseExpression(input)
+		require.NoError(t, err)
+		var s string
+		require.NoError(t, vm.New(expr).Evaluate(scope, &s))
+		return s
+	}
+
+	// Values are generated once and reused.
+	require.Equal(t, evalWith(`uuidv4()`), evalWith(`uuidv4()`))
+	require.Equal(t, evalWith(`random_id(16)`), evalWith(`random_id(16)`))
+	require.Len(t, evalWith(`random_id(8)`), 16)
+
+	// Other RandomValues generate other values.
+	other := stdlib.NewRandomValues().Functions()["uuidv4"].(func() (string, error))
+	id, err := other()
+	require.NoError(t, err)
+	require.NotEqual(t, evalWith(`uuidv4()`), id)
+}
+
+func TestContainsAndIndex(t *testing.T) {
+	tt := []struct {
+		expr   string
+		expect interface{}
+	}{
+		{`contains(["us-east-1", "eu-west-1"], "eu-west-1")`, true},
+		{`contains(["us-east-1", "eu-west-1"], "ap-south-1")`, false},
+		{`contains([], "us-east-1")`, false},
+		{`contains([1, 2.5], 1.0)`, true},
+		{`contains([[1, 2], {a = "b"}], {a = "b"})`, true},
+		{`contains(["1"], 1)`, false},
+		{`index(["a", "b", "b"], "b")`, 1},
+		{`index(["a", "b"], "c")`, -1},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.expr, func(t *testing.T) {
+			var actual interface{}
+			eval(t, tc.expr, &actual)
+			require.Equal(t, tc.expect, actual)
+		})
+	}
+}
+
+func TestContains_TypeMismatch(t *testing.T) {
+	expr, err := parser.ParseExpression(`contains("us-east-1", "us")`)
+	require.NoError(t, err)
+
+	var actual bool
+	err = vm.New(expr).Evaluate(&vm.Scope{Variables: stdlib.Identifiers}, &actual)
+	require.EqualError(t, err, `1:10: "us-east-1" should be array, got string`)
+}
+
+func TestSelect(t *testing.T) {
+	tt := []struct {
+		expr   string
+		expect interface{}
+	}{
+		{`select("prod", {prod = {replicas = 3}, dev = {replicas = 1}})`, map[string]interface{}{"replicas": 3}},
+		{`select("dev", {prod = "a", dev = "b"}, "c")`, "b"},
+		{`select("staging", {prod = "a", dev = "b"}, "c")`, "c"},
+		{`select("staging", {}, null)`, nil},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.expr, func(t *testing.T) {
+			var actual interface{}
+			eval(t, tc.expr, &actual)
+			require.Equal(t, tc.expect, actual)
+		})
+	}
+}
+
+func TestSelect_Errors(t *testing.T) {
+	tt := []struct {
+		expr      string
+		expectErr string
+	}{
+		{`select("staging", {prod = "a", dev = "b"})`, `select: key "staging" not found and no default given`},
+		{`select("prod", {prod = "a"}, "b", "c")`, `select: expected at most 3 arguments, got 4`},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.expr, func(t *testing.T) {
+			expr, err := parser.ParseExpression(tc.expr)
+			require.NoError(t, err)
+
+			var actual interface{}
+			err = vm.New(expr).Evaluate(&vm.Scope{Variables: stdlib.Identifiers}, &actual)
+			require.ErrorContains(t, err, tc.expectErr)
+		})
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	tt := []struct {
+		expr   string
+		expect interface{}
+	}{
+		{`flatten([])`, []interface{}{}},
+		{`flatten([[], []])`, []interface{}{}},
+		{`flatten(["a", "b"])`, []interface{}{"a", "b"}},
+		{`flatten([["a"], ["b", "c"]])`, []interface{}{"a", "b", "c"}},
+		{`flatten([1, [2, [3, [4, []]]], 5])`, []interface{}{1, 2, 3, 4, 5}},
+		{`flatten([{a = [1]}, [{b = 2}]])`, []interface{}{map[string]interface{}{"a": []interface{}{1}}, map[string]interface{}{"b": 2}}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.expr, func(t *testing.T) {
+			var actual interface{}
+			eval(t, tc.expr, &actual)
+			require.Equal(t, tc.expect, actual)
+		})
+	}
+}
+
+func TestPrev_NotReplaced(t *testing.T) {
+	expr, err := parser.ParseExpression(`prev([1, 2])`)
+	require.NoError(t, err)
+
+	var actual interface{}
+	err = vm.New(expr).Evaluate(&vm.Scope{Variables: stdlib.Identifiers}, &actual)
+	require.ErrorContains(t, err, "prev: argument must be a reference")
+}
+
+func eval(t *testing.T, input string, v interface{}) {
+	t.Helper()
+
+	expr, err := parser.ParseExpression(input)
+	require.NoError(t, err)
+	require.NoError(t, vm.New(expr).Evaluate(&vm.Scope{Variables: stdlib.Identifiers}, v))
+}
@@ -0,0 +1,79 @@
+// Package stepper lets tests step the propagation of component updates in a
+// Flow controller deterministically, instead of waiting for the controller to
+// propagate updates in the background.
+package stepper
+
+import (
+	"context"
+	"fmt"
+)
+
+// A Stepper takes over propagating component updates for the Flow controller
+// it's passed to. Updates reported by components stay queued until Step is
+// called, which propagates them in the controller's run loop and waits for
+// the resulting evaluations to finish.
+type Stepper struct {
+	requests chan Request
+}
+
+// Request is a request to run one propagation pass, received by the Flow
+// controller from Requests.
+type Request struct {
+	// Updated holds the IDs of components to propagate updates from in
+	// addition to the components which reported an update since the last
+	// pass.
+	Updated []string
+
+	result chan<- result
+}
+
+type result struct {
+	evaluated []string
+	err       error
+}
+
+// Respond reports the result of the propagation pass to the caller of Step.
+// evaluated holds the IDs of the components evaluated by the pass, in the
+// order they were evaluated.
+func (r Request) Respond(evaluated []string, err error) {
+	r.result <- result{evaluated: evaluated, err: err}
+}
+
+// New returns a new Stepper.
+func New() *Stepper {
+	return &Stepper{requests: make(chan Request)}
+}
+
+// Requests returns the channel the Flow controller receives propagation
+// requests from.
+func (s *Stepper) Requests() <-chan Request {
+	return s.requests
+}
+
+// Step runs one propagation pass: the dependants of every updated component
+// are evaluated one at a time, ordered by their IDs, before Step returns.
+// Components whose exports change as a result are propagated by the next call
+// to Step, so each call moves updates one step further through the graph.
+//
+// updated optionally names components to treat as updated, such as a
+// component whose exports a test changed. Step returns the IDs of the
+// components which were evaluated, in order.
+//
+// Step blocks until the controller is running, or until ctx is canceled.
+func (s *Stepper) Step(ctx context.Context, updated ...string) ([]string, error) {
+	resultCh := make(chan result, 1)
+	req := Request{Updated: updated, result: resultCh}
+
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for the controller to run: %w", ctx.Err())
+	case s.requests <- req:
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-resultCh:
+		return res.evaluated, res.err
+	}
+}
@@ -3,6 +3,8 @@ package flow
 import (
 	"context"
 	"fmt"
+	"net"
+	"net/http"
 	"path"
 	"sync"
 
@@ -14,6 +16,7 @@ import (
 	"github.com/grafana/agent/pkg/flow/tracing"
 	"github.com/grafana/river/scanner"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tilinna/clock"
 	"golang.org/x/exp/maps"
 )
 
@@ -110,6 +113,7 @@ func newModule(o *moduleOptions) *module {
 			ModuleRegistry:    o.ModuleRegistry,
 			ComponentRegistry: o.ComponentRegistry,
 			WorkerPool:        o.WorkerPool,
+			EventLog:          o.EventLog,
 			Options: Options{
 				ControllerID: o.ID,
 				Tracer:       o.Tracer,
@@ -121,7 +125,20 @@ func newModule(o *moduleOptions) *module {
 						o.export(exports)
 					}
 				},
-				Services: o.ServiceMap.List(),
+				Services:          o.ServiceMap.List(),
+				AllowedComponents: o.AllowedComponents,
+				DeniedComponents:  o.DeniedComponents,
+				Functions:         o.Functions,
+				ConfigDir:         o.ConfigDir,
+				Parallelism:       o.Parallelism,
+				GraphReduction:    o.GraphReduction,
+				ErrorHistorySize:  o.ErrorHistorySize,
+				StrictShadowing:   o.StrictShadowing,
+				Singletons:        o.Singletons,
+				CircuitBreaker:    o.CircuitBreaker,
+				HTTPClient:        o.HTTPClient,
+				Resolver:          o.Resolver,
+				Clock:             o.Clock,
 			},
 		}),
 	}
@@ -187,4 +204,52 @@ type moduleControllerOptions struct {
 	// WorkerPool is a worker pool that can be used to run tasks asynchronously. A default pool will be created if this
 	// is nil.
 	WorkerPool worker.Pool
+
+	// AllowedComponents and DeniedComponents restrict the components which may
+	// be used in modules. See [Options.AllowedComponents] for more information.
+	AllowedComponents []string
+	DeniedComponents  []string
+
+	// Functions holds the custom functions which may be called from modules.
+	Functions *FunctionRegistry
+
+	// ConfigDir is the directory exposed to modules as config_dir. See
+	// [Options.ConfigDir] for more information.
+	ConfigDir string
+
+	// Parallelism is the maximum number of components of modules evaluated
+	// concurrently. See [Options.Parallelism] for more information.
+	Parallelism int
+
+	// GraphReduction controls which loads of modules reduce their graph. See
+	// [Options.GraphReduction] for more information.
+	GraphReduction GraphReduction
+
+	// ErrorHistorySize is the number of errors kept for each component in
+	// modules. See [Options.ErrorHistorySize] for more information.
+	ErrorHistorySize int
+
+	// StrictShadowing makes loading modules fail if a component shadows a
+	// function. See [Options.StrictShadowing] for more information.
+	StrictShadowing bool
+
+	// Singletons shares singleton components of modules with other
+	// controllers. See [Options.Singletons] for more information.
+	Singletons *SingletonRegistry
+
+	// CircuitBreaker quarantines components of modules which keep failing.
+	// See [Options.CircuitBreaker] for more information.
+	CircuitBreaker CircuitBreakerOptions
+
+	// HTTPClient and Resolver are used for network requests made by modules.
+	// See [Options.HTTPClient] and [Options.Resolver] for more information.
+	HTTPClient *http.Client
+	Resolver   *net.Resolver
+
+	// Clock times modules. See [Options.Clock] for more information.
+	Clock clock.Clock
+
+	// EventLog is the event log of the root controller, where events of
+	// modules are recorded.
+	EventLog *eventLog
 }
@@ -140,6 +140,85 @@ func TestUpdates_ThroughModule(t *testing.T) {
 	}, 3*time.Second, 10*time.Millisecond)
 }
 
+func TestUpdates_ThroughNestedModule(t *testing.T) {
+	// The inner module passes its argument through to its export.
+	inner := `
+	argument "input" {
+		optional = false
+	}
+
+	testcomponents.passthrough "pt" {
+		input = argument.input.value
+		lag = "1ms"
+	}
+
+	export "output" {
+		value = testcomponents.passthrough.pt.output
+	}
+`
+
+	// The outer module re-exports the export of the inner module, so the root
+	// config references an export defined two modules deep.
+	outer := `
+	argument "input" {
+		optional = false
+	}
+
+	module.string "inner" {
+		content = ` + strconv.Quote(inner) + `
+		arguments {
+			input = argument.input.value
+		}
+	}
+
+	export "output" {
+		value = module.string.inner.exports.output
+	}
+`
+
+	config := `
+	testcomponents.count "inc" {
+		frequency = "10ms"
+		max = 10
+	}
+
+	module.string "outer" {
+		content = ` + strconv.Quote(outer) + `
+		arguments {
+			input = testcomponents.count.inc.count
+		}
+	}
+
+	testcomponents.summation "sum" {
+		input = module.string.outer.exports.output
+	}
+`
+
+	ctrl := flow.New(testOptions(t))
+	f, err := flow.ParseSource(t.Name(), []byte(config))
+	require.NoError(t, err)
+	require.NotNil(t, f)
+
+	err = ctrl.LoadSource(f, nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		ctrl.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	require.Eventually(t, func() bool {
+		export := getExport[testcomponents.SummationExports](t, ctrl, "", "testcomponents.summation.sum")
+		return export.LastAdded == 10
+	}, 3*time.Second, 10*time.Millisecond)
+}
+
 func testOptions(t *testing.T) flow.Options {
 	t.Helper()
 	s, err := logging.New(os.Stderr, logging.DefaultOptions)
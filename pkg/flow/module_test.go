@@ -91,13 +91,13 @@ func TestModule(t *testing.T) {
 			name:                  "Duplicate argument config",
 			argumentModuleContent: argumentConfig + argumentConfig,
 			exportModuleContent:   exportStringConfig,
-			expectedErrorContains: "\"argument.username\" block already declared",
+			expectedErrorContains: "duplicate block \"argument.username\" defined at",
 		},
 		{
 			name:                  "Duplicate export config",
 			argumentModuleContent: argumentConfig,
 			exportModuleContent:   exportStringConfig + exportStringConfig,
-			expectedErrorContains: "\"export.username\" block already declared",
+			expectedErrorContains: "duplicate block \"export.username\" defined at",
 		},
 		{
 			name:                "Multiple exports but none are used but still exported",
@@ -138,6 +138,25 @@ func TestModule(t *testing.T) {
 	}
 }
 
+func TestModuleComponentPolicy(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+	opts := testModuleControllerOptions(t)
+	opts.DeniedComponents = []string{"testcomponents.*"}
+	mc := newModuleController(opts).(*moduleController)
+	defer mc.o.WorkerPool.Stop()
+
+	tm := &testModule{
+		content: `
+			testcomponents.passthrough "static" {
+				input = "hello, world!"
+			}`,
+		opts: component.Options{ModuleController: mc},
+	}
+	ctx, cnc := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cnc()
+	require.ErrorContains(t, tm.Run(ctx), `Component "testcomponents.passthrough" is not allowed by the component policy`)
+}
+
 func TestArgsNotInModules(t *testing.T) {
 	defer verifyNoGoroutineLeaks(t)
 	f := New(testOptions(t))
@@ -0,0 +1,139 @@
+package flow
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/tilinna/clock"
+)
+
+// errReloadGuardStopped is returned for reloads which weren't applied because
+// the controller stopped.
+var errReloadGuardStopped = errors.New("reload not applied: the controller stopped")
+
+// reloadGuard enforces a minimum interval between applied reloads. Reloads
+// requested sooner wait until the interval elapses, and are coalesced so only
+// the most recently requested source is applied.
+//
+// Deferred reloads are tied to the lifetime of the controller: they're
+// applied with the context of the guard, which is canceled by stop.
+type reloadGuard struct {
+	interval   time.Duration
+	clock      clock.Clock
+	apply      func(ctx context.Context, source *Source, trigger ReloadTrigger) (bool, error)
+	onCoalesce func() // Called for every source replaced before it was applied.
+
+	ctx     context.Context // Context of deferred reloads; canceled by stop.
+	cancel  context.CancelFunc
+	applies sync.WaitGroup // Deferred reloads being applied.
+
+	mut     sync.Mutex
+	last    time.Time      // When the last reload was applied.
+	pending *pendingReload // Reload waiting for the interval to elapse, if any.
+	timer   *clock.Timer   // Timer applying pending.
+}
+
+// pendingReload is a reload waiting for the minimum interval to elapse.
+type pendingReload struct {
+	source  *Source       // Source to apply; replaced by newer reloads.
+	trigger ReloadTrigger // Trigger of source; replaced by newer reloads.
+
+	done    chan struct{} // Closed once the reload was applied.
+	changed bool          // Result of the reload; set before done is closed.
+	err     error         // Result of the reload; set before done is closed.
+}
+
+func newReloadGuard(interval time.Duration, clk clock.Clock, apply func(ctx context.Context, source *Source, trigger ReloadTrigger) (bool, error), onCoalesce func()) *reloadGuard {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &reloadGuard{
+		interval:   interval,
+		clock:      clk,
+		apply:      apply,
+		onCoalesce: onCoalesce,
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// reload applies source immediately if the minimum interval elapsed since
+// the last applied reload. Otherwise, it waits for the interval to elapse and
+// returns the result of applying the most recent source requested by then,
+// along with its trigger.
+//
+// If ctx is canceled while waiting, reload returns ctx.Err(), but the pending
+// reload is still applied once the interval elapses unless the guard is
+// stopped first. Reloads requested after the guard stopped aren't applied.
+func (g *reloadGuard) reload(ctx context.Context, source *Source, trigger ReloadTrigger) (bool, error) {
+	g.mut.Lock()
+	if g.ctx.Err() != nil {
+		g.mut.Unlock()
+		return false, errReloadGuardStopped
+	}
+	if p := g.pending; p != nil {
+		p.source, p.trigger = source, trigger
+		g.mut.Unlock()
+
+		g.onCoalesce()
+		return p.wait(ctx)
+	}
+
+	wait := g.interval - g.clock.Since(g.last)
+	if wait <= 0 {
+		g.last = g.clock.Now()
+		g.mut.Unlock()
+		return g.apply(ctx, source, trigger)
+	}
+
+	p := &pendingReload{source: source, trigger: trigger, done: make(chan struct{})}
+	g.pending = p
+	g.timer = g.clock.AfterFunc(wait, func() {
+		g.mut.Lock()
+		if g.pending != p {
+			// The guard was stopped before the timer fired.
+			g.mut.Unlock()
+			return
+		}
+		g.pending, g.timer = nil, nil
+		g.last = g.clock.Now()
+		source, trigger := p.source, p.trigger
+		g.applies.Add(1)
+		g.mut.Unlock()
+		defer g.applies.Done()
+
+		// The reload is shared by every coalesced caller, so it isn't canceled
+		// by the context of any of them, only by stopping the guard.
+		p.changed, p.err = g.apply(g.ctx, source, trigger)
+		close(p.done)
+	})
+	g.mut.Unlock()
+	return p.wait(ctx)
+}
+
+// stop stops applying deferred reloads. The pending reload, if any, is
+// dropped and its callers receive an error, and the context of a deferred
+// reload being applied is canceled. stop waits for that reload to return.
+func (g *reloadGuard) stop() {
+	g.mut.Lock()
+	g.cancel()
+	if p := g.pending; p != nil {
+		g.timer.Stop()
+		g.pending, g.timer = nil, nil
+		p.err = errReloadGuardStopped
+		close(p.done)
+	}
+	g.mut.Unlock()
+
+	g.applies.Wait()
+}
+
+// wait waits for p to be applied and returns its result.
+func (p *pendingReload) wait(ctx context.Context) (bool, error) {
+	select {
+	case <-p.done:
+		return p.changed, p.err
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
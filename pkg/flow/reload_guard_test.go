@@ -0,0 +1,188 @@
+package flow
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tilinna/clock"
+)
+
+func TestReloadGuard(t *testing.T) {
+	var (
+		mut       sync.Mutex
+		applied   []*Source
+		triggers  []ReloadTrigger
+		coalesced int
+	)
+	g := newReloadGuard(100*time.Millisecond, clock.Realtime(), func(_ context.Context, source *Source, trigger ReloadTrigger) (bool, error) {
+		mut.Lock()
+		defer mut.Unlock()
+		applied = append(applied, source)
+		triggers = append(triggers, trigger)
+		return true, nil
+	}, func() {
+		mut.Lock()
+		defer mut.Unlock()
+		coalesced++
+	})
+
+	first, second, third := &Source{}, &Source{}, &Source{}
+
+	// The first reload is applied immediately.
+	changed, err := g.reload(context.Background(), first, ReloadTriggerStartup)
+	require.NoError(t, err)
+	require.True(t, changed)
+	start := time.Now()
+
+	// Later reloads wait for the interval, and only the latest is applied.
+	var (
+		wg            sync.WaitGroup
+		secondChanged bool
+		secondErr     error
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		secondChanged, secondErr = g.reload(context.Background(), second, ReloadTriggerSignal)
+	}()
+	require.Eventually(t, func() bool {
+		g.mut.Lock()
+		defer g.mut.Unlock()
+		return g.pending != nil
+	}, time.Second, time.Millisecond)
+
+	changed, err = g.reload(context.Background(), third, ReloadTriggerHTTP)
+	require.NoError(t, err)
+	require.True(t, changed)
+	wg.Wait()
+	require.NoError(t, secondErr)
+	require.True(t, secondChanged)
+
+	require.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	require.Len(t, applied, 2)
+	require.Same(t, first, applied[0])
+	require.Same(t, third, applied[1])
+	require.Equal(t, []ReloadTrigger{ReloadTriggerStartup, ReloadTriggerHTTP}, triggers)
+	require.Equal(t, 1, coalesced)
+}
+
+func TestReloadGuard_Canceled(t *testing.T) {
+	applied := make(chan *Source, 2)
+	g := newReloadGuard(50*time.Millisecond, clock.Realtime(), func(_ context.Context, source *Source, _ ReloadTrigger) (bool, error) {
+		applied <- source
+		return true, nil
+	}, func() {})
+
+	_, err := g.reload(context.Background(), &Source{}, ReloadTriggerUnknown)
+	require.NoError(t, err)
+	<-applied
+
+	// Canceled callers stop waiting, but the reload is still applied.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	source := &Source{}
+	_, err = g.reload(ctx, source, ReloadTriggerUnknown)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Same(t, source, <-applied)
+}
+
+func TestReloadGuard_Stop(t *testing.T) {
+	applied := make(chan *Source, 2)
+	g := newReloadGuard(time.Hour, clock.Realtime(), func(_ context.Context, source *Source, _ ReloadTrigger) (bool, error) {
+		applied <- source
+		return true, nil
+	}, func() {})
+
+	_, err := g.reload(context.Background(), &Source{}, ReloadTriggerUnknown)
+	require.NoError(t, err)
+	<-applied
+
+	// Stopping the guard drops the pending reload.
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := g.reload(context.Background(), &Source{}, ReloadTriggerUnknown)
+		errCh <- err
+	}()
+	require.Eventually(t, func() bool {
+		g.mut.Lock()
+		defer g.mut.Unlock()
+		return g.pending != nil
+	}, time.Second, time.Millisecond)
+
+	g.stop()
+	require.ErrorIs(t, <-errCh, errReloadGuardStopped)
+	require.Empty(t, applied)
+
+	_, err = g.reload(context.Background(), &Source{}, ReloadTriggerUnknown)
+	require.ErrorIs(t, err, errReloadGuardStopped)
+	require.Empty(t, applied)
+}
+
+func TestReloadGuard_StopCancelsApply(t *testing.T) {
+	started := make(chan struct{}, 2)
+	g := newReloadGuard(10*time.Millisecond, clock.Realtime(), func(ctx context.Context, _ *Source, _ ReloadTrigger) (bool, error) {
+		started <- struct{}{}
+		<-ctx.Done()
+		return false, ctx.Err()
+	}, func() {})
+
+	// Only the deferred reload is applied with the context of the guard.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := g.reload(ctx, &Source{}, ReloadTriggerUnknown)
+	require.ErrorIs(t, err, context.Canceled)
+	<-started
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := g.reload(context.Background(), &Source{}, ReloadTriggerUnknown)
+		errCh <- err
+	}()
+	<-started
+
+	g.stop()
+	require.ErrorIs(t, <-errCh, context.Canceled)
+}
+
+func TestController_StopsDeferredReloads(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+	opts := testOptions(t)
+	opts.MinReloadInterval = time.Hour
+	ctrl := New(opts)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		ctrl.Run(ctx)
+		close(done)
+	}()
+
+	source, err := ParseSource(t.Name(), []byte(`
+		testcomponents.tick "ticker" {
+			frequency = "1s"
+		}
+	`))
+	require.NoError(t, err)
+	_, err = ctrl.Reload(context.Background(), source)
+	require.NoError(t, err)
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := ctrl.Reload(context.Background(), source)
+		errCh <- err
+	}()
+	require.Eventually(t, func() bool {
+		ctrl.reloads.mut.Lock()
+		defer ctrl.reloads.mut.Unlock()
+		return ctrl.reloads.pending != nil
+	}, time.Second, time.Millisecond)
+
+	// Shutting down the controller fails the deferred reload instead of
+	// applying it once the interval elapses.
+	cancel()
+	<-done
+	require.ErrorIs(t, <-errCh, errReloadGuardStopped)
+}
@@ -0,0 +1,17 @@
+package flow
+
+// ReloadTrigger identifies the mechanism which triggered a reload, so
+// operators can tell why the config was reloaded. Triggers are included in
+// logs, in the LoadSummary of the reload, and as the trigger label of the
+// agent_component_controller_reloads_total metric, so embedders defining
+// their own triggers should only use a small, fixed set of values.
+type ReloadTrigger string
+
+// Common reload triggers.
+const (
+	ReloadTriggerUnknown      ReloadTrigger = "unknown"       // Reloads requested by Reload.
+	ReloadTriggerStartup      ReloadTrigger = "startup"       // The initial load when starting.
+	ReloadTriggerSignal       ReloadTrigger = "signal"        // A signal such as SIGHUP.
+	ReloadTriggerHTTP         ReloadTrigger = "http"          // A request to an HTTP endpoint.
+	ReloadTriggerConfigSource ReloadTrigger = "config_source" // A config received by RunWithConfigSource.
+)
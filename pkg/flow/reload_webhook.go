@@ -0,0 +1,162 @@
+package flow
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/grafana/agent/pkg/flow/logging"
+	"github.com/grafana/agent/pkg/flow/logging/level"
+	"github.com/grafana/dskit/backoff"
+)
+
+// LoadSummary summarizes a successful call to [Flow.LoadSource].
+type LoadSummary struct {
+	ControllerID string        `json:"controllerID,omitempty"` // ID of the controller which loaded the source.
+	SHA256       string        `json:"sha256"`                 // Hex-encoded checksum of the loaded source.
+	Components   int           `json:"components"`             // Number of components in the loaded source.
+	Warnings     int           `json:"warnings"`               // Number of warnings reported while loading.
+	LoadedAt     time.Time     `json:"loadedAt"`               // Time the load finished.
+	Duration     time.Duration `json:"duration"`               // Time spent applying the source, in nanoseconds.
+	Unchanged    bool          `json:"unchanged,omitempty"`    // Whether the load was skipped because the source didn't change.
+	Trigger      ReloadTrigger `json:"trigger,omitempty"`      // What triggered the reload; empty for calls to LoadSource.
+}
+
+var (
+	// reloadWebhookTimeout is the maximum amount of time spent delivering a
+	// single LoadSummary, including retries.
+	reloadWebhookTimeout = 30 * time.Second
+
+	// reloadWebhookBackoff configures retries of failed webhook requests.
+	reloadWebhookBackoff = backoff.Config{
+		MinBackoff: 500 * time.Millisecond,
+		MaxBackoff: 5 * time.Second,
+		MaxRetries: 5,
+	}
+)
+
+// reloadNotifier delivers LoadSummaries to a webhook. Delivery happens in the
+// background, and failures are only logged so notifying the webhook never
+// affects the running controller.
+type reloadNotifier struct {
+	log    *logging.Logger
+	url    string
+	client *http.Client
+
+	mut    sync.Mutex
+	cancel context.CancelFunc // Cancels the in-flight notification, if any.
+	wg     sync.WaitGroup
+}
+
+// newReloadNotifier returns a reloadNotifier which sends requests to url with
+// client, or with a default client if client is nil.
+func newReloadNotifier(l *logging.Logger, url string, client *http.Client) *reloadNotifier {
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	return &reloadNotifier{
+		log:    l,
+		url:    url,
+		client: client,
+	}
+}
+
+// Notify sends summary to the webhook in the background. An in-flight
+// notification for an older summary is abandoned, since only the most recent
+// load is relevant to the receiver.
+func (n *reloadNotifier) Notify(summary LoadSummary) {
+	body, err := json.Marshal(summary)
+	if err != nil {
+		level.Error(n.log).Log("msg", "failed to encode reload webhook payload", "err", err)
+		return
+	}
+
+	n.mut.Lock()
+	defer n.mut.Unlock()
+
+	if n.cancel != nil {
+		n.cancel()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), reloadWebhookTimeout)
+	n.cancel = cancel
+
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		defer cancel()
+
+		if err := n.send(ctx, body); err != nil {
+			level.Warn(n.log).Log("msg", "failed to notify reload webhook", "url", n.url, "err", err)
+		}
+	}()
+}
+
+func (n *reloadNotifier) send(ctx context.Context, body []byte) error {
+	var (
+		bo      = backoff.New(ctx, reloadWebhookBackoff)
+		lastErr error
+	)
+
+	for bo.Ongoing() {
+		lastErr = n.post(ctx, body)
+		if lastErr == nil {
+			return nil
+		}
+		bo.Wait()
+	}
+
+	if lastErr == nil {
+		return bo.Err()
+	}
+	return lastErr
+}
+
+func (n *reloadNotifier) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Stop abandons any in-flight notification and waits for it to exit.
+func (n *reloadNotifier) Stop() {
+	n.mut.Lock()
+	if n.cancel != nil {
+		n.cancel()
+	}
+	n.mut.Unlock()
+
+	n.wg.Wait()
+	n.client.CloseIdleConnections()
+}
+
+func newLoadSummary(controllerID string, source *Source, components, warnings int, loadedAt time.Time, duration time.Duration) LoadSummary {
+	hash := source.SHA256()
+	return LoadSummary{
+		ControllerID: controllerID,
+		SHA256:       hex.EncodeToString(hash[:]),
+		Components:   components,
+		Warnings:     warnings,
+		LoadedAt:     loadedAt,
+		Duration:     duration,
+	}
+}
@@ -0,0 +1,133 @@
+package flow
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func TestController_ReloadWebhook(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+
+	var (
+		attempts  atomic.Int32
+		summaries = make(chan LoadSummary, 1)
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Fail the first attempt to make sure requests are retried.
+		if attempts.Inc() == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		var summary LoadSummary
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&summary))
+		summaries <- summary
+	}))
+	defer srv.Close()
+
+	opts := testOptions(t)
+	opts.ReloadWebhook = srv.URL
+	ctrl := New(opts)
+	defer cleanUpController(ctrl)
+
+	f, err := ParseSource(t.Name(), []byte(`
+		testcomponents.passthrough "static" {
+			input = "hello, world!"
+		}
+	`))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	select {
+	case summary := <-summaries:
+		hash := f.SHA256()
+		require.Equal(t, hex.EncodeToString(hash[:]), summary.SHA256)
+		require.Equal(t, 1, summary.Components)
+		require.Equal(t, 0, summary.Warnings)
+		require.Equal(t, int32(2), attempts.Load())
+		require.False(t, summary.Unchanged)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "webhook was not notified")
+	}
+
+	// Loading the same source again is reported as unchanged.
+	require.NoError(t, ctrl.LoadSource(f, nil))
+	select {
+	case summary := <-summaries:
+		require.True(t, summary.Unchanged)
+		require.Equal(t, 1, summary.Components)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "webhook was not notified")
+	}
+}
+
+func TestController_ReloadWebhook_SkipsFailedLoads(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Inc()
+	}))
+	defer srv.Close()
+
+	opts := testOptions(t)
+	opts.ReloadWebhook = srv.URL
+	ctrl := New(opts)
+
+	f, err := ParseSource(t.Name(), []byte(`
+		testcomponents.passthrough "static" {
+			input = testcomponents.passthrough.missing.output
+		}
+	`))
+	require.NoError(t, err)
+	require.Error(t, ctrl.LoadSource(f, nil))
+
+	cleanUpController(ctrl)
+	require.Equal(t, int32(0), attempts.Load())
+}
+
+// recordingTransport records the URLs of requests before sending them.
+type recordingTransport struct {
+	requests chan string
+}
+
+func (rt recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.requests <- req.URL.String()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestController_ReloadWebhook_HTTPClient(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	rt := recordingTransport{requests: make(chan string, 1)}
+	opts := testOptions(t)
+	opts.ReloadWebhook = srv.URL
+	opts.HTTPClient = &http.Client{Transport: rt}
+	ctrl := New(opts)
+	defer cleanUpController(ctrl)
+
+	f, err := ParseSource(t.Name(), []byte(`
+		testcomponents.passthrough "static" {
+			input = "hello, world!"
+		}
+	`))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	select {
+	case url := <-rt.requests:
+		require.Equal(t, srv.URL, url)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "webhook wasn't notified through the configured client")
+	}
+}
@@ -0,0 +1,32 @@
+package flow
+
+import "github.com/grafana/agent/pkg/flow/internal/controller"
+
+// SingletonRegistry shares components registered as singletons between the
+// Flow controllers using it through [Options.Singletons], such as when one
+// process runs a controller per tenant.
+//
+// Each singleton component is built once per registry, by the first
+// controller to evaluate it, and other controllers reuse it instead of
+// building a duplicate. Every controller updates the shared component with
+// its own arguments, so the most recently evaluated arguments apply. The
+// shared component runs while any controller using it runs it, and is
+// stopped once the last one stops. Once no controller uses it anymore, because
+// the controllers shut down or removed it from their configs, the component is
+// dropped, and the next controller to evaluate it builds a new one.
+type SingletonRegistry struct {
+	components *controller.SingletonRegistry
+}
+
+// NewSingletonRegistry returns a new, empty SingletonRegistry.
+func NewSingletonRegistry() *SingletonRegistry {
+	return &SingletonRegistry{components: controller.NewSingletonRegistry()}
+}
+
+// registry returns the registry of shared components, or nil if r is nil.
+func (r *SingletonRegistry) registry() *controller.SingletonRegistry {
+	if r == nil {
+		return nil
+	}
+	return r.components
+}
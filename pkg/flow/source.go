@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/grafana/agent/pkg/config/encoder"
 	"github.com/grafana/river/ast"
@@ -17,6 +18,8 @@ type Source struct {
 	sourceMap map[string][]byte // Map that links parsed Flow source's name with its content.
 	hash      [sha256.Size]byte // Hash of all files in sourceMap sorted by name.
 
+	parseDuration time.Duration // Time spent parsing the source.
+
 	// Components holds the list of raw River AST blocks describing components.
 	// The Flow controller can interpret them.
 	components   []*ast.BlockStmt
@@ -28,29 +31,106 @@ type Source struct {
 //
 // bb must not be modified after passing to ParseSource.
 func ParseSource(name string, bb []byte) (*Source, error) {
+	return ParseSourceWithIncludes(name, bb, nil)
+}
+
+// ParseSourceWithIncludes parses the River file specified by bb like
+// ParseSource, and also resolves include directives.
+//
+// An include directive is written as `include "path"` on its own line at the
+// top level of a file, and splices the blocks of the River file at path into
+// the including file in place of the directive. Relative paths are resolved
+// against the directory of the including file, and included files are read
+// with readFile. Included files may include other files, but not in a cycle.
+//
+// Diagnostics for included blocks refer to the file they were written in, and
+// included files are part of the raw configs and the hash of the Source. If
+// readFile is nil, include directives are reported as errors.
+func ParseSourceWithIncludes(name string, bb []byte, readFile func(name string) ([]byte, error)) (*Source, error) {
+	start := time.Now()
+
+	p := includeParser{readFile: readFile}
+	body, bb, err := p.parseFile(name, bb, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	sourceMap := map[string][]byte{name: bb}
+	hash := sha256.Sum256(bb)
+	if len(p.included) > 0 {
+		h := sha256.New()
+		h.Write(bb)
+		for _, included := range p.included {
+			h.Write(included.Content)
+			sourceMap[included.Name] = included.Content
+		}
+		hash = [sha256.Size]byte(h.Sum(nil))
+	}
+
+	components, configs, err := splitBlocks(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Source{
+		components:   components,
+		configBlocks: configs,
+		sourceMap:    sourceMap,
+		hash:         hash,
+
+		parseDuration: time.Since(start),
+	}, nil
+}
+
+// ParseConfig parses the River file bb named name the same way ParseSource
+// does, but returns its syntax tree instead of a Source. It's intended for
+// tools which inspect or rewrite configs and need to agree with the Flow
+// controller on what a config contains.
+//
+// Like ParseSource, ParseConfig converts bb to UTF-8 and reports the same
+// errors for statements which aren't allowed at the top level of a config.
+// Include directives aren't River syntax, so they're kept in the returned
+// tree as comments holding the text of the directive, without reading the
+// included files. Printing the tree writes the directives back unchanged,
+// and positions in the tree refer to the original contents of bb.
+func ParseConfig(name string, bb []byte) (*ast.File, error) {
 	bb, err := encoder.EnsureUTF8(bb, true)
 	if err != nil {
 		return nil, err
 	}
-	node, err := parser.ParseFile(name, bb)
+
+	directives, err := findIncludes(name, bb)
+	if err != nil {
+		return nil, err
+	}
+	parsed := bb
+	if len(directives) > 0 {
+		parsed = blankIncludes(bb, directives)
+	}
+
+	file, err := parser.ParseFile(name, parsed)
 	if err != nil {
 		return nil, err
 	}
+	if _, _, err := splitBlocks(file.Body); err != nil {
+		return nil, err
+	}
+	if len(directives) > 0 {
+		file.Comments = includeComments(file.Comments, bb, directives)
+	}
+	return file, nil
+}
 
-	// Look for predefined non-components blocks (i.e., logging), and store
-	// everything else into a list of components.
-	//
+// splitBlocks splits the top-level statements of a config into component
+// blocks and predefined config blocks (e.g., logging). It returns an error
+// for any statement which isn't a block.
+func splitBlocks(body ast.Body) (components, configs []*ast.BlockStmt, err error) {
 	// TODO(rfratto): should this code be brought into a helper somewhere? Maybe
 	// in ast?
-	var (
-		components []*ast.BlockStmt
-		configs    []*ast.BlockStmt
-	)
-
-	for _, stmt := range node.Body {
+	for _, stmt := range body {
 		switch stmt := stmt.(type) {
 		case *ast.AttributeStmt:
-			return nil, diag.Diagnostic{
+			return nil, nil, diag.Diagnostic{
 				Severity: diag.SeverityLevelError,
 				StartPos: ast.StartPos(stmt.Name).Position(),
 				EndPos:   ast.EndPos(stmt.Name).Position(),
@@ -60,14 +140,14 @@ func ParseSource(name string, bb []byte) (*Source, error) {
 		case *ast.BlockStmt:
 			fullName := strings.Join(stmt.Name, ".")
 			switch fullName {
-			case "logging", "tracing", "argument", "export":
+			case "logging", "tracing", "argument", "export", "test":
 				configs = append(configs, stmt)
 			default:
 				components = append(components, stmt)
 			}
 
 		default:
-			return nil, diag.Diagnostic{
+			return nil, nil, diag.Diagnostic{
 				Severity: diag.SeverityLevelError,
 				StartPos: ast.StartPos(stmt).Position(),
 				EndPos:   ast.EndPos(stmt).Position(),
@@ -75,13 +155,7 @@ func ParseSource(name string, bb []byte) (*Source, error) {
 			}
 		}
 	}
-
-	return &Source{
-		components:   components,
-		configBlocks: configs,
-		sourceMap:    map[string][]byte{name: bb},
-		hash:         sha256.Sum256(bb),
-	}, nil
+	return components, configs, nil
 }
 
 type namedSource struct {
@@ -92,9 +166,17 @@ type namedSource struct {
 // ParseSources parses the map of sources and combines them into a single
 // Source. sources must not be modified after calling ParseSources.
 func ParseSources(sources map[string][]byte) (*Source, error) {
+	return ParseSourcesWithIncludes(sources, nil)
+}
+
+// ParseSourcesWithIncludes parses the map of sources like ParseSources, and
+// resolves include directives in each source using readFile. See
+// [ParseSourceWithIncludes] for how include directives are resolved.
+func ParseSourcesWithIncludes(sources map[string][]byte, readFile func(name string) ([]byte, error)) (*Source, error) {
 	var (
-		mergedSource = &Source{sourceMap: sources} // Combined source from all the input content.
-		hash         = sha256.New()                // Combined hash of all the sources.
+		mergedSource  = &Source{sourceMap: sources} // Combined source from all the input content.
+		hash          = sha256.New()                // Combined hash of all the sources.
+		copiedSources bool                          // Whether mergedSource.sourceMap is a copy of sources.
 	)
 
 	// Sorted slice so ParseSources always does the same thing.
@@ -113,19 +195,47 @@ func ParseSources(sources map[string][]byte) (*Source, error) {
 	for _, namedSource := range sortedSources {
 		hash.Write(namedSource.Content)
 
-		sourceFragment, err := ParseSource(namedSource.Name, namedSource.Content)
+		sourceFragment, err := ParseSourceWithIncludes(namedSource.Name, namedSource.Content, readFile)
 		if err != nil {
 			return nil, err
 		}
 
+		// Add any files included by the source, copying sources first so it
+		// isn't modified.
+		if len(sourceFragment.sourceMap) > 1 && !copiedSources {
+			mergedSource.sourceMap = make(map[string][]byte, len(sources))
+			for name, bb := range sources {
+				mergedSource.sourceMap[name] = bb
+			}
+			copiedSources = true
+		}
+		for _, name := range sortedIncludes(sourceFragment, namedSource.Name) {
+			hash.Write(sourceFragment.sourceMap[name])
+			mergedSource.sourceMap[name] = sourceFragment.sourceMap[name]
+		}
+
 		mergedSource.components = append(mergedSource.components, sourceFragment.components...)
 		mergedSource.configBlocks = append(mergedSource.configBlocks, sourceFragment.configBlocks...)
+		mergedSource.parseDuration += sourceFragment.parseDuration
 	}
 
 	mergedSource.hash = [32]byte(hash.Sum(nil))
 	return mergedSource, nil
 }
 
+// sortedIncludes returns the sorted names of the files included by the source
+// s, which was parsed from the file name.
+func sortedIncludes(s *Source, name string) []string {
+	names := make([]string, 0, len(s.sourceMap)-1)
+	for included := range s.sourceMap {
+		if included != name {
+			names = append(names, included)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
 // RawConfigs returns the raw source content used to create Source.
 // Do not modify the returned map.
 func (s *Source) RawConfigs() map[string][]byte {
@@ -135,6 +245,16 @@ func (s *Source) RawConfigs() map[string][]byte {
 	return s.sourceMap
 }
 
+// size returns the total size in bytes of the config files of s, including
+// included files.
+func (s *Source) size() int {
+	var size int
+	for _, bb := range s.RawConfigs() {
+		size += len(bb)
+	}
+	return size
+}
+
 // SHA256 returns the sha256 checksum of the source.
 // Do not modify the returned byte array.
 func (s *Source) SHA256() [sha256.Size]byte {
@@ -0,0 +1,258 @@
+package flow
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/packfile"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp/capability"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp/sideband"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// gitSourcePrefix is the prefix used by config paths which refer to a file
+// inside of a Git repository.
+const gitSourcePrefix = "git::"
+
+// gitSource describes a config file stored in a Git repository. gitSources
+// are written as:
+//
+//	git::<repository>//<path>?ref=<ref>
+//
+// such as git::https://github.com/org/repo.git//config/agent.river?ref=main.
+// If ref is not provided, the default branch of the repository is used.
+type gitSource struct {
+	Repository string
+	Path       string
+	Ref        string
+}
+
+// IsGitSource returns true if path refers to a config file inside of a Git
+// repository, which can be read with [Flow.ReadGitSource].
+func IsGitSource(path string) bool {
+	return strings.HasPrefix(path, gitSourcePrefix)
+}
+
+// parseGitSource parses a gitSource from the provided path.
+func parseGitSource(path string) (gitSource, error) {
+	if !IsGitSource(path) {
+		return gitSource{}, fmt.Errorf("git source %q must start with %q", path, gitSourcePrefix)
+	}
+
+	u, err := url.Parse(strings.TrimPrefix(path, gitSourcePrefix))
+	if err != nil {
+		return gitSource{}, fmt.Errorf("invalid git source %q: %w", path, err)
+	}
+
+	// The path to the file is separated from the path to the repository by a
+	// double slash. The scheme separator has already been consumed by
+	// url.Parse, so the first double slash in u.Path is the separator.
+	repoPath, filePath, found := strings.Cut(u.Path, "//")
+	if !found || filePath == "" {
+		return gitSource{}, fmt.Errorf("git source %q must include a path to a file after //", path)
+	}
+
+	ref := u.Query().Get("ref")
+	if ref == "" {
+		ref = plumbing.HEAD.String()
+	}
+
+	u.Path = repoPath
+	u.RawPath = ""
+	u.RawQuery = ""
+
+	return gitSource{
+		Repository: u.String(),
+		Path:       filePath,
+		Ref:        ref,
+	}, nil
+}
+
+// gitConfigFetcher retrieves config files from Git repositories. The most
+// recently fetched file is cached, and the repository is only fetched again
+// once the requested ref points to a different commit.
+type gitConfigFetcher struct {
+	auth   transport.AuthMethod
+	client *http.Client
+
+	mut        sync.Mutex
+	lastSource gitSource
+	lastHash   plumbing.Hash
+	content    []byte
+}
+
+// newGitConfigFetcher creates a new gitConfigFetcher. auth is used for all
+// requests to remote repositories and may be nil. Repositories served over
+// HTTP are read with client, or with the default client of go-git if client
+// is nil.
+func newGitConfigFetcher(auth transport.AuthMethod, client *http.Client) *gitConfigFetcher {
+	return &gitConfigFetcher{auth: auth, client: client}
+}
+
+// ReadGitSource returns the contents of the config file described by path,
+// which must be written as described by [IsGitSource], such as
+// git::https://github.com/org/repo.git//config/agent.river?ref=main. If ref
+// is not provided, the default branch of the repository is used.
+//
+// Requests to the repository are authenticated with [Options.GitAuth], and
+// repositories served over HTTP are read with [Options.HTTPClient]. The most
+// recently read file is cached, and the repository is only fetched again
+// once ref points to a different commit, so ReadGitSource can be called on
+// every reload. The returned contents can be parsed with [ParseSource].
+func (f *Flow) ReadGitSource(ctx context.Context, path string) ([]byte, error) {
+	src, err := parseGitSource(path)
+	if err != nil {
+		return nil, err
+	}
+	return f.git.Fetch(ctx, src)
+}
+
+// Fetch returns the contents of the file described by src.
+func (f *gitConfigFetcher) Fetch(ctx context.Context, src gitSource) ([]byte, error) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	ep, err := transport.NewEndpoint(src.Repository)
+	if err != nil {
+		return nil, fmt.Errorf("invalid repository %s: %w", src.Repository, err)
+	}
+	tr, err := f.transport(ep)
+	if err != nil {
+		return nil, err
+	}
+	sess, err := tr.NewUploadPackSession(ep, f.auth)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to %s: %w", src.Repository, err)
+	}
+	defer sess.Close()
+
+	advRefs, err := sess.AdvertisedReferencesContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing refs of %s: %w", src.Repository, err)
+	}
+	ref, err := resolveRef(advRefs, src)
+	if err != nil {
+		return nil, err
+	}
+	if f.content != nil && f.lastSource == src && f.lastHash == ref.Hash() {
+		return f.content, nil
+	}
+
+	bb, err := fetchFile(ctx, sess, advRefs, ref.Hash(), src)
+	if err != nil {
+		return nil, err
+	}
+
+	f.lastSource = src
+	f.lastHash = ref.Hash()
+	f.content = bb
+	return bb, nil
+}
+
+// transport returns the transport used to connect to ep. go-git only allows
+// changing the HTTP client of every repository by installing a protocol
+// globally, so HTTP repositories are read through a transport of the fetcher
+// instead.
+func (f *gitConfigFetcher) transport(ep *transport.Endpoint) (transport.Transport, error) {
+	if f.client != nil && (ep.Protocol == "http" || ep.Protocol == "https") {
+		return githttp.NewClient(f.client), nil
+	}
+	return client.NewClient(ep)
+}
+
+// resolveRef finds the advertised reference for src.Ref, searching for HEAD,
+// branches, and then tags. The returned reference is never symbolic.
+func resolveRef(advRefs *packp.AdvRefs, src gitSource) (*plumbing.Reference, error) {
+	byName, err := advRefs.AllReferences()
+	if err != nil {
+		return nil, fmt.Errorf("listing refs of %s: %w", src.Repository, err)
+	}
+
+	candidates := []plumbing.ReferenceName{
+		plumbing.ReferenceName(src.Ref),
+		plumbing.NewBranchReferenceName(src.Ref),
+		plumbing.NewTagReferenceName(src.Ref),
+	}
+	for _, name := range candidates {
+		ref, ok := byName[name]
+		if !ok {
+			continue
+		}
+		if ref.Type() == plumbing.SymbolicReference {
+			target, ok := byName[ref.Target()]
+			if !ok {
+				continue
+			}
+			return plumbing.NewHashReference(ref.Name(), target.Hash()), nil
+		}
+		return ref, nil
+	}
+
+	return nil, fmt.Errorf("ref %q not found in %s", src.Ref, src.Repository)
+}
+
+// fetchFile performs a shallow fetch of the commit or annotated tag hash into
+// memory, and returns the contents of src.Path in its tree; only a single
+// file from the tip of the ref is needed.
+func fetchFile(ctx context.Context, sess transport.UploadPackSession, advRefs *packp.AdvRefs, hash plumbing.Hash, src gitSource) ([]byte, error) {
+	req := packp.NewUploadPackRequestFromCapabilities(advRefs.Capabilities)
+	req.Wants = []plumbing.Hash{hash}
+	req.Depth = packp.DepthCommits(1)
+	if err := req.Capabilities.Set(capability.Shallow); err != nil {
+		return nil, err
+	}
+	if advRefs.Capabilities.Supports(capability.NoProgress) {
+		if err := req.Capabilities.Set(capability.NoProgress); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := sess.UploadPack(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", src.Repository, err)
+	}
+	defer resp.Close()
+
+	var pack io.Reader = resp
+	switch {
+	case req.Capabilities.Supports(capability.Sideband64k):
+		pack = sideband.NewDemuxer(sideband.Sideband64k, resp)
+	case req.Capabilities.Supports(capability.Sideband):
+		pack = sideband.NewDemuxer(sideband.Sideband, resp)
+	}
+	storage := memory.NewStorage()
+	if err := packfile.UpdateObjectStorage(storage, pack); err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", src.Repository, err)
+	}
+
+	var commit *object.Commit
+	if tag, err := object.GetTag(storage, hash); err == nil {
+		commit, err = tag.Commit()
+		if err != nil {
+			return nil, fmt.Errorf("reading tag %s of %s: %w", src.Ref, src.Repository, err)
+		}
+	} else if commit, err = object.GetCommit(storage, hash); err != nil {
+		return nil, fmt.Errorf("reading commit %s of %s: %w", hash, src.Repository, err)
+	}
+
+	file, err := commit.File(src.Path)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s from %s: %w", src.Path, src.Repository, err)
+	}
+	contents, err := file.Contents()
+	if err != nil {
+		return nil, fmt.Errorf("reading %s from %s: %w", src.Path, src.Repository, err)
+	}
+	return []byte(contents), nil
+}
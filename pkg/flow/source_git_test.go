@@ -0,0 +1,197 @@
+package flow
+
+import (
+	"context"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGitSource(t *testing.T) {
+	tt := []struct {
+		input     string
+		expect    gitSource
+		expectErr bool
+	}{
+		{
+			input: "git::https://github.com/org/repo.git//config/agent.river?ref=main",
+			expect: gitSource{
+				Repository: "https://github.com/org/repo.git",
+				Path:       "config/agent.river",
+				Ref:        "main",
+			},
+		},
+		{
+			input: "git::ssh://git@github.com/org/repo.git//agent.river",
+			expect: gitSource{
+				Repository: "ssh://git@github.com/org/repo.git",
+				Path:       "agent.river",
+				Ref:        "HEAD",
+			},
+		},
+		{
+			input:     "git::https://github.com/org/repo.git",
+			expectErr: true,
+		},
+		{
+			input:     "https://github.com/org/repo.git//agent.river",
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.input, func(t *testing.T) {
+			actual, err := parseGitSource(tc.input)
+			if tc.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expect, actual)
+		})
+	}
+}
+
+func TestGitConfigFetcher(t *testing.T) {
+	repoDir := t.TempDir()
+	commit := initGitRepo(t, repoDir)
+
+	var (
+		ctx     = context.Background()
+		fetcher = newGitConfigFetcher(nil, nil)
+		src     = gitSource{Repository: "file://" + repoDir, Path: "agent.river", Ref: "HEAD"}
+	)
+
+	commit(`logging {}`)
+	bb, err := fetcher.Fetch(ctx, src)
+	require.NoError(t, err)
+	require.Equal(t, `logging {}`, string(bb))
+
+	prevHash := fetcher.lastHash
+
+	// Fetching again without a new commit should reuse the cached content.
+	bb, err = fetcher.Fetch(ctx, src)
+	require.NoError(t, err)
+	require.Equal(t, `logging {}`, string(bb))
+	require.Equal(t, prevHash, fetcher.lastHash)
+
+	commit(`tracing {}`)
+	bb, err = fetcher.Fetch(ctx, src)
+	require.NoError(t, err)
+	require.Equal(t, `tracing {}`, string(bb))
+	require.NotEqual(t, prevHash, fetcher.lastHash)
+}
+
+func TestFlow_ReadGitSource(t *testing.T) {
+	repoDir := t.TempDir()
+	initGitRepo(t, repoDir)(`logging {}`)
+
+	opts := testOptions(t)
+	opts.GitAuth = &githttp.BasicAuth{Username: "agent", Password: "secret"}
+	f := New(opts)
+	defer cleanUpController(f)
+	require.Same(t, opts.GitAuth, f.git.auth)
+
+	bb, err := f.ReadGitSource(context.Background(), "git::file://"+repoDir+"//agent.river")
+	require.NoError(t, err)
+	require.Equal(t, `logging {}`, string(bb))
+
+	_, err = f.ReadGitSource(context.Background(), "file://"+repoDir+"//agent.river")
+	require.ErrorContains(t, err, `must start with "git::"`)
+}
+
+func TestFlow_ReadGitSource_HTTPClient(t *testing.T) {
+	execPath, err := exec.Command("git", "--exec-path").Output()
+	if err != nil {
+		t.Skip("git is not installed")
+	}
+
+	root := t.TempDir()
+	commit := initGitRepo(t, filepath.Join(root, "repo"))
+	commit(`logging {}`)
+
+	// Serve the repository with the smart HTTP protocol of git.
+	srv := httptest.NewServer(&cgi.Handler{
+		Path: filepath.Join(strings.TrimSpace(string(execPath)), "git-http-backend"),
+		Env:  []string{"GIT_PROJECT_ROOT=" + root, "GIT_HTTP_EXPORT_ALL=1"},
+	})
+	defer srv.Close()
+
+	transport := &countingTransport{next: http.DefaultTransport}
+	opts := testOptions(t)
+	opts.HTTPClient = &http.Client{Transport: transport}
+	f := New(opts)
+	defer cleanUpController(f)
+
+	path := "git::" + srv.URL + "/repo/.git//agent.river"
+	bb, err := f.ReadGitSource(context.Background(), path)
+	require.NoError(t, err)
+	require.Equal(t, `logging {}`, string(bb))
+	require.Positive(t, transport.Requests())
+
+	// Tags are peeled to the commit they point to.
+	tagged := commit(`tracing {}`)
+	repo, err := git.PlainOpen(filepath.Join(root, "repo"))
+	require.NoError(t, err)
+	_, err = repo.CreateTag("v1", tagged, &git.CreateTagOptions{
+		Tagger:  &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		Message: "v1",
+	})
+	require.NoError(t, err)
+	commit(`logging {}`)
+
+	bb, err = f.ReadGitSource(context.Background(), path+"?ref=v1")
+	require.NoError(t, err)
+	require.Equal(t, `tracing {}`, string(bb))
+}
+
+// initGitRepo creates a repository in dir. The returned function commits a
+// new version of agent.river with the given content, and returns the hash of
+// the commit.
+func initGitRepo(t *testing.T, dir string) func(content string) plumbing.Hash {
+	t.Helper()
+
+	repo, err := git.PlainInit(dir, false)
+	require.NoError(t, err)
+
+	return func(content string) plumbing.Hash {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "agent.river"), []byte(content), 0644))
+
+		wt, err := repo.Worktree()
+		require.NoError(t, err)
+		_, err = wt.Add("agent.river")
+		require.NoError(t, err)
+		hash, err := wt.Commit("update config", &git.CommitOptions{
+			Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		})
+		require.NoError(t, err)
+		return hash
+	}
+}
+
+// countingTransport is an http.RoundTripper which counts the requests sent
+// through it.
+type countingTransport struct {
+	next     http.RoundTripper
+	requests atomic.Int64
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests.Add(1)
+	return t.next.RoundTrip(req)
+}
+
+func (t *countingTransport) Requests() int64 { return t.requests.Load() }
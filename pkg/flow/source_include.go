@@ -0,0 +1,241 @@
+package flow
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/agent/pkg/config/encoder"
+	"github.com/grafana/river/ast"
+	"github.com/grafana/river/diag"
+	"github.com/grafana/river/parser"
+	"github.com/grafana/river/scanner"
+	"github.com/grafana/river/token"
+)
+
+// includeKeyword starts an include directive.
+const includeKeyword = "include"
+
+// includeDirective is an include directive found in a River file, written as
+// `include "path"` on its own line at the top level of the file.
+type includeDirective struct {
+	path       string    // Unquoted path to include.
+	start, end int       // Byte offsets of the directive in the file.
+	pos        token.Pos // Position of the directive.
+}
+
+// findIncludes returns the include directives of the River file bb. Errors
+// scanning bb are ignored, since they're reported when bb is parsed.
+func findIncludes(name string, bb []byte) ([]includeDirective, error) {
+	type scanned struct {
+		pos token.Pos
+		tok token.Token
+		lit string
+	}
+
+	var tokens []scanned
+	s := scanner.New(token.NewFile(name), bb, func(token.Pos, string) {}, 0)
+	for {
+		pos, tok, lit := s.Scan()
+		tokens = append(tokens, scanned{pos, tok, lit})
+		if tok == token.EOF {
+			break
+		}
+	}
+
+	var (
+		directives []includeDirective
+		depth      int
+		stmtStart  = true
+	)
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+
+		// Directives are an identifier followed by a string and the end of the
+		// line, at the start of a top-level statement. Anything else, such as a
+		// block named include, is left to the parser.
+		if stmtStart && depth == 0 && t.tok == token.IDENT && t.lit == includeKeyword && i+2 < len(tokens) {
+			str, end := tokens[i+1], tokens[i+2]
+			if str.tok == token.STRING && (end.tok == token.TERMINATOR || end.tok == token.EOF) {
+				path, err := strconv.Unquote(str.lit)
+				if err != nil {
+					return nil, diag.Diagnostic{
+						Severity: diag.SeverityLevelError,
+						StartPos: str.pos.Position(),
+						EndPos:   str.pos.Add(len(str.lit) - 1).Position(),
+						Message:  fmt.Sprintf("invalid include path %s", str.lit),
+					}
+				}
+
+				directives = append(directives, includeDirective{
+					path:  path,
+					start: t.pos.Offset(),
+					end:   str.pos.Offset() + len(str.lit),
+					pos:   t.pos,
+				})
+				i++ // Skip over the string; the terminator is handled below.
+				continue
+			}
+		}
+
+		switch t.tok {
+		case token.LCURLY, token.LPAREN, token.LBRACK:
+			depth++
+		case token.RCURLY, token.RPAREN, token.RBRACK:
+			depth--
+		}
+		stmtStart = t.tok == token.TERMINATOR
+	}
+	return directives, nil
+}
+
+// blankIncludes returns a copy of bb where every directive is replaced with
+// spaces, so the rest of bb can be parsed with its original positions.
+func blankIncludes(bb []byte, directives []includeDirective) []byte {
+	blanked := make([]byte, len(bb))
+	copy(blanked, bb)
+	for _, d := range directives {
+		for i := d.start; i < d.end; i++ {
+			blanked[i] = ' '
+		}
+	}
+	return blanked
+}
+
+// commentIncludes returns a copy of bb where every directive is turned into a
+// comment, so bb can be parsed and printed again without losing directives.
+// Unlike blankIncludes, the offsets of the rest of bb aren't kept.
+func commentIncludes(bb []byte, directives []includeDirective) []byte {
+	var (
+		commented = make([]byte, 0, len(bb)+3*len(directives))
+		last      int
+	)
+	for _, d := range directives {
+		commented = append(commented, bb[last:d.start]...)
+		commented = append(commented, "// "...)
+		last = d.start
+	}
+	return append(commented, bb[last:]...)
+}
+
+// includeComments returns comments with a comment group added for every
+// directive found in bb, holding the text of the directive, so the
+// directives are kept when the file is printed. The returned groups are
+// sorted by position like the groups returned by the parser.
+func includeComments(comments []ast.CommentGroup, bb []byte, directives []includeDirective) []ast.CommentGroup {
+	merged := make([]ast.CommentGroup, 0, len(comments)+len(directives))
+	for _, d := range directives {
+		for len(comments) > 0 && comments[0][0].StartPos.Offset() < d.start {
+			merged = append(merged, comments[0])
+			comments = comments[1:]
+		}
+		merged = append(merged, ast.CommentGroup{{StartPos: d.pos, Text: string(bb[d.start:d.end])}})
+	}
+	return append(merged, comments...)
+}
+
+// includeParser parses River files and the files they include.
+type includeParser struct {
+	readFile func(name string) ([]byte, error)
+
+	// included holds every file included while parsing, after any files they
+	// include in turn.
+	included []namedSource
+}
+
+// parseFile parses the River file bb named name, and returns its statements
+// with the statements of included files spliced in place of their include
+// directives. stack holds the names of the files which included name.
+//
+// Since each included file is parsed on its own, positions of statements and
+// diagnostics always refer to the file they were written in.
+func (p *includeParser) parseFile(name string, bb []byte, stack []string) (ast.Body, []byte, error) {
+	bb, err := encoder.EnsureUTF8(bb, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	directives, err := findIncludes(name, bb)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parseBytes := bb
+	if len(directives) > 0 {
+		parseBytes = blankIncludes(bb, directives)
+	}
+	node, err := parser.ParseFile(name, parseBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(directives) == 0 {
+		return node.Body, bb, nil
+	}
+
+	stack = append(stack, name)
+
+	var (
+		body ast.Body
+		next int // Next statement of node to add to body.
+	)
+	for _, d := range directives {
+		for next < len(node.Body) && ast.StartPos(node.Body[next]).Offset() < d.start {
+			body = append(body, node.Body[next])
+			next++
+		}
+
+		included, err := p.include(d, stack)
+		if err != nil {
+			return nil, nil, err
+		}
+		body = append(body, included...)
+	}
+	body = append(body, node.Body[next:]...)
+
+	return body, bb, nil
+}
+
+// include reads and parses the file included by d, resolving its path
+// relative to the including file at the top of stack.
+func (p *includeParser) include(d includeDirective, stack []string) (ast.Body, error) {
+	including := stack[len(stack)-1]
+
+	errorf := func(format string, args ...any) error {
+		return diag.Diagnostic{
+			Severity: diag.SeverityLevelError,
+			StartPos: d.pos.Position(),
+			EndPos:   d.pos.Add(d.end - d.start - 1).Position(),
+			Message:  fmt.Sprintf(format, args...),
+		}
+	}
+
+	if p.readFile == nil {
+		return nil, errorf("include directives are only supported in config files read from disk")
+	}
+
+	path := d.path
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(including), path)
+	}
+	path = filepath.Clean(path)
+
+	for i, name := range stack {
+		if filepath.Clean(name) == path {
+			cycle := append(append([]string{}, stack[i:]...), path)
+			return nil, errorf("include cycle detected: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	bb, err := p.readFile(path)
+	if err != nil {
+		return nil, errorf("failed to include %q: %s", d.path, err)
+	}
+
+	body, bb, err := p.parseFile(path, bb, stack)
+	if err != nil {
+		return nil, err
+	}
+	p.included = append(p.included, namedSource{Name: path, Content: bb})
+	return body, nil
+}
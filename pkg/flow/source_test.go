@@ -1,12 +1,16 @@
 package flow
 
 import (
+	"bytes"
+	"io/fs"
 	"strings"
 	"testing"
 
 	"github.com/grafana/river/ast"
 	"github.com/grafana/river/diag"
+	"github.com/grafana/river/printer"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/maps"
 
 	_ "github.com/grafana/agent/pkg/flow/internal/testcomponents" // Include test components
 )
@@ -124,6 +128,91 @@ func TestParseSources_UniqueComponent(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestParseSourceWithIncludes(t *testing.T) {
+	files := map[string][]byte{
+		"shared/ticker.river": []byte(`
+			include "passthrough.river"
+
+			testcomponents.tick "ticker" {
+				frequency = "1s"
+			}
+		`),
+		"shared/passthrough.river": []byte(`testcomponents.passthrough "static" {
+	input = "hello, world!"
+	unknown = true
+}`),
+		"cycle/a.river": []byte(`include "b.river"`),
+		"cycle/b.river": []byte(`include "./a.river"`),
+	}
+	readFile := func(name string) ([]byte, error) {
+		bb, ok := files[name]
+		if !ok {
+			return nil, fs.ErrNotExist
+		}
+		return bb, nil
+	}
+
+	t.Run("Spliced in place", func(t *testing.T) {
+		f, err := ParseSourceWithIncludes("config.river", []byte(`
+			logging {}
+
+			include "shared/ticker.river"
+
+			// A block named include isn't a directive.
+			testcomponents.passthrough "include" {
+				input = "include"
+			}
+		`), readFile)
+		require.NoError(t, err)
+
+		ids := make([]string, len(f.components))
+		for i, c := range f.components {
+			ids[i] = getBlockID(c)
+		}
+		require.Equal(t, []string{
+			"testcomponents.passthrough.static",
+			"testcomponents.tick.ticker",
+			"testcomponents.passthrough.include",
+		}, ids)
+
+		// Included blocks keep the positions of the file they were written in.
+		pos := ast.StartPos(f.components[0]).Position()
+		require.Equal(t, "shared/passthrough.river", pos.Filename)
+		require.Equal(t, 1, pos.Line)
+
+		require.ElementsMatch(t, []string{"config.river", "shared/ticker.river", "shared/passthrough.river"}, maps.Keys(f.RawConfigs()))
+	})
+
+	t.Run("Diagnostics refer to included files", func(t *testing.T) {
+		f, err := ParseSourceWithIncludes("config.river", []byte(`include "shared/passthrough.river"`), readFile)
+		require.NoError(t, err)
+
+		ctrl := New(testOptions(t))
+		defer cleanUpController(ctrl)
+		err = ctrl.LoadSource(f, nil)
+		diags, ok := err.(diag.Diagnostics)
+		require.True(t, ok)
+		require.Len(t, diags, 1)
+		require.Equal(t, "shared/passthrough.river", diags[0].StartPos.Filename)
+		require.Equal(t, 3, diags[0].StartPos.Line)
+	})
+
+	t.Run("Cycles are reported", func(t *testing.T) {
+		_, err := ParseSourceWithIncludes("cycle/a.river", files["cycle/a.river"], readFile)
+		require.EqualError(t, err, "cycle/b.river:1:1: include cycle detected: cycle/a.river -> cycle/b.river -> cycle/a.river")
+	})
+
+	t.Run("Missing files are reported", func(t *testing.T) {
+		_, err := ParseSourceWithIncludes("config.river", []byte(`include "missing.river"`), readFile)
+		require.EqualError(t, err, `config.river:1:1: failed to include "missing.river": file does not exist`)
+	})
+
+	t.Run("Unsupported without readFile", func(t *testing.T) {
+		_, err := ParseSource("config.river", []byte(`include "shared/ticker.river"`))
+		require.EqualError(t, err, "config.river:1:1: include directives are only supported in config files read from disk")
+	})
+}
+
 func getBlockID(b *ast.BlockStmt) string {
 	var parts []string
 	parts = append(parts, b.Name...)
@@ -132,3 +221,59 @@ func getBlockID(b *ast.BlockStmt) string {
 	}
 	return strings.Join(parts, ".")
 }
+
+func TestParseConfig(t *testing.T) {
+	t.Run("Matches ParseSource", func(t *testing.T) {
+		content := []byte(`
+			logging {}
+
+			include "shared.river"
+
+			// The ticker.
+			testcomponents.tick "ticker" {
+				frequency = "1s"
+			}
+		`)
+
+		file, err := ParseConfig(t.Name(), content)
+		require.NoError(t, err)
+		require.Len(t, file.Body, 2)
+
+		// Positions refer to the original content, including the directive.
+		block := file.Body[1].(*ast.BlockStmt)
+		require.Equal(t, "testcomponents.tick", block.GetBlockName())
+		require.Equal(t, 7, ast.StartPos(block).Position().Line)
+
+		// The directive is kept as a comment before the comments which follow
+		// it.
+		require.Len(t, file.Comments, 2)
+		require.Equal(t, `include "shared.river"`, file.Comments[0][0].Text)
+		require.Equal(t, 4, file.Comments[0][0].StartPos.Position().Line)
+		require.Equal(t, "// The ticker.", file.Comments[1][0].Text)
+	})
+
+	t.Run("Include directives are printed", func(t *testing.T) {
+		content := []byte(`logging {
+	level = "info"
+}
+
+include "shared.river"
+
+// The ticker.
+testcomponents.tick "ticker" {
+	frequency = "1s"
+}`)
+
+		file, err := ParseConfig(t.Name(), content)
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		require.NoError(t, printer.Fprint(&buf, file))
+		require.Equal(t, string(content), buf.String())
+	})
+
+	t.Run("Top-level attributes are rejected", func(t *testing.T) {
+		_, err := ParseConfig("config.river", []byte(`value = 1`))
+		require.EqualError(t, err, "config.river:1:1: unrecognized attribute value")
+	})
+}
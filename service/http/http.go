@@ -41,14 +41,32 @@ type Options struct {
 	Tracer   trace.TracerProvider // Where to send traces.
 	Gatherer prometheus.Gatherer  // Where to collect metrics from.
 
-	ReadyFunc  func() bool
-	ReloadFunc func() (*flow.Source, error)
+	ReadyFunc func() bool
+
+	// ReloadFunc reloads the config, and returns the loaded source and whether
+	// the config changed since the last successful load. req describes the
+	// caller of the /-/reload endpoint.
+	ReloadFunc func(req ReloadRequest) (source *flow.Source, changed bool, err error)
 
 	HTTPListenAddr   string // Address to listen for HTTP traffic on.
 	MemoryListenAddr string // Address to accept in-memory traffic on.
 	EnablePProf      bool   // Whether pprof endpoints should be exposed.
 }
 
+// ReloadRequest describes a request to the /-/reload endpoint, so reloads
+// can be audited.
+type ReloadRequest struct {
+	RemoteAddr string // Address of the client which requested the reload.
+	Reason     string // Value of the X-Reload-Reason header, if set.
+}
+
+// reloadReasonHeader is the header clients can set to explain why they
+// requested a reload.
+const reloadReasonHeader = "X-Reload-Reason"
+
+// maxReloadReasonLength is the longest reason kept from reloadReasonHeader.
+const maxReloadReasonLength = 256
+
 // Arguments holds runtime settings for the HTTP service.
 type Arguments struct {
 	TLS *TLSArguments `river:"tls,block,optional"`
@@ -184,15 +202,28 @@ func (s *Service) Run(ctx context.Context, host service.Host) error {
 	}
 
 	if s.opts.ReloadFunc != nil {
-		r.HandleFunc("/-/reload", func(w http.ResponseWriter, _ *http.Request) {
+		r.HandleFunc("/-/reload", func(w http.ResponseWriter, r *http.Request) {
 			level.Info(s.log).Log("msg", "reload requested via /-/reload endpoint")
-			defer level.Info(s.log).Log("msg", "config reloaded")
 
-			_, err := s.opts.ReloadFunc()
+			req := ReloadRequest{
+				RemoteAddr: r.RemoteAddr,
+				Reason:     r.Header.Get(reloadReasonHeader),
+			}
+			if len(req.Reason) > maxReloadReasonLength {
+				req.Reason = req.Reason[:maxReloadReasonLength]
+			}
+
+			_, changed, err := s.opts.ReloadFunc(req)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusBadRequest)
 				return
 			}
+			if !changed {
+				level.Info(s.log).Log("msg", "config unchanged")
+				fmt.Fprintln(w, "config unchanged")
+				return
+			}
+			level.Info(s.log).Log("msg", "config reloaded")
 			fmt.Fprintln(w, "config reloaded")
 		}).Methods(http.MethodGet, http.MethodPost)
 	}
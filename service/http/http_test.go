@@ -45,6 +45,34 @@ func TestHTTP(t *testing.T) {
 	})
 }
 
+func TestReload(t *testing.T) {
+	ctx := componenttest.TestContext(t)
+
+	env, err := newTestEnvironment(t)
+	require.NoError(t, err)
+	require.NoError(t, env.ApplyConfig(`/* empty */`))
+
+	go func() {
+		require.NoError(t, env.Run(ctx))
+	}()
+
+	util.Eventually(t, func(t require.TestingT) {
+		req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("http://%s/-/reload", env.ListenAddr()), nil)
+		require.NoError(t, err)
+		req.Header.Set("X-Reload-Reason", "rotated credentials")
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	reload := <-env.reloads
+	require.Equal(t, "rotated credentials", reload.Reason)
+	require.Contains(t, reload.RemoteAddr, "127.0.0.1:")
+}
+
 func TestTLS(t *testing.T) {
 	ctx := componenttest.TestContext(t)
 
@@ -156,8 +184,9 @@ func Test_Toggle_TLS(t *testing.T) {
 }
 
 type testEnvironment struct {
-	svc  *Service
-	addr string
+	svc     *Service
+	addr    string
+	reloads chan ReloadRequest // Requests passed to ReloadFunc.
 }
 
 func newTestEnvironment(t *testing.T) (*testEnvironment, error) {
@@ -166,23 +195,26 @@ func newTestEnvironment(t *testing.T) (*testEnvironment, error) {
 		return nil, err
 	}
 
-	svc := New(Options{
+	env := &testEnvironment{
+		addr:    fmt.Sprintf("127.0.0.1:%d", port),
+		reloads: make(chan ReloadRequest, 1),
+	}
+	env.svc = New(Options{
 		Logger:   util.TestLogger(t),
 		Tracer:   noop.NewTracerProvider(),
 		Gatherer: prometheus.NewRegistry(),
 
-		ReadyFunc:  func() bool { return true },
-		ReloadFunc: func() (*flow.Source, error) { return nil, nil },
+		ReadyFunc: func() bool { return true },
+		ReloadFunc: func(req ReloadRequest) (*flow.Source, bool, error) {
+			env.reloads <- req
+			return nil, true, nil
+		},
 
-		HTTPListenAddr:   fmt.Sprintf("127.0.0.1:%d", port),
+		HTTPListenAddr:   env.addr,
 		MemoryListenAddr: "agent.internal:12345",
 		EnablePProf:      true,
 	})
-
-	return &testEnvironment{
-		svc:  svc,
-		addr: fmt.Sprintf("127.0.0.1:%d", port),
-	}, nil
+	return env, nil
 }
 
 func (env *testEnvironment) ApplyConfig(config string) error {
@@ -47,14 +47,30 @@ func (f *FlowAPI) listComponentsHandler() http.HandlerFunc {
 			moduleID = vars["moduleID"]
 		}
 
+		// Resource usage is opt-in for the list of components, since collecting
+		// it requires taking a goroutine profile. Errors are opt-in to keep the
+		// list small.
 		components, err := f.flow.ListComponents(moduleID, component.InfoOptions{
-			GetHealth: true,
+			GetHealth:    true,
+			GetResources: r.URL.Query().Has("resources"),
+			GetErrors:    r.URL.Query().Has("errors"),
 		})
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
+		// Only components with every requested tag are listed.
+		if tags := r.URL.Query()["tag"]; len(tags) > 0 {
+			filtered := make([]*component.Info, 0, len(components))
+			for _, c := range components {
+				if c.HasTags(tags) {
+					filtered = append(filtered, c)
+				}
+			}
+			components = filtered
+		}
+
 		bb, err := json.Marshal(components)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -74,6 +90,8 @@ func (f *FlowAPI) getComponentHandler() http.HandlerFunc {
 			GetArguments: true,
 			GetExports:   true,
 			GetDebugInfo: true,
+			GetResources: true,
+			GetErrors:    true,
 		})
 		if err != nil {
 			http.NotFound(w, r)